@@ -8,6 +8,13 @@ set -euo pipefail
 # SET UP VARS HERE
 source ../.env
 
+# Pass --force to overwrite proof artifacts left in ../out by a previous run.
+# Only do this when that proof was never submitted on-chain.
+FORCE=False
+if [[ "${1:-}" == "--force" ]]; then
+  FORCE=True
+fi
+
 TOTAL=130  # a guess
 CMD_PID=
 
@@ -179,7 +186,7 @@ run_with_progress \
 "
 from src.commands import create_snark_tx, create_reencryption_tx
 
-a1, r1, hk = create_snark_tx('${bob_public_value}')
+a1, r1, hk = create_snark_tx('${bob_public_value}', force=${FORCE})
 
 create_reencryption_tx('${alice_wallet_path}/payment.skey', '${bob_public_value}', '${encryption_token}', a1, r1, hk)
 "
@@ -0,0 +1,155 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// aiken.go renders a verifying key in the shapes the on-chain side consumes,
+// so nobody has to hand-translate vk.json. The Aiken validator reads the VK as
+// SnarkVerificationKey (app/contracts/lib/types/groth.ak): constructor 0 with
+// fields nPublic, vkAlpha, vkBeta, vkGamma, vkDelta, vkIC and commitmentKeys,
+// each CommitmentKey being constructor 0 with fields g and gSigmaNeg. The
+// "aiken" format is that value as an Aiken literal, ready to paste into a test
+// or a constant; the "datum" format is the same value as a cardano-cli JSON
+// datum, byte-for-byte what app/src/vk_convert.py writes.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// VKFormat selects how FormatVK renders a verifying key.
+type VKFormat string
+
+const (
+	VKFormatJSON  VKFormat = "json"  // vk.json, as written by setup
+	VKFormatAiken VKFormat = "aiken" // SnarkVerificationKey literal for Aiken source
+	VKFormatDatum VKFormat = "datum" // SnarkVerificationKey as a cardano-cli JSON datum
+)
+
+// ParseVKFormat parses the export-vk -format flag: "json", "aiken" or "datum".
+func ParseVKFormat(s string) (VKFormat, error) {
+	switch f := VKFormat(s); f {
+	case VKFormatJSON, VKFormatAiken, VKFormatDatum:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown vk format %q (want json, aiken or datum)", s)
+}
+
+// LoadVK reads a verifying key from path, either gnark's vk.bin or a vk.json
+// (see LoadVerifyingKeyFile), and returns it as VKJSON. A vk.json is decoded
+// point by point and re-exported, so its hex comes out canonical and a bad
+// point is reported here rather than on-chain.
+func LoadVK(path string) (VKJSON, error) {
+	return LoadVKWithOptions(path, LoadVKOptions{})
+}
+
+// LoadVKOptions tunes LoadVKWithOptions. The zero value is LoadVK.
+type LoadVKOptions struct {
+	// AcceptUncompressedVK also reads a key in decimal affine coordinates,
+	// as snarkjs and the Solidity verifier generators write it (see
+	// vkdecimal.go), so it can be re-exported in our forms.
+	AcceptUncompressedVK bool
+}
+
+// LoadVKWithOptions is LoadVK with explicit LoadVKOptions.
+func LoadVKWithOptions(path string, opts LoadVKOptions) (VKJSON, error) {
+	vk, err := loadVerifyingKeyFile(path, false, opts.AcceptUncompressedVK)
+	if err != nil {
+		return VKJSON{}, err
+	}
+	vkj, err := exportVKOnlyJSON(vk)
+	if err != nil {
+		return VKJSON{}, err
+	}
+	// Keep the circuit hash a vk.json records; vk.bin carries none.
+	var stamp struct {
+		CircuitHash string `json:"circuitHash"`
+	}
+	if readJSONFile(path, &stamp) == nil {
+		vkj.CircuitHash = stamp.CircuitHash
+	}
+	return vkj, nil
+}
+
+// FormatVK renders vkj in format. Every format ends with a newline.
+func FormatVK(vkj VKJSON, format VKFormat) ([]byte, error) {
+	switch format {
+	case VKFormatJSON:
+		out, err := json.MarshalIndent(vkj, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	case VKFormatAiken:
+		return []byte(vkAikenLiteral(vkj)), nil
+	case VKFormatDatum:
+		out, err := json.MarshalIndent(vkDatum(vkj), "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	}
+	return nil, fmt.Errorf("unknown vk format %q (want json, aiken or datum)", format)
+}
+
+// vkAikenLiteral writes vkj as a SnarkVerificationKey expression, laid out
+// the way aiken fmt lays out the literals in app/contracts/lib/tests.
+func vkAikenLiteral(vkj VKJSON) string {
+	var b strings.Builder
+	b.WriteString("SnarkVerificationKey {\n")
+	fmt.Fprintf(&b, "  nPublic: %d,\n", vkj.NPublic)
+	fmt.Fprintf(&b, "  vkAlpha: #%q,\n", vkj.VkAlpha)
+	fmt.Fprintf(&b, "  vkBeta: #%q,\n", vkj.VkBeta)
+	fmt.Fprintf(&b, "  vkGamma: #%q,\n", vkj.VkGamma)
+	fmt.Fprintf(&b, "  vkDelta: #%q,\n", vkj.VkDelta)
+	b.WriteString("  vkIC: [\n")
+	for _, ic := range vkj.VkIC {
+		fmt.Fprintf(&b, "    #%q,\n", ic)
+	}
+	b.WriteString("  ],\n")
+	if len(vkj.CommitmentKeys) == 0 {
+		b.WriteString("  commitmentKeys: [],\n")
+	} else {
+		b.WriteString("  commitmentKeys: [\n")
+		for _, ck := range vkj.CommitmentKeys {
+			b.WriteString("    CommitmentKey {\n")
+			fmt.Fprintf(&b, "      g: #%q,\n", ck.G)
+			fmt.Fprintf(&b, "      gSigmaNeg: #%q,\n", ck.GSigmaNeg)
+			b.WriteString("    },\n")
+		}
+		b.WriteString("  ],\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// vkDatum builds vkj as a datum in the cardano-cli detailed JSON schema.
+// Maps marshal with sorted keys, which puts "constructor" before "fields" as
+// vk_convert.py does.
+func vkDatum(vkj VKJSON) map[string]any {
+	bs := func(h string) map[string]any { return map[string]any{"bytes": h} }
+
+	ic := make([]any, len(vkj.VkIC))
+	for i, h := range vkj.VkIC {
+		ic[i] = bs(h)
+	}
+	cks := make([]any, len(vkj.CommitmentKeys))
+	for i, ck := range vkj.CommitmentKeys {
+		cks[i] = map[string]any{
+			"constructor": 0,
+			"fields":      []any{bs(ck.G), bs(ck.GSigmaNeg)},
+		}
+	}
+	return map[string]any{
+		"constructor": 0,
+		"fields": []any{
+			map[string]any{"int": vkj.NPublic},
+			bs(vkj.VkAlpha),
+			bs(vkj.VkBeta),
+			bs(vkj.VkGamma),
+			bs(vkj.VkDelta),
+			map[string]any{"list": ic},
+			map[string]any{"list": cks},
+		},
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// atomicwrite.go makes setup and ceremony files appear whole or not at all.
+// Every such file is written to <name>.tmp and renamed into place once it is
+// complete, so a crash or Ctrl-C mid-write can never leave a truncated
+// pk.bin or phase2_NNNN.bin that a later load or contribution would trip
+// over. The temp files in flight are tracked, so that runInterruptible can
+// delete them when its context is cancelled (the CLI ties that context to
+// SIGINT/SIGTERM) and refuse any write that starts or finishes afterwards.
+// The files a step has already renamed into place are tracked too, and
+// rolled back on interruption: a setup stopped after ccs.bin but before
+// pk.bin leaves the directory as it was, not a half-finished set. The gnark
+// setup and MPC steps themselves cannot be cancelled; an interrupted step is
+// abandoned and only its files are withheld.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ErrInterrupted is returned by runInterruptible, and by atomic writes its
+// abandoned step attempts afterwards, when the context was cancelled before
+// the work finished.
+var ErrInterrupted = errors.New("interrupted, no partial files written")
+
+// atomicStep is the state of one runInterruptible step: each file it renamed
+// into place, mapped to the backup of the file that was replaced ("" if there
+// was none), and whether the step was canceled.
+type atomicStep struct {
+	renamed  map[string]string
+	canceled bool
+}
+
+// atomicWrites tracks the temp files being written and the active step. Once
+// the step is canceled no new file is started and none in flight is renamed
+// into place. A canceled step stays active until its abandoned fn returns,
+// so writes are refused only for that long; outside a step they always run.
+var atomicWrites = struct {
+	sync.Mutex
+	pending map[string]bool // temp paths
+	step    *atomicStep     // nil outside runInterruptible
+}{pending: map[string]bool{}}
+
+// canceledLocked reports whether writes are refused. Called with the lock held.
+func canceledLocked() bool {
+	return atomicWrites.step != nil && atomicWrites.step.canceled
+}
+
+// beginTempFile registers tmp as a temp file about to be written, or fails
+// with ErrInterrupted once the step is canceled. Pair it with endTempFile.
+func beginTempFile(tmp string) error {
+	atomicWrites.Lock()
+	defer atomicWrites.Unlock()
+	if canceledLocked() {
+		return fmt.Errorf("write %s: %w", filepath.Base(tmp), ErrInterrupted)
+	}
+	atomicWrites.pending[tmp] = true
+	return nil
+}
+
+// endTempFile unregisters tmp and removes it if it is still there.
+func endTempFile(tmp string) {
+	atomicWrites.Lock()
+	delete(atomicWrites.pending, tmp)
+	atomicWrites.Unlock()
+	os.Remove(tmp)
+}
+
+// writeFileAtomic writes path through write, via path+".tmp" and a rename.
+// On any error the temp file is removed and path is left as it was.
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	name := filepath.Base(path)
+	tmp := path + ".tmp"
+	if err := beginTempFile(tmp); err != nil {
+		return err
+	}
+	defer endTempFile(tmp)
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", name, err)
+	}
+
+	// Checked under the lock so a cancellation either happens before the
+	// rename (and the file is withheld) or after it (and the file is whole).
+	atomicWrites.Lock()
+	defer atomicWrites.Unlock()
+	if canceledLocked() {
+		return fmt.Errorf("write %s: %w", name, ErrInterrupted)
+	}
+	step := atomicWrites.step
+	if step == nil {
+		if err := os.Rename(tmp, path); err != nil {
+			return fmt.Errorf("rename %s: %w", name, err)
+		}
+		return nil
+	}
+
+	// Inside a step, keep whatever this replaces until the step completes.
+	backup, seen := step.renamed[path]
+	if !seen {
+		if _, err := os.Lstat(path); err == nil {
+			if backup, err = backupFile(path); err != nil {
+				return fmt.Errorf("back up %s: %w", name, err)
+			}
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		if !seen && backup != "" {
+			os.Rename(backup, path)
+		}
+		return fmt.Errorf("rename %s: %w", name, err)
+	}
+	step.renamed[path] = backup
+	return nil
+}
+
+// backupFile moves path aside to a fresh <name>.*.bak in the same directory,
+// so an existing file of the user's is never overwritten, and returns the
+// backup's path.
+func backupFile(path string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.bak")
+	if err != nil {
+		return "", err
+	}
+	backup := f.Name()
+	f.Close()
+	if err := os.Rename(path, backup); err != nil {
+		os.Remove(backup)
+		return "", err
+	}
+	return backup, nil
+}
+
+// writeBytesAtomic is writeFileAtomic for data already in memory.
+func writeBytesAtomic(path string, data []byte) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// cancelStep cancels step and deletes the temp files in flight. It returns
+// the deleted paths in order.
+func cancelStep(step *atomicStep) []string {
+	atomicWrites.Lock()
+	defer atomicWrites.Unlock()
+	step.canceled = true
+	var removed []string
+	for tmp := range atomicWrites.pending {
+		if err := os.Remove(tmp); err == nil {
+			removed = append(removed, tmp)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// settleStepLocked resolves the files step renamed into place. With rollback
+// set, each is removed and the file it replaced, if any, restored; otherwise
+// only the backups are deleted. Called with the lock held.
+func settleStepLocked(step *atomicStep, rollback bool) {
+	for path, backup := range step.renamed {
+		switch {
+		case !rollback:
+			if backup != "" {
+				os.Remove(backup)
+			}
+		case backup != "":
+			os.Rename(backup, path)
+		default:
+			os.Remove(path)
+		}
+	}
+	step.renamed = map[string]string{}
+}
+
+// endStep settles step and, if it is still the active one, deactivates it.
+func endStep(step *atomicStep, rollback bool) {
+	atomicWrites.Lock()
+	defer atomicWrites.Unlock()
+	settleStepLocked(step, rollback)
+	if atomicWrites.step == step {
+		atomicWrites.step = nil
+	}
+}
+
+// runInterruptible runs fn, which writes its files with writeFileAtomic,
+// until it returns or ctx is cancelled. On cancellation the temp files in
+// flight are deleted, the files fn already renamed into place are rolled
+// back, fn is abandoned and ErrInterrupted is returned. The abandoned fn's
+// later writes fail until it returns. Only one runInterruptible may run at a
+// time.
+func runInterruptible(ctx context.Context, fn func() error) error {
+	step := &atomicStep{renamed: map[string]string{}}
+	atomicWrites.Lock()
+	atomicWrites.step = step
+	atomicWrites.Unlock()
+
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		endStep(step, false)
+		return err
+	case <-ctx.Done():
+		cancelStep(step)
+		select {
+		case err := <-done:
+			// fn finished as the signal arrived. If it got all its files
+			// out they are whole; if a write was refused, undo the rest.
+			endStep(step, errors.Is(err, ErrInterrupted))
+			return err
+		default:
+			atomicWrites.Lock()
+			settleStepLocked(step, true)
+			atomicWrites.Unlock()
+			go func() {
+				<-done
+				endStep(step, true)
+			}()
+			return ErrInterrupted
+		}
+	}
+}
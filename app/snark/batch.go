@@ -0,0 +1,175 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// batch.go proves several vw0w1 statements with a single Groth16 proof. The
+// batch circuit repeats the vw0w1 constraints once per statement and shares
+// the curve and pairing gadgets between them, so the fixed proof cost (one
+// proof, one on-chain verification) is paid once for the whole batch.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+)
+
+// vw0w1BatchCircuit proves len(Items) vw0w1 statements. The public inputs are
+// each statement's (vx, vy, w0x, w0y, w1x, w1y), concatenated in order.
+type vw0w1BatchCircuit struct {
+	Items []vw0w1Circuit
+}
+
+// Define adds the constraints of every statement, sharing one set of gadgets.
+func (c *vw0w1BatchCircuit) Define(api frontend.API) error {
+	g := &vw0w1Gadgets{}
+	for i := range c.Items {
+		if err := c.Items[i].define(api, g); err != nil {
+			return fmt.Errorf("statement %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// CompileVW0W1BatchCircuit compiles the batch circuit for n statements.
+func CompileVW0W1BatchCircuit(n int) (constraint.ConstraintSystem, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("batch size must be >= 1 (got %d)", n)
+	}
+	circuit := vw0w1BatchCircuit{Items: make([]vw0w1Circuit, n)}
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &circuit)
+	if err != nil {
+		return nil, fmt.Errorf("compile batch of %d: %w", n, err)
+	}
+	return ccs, nil
+}
+
+// VW0W1Statement is one (a, r, v, w0, w1) instance of a batch.
+type VW0W1Statement struct {
+	A, R               *big.Int
+	VHex, W0Hex, W1Hex string
+}
+
+// statementJSON is the on-disk form of a VW0W1Statement. Scalars are decimal or
+// 0x-hex strings, points are compressed G1 hex.
+type statementJSON struct {
+	A  string `json:"a"`
+	R  string `json:"r"`
+	V  string `json:"v"`
+	W0 string `json:"w0"`
+	W1 string `json:"w1"`
+}
+
+// ParseStatementList decodes a JSON array of {"a", "r", "v", "w0", "w1"}
+// objects into statements.
+func ParseStatementList(raw []byte) ([]VW0W1Statement, error) {
+	var items []statementJSON
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("statements file must be a JSON array of {a, r, v, w0, w1}: %w", err)
+	}
+	stmts := make([]VW0W1Statement, len(items))
+	for i, it := range items {
+		a, ok := new(big.Int).SetString(it.A, 0)
+		if !ok {
+			return nil, fmt.Errorf("statement %d: could not parse a %q", i, it.A)
+		}
+		r, ok := new(big.Int).SetString(it.R, 0)
+		if !ok {
+			return nil, fmt.Errorf("statement %d: could not parse r %q", i, it.R)
+		}
+		stmts[i] = VW0W1Statement{
+			A:     a,
+			R:     r,
+			VHex:  normalizeHex(it.V),
+			W0Hex: normalizeHex(it.W0),
+			W1Hex: normalizeHex(it.W1),
+		}
+	}
+	return stmts, nil
+}
+
+// BatchReport summarizes a batch proof so callers can weigh its size.
+type BatchReport struct {
+	Statements  int
+	Constraints int
+}
+
+// PerStatement is the average number of constraints per statement.
+func (r BatchReport) PerStatement() int {
+	if r.Statements == 0 {
+		return 0
+	}
+	return r.Constraints / r.Statements
+}
+
+// wipeSecrets wipes the secrets of every statement in a batch assignment.
+func (c *vw0w1BatchCircuit) wipeSecrets() {
+	for i := range c.Items {
+		c.Items[i].wipeSecrets()
+	}
+}
+
+// prepareVW0W1Batch validates and pre-flights every statement, as
+// prepareVW0W1 does for one, and builds the batch assignment.
+func prepareVW0W1Batch(stmts []VW0W1Statement, opts ProveOptions) (*vw0w1BatchCircuit, error) {
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("batch has no statements")
+	}
+	if opts.WitnessOut != "" {
+		return nil, fmt.Errorf("WitnessOut is not supported for batches")
+	}
+
+	assignment := &vw0w1BatchCircuit{Items: make([]vw0w1Circuit, len(stmts))}
+	for i, st := range stmts {
+		item, err := prepareVW0W1(st.A, st.R, st.VHex, st.W0Hex, st.W1Hex, opts)
+		if err != nil {
+			assignment.wipeSecrets()
+			return nil, fmt.Errorf("statement %d: %w", i, err)
+		}
+		assignment.Items[i] = *item
+	}
+	return assignment, nil
+}
+
+// ProveBatchVW0W1 compiles the batch circuit for len(stmts) statements, runs a
+// fresh setup, and proves and verifies all statements with one proof. The
+// artifacts are written to outDir like ProveAndVerifyVW0W1WithOptions does,
+// with the public inputs of the statements concatenated in order.
+func ProveBatchVW0W1(stmts []VW0W1Statement, outDir string, opts ProveOptions) (BatchReport, error) {
+	assignment, err := prepareVW0W1Batch(stmts, opts)
+	if err != nil {
+		return BatchReport{}, err
+	}
+	defer assignment.wipeSecrets()
+	if err := checkArtifacts(outDir, opts); err != nil {
+		return BatchReport{}, err
+	}
+
+	ccs, err := CompileVW0W1BatchCircuit(len(stmts))
+	if err != nil {
+		return BatchReport{}, err
+	}
+	report := BatchReport{Statements: len(stmts), Constraints: ccs.GetNbConstraints()}
+
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		return report, fmt.Errorf("setup: %w", err)
+	}
+
+	h := &SetupHandle{CCS: ccs, PK: pk, VK: vk}
+	opts.SkipVerify = false
+	proof, publicWitness, err := h.proveAssignment(assignment, opts)
+	if err != nil {
+		return report, err
+	}
+	if opts.NoExport {
+		return report, nil
+	}
+
+	return report, writeArtifacts(h, proof, publicWitness, outDir, opts)
+}
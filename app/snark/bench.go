@@ -0,0 +1,145 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// bench.go implements repeated proving against a loaded setup for load testing.
+// It backs the `prove -count N` CLI flag: the setup is opened once through a
+// SetupHandle and the vw0w1 circuit is proven N times with deterministic,
+// per-iteration witnesses so that sustained throughput can be measured on a
+// given machine without writing Go benchmarks.
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+)
+
+// BenchStats summarizes the latency of a repeated-prove run.
+type BenchStats struct {
+	Count int
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+	Mean  time.Duration
+	P95   time.Duration
+}
+
+// summarizeDurations computes min/max/mean/p95 and the total over samples.
+// The p95 uses the nearest-rank method. Returns a zero BenchStats for no samples.
+func summarizeDurations(samples []time.Duration) BenchStats {
+	if len(samples) == 0 {
+		return BenchStats{}
+	}
+
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+
+	// nearest-rank: ceil(0.95 * n) - 1
+	rank := (95*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return BenchStats{
+		Count: len(sorted),
+		Total: total,
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+		Mean:  total / time.Duration(len(sorted)),
+		P95:   sorted[rank-1],
+	}
+}
+
+// benchWitness returns the deterministic inputs for iteration i of a benchmark:
+//
+//	a_i = a + i
+//	r_i = (r - 1 + i) mod (q - 1) + 1
+//	w0_i, w1_i = computeW0W1(a_i, r_i, v)
+//
+// r_i steps through [1, q) like r + i but wraps before reaching q, so every
+// iteration passes validateR whatever r in [1, q) the run starts from.
+// Varying the witness keeps every iteration a genuine proof rather than a replay.
+func benchWitness(a, r *big.Int, vHex string, i int) (aI, rI *big.Int, w0Hex, w1Hex string, err error) {
+	v, err := parseG1CompressedHex(vHex)
+	if err != nil {
+		return nil, nil, "", "", fmt.Errorf("invalid compressed G1 v: %w", err)
+	}
+
+	step := big.NewInt(int64(i))
+	aI = new(big.Int).Add(a, step)
+	one := big.NewInt(1)
+	rI = new(big.Int).Sub(r, one)
+	rI.Add(rI, step)
+	rI.Mod(rI, new(big.Int).Sub(fr.Modulus(), one))
+	rI.Add(rI, one)
+
+	w0, w1, err := computeW0W1(aI, rI, v)
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+	if w0Hex, err = g1CompressedHex(w0); err != nil {
+		return nil, nil, "", "", err
+	}
+	if w1Hex, err = g1CompressedHex(w1); err != nil {
+		return nil, nil, "", "", err
+	}
+	return aI, rI, w0Hex, w1Hex, nil
+}
+
+// RunProveBenchmark proves the vw0w1 circuit count times using h, deriving each
+// iteration's witness with benchWitness. Only groth16 proving is timed: the
+// witness derivation and the assignment (including the hk pairing) are built
+// before the clock starts. If verify is true, each proof is also verified (and
+// the verification time is included in the sample). progress, if non-nil, is
+// called after every iteration with its index and latency.
+func RunProveBenchmark(h *SetupHandle, count int, a, r *big.Int, vHex string, verify bool, progress func(i int, d time.Duration)) (BenchStats, error) {
+	if h == nil {
+		return BenchStats{}, fmt.Errorf("setup handle is nil")
+	}
+	if count < 1 {
+		return BenchStats{}, fmt.Errorf("count must be >= 1 (got %d)", count)
+	}
+	if a == nil || a.Sign() == 0 {
+		return BenchStats{}, fmt.Errorf("a must be > 0")
+	}
+	if r == nil {
+		r = new(big.Int)
+	}
+
+	samples := make([]time.Duration, 0, count)
+	for i := 0; i < count; i++ {
+		aI, rI, w0Hex, w1Hex, err := benchWitness(a, r, vHex, i)
+		if err != nil {
+			return BenchStats{}, fmt.Errorf("iteration %d: %w", i, err)
+		}
+
+		// Witnesses are correct by construction, so the pre-flight is skipped.
+		opts := ProveOptions{SkipVerify: !verify, SkipPreflight: true}
+		assignment, err := prepareVW0W1(aI, rI, vHex, w0Hex, w1Hex, opts)
+		if err != nil {
+			return BenchStats{}, fmt.Errorf("iteration %d: %w", i, err)
+		}
+		start := time.Now()
+		_, _, err = h.proveAssignment(assignment, opts)
+		d := time.Since(start)
+		assignment.wipeSecrets()
+		if err != nil {
+			return BenchStats{}, fmt.Errorf("iteration %d: %w", i, err)
+		}
+
+		samples = append(samples, d)
+		if progress != nil {
+			progress(i, d)
+		}
+	}
+
+	return summarizeDurations(samples), nil
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// bls.go exposes the small set of BLS12-381 G1 operations needed to build
+// vw0w1 witnesses (w0 = [hk]q, w1 = [a]q + [r]v) without reaching into
+// gnark-crypto directly. Scalars are reduced mod the scalar field order and
+// points are checked to be on the curve and in the prime-order subgroup.
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+)
+
+// reduceScalar returns k mod r as a fresh value; nil is treated as 0.
+func reduceScalar(k *big.Int) *big.Int {
+	if k == nil {
+		return new(big.Int)
+	}
+	return reduceFr(k)
+}
+
+// reduceFr reduces k into Fr the way the witness builders do, native and
+// WASM alike: fr.Element.SetBigInt, then BigInt. Negative k wraps around.
+func reduceFr(k *big.Int) *big.Int {
+	var e fr.Element
+	e.SetBigInt(k)
+	return e.BigInt(new(big.Int))
+}
+
+// wasmReducedLogLine is the line wasmProve logs to the browser console after
+// reducing its secrets; `reduce -wasm-log` prints the same line natively so
+// the two can be diffed.
+func wasmReducedLogLine(aFr, rFr *big.Int) string {
+	return fmt.Sprintf("[WASM] wasmProve: reduced a = %s, r = %s", aFr.String(), rFr.String())
+}
+
+// ReducedScalar describes k mod r, the value the circuit actually uses for a
+// secret scalar k.
+type ReducedScalar struct {
+	Decimal string // k mod r in decimal
+	Hex     string // k mod r as 32-byte big-endian hex
+	Reduced bool   // k was outside [0, r) and changed under reduction
+}
+
+// ReduceScalar returns the Fr-reduced form of k, matching the reduction the
+// WASM prover logs.
+func ReduceScalar(k *big.Int) ReducedScalar {
+	red := reduceScalar(k)
+	var e fr.Element
+	e.SetBigInt(red)
+	b := e.Bytes()
+	return ReducedScalar{
+		Decimal: red.String(),
+		Hex:     hex.EncodeToString(b[:]),
+		Reduced: k != nil && red.Cmp(k) != 0,
+	}
+}
+
+// checkG1 rejects points that are off the curve or outside the subgroup.
+func checkG1(name string, p *bls12381.G1Affine) error {
+	if !p.IsOnCurve() {
+		return withKind(ErrBadPoint, fmt.Errorf("%s is not on the curve", name))
+	}
+	if !p.IsInSubGroup() {
+		return withKind(ErrBadPoint, fmt.Errorf("%s is not in the G1 subgroup", name))
+	}
+	return nil
+}
+
+// ScalarBaseMulG1 returns [k]q, where q is the G1 generator.
+func ScalarBaseMulG1(k *big.Int) bls12381.G1Affine {
+	var p bls12381.G1Affine
+	p.ScalarMultiplicationBase(reduceScalar(k))
+	return p
+}
+
+// ScalarMulG1 returns [k]p.
+func ScalarMulG1(p bls12381.G1Affine, k *big.Int) (bls12381.G1Affine, error) {
+	if err := checkG1("point", &p); err != nil {
+		return bls12381.G1Affine{}, err
+	}
+	var out bls12381.G1Affine
+	out.ScalarMultiplication(&p, reduceScalar(k))
+	return out, nil
+}
+
+// AddG1 returns p + q.
+func AddG1(p, q bls12381.G1Affine) (bls12381.G1Affine, error) {
+	if err := checkG1("p", &p); err != nil {
+		return bls12381.G1Affine{}, err
+	}
+	if err := checkG1("q", &q); err != nil {
+		return bls12381.G1Affine{}, err
+	}
+	var out bls12381.G1Affine
+	out.Add(&p, &q)
+	return out, nil
+}
+
+// ScalarBaseMulG1Hex is ScalarBaseMulG1 returning compressed hex.
+func ScalarBaseMulG1Hex(k *big.Int) (string, error) {
+	return g1CompressedHex(ScalarBaseMulG1(k))
+}
+
+// ScalarMulG1Hex is ScalarMulG1 over compressed hex points.
+func ScalarMulG1Hex(pHex string, k *big.Int) (string, error) {
+	p, err := parseG1CompressedHex(pHex)
+	if err != nil {
+		return "", fmt.Errorf("point: %w", err)
+	}
+	out, err := ScalarMulG1(p, k)
+	if err != nil {
+		return "", err
+	}
+	return g1CompressedHex(out)
+}
+
+// AddG1Hex is AddG1 over compressed hex points.
+func AddG1Hex(pHex, qHex string) (string, error) {
+	p, err := parseG1CompressedHex(pHex)
+	if err != nil {
+		return "", fmt.Errorf("p: %w", err)
+	}
+	q, err := parseG1CompressedHex(qHex)
+	if err != nil {
+		return "", fmt.Errorf("q: %w", err)
+	}
+	out, err := AddG1(p, q)
+	if err != nil {
+		return "", err
+	}
+	return g1CompressedHex(out)
+}
@@ -0,0 +1,67 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// buildinfo.go reports which build of the tool and which gnark and
+// gnark-crypto releases produced an artifact. Serialization formats and
+// emulated-field behavior move between those releases, so a bug report or a
+// vk.json that does not say which versions made it can be impossible to
+// reproduce. Everything here comes from the module data the Go linker embeds
+// (runtime/debug.ReadBuildInfo); nothing has to be stamped by hand.
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	gnarkModule       = "github.com/consensys/gnark"
+	gnarkCryptoModule = "github.com/consensys/gnark-crypto"
+)
+
+// BuildInfo describes the running binary. Fields the build did not record
+// are empty.
+type BuildInfo struct {
+	Version     string // main module version, "(devel)" for local builds
+	Revision    string // VCS revision, with a "-dirty" suffix for modified trees
+	GoVersion   string // runtime.Version()
+	Gnark       string // gnark module version
+	GnarkCrypto string // gnark-crypto module version
+}
+
+// ReadBuildInfo returns the versions embedded in the running binary. Replaced
+// modules report the version of their replacement.
+var ReadBuildInfo = sync.OnceValue(func() BuildInfo {
+	out := BuildInfo{GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return out
+	}
+	out.Version = bi.Main.Version
+	modified := false
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			out.Revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if modified && out.Revision != "" {
+		out.Revision += "-dirty"
+	}
+	for _, dep := range bi.Deps {
+		version := dep.Version
+		if dep.Replace != nil {
+			version = dep.Replace.Version
+		}
+		switch dep.Path {
+		case gnarkModule:
+			out.Gnark = version
+		case gnarkCryptoModule:
+			out.GnarkCrypto = version
+		}
+	}
+	return out
+})
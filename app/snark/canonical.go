@@ -0,0 +1,116 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// canonical.go pins the order of the vw0w1 public inputs. exportPublicInputs
+// emits them in witness-vector order, and the on-chain verifier hard-codes
+// the same sequence, so a reordering anywhere in between would only show up
+// as a failed verification. The order is taken from the circuit's declared
+// public variables rather than written down a second time:
+//
+//	vx, vy, w0x, w0y, w1x, w1y  (struct field order of vw0w1Circuit)
+//
+// each an emulated Fp element of six 64-bit limbs, least significant limb
+// first, for 36 inputs. public.json prepends the one-wire "1".
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// VW0W1NbPublic is the number of vw0w1 public inputs without the one-wire:
+// three points, two emulated coordinates each, six limbs per coordinate. It
+// equals circuitNbPublic of the compiled circuit, which the prove paths
+// check every export against.
+const VW0W1NbPublic = 36
+
+// ErrPublicInputOrder is returned (wrapped) when public inputs do not follow
+// the canonical vw0w1 order for the given points.
+var ErrPublicInputOrder = errors.New("public inputs out of canonical order")
+
+// VW0W1Points holds the compressed G1 hex of the three public points.
+type VW0W1Points struct {
+	V, W0, W1 string
+}
+
+// VW0W1PublicInputNames returns the names of the vw0w1 public variables in
+// canonical order, e.g. "vx_Limbs_0" .. "w1y_Limbs_5".
+func VW0W1PublicInputNames() ([]string, error) {
+	tVariable := reflect.TypeOf((*frontend.Variable)(nil)).Elem()
+	var names []string
+	_, err := schema.Walk(ecc.BLS12_381.ScalarField(), &vw0w1Circuit{}, tVariable, func(f schema.LeafInfo, _ reflect.Value) error {
+		if f.Visibility == schema.Public {
+			names = append(names, f.FullName())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk vw0w1 circuit: %w", err)
+	}
+	return names, nil
+}
+
+// CanonicalVW0W1PublicInputs returns the public inputs (without the
+// one-wire) that a vw0w1 proof over the compressed points v, w0, w1 must
+// carry, in canonical order.
+func CanonicalVW0W1PublicInputs(vHex, w0Hex, w1Hex string) (fr.Vector, error) {
+	vAff, w0Aff, w1Aff, err := parseVW0W1Points(normalizeHex(vHex), normalizeHex(w0Hex), normalizeHex(w1Hex), false)
+	if err != nil {
+		return nil, err
+	}
+	// The secrets do not reach the public witness; any valid pair will do.
+	assignment := NewVW0W1Assignment(big.NewInt(1), new(big.Int), vAff, w0Aff, w1Aff)
+	w, err := frontend.NewWitness(assignment, ecc.BLS12_381.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return nil, fmt.Errorf("build public witness: %w", err)
+	}
+	vec, ok := w.Vector().(fr.Vector)
+	if !ok {
+		return nil, fmt.Errorf("unexpected public witness vector type %T", w.Vector())
+	}
+	return vec, nil
+}
+
+// CheckVW0W1PublicOrder checks that got (without the one-wire) holds the
+// public inputs of v, w0, w1 in canonical order. A permutation of the right
+// values is reported as such, naming the first misplaced variable.
+func CheckVW0W1PublicOrder(got []fr.Element, vHex, w0Hex, w1Hex string) error {
+	want, err := CanonicalVW0W1PublicInputs(vHex, w0Hex, w1Hex)
+	if err != nil {
+		return err
+	}
+	names, err := VW0W1PublicInputNames()
+	if err != nil {
+		return err
+	}
+	if len(got) != len(want) {
+		return fmt.Errorf("%w: got %d public inputs, the circuit declares %d", ErrPublicInputOrder, len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Equal(&want[i]) {
+			continue
+		}
+		for j := range want {
+			if got[i].Equal(&want[j]) {
+				return fmt.Errorf("%w: input %d holds %s, expected at %d, where %s belongs", ErrPublicInputOrder, i, names[j], j, names[i])
+			}
+		}
+		return fmt.Errorf("%w: input %d (%s) is %s, want %s", ErrPublicInputOrder, i, names[i], got[i].String(), want[i].String())
+	}
+	return nil
+}
+
+// checkCanonical runs CheckVW0W1PublicOrder when points is set.
+func checkCanonical(pubFr []fr.Element, points *VW0W1Points) error {
+	if points == nil {
+		return nil
+	}
+	return CheckVW0W1PublicOrder(pubFr, points.V, points.W0, points.W1)
+}
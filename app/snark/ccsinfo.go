@@ -0,0 +1,113 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// ccsinfo.go summarizes a compiled constraint system for audits: its size,
+// variable counts and BSB22 commitment metadata. The committed public
+// indices it reports are the ones Setup copies into
+// vk.PublicAndCommitmentCommitted, so they can stand in for the VK when the
+// commitment wire is recomputed without one.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark/constraint"
+)
+
+// CCSSummary is the JSON form of a compiled circuit, as printed by `ccs-info`.
+type CCSSummary struct {
+	Curve       string `json:"curve"`
+	Constraints int    `json:"constraints"`
+
+	// PublicVariables counts the one-wire, as gnark does; the exported public
+	// vector has this many entries.
+	PublicVariables   int `json:"publicVariables"`
+	SecretVariables   int `json:"secretVariables"`
+	InternalVariables int `json:"internalVariables"`
+
+	Commitments []CCSCommitment `json:"commitments"`
+}
+
+// CCSCommitment describes one BSB22 commitment.
+type CCSCommitment struct {
+	// Wire is the wire index the commitment value is assigned to.
+	Wire int `json:"wire"`
+
+	// PublicCommitted lists the committed public inputs as 1-based indices
+	// into the public vector (0 is the one-wire), the form
+	// CommitmentWireOptions.Committed takes.
+	PublicCommitted []int `json:"publicCommitted"`
+
+	// PrivateCommitted counts the committed secret and internal wires.
+	PrivateCommitted int `json:"privateCommitted"`
+}
+
+// SummarizeCCS returns the audit summary of ccs.
+func SummarizeCCS(ccs constraint.ConstraintSystem) (CCSSummary, error) {
+	s := CCSSummary{
+		Curve:             "bls12381",
+		Constraints:       ccs.GetNbConstraints(),
+		PublicVariables:   ccs.GetNbPublicVariables(),
+		SecretVariables:   ccs.GetNbSecretVariables(),
+		InternalVariables: ccs.GetNbInternalVariables(),
+		Commitments:       []CCSCommitment{},
+	}
+	commitments, ok := ccs.GetCommitments().(constraint.Groth16Commitments)
+	if !ok {
+		return CCSSummary{}, fmt.Errorf("unexpected commitment type %T (not a Groth16 constraint system)", ccs.GetCommitments())
+	}
+	committed := commitments.GetPublicAndCommitmentCommitted(commitments.CommitmentIndexes(), ccs.GetNbPublicVariables())
+	for i, c := range commitments {
+		s.Commitments = append(s.Commitments, CCSCommitment{
+			Wire:             c.CommitmentIndex,
+			PublicCommitted:  committed[i],
+			PrivateCommitted: len(c.PrivateCommitted),
+		})
+	}
+	return s, nil
+}
+
+// SummarizeVW0W1 summarizes the vw0w1 circuit, reading ccs.bin from setupDir
+// or, when setupDir is empty, compiling the circuit.
+func SummarizeVW0W1(setupDir string) (CCSSummary, error) {
+	var ccs constraint.ConstraintSystem
+	var err error
+	if setupDir != "" {
+		ccs, err = ReadCCSFile(filepath.Join(setupDir, "ccs.bin"))
+	} else {
+		ccs, err = CompileVW0W1Circuit()
+	}
+	if err != nil {
+		return CCSSummary{}, err
+	}
+	return SummarizeCCS(ccs)
+}
+
+// committedPublicIndices returns the public indices bound by the first
+// commitment of ccs, or nil if it has none.
+func committedPublicIndices(ccs constraint.ConstraintSystem) ([]int, error) {
+	s, err := SummarizeCCS(ccs)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Commitments) == 0 {
+		return nil, nil
+	}
+	return s.Commitments[0].PublicCommitted, nil
+}
+
+// ReadCCSFile loads a compiled constraint system (ccs.bin) from path.
+func ReadCCSFile(path string) (constraint.ConstraintSystem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+	ccs, err := readCCS(f)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return ccs, nil
+}
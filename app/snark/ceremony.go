@@ -11,6 +11,8 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -87,15 +89,10 @@ func fileHash(path string) (string, error) {
 // --- Phase1 I/O ---
 
 func savePhase1(path string, p *mpcsetup.Phase1) error {
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("create %s: %w", path, err)
-	}
-	defer f.Close()
-	if _, err := p.WriteTo(f); err != nil {
-		return fmt.Errorf("write %s: %w", path, err)
-	}
-	return nil
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := p.WriteTo(w)
+		return err
+	})
 }
 
 func loadPhase1(path string) (*mpcsetup.Phase1, error) {
@@ -114,15 +111,10 @@ func loadPhase1(path string) (*mpcsetup.Phase1, error) {
 // --- Phase2 I/O ---
 
 func savePhase2(path string, p *mpcsetup.Phase2) error {
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("create %s: %w", path, err)
-	}
-	defer f.Close()
-	if _, err := p.WriteTo(f); err != nil {
-		return fmt.Errorf("write %s: %w", path, err)
-	}
-	return nil
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := p.WriteTo(w)
+		return err
+	})
 }
 
 func loadPhase2(path string) (*mpcsetup.Phase2, error) {
@@ -141,15 +133,10 @@ func loadPhase2(path string) (*mpcsetup.Phase2, error) {
 // --- SrsCommons I/O ---
 
 func saveSrsCommons(path string, c *mpcsetup.SrsCommons) error {
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("create %s: %w", path, err)
-	}
-	defer f.Close()
-	if _, err := c.WriteTo(f); err != nil {
-		return fmt.Errorf("write %s: %w", path, err)
-	}
-	return nil
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := c.WriteTo(w)
+		return err
+	})
 }
 
 func loadSrsCommons(path string) (*mpcsetup.SrsCommons, error) {
@@ -168,15 +155,10 @@ func loadSrsCommons(path string) (*mpcsetup.SrsCommons, error) {
 // --- CCS / R1CS I/O ---
 
 func saveCCS(path string, ccs constraint.ConstraintSystem) error {
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("create %s: %w", path, err)
-	}
-	defer f.Close()
-	if _, err := ccs.WriteTo(f); err != nil {
-		return fmt.Errorf("write %s: %w", path, err)
-	}
-	return nil
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := ccs.WriteTo(w)
+		return err
+	})
 }
 
 func loadR1CS(path string) (*cs.R1CS, error) {
@@ -205,18 +187,48 @@ func domainSize(ccs constraint.ConstraintSystem) uint64 {
 
 // CeremonyInit compiles the circuit, saves ccs.bin, and creates the initial Phase1 accumulator.
 func CeremonyInit(dir string, force bool) error {
+	return CeremonyInitWithOptions(dir, force, CeremonyInitOptions{})
+}
+
+// CeremonyInitOptions tunes CeremonyInitWithOptions. The zero value imposes
+// no limits.
+type CeremonyInitOptions struct {
+	// MaxConstraints, if positive, aborts init before anything is written
+	// when the compiled circuit has more constraints than this. Key and
+	// contribution sizes grow with the domain, so a circuit change that
+	// blows up the constraint count would otherwise only surface as a full
+	// disk midway through the ceremony.
+	MaxConstraints int
+}
+
+// ErrTooManyConstraints is returned (wrapped) when the circuit exceeds
+// CeremonyInitOptions.MaxConstraints.
+var ErrTooManyConstraints = errors.New("circuit exceeds the constraint limit")
+
+// CeremonyInitWithOptions is CeremonyInit with explicit CeremonyInitOptions.
+func CeremonyInitWithOptions(dir string, force bool, opts CeremonyInitOptions) error {
 	if _, err := os.Stat(filepath.Join(dir, "ccs.bin")); err == nil && !force {
 		return fmt.Errorf("ceremony already initialized in %s (use -force to overwrite)", dir)
 	}
 
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return fmt.Errorf("mkdir: %w", err)
-	}
-
 	ccs, err := CompileVW0W1Circuit()
 	if err != nil {
 		return err
 	}
+	return initCeremony(dir, ccs, opts)
+}
+
+// initCeremony checks ccs against opts, then writes ccs.bin and the initial
+// phase 1 accumulator into dir.
+func initCeremony(dir string, ccs constraint.ConstraintSystem, opts CeremonyInitOptions) error {
+	if n := ccs.GetNbConstraints(); opts.MaxConstraints > 0 && n > opts.MaxConstraints {
+		return fmt.Errorf("%w: %d constraints (domain %d), limit %d; nothing was written",
+			ErrTooManyConstraints, n, domainSize(ccs), opts.MaxConstraints)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
 
 	if err := saveCCS(filepath.Join(dir, "ccs.bin"), ccs); err != nil {
 		return err
@@ -233,12 +245,51 @@ func CeremonyInit(dir string, force bool) error {
 	return nil
 }
 
+// ContributeOptions tunes CeremonyContributePhase1WithOptions and
+// CeremonyContributePhase2WithOptions. The zero value writes the new
+// contribution next to the one it extends.
+type ContributeOptions struct {
+	// OutDir, if set, receives the new contribution instead of the ceremony
+	// directory, which is then only read. This lets a contributor work from a
+	// read-only snapshot of the canonical store and hand the single new file
+	// back to the coordinator. An existing file at the output path is never
+	// overwritten.
+	OutDir string
+}
+
+// outPath returns where the contribution with index lands, creating OutDir
+// when set.
+func (opts ContributeOptions) outPath(dir string, phase, index int) (string, error) {
+	if opts.OutDir == "" {
+		return contributionPath(dir, phase, index), nil
+	}
+	if err := os.MkdirAll(opts.OutDir, 0o755); err != nil {
+		return "", fmt.Errorf("create output dir: %w", err)
+	}
+	path := contributionPath(opts.OutDir, phase, index)
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists; refusing to overwrite", path)
+	}
+	return path, nil
+}
+
 // CeremonyContributePhase1 loads the latest Phase1 accumulator, contributes, and saves the result.
 func CeremonyContributePhase1(dir string) (int, string, error) {
+	return CeremonyContributePhase1WithOptions(dir, ContributeOptions{})
+}
+
+// CeremonyContributePhase1WithOptions is CeremonyContributePhase1 with
+// explicit ContributeOptions.
+func CeremonyContributePhase1WithOptions(dir string, opts ContributeOptions) (int, string, error) {
 	latestPath, idx, err := latestContribution(dir, 1)
 	if err != nil {
 		return 0, "", err
 	}
+	nextIdx := idx + 1
+	nextPath, err := opts.outPath(dir, 1, nextIdx)
+	if err != nil {
+		return 0, "", err
+	}
 
 	p1, err := loadPhase1(latestPath)
 	if err != nil {
@@ -247,8 +298,6 @@ func CeremonyContributePhase1(dir string) (int, string, error) {
 
 	p1.Contribute()
 
-	nextIdx := idx + 1
-	nextPath := contributionPath(dir, 1, nextIdx)
 	if err := savePhase1(nextPath, p1); err != nil {
 		return 0, "", err
 	}
@@ -263,10 +312,21 @@ func CeremonyContributePhase1(dir string) (int, string, error) {
 
 // CeremonyContributePhase2 loads the latest Phase2 accumulator, contributes, and saves the result.
 func CeremonyContributePhase2(dir string) (int, string, error) {
+	return CeremonyContributePhase2WithOptions(dir, ContributeOptions{})
+}
+
+// CeremonyContributePhase2WithOptions is CeremonyContributePhase2 with
+// explicit ContributeOptions.
+func CeremonyContributePhase2WithOptions(dir string, opts ContributeOptions) (int, string, error) {
 	latestPath, idx, err := latestContribution(dir, 2)
 	if err != nil {
 		return 0, "", err
 	}
+	nextIdx := idx + 1
+	nextPath, err := opts.outPath(dir, 2, nextIdx)
+	if err != nil {
+		return 0, "", err
+	}
 
 	p2, err := loadPhase2(latestPath)
 	if err != nil {
@@ -275,8 +335,6 @@ func CeremonyContributePhase2(dir string) (int, string, error) {
 
 	p2.Contribute()
 
-	nextIdx := idx + 1
-	nextPath := contributionPath(dir, 2, nextIdx)
 	if err := savePhase2(nextPath, p2); err != nil {
 		return 0, "", err
 	}
@@ -289,8 +347,92 @@ func CeremonyContributePhase2(dir string) (int, string, error) {
 	return nextIdx, hash, nil
 }
 
+// ChallengeInfo identifies the accumulator the next contributor builds on, so
+// a participant can compare it with what the coordinator announced.
+type ChallengeInfo struct {
+	Phase       int
+	Index       int
+	File        string
+	SHA256      string
+	Constraints int
+	DomainSize  uint64
+}
+
+// CeremonyChallengeHash hashes the latest contribution of the given phase and
+// reports the circuit size from ccs.bin. It is read-only.
+func CeremonyChallengeHash(dir string, phase int) (ChallengeInfo, error) {
+	if phase != 1 && phase != 2 {
+		return ChallengeInfo{}, fmt.Errorf("phase must be 1 or 2 (got %d)", phase)
+	}
+
+	latestPath, idx, err := latestContribution(dir, phase)
+	if err != nil {
+		return ChallengeInfo{}, err
+	}
+	hash, err := fileHash(latestPath)
+	if err != nil {
+		return ChallengeInfo{}, fmt.Errorf("hash %s: %w", filepath.Base(latestPath), err)
+	}
+
+	r1cs, err := loadR1CS(filepath.Join(dir, "ccs.bin"))
+	if err != nil {
+		return ChallengeInfo{}, fmt.Errorf("load ccs: %w", err)
+	}
+
+	return ChallengeInfo{
+		Phase:       phase,
+		Index:       idx,
+		File:        filepath.Base(latestPath),
+		SHA256:      hash,
+		Constraints: r1cs.GetNbConstraints(),
+		DomainSize:  domainSize(r1cs),
+	}, nil
+}
+
+// CeremonyVerifyOptions tunes CeremonyVerifyPhase1WithOptions and
+// CeremonyVerifyPhase2WithOptions. The zero value verifies the whole chain.
+type CeremonyVerifyOptions struct {
+	// Since, if positive, starts verification at contribution Since: only
+	// the pairs (Since, Since+1), ... are checked. The chain up to Since is
+	// taken on trust, so the file at Since must hash to SinceSHA256, the
+	// value recorded when it was last verified.
+	Since       int
+	SinceSHA256 string
+}
+
+// checkAnchor confirms that paths[opts.Since] is the attested file and that
+// there is something after it to verify.
+func (opts CeremonyVerifyOptions) checkAnchor(paths []string) error {
+	if opts.Since == 0 {
+		return nil
+	}
+	if opts.Since < 0 {
+		return fmt.Errorf("since must be >= 0 (got %d)", opts.Since)
+	}
+	if opts.Since >= len(paths)-1 {
+		return fmt.Errorf("nothing to verify after contribution %d (latest is %d)", opts.Since, len(paths)-1)
+	}
+	if opts.SinceSHA256 == "" {
+		return fmt.Errorf("verifying from contribution %d needs its attested sha256", opts.Since)
+	}
+	got, err := fileHash(paths[opts.Since])
+	if err != nil {
+		return fmt.Errorf("hash contribution %d: %w", opts.Since, err)
+	}
+	if got != strings.ToLower(opts.SinceSHA256) {
+		return fmt.Errorf("contribution %d has sha256 %s, attested %s", opts.Since, got, opts.SinceSHA256)
+	}
+	return nil
+}
+
 // CeremonyVerifyPhase1 loads all Phase1 contributions and verifies each pair sequentially.
 func CeremonyVerifyPhase1(dir string) (int, error) {
+	return CeremonyVerifyPhase1WithOptions(dir, CeremonyVerifyOptions{})
+}
+
+// CeremonyVerifyPhase1WithOptions is CeremonyVerifyPhase1 with explicit
+// CeremonyVerifyOptions.
+func CeremonyVerifyPhase1WithOptions(dir string, opts CeremonyVerifyOptions) (int, error) {
 	paths, err := findContributions(dir, 1)
 	if err != nil {
 		return 0, err
@@ -298,14 +440,17 @@ func CeremonyVerifyPhase1(dir string) (int, error) {
 	if len(paths) < 2 {
 		return 0, fmt.Errorf("need at least 1 contribution beyond the initial (found %d files)", len(paths))
 	}
+	if err := opts.checkAnchor(paths); err != nil {
+		return 0, err
+	}
 
-	prev, err := loadPhase1(paths[0])
+	prev, err := loadPhase1(paths[opts.Since])
 	if err != nil {
-		return 0, fmt.Errorf("load initial: %w", err)
+		return 0, fmt.Errorf("load contribution %d: %w", opts.Since, err)
 	}
 
 	verified := 0
-	for i := 1; i < len(paths); i++ {
+	for i := opts.Since + 1; i < len(paths); i++ {
 		next, err := loadPhase1(paths[i])
 		if err != nil {
 			return verified, fmt.Errorf("load contribution %d: %w", i, err)
@@ -322,6 +467,12 @@ func CeremonyVerifyPhase1(dir string) (int, error) {
 
 // CeremonyVerifyPhase2 loads all Phase2 contributions and verifies each pair sequentially.
 func CeremonyVerifyPhase2(dir string) (int, error) {
+	return CeremonyVerifyPhase2WithOptions(dir, CeremonyVerifyOptions{})
+}
+
+// CeremonyVerifyPhase2WithOptions is CeremonyVerifyPhase2 with explicit
+// CeremonyVerifyOptions.
+func CeremonyVerifyPhase2WithOptions(dir string, opts CeremonyVerifyOptions) (int, error) {
 	paths, err := findContributions(dir, 2)
 	if err != nil {
 		return 0, err
@@ -329,14 +480,17 @@ func CeremonyVerifyPhase2(dir string) (int, error) {
 	if len(paths) < 2 {
 		return 0, fmt.Errorf("need at least 1 contribution beyond the initial (found %d files)", len(paths))
 	}
+	if err := opts.checkAnchor(paths); err != nil {
+		return 0, err
+	}
 
-	prev, err := loadPhase2(paths[0])
+	prev, err := loadPhase2(paths[opts.Since])
 	if err != nil {
-		return 0, fmt.Errorf("load initial: %w", err)
+		return 0, fmt.Errorf("load contribution %d: %w", opts.Since, err)
 	}
 
 	verified := 0
-	for i := 1; i < len(paths); i++ {
+	for i := opts.Since + 1; i < len(paths); i++ {
 		next, err := loadPhase2(paths[i])
 		if err != nil {
 			return verified, fmt.Errorf("load contribution %d: %w", i, err)
@@ -351,6 +505,139 @@ func CeremonyVerifyPhase2(dir string) (int, error) {
 	return verified, nil
 }
 
+// ContributionStatus is the outcome of verifying contribution Index against
+// the one before it. Hash is the SHA-256 of the contribution file.
+type ContributionStatus struct {
+	Index int
+	Hash  string
+	OK    bool
+	Err   error
+}
+
+// CeremonyVerifyPhase1Details verifies every Phase1 contribution pair, unlike
+// CeremonyVerifyPhase1 it does not stop at the first failure. It returns one
+// status per contribution (index 1..n) and a non-nil error if any failed.
+func CeremonyVerifyPhase1Details(dir string) ([]ContributionStatus, error) {
+	paths, err := findContributions(dir, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) < 2 {
+		return nil, fmt.Errorf("need at least 1 contribution beyond the initial (found %d files)", len(paths))
+	}
+
+	prev, err := loadPhase1(paths[0])
+	if err != nil {
+		return nil, fmt.Errorf("load initial: %w", err)
+	}
+
+	statuses := make([]ContributionStatus, 0, len(paths)-1)
+	for i := 1; i < len(paths); i++ {
+		st := ContributionStatus{Index: i}
+		st.Hash, _ = fileHash(paths[i])
+
+		next, err := loadPhase1(paths[i])
+		switch {
+		case err != nil:
+			st.Err = fmt.Errorf("load: %w", err)
+		case prev == nil:
+			st.Err = fmt.Errorf("previous contribution %d could not be loaded", i-1)
+		default:
+			if err := prev.Verify(next); err != nil {
+				st.Err = err
+			} else {
+				st.OK = true
+			}
+		}
+		statuses = append(statuses, st)
+		prev = next
+	}
+
+	return statuses, contributionStatusError(statuses)
+}
+
+// CeremonyVerifyPhase2Details is CeremonyVerifyPhase1Details for Phase2.
+func CeremonyVerifyPhase2Details(dir string) ([]ContributionStatus, error) {
+	paths, err := findContributions(dir, 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) < 2 {
+		return nil, fmt.Errorf("need at least 1 contribution beyond the initial (found %d files)", len(paths))
+	}
+
+	prev, err := loadPhase2(paths[0])
+	if err != nil {
+		return nil, fmt.Errorf("load initial: %w", err)
+	}
+
+	statuses := make([]ContributionStatus, 0, len(paths)-1)
+	for i := 1; i < len(paths); i++ {
+		st := ContributionStatus{Index: i}
+		st.Hash, _ = fileHash(paths[i])
+
+		next, err := loadPhase2(paths[i])
+		switch {
+		case err != nil:
+			st.Err = fmt.Errorf("load: %w", err)
+		case prev == nil:
+			st.Err = fmt.Errorf("previous contribution %d could not be loaded", i-1)
+		default:
+			if err := prev.Verify(next); err != nil {
+				st.Err = err
+			} else {
+				st.OK = true
+			}
+		}
+		statuses = append(statuses, st)
+		prev = next
+	}
+
+	return statuses, contributionStatusError(statuses)
+}
+
+// contributionStatusError summarizes failed statuses, or returns nil if all passed.
+func contributionStatusError(statuses []ContributionStatus) error {
+	var failed []string
+	var first error
+	for _, st := range statuses {
+		if !st.OK {
+			failed = append(failed, strconv.Itoa(st.Index))
+			if first == nil {
+				first = fmt.Errorf("contribution %d invalid: %w", st.Index, st.Err)
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d contributions invalid (%s); first: %w",
+		len(failed), len(statuses), strings.Join(failed, ", "), first)
+}
+
+// ReadBeaconFile reads a finalize beacon from path. A file holding only hex
+// digits (surrounding whitespace, such as a trailing newline, is ignored) is
+// decoded as hex, exactly as -beacon is; anything else is used as raw bytes.
+// An empty file is an error.
+func ReadBeaconFile(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read beacon file: %w", err)
+	}
+	text := strings.TrimSpace(string(raw))
+	if text == "" {
+		return nil, fmt.Errorf("beacon file %s is empty", path)
+	}
+	if strings.Trim(text, "0123456789abcdefABCDEF") != "" {
+		return raw, nil
+	}
+	beacon, err := hex.DecodeString(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid beacon hex in %s: %w", path, err)
+	}
+	return beacon, nil
+}
+
 // CeremonyFinalizePhase1 verifies all Phase1 contributions, seals with the beacon,
 // produces SRS commons, and initializes Phase2.
 func CeremonyFinalizePhase1(dir string, beacon []byte) error {
@@ -403,6 +690,12 @@ func CeremonyFinalizePhase1(dir string, beacon []byte) error {
 // CeremonyFinalizePhase2 verifies all Phase2 contributions, seals with the beacon,
 // and extracts the proving and verifying keys.
 func CeremonyFinalizePhase2(dir string, beacon []byte) error {
+	return CeremonyFinalizePhase2WithOptions(dir, beacon, SaveOptions{})
+}
+
+// CeremonyFinalizePhase2WithOptions is CeremonyFinalizePhase2 with explicit
+// SaveOptions for the extracted keys.
+func CeremonyFinalizePhase2WithOptions(dir string, beacon []byte, opts SaveOptions) error {
 	// Load CCS
 	r1cs, err := loadR1CS(filepath.Join(dir, "ccs.bin"))
 	if err != nil {
@@ -433,38 +726,194 @@ func CeremonyFinalizePhase2(dir string, beacon []byte) error {
 		contributions[i-1] = p
 	}
 
+	// Hash the last contribution before sealing; Seal only modifies it in memory
+	lastPath := paths[len(paths)-1]
+	lastHash, err := fileHash(lastPath)
+	if err != nil {
+		return fmt.Errorf("hash %s: %w", filepath.Base(lastPath), err)
+	}
+
 	// Verify and seal — extracts PK and VK
 	pk, vk, err := mpcsetup.VerifyPhase2(r1cs, commons, beacon, contributions...)
 	if err != nil {
 		return fmt.Errorf("verify phase2: %w", err)
 	}
 
-	// Save PK
-	pkPath := filepath.Join(dir, "pk.bin")
-	pkFile, err := os.Create(pkPath)
+	if err := saveCeremonyKeys(dir, pk, vk, opts); err != nil {
+		return err
+	}
+
+	// Record the seal so the keys can be re-extracted without re-verifying
+	seal := phase2Seal{
+		Contribution: filepath.Base(lastPath),
+		SHA256:       lastHash,
+		Beacon:       hex.EncodeToString(beacon),
+	}
+	if err := saveSeal(filepath.Join(dir, sealFile), seal); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FinalizePlan is what CeremonyFinalizeDryRun found: the finalize that
+// would run on the same directory and beacon.
+type FinalizePlan struct {
+	Phase         int
+	Contributions int      // contributions verified, excluding the initial file
+	Last          string   // the last contribution, which the beacon seals
+	LastSHA256    string   // its SHA-256
+	Beacon        string   // the beacon, hex
+	Writes        []string // files finalize would write to dir
+}
+
+// CeremonyFinalizeDryRun checks everything CeremonyFinalizePhase1/2 needs
+// without sealing or writing anything: the beacon is non-empty, ccs.bin (and,
+// for phase 2, commons.bin) load, and every contribution of the phase verifies
+// against the one before it. Sealing with the beacon and extracting the keys,
+// the expensive part of phase 2, are skipped.
+func CeremonyFinalizeDryRun(dir string, phase int, beacon []byte) (FinalizePlan, error) {
+	plan := FinalizePlan{Phase: phase, Beacon: hex.EncodeToString(beacon)}
+	if len(beacon) == 0 {
+		return plan, fmt.Errorf("beacon is empty")
+	}
+	if _, err := loadR1CS(filepath.Join(dir, "ccs.bin")); err != nil {
+		return plan, fmt.Errorf("load ccs: %w", err)
+	}
+
+	var err error
+	switch phase {
+	case 1:
+		plan.Writes = []string{"commons.bin", filepath.Base(contributionPath(dir, 2, 0))}
+		plan.Contributions, err = CeremonyVerifyPhase1(dir)
+	case 2:
+		if _, err := loadSrsCommons(filepath.Join(dir, "commons.bin")); err != nil {
+			return plan, fmt.Errorf("load commons: %w", err)
+		}
+		plan.Writes = []string{"pk.bin", "vk.bin", "vk.json", manifestFile, sealFile}
+		plan.Contributions, err = CeremonyVerifyPhase2(dir)
+	default:
+		return plan, fmt.Errorf("phase must be 1 or 2 (got %d)", phase)
+	}
+	if err != nil {
+		return plan, fmt.Errorf("verify phase%d: %w", phase, err)
+	}
+
+	paths, err := findContributions(dir, phase)
 	if err != nil {
-		return fmt.Errorf("create pk.bin: %w", err)
+		return plan, err
 	}
-	defer pkFile.Close()
-	if _, err := pk.WriteTo(pkFile); err != nil {
-		return fmt.Errorf("write pk.bin: %w", err)
+	last := paths[len(paths)-1]
+	plan.Last = filepath.Base(last)
+	if plan.LastSHA256, err = fileHash(last); err != nil {
+		return plan, fmt.Errorf("hash %s: %w", plan.Last, err)
 	}
+	return plan, nil
+}
 
-	// Save VK
-	vkPath := filepath.Join(dir, "vk.bin")
-	vkFile, err := os.Create(vkPath)
+// CeremonyExportKeys re-extracts pk.bin, vk.bin and vk.json from a phase 2
+// already sealed by CeremonyFinalizePhase2. It re-applies the recorded beacon
+// to the recorded last contribution but skips verifying the contribution
+// chain, so it is only as trustworthy as the earlier finalize.
+func CeremonyExportKeys(dir string) error {
+	return CeremonyExportKeysWithOptions(dir, SaveOptions{})
+}
+
+// CeremonyExportKeysWithOptions is CeremonyExportKeys with explicit SaveOptions,
+// e.g. to re-extract the keys in the raw encoding.
+func CeremonyExportKeysWithOptions(dir string, opts SaveOptions) error {
+	seal, err := loadSeal(filepath.Join(dir, sealFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("phase 2 is not sealed in %s (run 'ceremony finalize -phase 2' first)", dir)
+		}
+		return err
+	}
+
+	contribPath := filepath.Join(dir, seal.Contribution)
+	gotHash, err := fileHash(contribPath)
 	if err != nil {
-		return fmt.Errorf("create vk.bin: %w", err)
+		return fmt.Errorf("hash %s: %w", seal.Contribution, err)
 	}
-	defer vkFile.Close()
-	if _, err := vk.WriteTo(vkFile); err != nil {
-		return fmt.Errorf("write vk.bin: %w", err)
+	if gotHash != seal.SHA256 {
+		return fmt.Errorf("%s changed since it was sealed (sha256 %s, sealed %s)", seal.Contribution, gotHash, seal.SHA256)
+	}
+	beacon, err := hex.DecodeString(seal.Beacon)
+	if err != nil {
+		return fmt.Errorf("seal beacon: %w", err)
+	}
+
+	r1cs, err := loadR1CS(filepath.Join(dir, "ccs.bin"))
+	if err != nil {
+		return fmt.Errorf("load ccs: %w", err)
+	}
+	commons, err := loadSrsCommons(filepath.Join(dir, "commons.bin"))
+	if err != nil {
+		return fmt.Errorf("load commons: %w", err)
+	}
+	last, err := loadPhase2(contribPath)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", seal.Contribution, err)
+	}
+
+	// Same steps as mpcsetup.VerifyPhase2 minus the per-contribution Verify
+	evals := new(mpcsetup.Phase2).Initialize(r1cs, commons)
+	pk, vk := last.Seal(commons, &evals, beacon)
+
+	return saveCeremonyKeys(dir, pk, vk, opts)
+}
+
+// --- Phase2 seal record ---
+
+// sealFile records which contribution and beacon CeremonyFinalizePhase2 sealed.
+const sealFile = "phase2_seal.json"
+
+type phase2Seal struct {
+	Contribution string `json:"contribution"` // file name of the last contribution
+	SHA256       string `json:"sha256"`       // its hash at finalize time
+	Beacon       string `json:"beacon"`       // beacon hex
+}
+
+func saveSeal(path string, seal phase2Seal) error {
+	b, err := json.MarshalIndent(seal, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeBytesAtomic(path, append(b, '\n'))
+}
+
+func loadSeal(path string) (phase2Seal, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return phase2Seal{}, err
+	}
+	var seal phase2Seal
+	if err := json.Unmarshal(b, &seal); err != nil {
+		return phase2Seal{}, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+	return seal, nil
+}
+
+// saveCeremonyKeys writes pk.bin, vk.bin, vk.json and manifest.json into dir.
+func saveCeremonyKeys(dir string, pk groth16.ProvingKey, vk groth16.VerifyingKey, opts SaveOptions) error {
+	// Save PK
+	if err := writeProvingKeyFiles(dir, pk, opts); err != nil {
+		return err
+	}
+
+	// Save VK
+	if err := writeVerifyingKey(filepath.Join(dir, "vk.bin"), vk); err != nil {
+		return err
 	}
 
 	// Export vk.json for Aiken
-	if err := ExportVKOnly(vk, dir); err != nil {
+	circuitHash, err := setupCircuitHash(dir)
+	if err != nil {
+		return err
+	}
+	if err := exportVKOnly(vk, dir, circuitHash); err != nil {
 		return fmt.Errorf("export vk.json: %w", err)
 	}
 
-	return nil
+	return WriteSetupManifest(dir)
 }
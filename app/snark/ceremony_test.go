@@ -5,10 +5,25 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"math/big"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	mpcsetup "github.com/consensys/gnark/backend/groth16/bls12-381/mpcsetup"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
 )
 
 // ---------- file discovery tests (fast, no crypto) ----------
@@ -130,6 +145,42 @@ func TestCeremonyInit_RefusesOverwrite(t *testing.T) {
 	}
 }
 
+func TestInitCeremony_MaxConstraints(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &commitCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := ccs.GetNbConstraints()
+
+	dir := filepath.Join(t.TempDir(), "ceremony")
+	err = initCeremony(dir, ccs, CeremonyInitOptions{MaxConstraints: n - 1})
+	if !errors.Is(err, ErrTooManyConstraints) {
+		t.Fatalf("limit %d below %d constraints: err = %v", n-1, n, err)
+	}
+	if _, statErr := os.Stat(dir); !os.IsNotExist(statErr) {
+		t.Fatalf("guard tripped but %s was created (stat err = %v)", dir, statErr)
+	}
+
+	if err := initCeremony(dir, ccs, CeremonyInitOptions{MaxConstraints: n}); err != nil {
+		t.Fatalf("limit equal to the constraint count: %v", err)
+	}
+	for _, name := range []string{"ccs.bin", "phase1_0000.bin"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("missing %s: %v", name, err)
+		}
+	}
+}
+
+func TestRun_Ceremony_Init_MaxConstraintsUsage(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if code := run([]string{"ceremony", "init", "-dir", t.TempDir(), "-max-constraints", "-1"}, &out, &errOut); code != 2 {
+		t.Fatalf("want 2 got %d", code)
+	}
+	if !strings.Contains(errOut.String(), "-max-constraints must be >= 0") {
+		t.Fatalf("unexpected stderr: %q", errOut.String())
+	}
+}
+
 // ---------- end-to-end ceremony test (expensive) ----------
 
 func TestCeremonyEndToEnd(t *testing.T) {
@@ -228,6 +279,35 @@ func TestCeremonyEndToEnd(t *testing.T) {
 			t.Fatalf("%s is empty", name)
 		}
 	}
+	if _, err := VerifySetupManifest(dir); err != nil {
+		t.Fatalf("manifest after phase2 finalize: %v", err)
+	}
+
+	// 7b. export-keys re-extracts the same keys from the sealed state
+	t.Log("Phase2 export-keys...")
+	finalized := make(map[string][]byte)
+	for _, name := range []string{"pk.bin", "vk.bin", "vk.json"} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		finalized[name] = b
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := CeremonyExportKeys(dir); err != nil {
+		t.Fatalf("export keys: %v", err)
+	}
+	for name, want := range finalized {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("missing %s after export-keys: %v", name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s from export-keys differs from finalize", name)
+		}
+	}
 
 	// 8. Prove and verify using ceremony-produced keys
 	t.Log("Prove with ceremony keys...")
@@ -304,3 +384,562 @@ func TestCeremonyFinalizePhase2_NoCeremony(t *testing.T) {
 		t.Fatal("expected error for missing ceremony dir")
 	}
 }
+
+func TestCeremonyExportKeys_RequiresSeal(t *testing.T) {
+	dir := t.TempDir()
+	err := CeremonyExportKeys(dir)
+	if err == nil || !strings.Contains(err.Error(), "not sealed") {
+		t.Fatalf("expected not-sealed error, got %v", err)
+	}
+}
+
+func TestCeremonyExportKeys_RejectsChangedContribution(t *testing.T) {
+	dir := t.TempDir()
+	contrib := contributionPath(dir, 2, 1)
+	if err := os.WriteFile(contrib, []byte("sealed bytes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sealedHash, err := fileHash(contrib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seal := phase2Seal{Contribution: filepath.Base(contrib), SHA256: sealedHash, Beacon: "00"}
+	if err := saveSeal(filepath.Join(dir, sealFile), seal); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(contrib, []byte("other bytes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = CeremonyExportKeys(dir)
+	if err == nil || !strings.Contains(err.Error(), "changed since it was sealed") {
+		t.Fatalf("expected changed-contribution error, got %v", err)
+	}
+}
+
+func TestCeremonyVerifyPhase1Details_ReportsAroundBadContribution(t *testing.T) {
+	// A tiny domain keeps this fast; the chain logic does not depend on size.
+	const n = 8
+	dir := t.TempDir()
+
+	// Honest chain 0 -> 1, then a forged 2 that does not extend 1, then
+	// 3 and 4 which honestly extend the forged 2.
+	p := mpcsetup.NewPhase1(n)
+	if err := savePhase1(contributionPath(dir, 1, 0), p); err != nil {
+		t.Fatal(err)
+	}
+	p.Contribute()
+	if err := savePhase1(contributionPath(dir, 1, 1), p); err != nil {
+		t.Fatal(err)
+	}
+
+	forged := mpcsetup.NewPhase1(n)
+	forged.Contribute()
+	forged.Contribute()
+	if err := savePhase1(contributionPath(dir, 1, 2), forged); err != nil {
+		t.Fatal(err)
+	}
+	for i := 3; i <= 4; i++ {
+		prev, err := loadPhase1(contributionPath(dir, 1, i-1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev.Contribute()
+		if err := savePhase1(contributionPath(dir, 1, i), prev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statuses, err := CeremonyVerifyPhase1Details(dir)
+	if err == nil || !strings.Contains(err.Error(), "1 of 4 contributions invalid (2)") {
+		t.Fatalf("expected overall error naming contribution 2, got %v", err)
+	}
+	if len(statuses) != 4 {
+		t.Fatalf("expected 4 statuses, got %d", len(statuses))
+	}
+	for i, st := range statuses {
+		wantOK := st.Index != 2
+		if st.Index != i+1 || st.OK != wantOK || st.Hash == "" {
+			t.Fatalf("status %d: %+v", i, st)
+		}
+		if !st.OK && st.Err == nil {
+			t.Fatalf("failed status without error: %+v", st)
+		}
+	}
+
+	// The early-exit variant still stops at the bad contribution.
+	count, err := CeremonyVerifyPhase1(dir)
+	if err == nil || count != 1 {
+		t.Fatalf("expected early exit after 1 verified, got count=%d err=%v", count, err)
+	}
+}
+
+func TestCeremonyChallengeHash_LatestContribution(t *testing.T) {
+	dir := t.TempDir()
+
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if err := saveCCS(filepath.Join(dir, "ccs.bin"), ccs); err != nil {
+		t.Fatal(err)
+	}
+	// Contents are only hashed, so any bytes will do.
+	for i, body := range []string{"initial", "first", "second"} {
+		if err := os.WriteFile(contributionPath(dir, 2, i), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := CeremonyChallengeHash(dir, 2)
+	if err != nil {
+		t.Fatalf("CeremonyChallengeHash: %v", err)
+	}
+	sum := sha256.Sum256([]byte("second"))
+	if info.File != "phase2_0002.bin" || info.Index != 2 || info.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected challenge: %+v", info)
+	}
+	if info.Constraints != ccs.GetNbConstraints() || info.DomainSize != domainSize(ccs) {
+		t.Fatalf("unexpected circuit size: %+v", info)
+	}
+
+	if _, err := CeremonyChallengeHash(dir, 1); err == nil || !strings.Contains(err.Error(), "no phase 1 contributions") {
+		t.Fatalf("expected missing phase 1 error, got %v", err)
+	}
+	if _, err := CeremonyChallengeHash(dir, 3); err == nil {
+		t.Fatalf("expected error for phase 3")
+	}
+
+	var out, errOut bytes.Buffer
+	if code := run([]string{"ceremony", "challenge-hash", "-dir", dir, "-phase", "2"}, &out, &errOut); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errOut.String())
+	}
+	if !strings.Contains(out.String(), info.SHA256) || !strings.Contains(out.String(), "phase2_0002.bin") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestCeremonyContributePhase1_ReadOnlyInputSeparateOutput(t *testing.T) {
+	in := t.TempDir()
+	if err := savePhase1(contributionPath(in, 1, 0), mpcsetup.NewPhase1(8)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(in, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(in, 0o755) })
+
+	out := filepath.Join(t.TempDir(), "contrib")
+	idx, hash, err := CeremonyContributePhase1WithOptions(in, ContributeOptions{OutDir: out})
+	if err != nil {
+		t.Fatalf("contribute: %v", err)
+	}
+	if idx != 1 || hash == "" {
+		t.Fatalf("unexpected contribution: idx=%d hash=%q", idx, hash)
+	}
+	if got, err := findContributions(in, 1); err != nil || len(got) != 1 {
+		t.Fatalf("input dir was written to: %v %v", got, err)
+	}
+	written := contributionPath(out, 1, 1)
+	if h, err := fileHash(written); err != nil || h != hash {
+		t.Fatalf("output file hash %q, %v; want %s", h, err, hash)
+	}
+
+	// Once the coordinator adds the file to the store, the chain verifies.
+	store := t.TempDir()
+	for _, src := range []string{contributionPath(in, 1, 0), written} {
+		b, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(store, filepath.Base(src)), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := CeremonyVerifyPhase1(store); err != nil || n != 1 {
+		t.Fatalf("verify merged chain: n=%d err=%v", n, err)
+	}
+
+	if _, _, err := CeremonyContributePhase1WithOptions(in, ContributeOptions{OutDir: out}); err == nil || !strings.Contains(err.Error(), "refusing to overwrite") {
+		t.Fatalf("expected refusal to overwrite, got %v", err)
+	}
+}
+
+func TestCeremonyVerifyPhase1WithOptions_Since(t *testing.T) {
+	const n = 8
+	dir := t.TempDir()
+
+	// Honest chain 0..3, then a forged 4 and an honest 5 on top of it.
+	p := mpcsetup.NewPhase1(n)
+	if err := savePhase1(contributionPath(dir, 1, 0), p); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		p.Contribute()
+		if err := savePhase1(contributionPath(dir, 1, i), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	anchor, err := fileHash(contributionPath(dir, 1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the pair (2, 3) is checked.
+	count, err := CeremonyVerifyPhase1WithOptions(dir, CeremonyVerifyOptions{Since: 2, SinceSHA256: anchor})
+	if err != nil || count != 1 {
+		t.Fatalf("tail verify: count=%d err=%v", count, err)
+	}
+
+	// A bad early contribution is out of scope for -since.
+	if err := os.WriteFile(contributionPath(dir, 1, 1), []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := CeremonyVerifyPhase1WithOptions(dir, CeremonyVerifyOptions{Since: 2, SinceSHA256: anchor}); err != nil || count != 1 {
+		t.Fatalf("tail verify after head damage: count=%d err=%v", count, err)
+	}
+
+	forged := mpcsetup.NewPhase1(n)
+	forged.Contribute()
+	if err := savePhase1(contributionPath(dir, 1, 4), forged); err != nil {
+		t.Fatal(err)
+	}
+	forged.Contribute()
+	if err := savePhase1(contributionPath(dir, 1, 5), forged); err != nil {
+		t.Fatal(err)
+	}
+	count, err = CeremonyVerifyPhase1WithOptions(dir, CeremonyVerifyOptions{Since: 2, SinceSHA256: anchor})
+	if err == nil || !strings.Contains(err.Error(), "contribution 4 invalid") || count != 1 {
+		t.Fatalf("expected contribution 4 to fail after 1 verified, got count=%d err=%v", count, err)
+	}
+
+	// The anchor must be the attested file.
+	if _, err := CeremonyVerifyPhase1WithOptions(dir, CeremonyVerifyOptions{Since: 3, SinceSHA256: anchor}); err == nil || !strings.Contains(err.Error(), "attested") {
+		t.Fatalf("expected anchor mismatch, got %v", err)
+	}
+	if _, err := CeremonyVerifyPhase1WithOptions(dir, CeremonyVerifyOptions{Since: 2}); err == nil {
+		t.Fatal("missing anchor hash accepted")
+	}
+	if _, err := CeremonyVerifyPhase1WithOptions(dir, CeremonyVerifyOptions{Since: 5, SinceSHA256: anchor}); err == nil || !strings.Contains(err.Error(), "nothing to verify") {
+		t.Fatalf("expected nothing-to-verify error, got %v", err)
+	}
+}
+
+func TestReadBeaconFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	if b, err := ReadBeaconFile(write("hex", "DeadBeef\n")); err != nil || !bytes.Equal(b, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Fatalf("hex beacon: %x, %v", b, err)
+	}
+	if b, err := ReadBeaconFile(write("raw", "block 123456 hash\n")); err != nil || string(b) != "block 123456 hash\n" {
+		t.Fatalf("raw beacon: %q, %v", b, err)
+	}
+	if _, err := ReadBeaconFile(write("odd", "abc")); err == nil || !strings.Contains(err.Error(), "invalid beacon hex") {
+		t.Fatalf("expected invalid hex, got %v", err)
+	}
+	if _, err := ReadBeaconFile(write("empty", " \n")); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Fatalf("expected empty-file error, got %v", err)
+	}
+}
+
+func TestRun_Ceremony_Finalize_BeaconFileMatchesInline(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	inline := t.TempDir()
+	if err := saveCCS(filepath.Join(inline, "ccs.bin"), ccs); err != nil {
+		t.Fatal(err)
+	}
+	if err := savePhase1(contributionPath(inline, 1, 0), mpcsetup.NewPhase1(domainSize(ccs))); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := CeremonyContributePhase1(inline); err != nil {
+		t.Fatalf("contribute: %v", err)
+	}
+	fromFile := t.TempDir()
+	for _, name := range []string{"ccs.bin", "phase1_0000.bin", "phase1_0001.bin"} {
+		b, err := os.ReadFile(filepath.Join(inline, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(fromFile, name), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const beaconHex = "00112233445566778899aabbccddeeff"
+	beaconPath := filepath.Join(t.TempDir(), "beacon.txt")
+	if err := os.WriteFile(beaconPath, []byte(beaconHex+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"ceremony", "finalize", "-phase", "1", "-dir", inline, "-beacon", beaconHex}, &out, &errBuf); code != 0 {
+		t.Fatalf("inline: want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if code := run([]string{"ceremony", "finalize", "-phase", "1", "-dir", fromFile, "-beacon-file", beaconPath}, &out, &errBuf); code != 0 {
+		t.Fatalf("file: want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	for _, name := range []string{"commons.bin", "phase2_0000.bin"} {
+		a, errA := os.ReadFile(filepath.Join(inline, name))
+		b, errB := os.ReadFile(filepath.Join(fromFile, name))
+		if errA != nil || errB != nil || !bytes.Equal(a, b) {
+			t.Fatalf("%s differs between -beacon and -beacon-file (%v, %v)", name, errA, errB)
+		}
+	}
+
+	errBuf.Reset()
+	if code := run([]string{"ceremony", "finalize", "-phase", "1", "-dir", fromFile, "-beacon", beaconHex, "-beacon-file", beaconPath}, &out, &errBuf); code != 2 || !strings.Contains(errBuf.String(), "mutually exclusive") {
+		t.Fatalf("want 2 with a conflict error, got %d stderr=%q", code, errBuf.String())
+	}
+}
+
+// dirSnapshot maps every file in dir to its contents.
+func dirSnapshot(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := map[string]string{}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		snap[e.Name()] = string(b)
+	}
+	return snap
+}
+
+func TestRun_Ceremony_Finalize_DryRun(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	dir := t.TempDir()
+	if err := saveCCS(filepath.Join(dir, "ccs.bin"), ccs); err != nil {
+		t.Fatal(err)
+	}
+	p := mpcsetup.NewPhase1(domainSize(ccs))
+	for i := 0; i <= 2; i++ {
+		if i > 0 {
+			p.Contribute()
+		}
+		if err := savePhase1(contributionPath(dir, 1, i), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := dirSnapshot(t, dir)
+
+	var out, errBuf bytes.Buffer
+	args := []string{"ceremony", "finalize", "-phase", "1", "-dir", dir, "-beacon", "abcd", "-dry-run"}
+	if code := run(args, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	for _, want := range []string{"contributions verified: 2", "last contribution: phase1_0002.bin", "would write: commons.bin, phase2_0000.bin", "SUCCESS"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+	if after := dirSnapshot(t, dir); !reflect.DeepEqual(after, before) {
+		t.Fatal("-dry-run changed the ceremony directory")
+	}
+
+	// A contribution that does not extend the chain still fails the dry run.
+	forged := mpcsetup.NewPhase1(domainSize(ccs))
+	forged.Contribute()
+	if err := savePhase1(contributionPath(dir, 1, 2), forged); err != nil {
+		t.Fatal(err)
+	}
+	before = dirSnapshot(t, dir)
+	out.Reset()
+	errBuf.Reset()
+	if code := run(args, &out, &errBuf); code != 1 || !strings.Contains(errBuf.String(), "contribution 2 invalid") {
+		t.Fatalf("want 1 naming contribution 2, got %d stderr=%q", code, errBuf.String())
+	}
+	if after := dirSnapshot(t, dir); !reflect.DeepEqual(after, before) {
+		t.Fatal("failed -dry-run changed the ceremony directory")
+	}
+}
+
+func TestWriteFileAtomic_FailedWriteKeepsOldFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "phase2_0001.bin")
+	if err := writeBytesAtomic(path, []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	err := writeFileAtomic(path, func(w io.Writer) error {
+		w.Write([]byte("half a contrib"))
+		return errors.New("disk full")
+	})
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("want the write error, got %v", err)
+	}
+	if got := mustReadFile(t, path); string(got) != "old" {
+		t.Fatalf("file changed to %q", got)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temp file left behind: %v", err)
+	}
+}
+
+func TestRunInterruptible_LeavesNoPartialFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pk.bin")
+	started, release := make(chan struct{}), make(chan struct{})
+	writeErr := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel() // Ctrl-C while pk.bin is half written
+	}()
+	err := runInterruptible(ctx, func() error {
+		err := writeFileAtomic(path, func(w io.Writer) error {
+			w.Write([]byte("half a key"))
+			close(started)
+			<-release
+			return nil
+		})
+		writeErr <- err
+		return err
+	})
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("want ErrInterrupted, got %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temp file left after the interrupt: %v", err)
+	}
+
+	// The abandoned step finishes its write, but may not publish it.
+	close(release)
+	if err := <-writeErr; !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("abandoned write: want ErrInterrupted, got %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("files written after the interrupt: %v", entries)
+	}
+
+	// The next step writes normally.
+	if err := runInterruptible(context.Background(), func() error { return writeBytesAtomic(path, []byte("key")) }); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustReadFile(t, path); string(got) != "key" {
+		t.Fatalf("pk.bin = %q", got)
+	}
+}
+
+func TestRunInterruptible_RollsBackRenamedFiles(t *testing.T) {
+	dir := t.TempDir()
+	ccsPath, vkPath := filepath.Join(dir, "ccs.bin"), filepath.Join(dir, "vk.bin")
+	if err := writeBytesAtomic(vkPath, []byte("old vk")); err != nil {
+		t.Fatal(err)
+	}
+	// A backup the user made by hand must survive the step's own backups.
+	if err := os.WriteFile(vkPath+".bak", []byte("user backup"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	before := dirSnapshot(t, dir)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel() // Ctrl-C after ccs.bin and vk.bin are in place, during pk.bin
+	}()
+	err := runInterruptible(ctx, func() error {
+		defer close(done)
+		if err := writeBytesAtomic(ccsPath, []byte("new ccs")); err != nil {
+			return err
+		}
+		if err := writeBytesAtomic(vkPath, []byte("new vk")); err != nil {
+			return err
+		}
+		return writeFileAtomic(filepath.Join(dir, "pk.bin"), func(w io.Writer) error {
+			close(started)
+			<-release
+			return nil
+		})
+	})
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("want ErrInterrupted, got %v", err)
+	}
+	close(release)
+	<-done
+
+	if after := dirSnapshot(t, dir); !reflect.DeepEqual(after, before) {
+		t.Fatalf("interrupted step was not rolled back: %v", after)
+	}
+
+	// Once the abandoned step has returned, writes outside a step work again.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		atomicWrites.Lock()
+		active := atomicWrites.step != nil
+		atomicWrites.Unlock()
+		if !active {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("interrupted step never ended")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	other := filepath.Join(dir, "manifest.json")
+	if err := writeBytesAtomic(other, []byte("{}")); err != nil {
+		t.Fatalf("write after the interrupted step: %v", err)
+	}
+	if err := os.Remove(other); err != nil {
+		t.Fatal(err)
+	}
+
+	// A completed step keeps its files and drops the backups.
+	if err := runInterruptible(context.Background(), func() error { return writeBytesAtomic(vkPath, []byte("new vk")) }); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustReadFile(t, vkPath); string(got) != "new vk" {
+		t.Fatalf("vk.bin = %q", got)
+	}
+	if after := dirSnapshot(t, dir); len(after) != 2 || after["vk.bin.bak"] != "user backup" {
+		t.Fatalf("backup left behind or user file touched: %v", after)
+	}
+}
+
+// capturedStderr buffers stderr and keeps the last error a handler
+// recorded through printErr.
+type capturedStderr struct {
+	bytes.Buffer
+	err error
+}
+
+func (c *capturedStderr) recordError(err error) { c.err = err }
+
+func TestFailStep_Interrupted(t *testing.T) {
+	var errBuf capturedStderr
+	if code := failStep(&errBuf, fmt.Errorf("setup: %w", ErrInterrupted)); code != 130 {
+		t.Fatalf("want 130 got %d", code)
+	}
+	if got := errBuf.String(); got != "interrupted, no partial files written\n" {
+		t.Fatalf("unexpected stderr: %q", got)
+	}
+	if kind := classifyCLIError(errBuf.err, 130); kind != KindInterrupted {
+		t.Fatalf("kind = %q, want %q", kind, KindInterrupted)
+	}
+
+	errBuf.Reset()
+	if code := failStep(&errBuf, errors.New("boom")); code != 1 || errBuf.String() != "FAIL: boom\n" {
+		t.Fatalf("plain failure: code %d stderr %q", code, errBuf.String())
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// chain.go walks a full decryption path. Each hop derives its key with
+// DecryptToHash, and the key of one hop determines the shared G2 value used
+// by the next, so a whole chain can be decrypted from the initial shared value
+// and the ordered list of level entries. This mirrors recursive_decrypt in
+// app/src/commands.py.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+)
+
+// AdvanceShared returns the shared value for the hop after the one that
+// produced keyHex:
+//
+//	k      = int(keyHex, 16) mod r      (r = BLS12-381 scalar field order)
+//	shared = [k]G2                      (G2 = generator, compressed hex)
+//
+// The initial shared value is [sk]H0, computed by the wallet owner; only the
+// subsequent values follow this rule.
+func AdvanceShared(keyHex string) (string, error) {
+	k, ok := new(big.Int).SetString(keyHex, 16)
+	if !ok {
+		return "", fmt.Errorf("hop key is not hex: %q", keyHex)
+	}
+	k.Mod(k, fr.Modulus())
+
+	var shared bls12381.G2Affine
+	shared.ScalarMultiplicationBase(k)
+	return g2CompressedHex(shared)
+}
+
+// ErrChainLevel is returned (wrapped) when the entries of a chain do not have
+// the shape of a decryption path.
+var ErrChainLevel = errors.New("chain level mismatch")
+
+// ChainOptions tunes DecryptChainWithOptions. The zero value checks the
+// level of every entry and accepts a chain of any depth.
+type ChainOptions struct {
+	// Levels, if positive, is the declared depth of the chain: the number of
+	// entries, half level included. A chain of any other length is rejected.
+	Levels int
+
+	// Decrypt is applied to every hop.
+	Decrypt DecryptOptions
+}
+
+// chainHop holds the decrypt inputs of one entry.
+type chainHop struct {
+	g1b, g2b, r1 string
+}
+
+// checkChainLevels extracts the inputs of every entry and checks that they
+// form a decryption path: a tree walk from the owner down to the current
+// holder, one entry per level,
+//
+//	hop 0     half level: r1, r2_g1b           (no g2b)
+//	hop 1..   full level:  r1, r2_g1b, r2_g2b  (g2b present)
+//
+// The half level is the owner's entry and pairs with the initial shared
+// value [sk]H0; every re-encryption after it adds one full level, so a chain
+// of n entries has depth n. Whether an entry carries g2b is fixed by its
+// position, and an entry decrypted at the wrong position yields a wrong key
+// rather than an error. When levels is positive the chain must also have
+// exactly that depth.
+func checkChainLevels(entries []PlutusData, levels int) ([]chainHop, error) {
+	if levels > 0 && len(entries) != levels {
+		return nil, fmt.Errorf("%w: chain has %d levels, declared %d", ErrChainLevel, len(entries), levels)
+	}
+	hops := make([]chainHop, len(entries))
+	for i, entry := range entries {
+		g1b, g2b, r1, err := EntryDecryptInputs(entry)
+		if err != nil {
+			return nil, fmt.Errorf("hop %d: %w", i, err)
+		}
+		switch {
+		case i == 0 && g2b != "":
+			return nil, fmt.Errorf("%w: hop 0 must be the half level, but the entry carries g2b", ErrChainLevel)
+		case i > 0 && g2b == "":
+			return nil, fmt.Errorf("%w: hop %d must be a full level, but the entry has no g2b", ErrChainLevel, i)
+		}
+		hops[i] = chainHop{g1b: g1b, g2b: g2b, r1: r1}
+	}
+	return hops, nil
+}
+
+// DecryptChain decrypts every entry in order, starting from sharedInitHex and
+// advancing the shared value with AdvanceShared after each hop. It returns the
+// key of every hop; the last one is the key for the capsule.
+func DecryptChain(sharedInitHex string, entries []PlutusData) ([]string, error) {
+	return DecryptChainWithOptions(sharedInitHex, entries, ChainOptions{})
+}
+
+// DecryptChainWithOptions is DecryptChain with explicit ChainOptions. The
+// levels of all entries are checked before the first hop is decrypted.
+func DecryptChainWithOptions(sharedInitHex string, entries []PlutusData, opts ChainOptions) ([]string, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("decrypt chain: no entries")
+	}
+	hops, err := checkChainLevels(entries, opts.Levels)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := sharedInitHex
+	keys := make([]string, 0, len(hops))
+	for i, hop := range hops {
+		key, err := DecryptToHashWithOptions(hop.g1b, hop.g2b, hop.r1, shared, opts.Decrypt)
+		if err != nil {
+			return nil, fmt.Errorf("hop %d: %w", i, err)
+		}
+		keys = append(keys, key)
+
+		if shared, err = AdvanceShared(key); err != nil {
+			return nil, fmt.Errorf("hop %d: %w", i, err)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// circuithash.go defines the circuit hash: the sha256 of a compiled
+// constraint system in its ccs.bin encoding. It identifies the circuit a
+// setup, a vk.json or a manifest belongs to. Every stamp is computed here,
+// either from the constraint system in memory (ccsHash) or from the ccs.bin
+// it was saved to (setupCircuitHash); saveCCS writes exactly the bytes ccsHash
+// hashes, so the two agree. CircuitHash is the value for the vw0w1 circuit
+// this binary compiles. gnark records no source locations in the constraint
+// system unless built with its debug tag, so only a change to the circuit
+// itself (or to gnark's encoding) changes the hash.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/consensys/gnark/constraint"
+)
+
+// ErrCircuitHashMismatch is returned (wrapped) when two artifacts of one
+// setup name different circuits.
+var ErrCircuitHashMismatch = errors.New("circuit hash mismatch")
+
+// ccsHash returns the circuit hash of ccs: the hex sha256 of its ccs.bin
+// encoding.
+func ccsHash(ccs constraint.ConstraintSystem) (string, error) {
+	h := sha256.New()
+	if _, err := ccs.WriteTo(h); err != nil {
+		return "", fmt.Errorf("serialize ccs: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// setupCircuitHash returns the circuit hash of the ccs.bin in dir without
+// deserializing it.
+func setupCircuitHash(dir string) (string, error) {
+	sum, err := fileHash(filepath.Join(dir, "ccs.bin"))
+	if err != nil {
+		return "", fmt.Errorf("hash ccs.bin: %w", err)
+	}
+	return sum, nil
+}
+
+// memoHash holds a circuit hash computed at most once.
+type memoHash struct {
+	once sync.Once
+	hash string
+	err  error
+}
+
+var vw0w1CircuitHash memoHash
+
+// CircuitHash compiles the vw0w1 circuit and returns its circuit hash. The
+// compilation runs once per process.
+func CircuitHash() (string, error) {
+	c := &vw0w1CircuitHash
+	c.once.Do(func() {
+		ccs, err := CompileVW0W1Circuit()
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.hash, c.err = ccsHash(ccs)
+	})
+	return c.hash, c.err
+}
+
+// circuitHash returns the circuit hash of h.CCS. Serializing the constraint
+// system is not cheap, so it runs once per handle rather than once per proof.
+func (h *SetupHandle) circuitHash() (string, error) {
+	c := &h.hash
+	c.once.Do(func() { c.hash, c.err = ccsHash(h.CCS) })
+	return c.hash, c.err
+}
+
+// checkCircuitHash compares the circuit hash an artifact records with want.
+// An artifact that records none (written before circuit hashes existed)
+// passes.
+func checkCircuitHash(artifact, got, want string) error {
+	if got == "" || got == want {
+		return nil
+	}
+	return fmt.Errorf("%w: %s has circuitHash %s, expected %s", ErrCircuitHashMismatch, artifact, got, want)
+}
@@ -7,13 +7,25 @@ package main
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
 	"math/big"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 
 	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	groth16bls "github.com/consensys/gnark/backend/groth16/bls12-381"
 )
 
 func TestRun_NoArgs(t *testing.T) {
@@ -30,6 +42,105 @@ func TestRun_UnknownCommand(t *testing.T) {
 	if code != 2 {
 		t.Fatalf("want 2 got %d", code)
 	}
+	if strings.Contains(err.String(), "did you mean") {
+		t.Fatalf("no command is close to wat: %q", err.String())
+	}
+
+	for arg, want := range map[string]string{
+		"verfiy":      "did you mean verify?",
+		"prov":        "did you mean prove or prove-batch?",
+		"cicuit-hash": "did you mean circuit-hash?",
+	} {
+		err.Reset()
+		if code := run([]string{arg}, &out, &err); code != 2 {
+			t.Fatalf("%s: want 2 got %d", arg, code)
+		}
+		if !strings.Contains(err.String(), `unknown command "`+arg+`"`) || !strings.Contains(err.String(), want) {
+			t.Fatalf("%s: unexpected stderr: %q", arg, err.String())
+		}
+	}
+
+	err.Reset()
+	if code := run([]string{"ceremony", "contrib"}, &out, &err); code != 2 || !strings.Contains(err.String(), "did you mean contribute?") {
+		t.Fatalf("ceremony contrib: code %d stderr=%q", code, err.String())
+	}
+}
+
+func TestRun_Help(t *testing.T) {
+	for _, arg := range []string{"help", "-h", "--help"} {
+		var out, errBuf bytes.Buffer
+		if code := run([]string{arg}, &out, &errBuf); code != 0 {
+			t.Fatalf("%s: want 0 got %d", arg, code)
+		}
+		for _, c := range commands {
+			if !strings.Contains(out.String(), "  "+commandLabel(c)+" ") {
+				t.Fatalf("%s: list lacks %s:\n%s", arg, c.Name, out.String())
+			}
+		}
+	}
+
+	// Every command prints its flags without doing any work.
+	for _, c := range commands {
+		var out, errBuf bytes.Buffer
+		if code := run([]string{"help", c.Name}, &out, &errBuf); code != 0 {
+			t.Fatalf("help %s: want 0 got %d stderr=%q", c.Name, code, errBuf.String())
+		}
+		if !strings.HasPrefix(out.String(), "snark "+c.Name+": "+c.Summary) {
+			t.Fatalf("help %s: unexpected output:\n%s", c.Name, out.String())
+		}
+	}
+	var out, errBuf bytes.Buffer
+	run([]string{"help", "verify-keys"}, &out, &errBuf)
+	if !strings.Contains(out.String(), "-dir string") {
+		t.Fatalf("help verify-keys lacks its flags:\n%s", out.String())
+	}
+	if code := run([]string{"help", "verfiy"}, &out, &errBuf); code != 2 || !strings.Contains(errBuf.String(), "did you mean verify?") {
+		t.Fatalf("help verfiy: code %d stderr=%q", code, errBuf.String())
+	}
+}
+
+// TestCommands_MatchRun checks the registry against the case labels of the
+// switch in run, so a subcommand added there must be registered too.
+func TestCommands_MatchRun(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "run" {
+			continue
+		}
+		for _, stmt := range fn.Body.List {
+			sw, ok := stmt.(*ast.SwitchStmt)
+			if !ok {
+				continue
+			}
+			for _, cc := range sw.Body.List {
+				for _, e := range cc.(*ast.CaseClause).List {
+					lit, ok := e.(*ast.BasicLit)
+					if !ok {
+						continue
+					}
+					name, err := strconv.Unquote(lit.Value)
+					if err != nil {
+						t.Fatal(err)
+					}
+					cases = append(cases, name)
+				}
+			}
+		}
+	}
+	registered := commandNames()
+	sort.Strings(cases)
+	sort.Strings(registered)
+	// help is handled before the switch.
+	want := append([]string{"help"}, cases...)
+	sort.Strings(want)
+	if strings.Join(want, ",") != strings.Join(registered, ",") {
+		t.Fatalf("run dispatches %v, registry has %v", want, registered)
+	}
 }
 
 func TestRun_Hash_MissingA(t *testing.T) {
@@ -73,6 +184,146 @@ func TestRun_Hash_Success(t *testing.T) {
 	}
 }
 
+func TestRun_Hash_MinEntropyBits(t *testing.T) {
+	g := g1Hex(mustG1Base(2))
+	secrets := filepath.Join(t.TempDir(), "secrets.json")
+	if err := os.WriteFile(secrets, []byte(`["0xffff", "2"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantErr  string
+	}{
+		{"at threshold", []string{"hash", "-a", "4", "-min-entropy-bits", "3"}, 0, ""},
+		{"one bit short", []string{"hash", "-a", "3", "-min-entropy-bits", "3"}, 2, "pass -allow-weak"},
+		{"allowed", []string{"hash", "-a", "3", "-min-entropy-bits", "3", "-allow-weak"}, 0, "warning:"},
+		{"reduced into Fr", []string{"hash", "-a", new(big.Int).Add(fr.Modulus(), big.NewInt(1)).String(), "-min-entropy-bits", "2"}, 2, "1 bits after reduction"},
+		{"out of range", []string{"hash", "-a", "3", "-min-entropy-bits", "256"}, 2, "-min-entropy-bits must be in [0, 255]"},
+		{"file entry", []string{"hash", "-file", secrets, "-min-entropy-bits", "16"}, 2, "entry 1: "},
+		{"prove stops before loading anything", []string{"prove", "-a", "2", "-r", "5", "-v", g, "-w0", g, "-w1", g, "-min-entropy-bits", "128"}, 2, "a has 2 bits"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out, errBuf bytes.Buffer
+			code := run(tc.args, &out, &errBuf)
+			if code != tc.wantCode {
+				t.Fatalf("want %d got %d stderr=%q", tc.wantCode, code, errBuf.String())
+			}
+			if !strings.Contains(errBuf.String(), tc.wantErr) {
+				t.Fatalf("stderr %q does not contain %q", errBuf.String(), tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun_Hash_File(t *testing.T) {
+	want1, _, e := gtToHash(big.NewInt(12345))
+	if e != nil {
+		t.Fatalf("gtToHash: %v", e)
+	}
+	want2, _, e := gtToHash(big.NewInt(0xff))
+	if e != nil {
+		t.Fatalf("gtToHash: %v", e)
+	}
+
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := os.WriteFile(path, []byte(`["12345", "0", "0xff", "nope"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"hash", "-file", path}, &out, &errBuf)
+	if code != 1 {
+		t.Fatalf("want 1 got %d stderr=%q", code, errBuf.String())
+	}
+
+	var got []HashResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("stdout is not a JSON array: %v (%q)", err, out.String())
+	}
+	if len(got) != 4 {
+		t.Fatalf("want 4 results, got %d", len(got))
+	}
+	if got[0].A != "12345" || got[0].Hash != want1 || got[2].Hash != want2 {
+		t.Fatalf("unexpected results: %+v", got)
+	}
+	if got[1].Hash != "" || got[1].Error == "" || got[3].Error == "" {
+		t.Fatalf("expected errors for entries 1 and 3: %+v", got)
+	}
+	if !strings.Contains(errBuf.String(), "entry 1:") || !strings.Contains(errBuf.String(), "entry 3:") {
+		t.Fatalf("stderr should name failing indices: %q", errBuf.String())
+	}
+}
+
+func TestRun_Hash_FileDedupe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := os.WriteFile(path, []byte(`["12345", "0xff", "12345", "0x3039"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"hash", "-file", path, "-dedupe"}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	var got []HashResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("stdout is not a JSON array: %v (%q)", err, out.String())
+	}
+	if len(got) != 4 || got[2].A != "12345" || got[3].A != "0x3039" || got[0].Hash != got[2].Hash || got[0].Hash != got[3].Hash || got[1].Hash == got[0].Hash {
+		t.Fatalf("unexpected results: %+v", got)
+	}
+	if !strings.Contains(errBuf.String(), "dedupe: 2 of 4 entries reused a cached hash") {
+		t.Fatalf("stderr should report the cache hits: %q", errBuf.String())
+	}
+
+	if code := run([]string{"hash", "-a", "1", "-dedupe"}, &out, &errBuf); code != 2 {
+		t.Fatalf("-dedupe without -file: want 2 got %d", code)
+	}
+}
+
+func TestRun_Hash_EmitKappa(t *testing.T) {
+	a := big.NewInt(12345)
+	h0, err := parseG2CompressedHex(H0Hex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kappa, err := bls12381.Pair([]bls12381.G1Affine{g1MulBase(a)}, []bls12381.G2Affine{h0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHK, _, err := gtToHash(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "kappa.bin")
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"hash", "-a", "12345", "-emit-kappa", path}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != wantHK {
+		t.Fatalf("hash mismatch got=%q want=%q", got, wantHK)
+	}
+	got := mustReadFile(t, path)
+	if len(got) != 576 || !bytes.Equal(got, fq12CanonicalBytes(kappa)) {
+		t.Fatalf("kappa file (%d bytes) does not match fq12CanonicalBytes(kappa)", len(got))
+	}
+
+	if code := run([]string{"hash", "-file", "x.json", "-emit-kappa", path}, &out, &errBuf); code != 2 {
+		t.Fatalf("-emit-kappa with -file: want 2 got %d", code)
+	}
+}
+
+func TestRun_Hash_FileAndA(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"hash", "-a", "1", "-file", "x.json"}, &out, &errBuf)
+	if code != 2 {
+		t.Fatalf("want 2 got %d", code)
+	}
+}
+
 func TestRun_Decrypt_MissingArgs(t *testing.T) {
 	var out, err bytes.Buffer
 	code := run([]string{"decrypt", "-g1b", "00"}, &out, &err)
@@ -127,6 +378,146 @@ func TestRun_Decrypt_Success_Ctor2(t *testing.T) {
 	}
 }
 
+func TestRun_Decrypt_AcceptsPrefixedHex(t *testing.T) {
+	g1b := g1Hex(mustG1Base(11))
+	r1 := g1Hex(mustG1Base(13))
+	shared := g2Hex(mustG2Base(17))
+	g2b := g2Hex(mustG2Base(19))
+
+	want, e := DecryptToHash(g1b, g2b, r1, shared)
+	if e != nil {
+		t.Fatalf("DecryptToHash: %v", e)
+	}
+
+	var out, err bytes.Buffer
+	code := run([]string{"decrypt",
+		"-g1b", "0x" + g1b,
+		"-g2b", "0X" + g2b,
+		"-r1", "0x" + r1,
+		"-shared", "0x" + shared,
+	}, &out, &err)
+	if code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, err.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Fatalf("decrypt mismatch got=%q want=%q", got, want)
+	}
+}
+
+func TestRun_Decrypt_AcceptsMixedCaseHex(t *testing.T) {
+	g1b := g1Hex(mustG1Base(3))
+	r1 := g1Hex(mustG1Base(5))
+	shared := g2Hex(mustG2Base(7))
+
+	want, e := DecryptToHash(g1b, "", r1, shared)
+	if e != nil {
+		t.Fatalf("DecryptToHash: %v", e)
+	}
+
+	// Uppercase every other character so both cases appear.
+	mixed := func(s string) string {
+		b := []byte(s)
+		for i := 0; i < len(b); i += 2 {
+			b[i] = strings.ToUpper(string(b[i]))[0]
+		}
+		return string(b)
+	}
+
+	var out, err bytes.Buffer
+	code := run([]string{"decrypt", "-g1b", mixed(g1b), "-r1", "0x" + strings.ToUpper(r1), "-shared", mixed(shared)}, &out, &err)
+	if code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, err.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Fatalf("decrypt mismatch got=%q want=%q", got, want)
+	}
+}
+
+func TestRun_Decrypt_Entry(t *testing.T) {
+	r1 := g1Hex(mustG1Base(5))
+	g1b := g1Hex(mustG1Base(3))
+	shared := g2Hex(mustG2Base(7))
+
+	want, e := DecryptToHash(g1b, "", r1, shared)
+	if e != nil {
+		t.Fatalf("DecryptToHash: %v", e)
+	}
+
+	entry := filepath.Join(t.TempDir(), "half-level.json")
+	datum := `{"constructor":0,"fields":[{"bytes":"` + r1 + `"},{"bytes":"` + g1b + `"},{"bytes":"` + r1 + `"}]}`
+	if e := os.WriteFile(entry, []byte(datum), 0o644); e != nil {
+		t.Fatalf("write entry: %v", e)
+	}
+
+	var out, err bytes.Buffer
+	code := run([]string{"decrypt", "-entry", entry, "-shared", shared}, &out, &err)
+	if code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, err.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Fatalf("decrypt mismatch got=%q want=%q", got, want)
+	}
+
+	out.Reset()
+	err.Reset()
+	if code := run([]string{"decrypt", "-entry", entry, "-g1b", g1b, "-shared", shared}, &out, &err); code != 2 {
+		t.Fatalf("want 2 for -entry with -g1b, got %d", code)
+	}
+}
+
+func TestRun_DecryptChain(t *testing.T) {
+	entries, raw := chainTestEntries(t)
+	sharedInit := g2Hex(mustG2Base(41))
+
+	want, e := DecryptChain(sharedInit, entries)
+	if e != nil {
+		t.Fatalf("DecryptChain: %v", e)
+	}
+
+	path := filepath.Join(t.TempDir(), "entries.json")
+	if e := os.WriteFile(path, []byte(raw), 0o644); e != nil {
+		t.Fatalf("write entries: %v", e)
+	}
+
+	var out, err bytes.Buffer
+	code := run([]string{"decrypt-chain", "-shared-init", "0x" + sharedInit, "-entries", path}, &out, &err)
+	if code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, err.String())
+	}
+	if got := strings.Fields(out.String()); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("keys mismatch got=%v want=%v", got, want)
+	}
+
+	if code := run([]string{"decrypt-chain", "-entries", path}, &out, &err); code != 2 {
+		t.Fatalf("want 2 without -shared-init, got %d", code)
+	}
+
+	err.Reset()
+	if code := run([]string{"decrypt-chain", "-shared-init", sharedInit, "-entries", path, "-levels", "2"}, &out, &err); code != 1 {
+		t.Fatalf("want 1 for a 3-level chain declared as 2, got %d", code)
+	}
+	if !strings.Contains(err.String(), "chain has 3 levels, declared 2") {
+		t.Fatalf("unexpected stderr: %q", err.String())
+	}
+}
+
+func TestNormalizeHex(t *testing.T) {
+	cases := map[string]string{
+		"":        "",
+		"abcd":    "abcd",
+		"0xABcd":  "abcd",
+		"0XAB":    "ab",
+		" 0xab\n": "ab",
+		"0":       "0",
+		"x0ab":    "x0ab",
+	}
+	for in, want := range cases {
+		if got := normalizeHex(in); got != want {
+			t.Errorf("normalizeHex(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
 func TestRun_Prove_MissingArgs(t *testing.T) {
 	var out, err bytes.Buffer
 	code := run([]string{"prove", "-a", "1"}, &out, &err)
@@ -175,6 +566,65 @@ func TestRun_Prove_Success_WritesArtifacts(t *testing.T) {
 	}
 }
 
+// setStdin makes the CLI read in as its stdin for the rest of the test.
+func setStdin(t *testing.T, in string) {
+	t.Helper()
+	old := stdin
+	stdin = strings.NewReader(in)
+	t.Cleanup(func() { stdin = old })
+}
+
+func TestRun_Prove_SecretsStdin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip expensive proof generation in -short")
+	}
+	vHex, w0Hex, w1Hex := computeVW0W1_local(t, big.NewInt(11111), big.NewInt(22222))
+	setStdin(t, "11111\n0x56ce\n")
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"prove", "-secrets-stdin", "-v", vHex, "-w0", w0Hex, "-w1", w1Hex, "-no-export"}, &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if !strings.Contains(out.String(), "SUCCESS: proof verified") {
+		t.Fatalf("unexpected stdout: %q", out.String())
+	}
+}
+
+func TestRun_Prove_SecretsStdin_Errors(t *testing.T) {
+	g := g1Hex(mustG1Base(1))
+	cases := []struct {
+		name  string
+		stdin string
+		extra []string
+		want  string
+	}{
+		{"with -a", "3\n5\n", []string{"-a", "3"}, "cannot be combined with -a or -r"},
+		{"empty", "", nil, "stdin is empty"},
+		{"one line", "3\n", nil, "expected 2 lines on stdin (a, then r), got 1"},
+		{"three lines", "3\n5\n7\n", nil, "got 3"},
+		{"blank a", "  \r\n5\r\n", nil, "stdin line 1 (a) is empty"},
+		{"oversized", strings.Repeat("1", maxSecretsInput) + "\n5\n", nil, "longer than"},
+		{"bad a", "x\n5\n", nil, "could not parse a (stdin line 1)"},
+		{"zero a", "0\n5\n", nil, "could not parse a (stdin line 1)"},
+		{"bad r", "3\n-1\n", nil, "r (stdin line 2) must be in [1, q)"},
+		{"zero r", "3\n0\n", nil, "r (stdin line 2) must be in [1, q)"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setStdin(t, tc.stdin)
+			args := append([]string{"prove", "-secrets-stdin", "-v", g, "-w0", g, "-w1", g}, tc.extra...)
+			var out, errBuf bytes.Buffer
+			if code := run(args, &out, &errBuf); code != 2 {
+				t.Fatalf("want 2 got %d stderr=%q", code, errBuf.String())
+			}
+			if !strings.Contains(errBuf.String(), tc.want) {
+				t.Fatalf("stderr %q does not mention %q", errBuf.String(), tc.want)
+			}
+		})
+	}
+}
+
 // ---- local deterministic point helpers ----
 
 func mustG1Base(k int64) bls12381.G1Affine {
@@ -258,6 +708,126 @@ func TestRun_Setup_SkipsExisting(t *testing.T) {
 	}
 }
 
+func TestRun_PKSplit_Negative(t *testing.T) {
+	for _, args := range [][]string{
+		{"setup", "-out", t.TempDir(), "-pk-split", "-1"},
+		{"ceremony", "finalize", "-dir", t.TempDir(), "-phase", "2", "-beacon", "00", "-pk-split", "-1"},
+		{"ceremony", "export-keys", "-dir", t.TempDir(), "-pk-split", "-1"},
+	} {
+		var out, errBuf bytes.Buffer
+		if code := run(args, &out, &errBuf); code != 2 {
+			t.Fatalf("%v: want 2 got %d stderr=%q", args, code, errBuf.String())
+		}
+		if !strings.Contains(errBuf.String(), "-pk-split must be >= 0") {
+			t.Fatalf("%v: unexpected stderr: %q", args, errBuf.String())
+		}
+	}
+}
+
+func TestRun_Verify_ExternalVK(t *testing.T) {
+	canonDir := saveTinySetup(t, &commitCircuit{})
+	staleDir := saveTinySetup(t, &commitCircuit{})
+	h, err := OpenSetup(canonDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := OpenSetup(staleDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	if err := SaveNativeFiles(stale.VK, proof, publicWitness, out); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, errBuf bytes.Buffer
+	if code := run([]string{"verify", "-out", out}, &stdout, &errBuf); code != 1 {
+		t.Fatalf("colocated stale vk: want 1 got %d", code)
+	}
+	stdout.Reset()
+	errBuf.Reset()
+	if code := run([]string{"verify", "-out", out, "-vk", filepath.Join(canonDir, "vk.bin")}, &stdout, &errBuf); code != 0 {
+		t.Fatalf("-vk: want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if !strings.Contains(stdout.String(), "SUCCESS: proof verified") {
+		t.Fatalf("unexpected stdout: %q", stdout.String())
+	}
+}
+
+func TestRun_Verify_StrictJSONDefault(t *testing.T) {
+	h, err := OpenSetup(saveTinySetup(t, &commitCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ExportAllWithOptions(h.VK, proof, publicWitness, dir, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	vkPath := filepath.Join(dir, "vk.json")
+	vk := mustReadFile(t, vkPath)
+	extended := append([]byte(`{"comment":"extra",`), bytes.TrimPrefix(bytes.TrimSpace(vk), []byte("{"))...)
+	if err := os.WriteFile(vkPath, extended, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"verify", "-json", "-out", dir}, &out, &errBuf); code != 1 {
+		t.Fatalf("strict by default: want 1 got %d", code)
+	}
+	if !strings.Contains(errBuf.String(), `unknown field "comment"`) {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+	out.Reset()
+	errBuf.Reset()
+	if code := run([]string{"verify", "-json", "-strict-json=false", "-out", dir}, &out, &errBuf); code != 0 {
+		t.Fatalf("-strict-json=false: want 0 got %d stderr=%q", code, errBuf.String())
+	}
+}
+
+func TestRun_Verify_EachContinueOnError(t *testing.T) {
+	parent := writeMixedProofDirs(t)
+
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"verify", "-each", parent, "-continue-on-error"}, &out, &errBuf); code != 1 {
+		t.Fatalf("want 1 got %d", code)
+	}
+	for _, line := range []string{"a_ok OK", "b_bad FAIL: ", "c_ok OK", "d_bad FAIL: "} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("missing %q in stdout=%q", line, out.String())
+		}
+	}
+	if !strings.Contains(errBuf.String(), "2 of 4") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+
+	out.Reset()
+	errBuf.Reset()
+	if code := run([]string{"verify", "-each", parent}, &out, &errBuf); code != 1 {
+		t.Fatalf("stop at first failure: want 1 got %d", code)
+	}
+	if strings.Contains(out.String(), "c_ok") {
+		t.Fatalf("verification continued past b_bad: %q", out.String())
+	}
+
+	for _, args := range [][]string{
+		{"verify", "-continue-on-error"},
+		{"verify", "-each", parent, "-expect-wire", "1"},
+	} {
+		errBuf.Reset()
+		if code := run(args, &out, &errBuf); code != 2 {
+			t.Fatalf("%v: want 2 got %d", args, code)
+		}
+	}
+}
+
 func TestRun_Verify_MissingFiles(t *testing.T) {
 	tmp := t.TempDir()
 	var out, errBuf bytes.Buffer
@@ -302,27 +872,59 @@ func TestRun_Prove_BadR(t *testing.T) {
 	}
 }
 
-func TestRun_Prove_SetupDirMissing(t *testing.T) {
-	tmp := t.TempDir()
+func TestRun_Prove_RAtModulus(t *testing.T) {
 	var out, errBuf bytes.Buffer
 	code := run([]string{"prove",
-		"-a", "123", "-r", "0",
+		"-a", "123", "-r", fr.Modulus().String(),
 		"-v", strings.Repeat("a", 96),
 		"-w0", strings.Repeat("a", 96),
 		"-w1", strings.Repeat("a", 96),
-		"-setup", filepath.Join(tmp, "noexist"),
 	}, &out, &errBuf)
 	if code != 2 {
 		t.Fatalf("want 2 got %d stderr=%q", code, errBuf.String())
 	}
+	if !strings.Contains(errBuf.String(), "-r must be in") {
+		t.Fatalf("expected range error, got %q", errBuf.String())
+	}
 }
 
-func TestRun_Decrypt_BadHex(t *testing.T) {
+func TestRun_Prove_RZero(t *testing.T) {
 	var out, errBuf bytes.Buffer
-	code := run([]string{"decrypt",
-		"-g1b", "zzzz",
-		"-r1", "zzzz",
-		"-shared", "zzzz",
+	code := run([]string{"prove",
+		"-a", "123", "-r", "0",
+		"-v", strings.Repeat("a", 96),
+		"-w0", strings.Repeat("a", 96),
+		"-w1", strings.Repeat("a", 96),
+	}, &out, &errBuf)
+	if code != 2 {
+		t.Fatalf("want 2 got %d stderr=%q", code, errBuf.String())
+	}
+	if !strings.Contains(errBuf.String(), "-r must be in [1, q)") {
+		t.Fatalf("expected range error, got %q", errBuf.String())
+	}
+}
+
+func TestRun_Prove_SetupDirMissing(t *testing.T) {
+	tmp := t.TempDir()
+	var out, errBuf bytes.Buffer
+	code := run([]string{"prove",
+		"-a", "123", "-r", "0",
+		"-v", strings.Repeat("a", 96),
+		"-w0", strings.Repeat("a", 96),
+		"-w1", strings.Repeat("a", 96),
+		"-setup", filepath.Join(tmp, "noexist"),
+	}, &out, &errBuf)
+	if code != 2 {
+		t.Fatalf("want 2 got %d stderr=%q", code, errBuf.String())
+	}
+}
+
+func TestRun_Decrypt_BadHex(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"decrypt",
+		"-g1b", "zzzz",
+		"-r1", "zzzz",
+		"-shared", "zzzz",
 	}, &out, &errBuf)
 	if code != 1 {
 		t.Fatalf("want 1 got %d stderr=%q", code, errBuf.String())
@@ -435,3 +1037,1076 @@ func TestRun_Ceremony_Finalize_BadBeaconHex(t *testing.T) {
 		t.Fatalf("unexpected stderr: %q", errBuf.String())
 	}
 }
+
+// ---------- prove -count dispatch tests ----------
+
+func TestRun_Prove_CountRequiresSetup(t *testing.T) {
+	vHex, w0Hex, w1Hex := computeVW0W1_local(t, big.NewInt(5), big.NewInt(6))
+	var out, errBuf bytes.Buffer
+	code := run([]string{"prove", "-a", "5", "-r", "6", "-v", vHex, "-w0", w0Hex, "-w1", w1Hex, "-count", "3"}, &out, &errBuf)
+	if code != 2 {
+		t.Fatalf("want 2 got %d", code)
+	}
+	if !strings.Contains(errBuf.String(), "-count requires -setup") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
+func TestRun_Prove_CountNeedsNoW0W1(t *testing.T) {
+	vHex, _, _ := computeVW0W1_local(t, big.NewInt(5), big.NewInt(6))
+	var out, errBuf bytes.Buffer
+	code := run([]string{"prove", "-a", "5", "-r", "6", "-v", vHex, "-count", "3"}, &out, &errBuf)
+	if code != 2 {
+		t.Fatalf("want 2 got %d", code)
+	}
+	if strings.Contains(errBuf.String(), "is required") || !strings.Contains(errBuf.String(), "-count requires -setup") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
+func TestRun_Prove_CountMustBePositive(t *testing.T) {
+	vHex, w0Hex, w1Hex := computeVW0W1_local(t, big.NewInt(5), big.NewInt(6))
+	var out, errBuf bytes.Buffer
+	code := run([]string{"prove", "-a", "5", "-r", "6", "-v", vHex, "-w0", w0Hex, "-w1", w1Hex, "-count", "0"}, &out, &errBuf)
+	if code != 2 {
+		t.Fatalf("want 2 got %d", code)
+	}
+	if !strings.Contains(errBuf.String(), "-count must be >= 1") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
+func TestRun_Prove_PreflightReportsWrongA(t *testing.T) {
+	tmp := t.TempDir()
+	for _, name := range []string{"ccs.bin", "pk.bin", "vk.bin"} {
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte("dummy"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	vHex, w0Hex, w1Hex := computeVW0W1_local(t, big.NewInt(5), big.NewInt(6))
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"prove", "-a", "7", "-r", "6", "-v", vHex, "-w0", w0Hex, "-w1", w1Hex, "-setup", tmp, "-out", t.TempDir()}, &out, &errBuf)
+	if code != 1 {
+		t.Fatalf("want 1 got %d", code)
+	}
+	if !strings.Contains(errBuf.String(), "w0 mismatch") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
+func TestRun_Verify_ExpectWireMustBeDecimal(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"verify", "-out", t.TempDir(), "-expect-wire", "0xabc"}, &out, &errBuf)
+	if code != 2 {
+		t.Fatalf("want 2 got %d", code)
+	}
+	if !strings.Contains(errBuf.String(), "-expect-wire must be a decimal integer") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
+func TestRun_Verify_ExpectedICLen(t *testing.T) {
+	dir := filepath.Join("..", "out")
+	cases := []struct {
+		icLen    string
+		wantCode int
+		wantErr  string
+	}{
+		{"38", 0, ""},
+		{"37", 1, "vk has 38 IC points (nPublic=36 + 1 one-wire + nCommitments=1), expected 37"},
+		{"-1", 2, "-expected-ic-len must be >= 0"},
+	}
+	for _, tc := range cases {
+		var out, errBuf bytes.Buffer
+		code := run([]string{"verify", "-json", "-out", dir, "-expected-ic-len", tc.icLen}, &out, &errBuf)
+		if code != tc.wantCode {
+			t.Fatalf("%s: want %d got %d stderr=%q", tc.icLen, tc.wantCode, code, errBuf.String())
+		}
+		if !strings.Contains(errBuf.String(), tc.wantErr) {
+			t.Fatalf("%s: unexpected stderr: %q", tc.icLen, errBuf.String())
+		}
+	}
+}
+
+func TestRun_Verify_RequireCommitment(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"verify", "-json", "-out", filepath.Join("..", "out"), "-require-commitment"}, &out, &errBuf); code != 0 {
+		t.Fatalf("vw0w1 proof rejected: code %d stderr=%q", code, errBuf.String())
+	}
+
+	setupDir := saveTinySetup(t, &squareCircuit{})
+	h, err := OpenSetup(setupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&squareCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := SaveNativeFiles(h.VK, proof, publicWitness, dir); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	errBuf.Reset()
+	if code := run([]string{"verify", "-out", dir, "-require-commitment"}, &out, &errBuf); code != 1 {
+		t.Fatalf("want 1 got %d stderr=%q", code, errBuf.String())
+	}
+	if !strings.Contains(errBuf.String(), "proof has no commitment") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
+func TestRun_Verify_AcceptUncompressedVK(t *testing.T) {
+	h, err := OpenSetup(saveTinySetup(t, &squareCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&squareCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := SaveNativeFiles(h.VK, proof, publicWitness, dir); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(decimalVKOf(h.VK.(*groth16bls.VerifyingKey), Fp2OrderC1C0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vkPath := filepath.Join(t.TempDir(), "verification_key.json")
+	if err := os.WriteFile(vkPath, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"verify", "-out", dir, "-vk", vkPath}, &out, &errBuf); code != 1 {
+		t.Fatalf("want 1 got %d stderr=%q", code, errBuf.String())
+	}
+	if !strings.Contains(errBuf.String(), "-accept-uncompressed-vk") {
+		t.Fatalf("stderr should point at the flag: %q", errBuf.String())
+	}
+	out.Reset()
+	errBuf.Reset()
+	if code := run([]string{"verify", "-out", dir, "-vk", vkPath, "-accept-uncompressed-vk"}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if code := run([]string{"verify", "-out", dir, "-accept-uncompressed-vk"}, &out, &errBuf); code != 2 {
+		t.Fatalf("flag without -vk: want 2 got %d", code)
+	}
+}
+
+func TestRun_JSONErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantKind string
+	}{
+		{"missing arg", []string{"hash"}, 2, KindUsage},
+		{"bad scalar", []string{"hash", "-a", "nope"}, 2, KindBadScalar},
+		{"bad hex", []string{"decrypt", "-g1b", "zz", "-r1", "00", "-shared", "00"}, 1, KindBadHex},
+		{"missing file", []string{"verify", "-out", filepath.Join(t.TempDir(), "none")}, 1, KindMissingFile},
+		{"unknown flag", []string{"hash", "-x"}, 2, KindUsage},
+		{"zero r", []string{"prove", "-a", "3", "-r", "0", "-v", strings.Repeat("a", 96), "-w0", strings.Repeat("a", 96), "-w1", strings.Repeat("a", 96)}, 2, KindBadScalar},
+		{"bad point", []string{"reencode", "-type", "g1", "-in", strings.Repeat("f", 96), "-to", "uncompressed"}, 2, KindBadPoint},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out, errBuf bytes.Buffer
+			code := run(append([]string{"-json-errors"}, tc.args...), &out, &errBuf)
+			if code != tc.wantCode {
+				t.Fatalf("want %d got %d", tc.wantCode, code)
+			}
+			lines := strings.Split(strings.TrimSuffix(errBuf.String(), "\n"), "\n")
+			var got CLIError
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &got); err != nil {
+				t.Fatalf("last stderr line is not a JSON object: %v (%q)", err, errBuf.String())
+			}
+			for _, l := range lines[:len(lines)-1] {
+				if hasDiagnosticPrefix(strings.TrimSpace(l)) {
+					t.Fatalf("diagnostic line passed through: %q", l)
+				}
+			}
+			if got.Code != tc.wantCode || got.Kind != tc.wantKind || got.Error == "" {
+				t.Fatalf("unexpected error object: %+v", got)
+			}
+			if strings.HasPrefix(got.Error, "error:") || strings.HasPrefix(got.Error, "FAIL:") {
+				t.Fatalf("prefix not stripped: %q", got.Error)
+			}
+		})
+	}
+}
+
+// TestJSONErrorsStderr_StreamsLines checks that non-diagnostic lines reach
+// stderr as soon as they are complete, while diagnostics are held for the
+// final JSON object.
+func TestJSONErrorsStderr_StreamsLines(t *testing.T) {
+	var out bytes.Buffer
+	s := &jsonErrorsStderr{out: &out}
+
+	fmt.Fprint(s, "Loading setup")
+	if out.Len() != 0 {
+		t.Fatalf("partial line written early: %q", out.String())
+	}
+	fmt.Fprintln(s, "...")
+	if out.String() != "Loading setup...\n" {
+		t.Fatalf("line not streamed: %q", out.String())
+	}
+	printErr(s, "error:", errors.New("setup files not found in x"))
+	fmt.Fprintln(s, "       run 'snark setup -out x' first")
+	fmt.Fprintln(s, "warning: after the error")
+	if out.String() != "Loading setup...\nwarning: after the error\n" {
+		t.Fatalf("diagnostic lines not held back: %q", out.String())
+	}
+	fmt.Fprint(s, "done")
+
+	held := s.finish(1)
+	if got, want := diagnosticMessage(held), "setup files not found in x; run 'snark setup -out x' first"; got != want {
+		t.Fatalf("held diagnostics %q give %q, want %q", held, got, want)
+	}
+	if !strings.HasSuffix(out.String(), "done\n") || s.err == nil {
+		t.Fatalf("unterminated line or recorded error lost: %q, %v", out.String(), s.err)
+	}
+}
+
+func TestRun_JSONErrors_StreamsUsage(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"-json-errors", "hash"}, &out, &errBuf)
+	if code != 2 {
+		t.Fatalf("want 2 got %d", code)
+	}
+	usage := strings.Index(errBuf.String(), "Usage")
+	obj := strings.LastIndex(errBuf.String(), "{")
+	if usage < 0 || obj < usage {
+		t.Fatalf("usage text should precede the JSON object: %q", errBuf.String())
+	}
+}
+
+func TestRun_JSONErrors_PassesThroughOnSuccess(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"-json-errors", "hash", "-a", "12345"}, &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if strings.TrimSpace(out.String()) == "" || errBuf.Len() != 0 {
+		t.Fatalf("unexpected output: stdout=%q stderr=%q", out.String(), errBuf.String())
+	}
+}
+
+func TestClassifyCLIError_IgnoresMessageText(t *testing.T) {
+	cases := []struct {
+		err  error
+		code int
+		want string
+	}{
+		{errors.New("hex modulus not found"), 1, KindFailure},
+		{errors.New("-r must be in [1, q)"), 2, KindUsage},
+		{withKind(ErrBadScalar, errors.New("anything")), 2, KindBadScalar},
+		{fmt.Errorf("load: %w", withKind(ErrBadPoint, errors.New("x"))), 1, KindBadPoint},
+		{fmt.Errorf("read vk.bin: %w", fs.ErrNotExist), 1, KindMissingFile},
+		{fmt.Errorf("%w: %w", ErrVerificationFailed, errors.New("pairing")), 1, KindVerificationFailed},
+		{nil, 1, KindFailure},
+	}
+	for _, tc := range cases {
+		if got := classifyCLIError(tc.err, tc.code); got != tc.want {
+			t.Errorf("classifyCLIError(%v, %d) = %q, want %q", tc.err, tc.code, got, tc.want)
+		}
+	}
+}
+
+func TestDiagnosticMessage_JoinsContinuationLines(t *testing.T) {
+	got := diagnosticMessage("error: setup files not found in x\n       run 'snark setup -out x' first\n")
+	want := "setup files not found in x; run 'snark setup -out x' first"
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestRun_Decrypt_UnsafeSkipSubgroupCheck(t *testing.T) {
+	g1b := g1Hex(g1OutsideSubgroup(t))
+	r1 := g1Hex(mustG1Base(5))
+	shared := g2Hex(mustG2Base(7))
+	args := []string{"decrypt", "-g1b", g1b, "-r1", r1, "-shared", shared}
+
+	var out, err bytes.Buffer
+	if code := run(args, &out, &err); code != 1 {
+		t.Fatalf("default: want 1 got %d stderr=%q", code, err.String())
+	}
+	if !strings.Contains(err.String(), "parse g1b") || strings.Contains(err.String(), "WARNING") {
+		t.Fatalf("default: unexpected stderr: %q", err.String())
+	}
+
+	want, e := DecryptToHashWithOptions(g1b, "", r1, shared, DecryptOptions{UnsafeSkipSubgroupCheck: true})
+	if e != nil {
+		t.Fatalf("DecryptToHashWithOptions: %v", e)
+	}
+
+	out.Reset()
+	err.Reset()
+	args = append([]string{"decrypt", "-unsafe-skip-subgroup-check"}, args[1:]...)
+	if code := run(args, &out, &err); code != 0 {
+		t.Fatalf("skip: want 0 got %d stderr=%q", code, err.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Fatalf("decrypt mismatch got=%q want=%q", got, want)
+	}
+	if !strings.Contains(err.String(), "WARNING: -unsafe-skip-subgroup-check") {
+		t.Fatalf("missing warning on stderr: %q", err.String())
+	}
+}
+
+func TestRun_Prove_UnsafeSkipSubgroupCheckReachesParser(t *testing.T) {
+	v := g1Hex(g1OutsideSubgroup(t))
+	w := g1Hex(mustG1Base(9))
+	args := []string{"prove", "-a", "3", "-r", "5", "-v", v, "-w0", w, "-w1", w, "-out", t.TempDir()}
+
+	var out, err bytes.Buffer
+	if code := run(args, &out, &err); code != 1 {
+		t.Fatalf("default: want 1 got %d stderr=%q", code, err.String())
+	}
+	if !strings.Contains(err.String(), "invalid compressed G1 v") {
+		t.Fatalf("default: expected parser rejection of v, got %q", err.String())
+	}
+
+	// With the flag, v gets past the parser and the unchecked pre-flight
+	// reports the (deliberately wrong) w0 instead.
+	out.Reset()
+	err.Reset()
+	args = append([]string{"prove", "-unsafe-skip-subgroup-check"}, args[1:]...)
+	if code := run(args, &out, &err); code != 1 {
+		t.Fatalf("skip: want 1 got %d stderr=%q", code, err.String())
+	}
+	if !strings.Contains(err.String(), "WARNING: -unsafe-skip-subgroup-check") {
+		t.Fatalf("missing warning on stderr: %q", err.String())
+	}
+	if !strings.Contains(err.String(), "w0 mismatch") {
+		t.Fatalf("skip: expected pre-flight w0 mismatch, got %q", err.String())
+	}
+}
+
+func TestRun_Decrypt_DomainTag(t *testing.T) {
+	g1b := g1Hex(mustG1Base(3))
+	r1 := g1Hex(mustG1Base(5))
+	shared := g2Hex(mustG2Base(7))
+	v2 := hex.EncodeToString([]byte("F12|To|Hex|v2|"))
+
+	want, e := DecryptToHashWithOptions(g1b, "", r1, shared, DecryptOptions{DomainTagHex: v2})
+	if e != nil {
+		t.Fatalf("DecryptToHashWithOptions: %v", e)
+	}
+
+	var out, err bytes.Buffer
+	code := run([]string{"decrypt", "-g1b", g1b, "-r1", r1, "-shared", shared, "-domain-tag", "0x" + v2}, &out, &err)
+	if code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, err.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Fatalf("decrypt mismatch got=%q want=%q", got, want)
+	}
+}
+
+func TestRun_Prove_NoExportConflicts(t *testing.T) {
+	g := g1Hex(mustG1Base(2))
+	base := []string{"prove", "-a", "3", "-r", "5", "-v", g, "-w0", g, "-w1", g, "-no-export"}
+	for _, extra := range [][]string{{"-no-verify"}, {"-bundle"}, {"-bundle-only"}} {
+		var out, err bytes.Buffer
+		code := run(append(append([]string{}, base...), extra...), &out, &err)
+		if code != 2 {
+			t.Fatalf("%v: want 2 got %d stderr=%q", extra, code, err.String())
+		}
+		if !strings.Contains(err.String(), "-no-export cannot be combined") {
+			t.Fatalf("%v: unexpected stderr: %q", extra, err.String())
+		}
+	}
+}
+
+func TestRun_Prove_SetupURLConflicts(t *testing.T) {
+	g := g1Hex(mustG1Base(2))
+	base := []string{"prove", "-a", "3", "-r", "5", "-v", g, "-w0", g, "-w1", g}
+	urls := []string{"-ccs-url", "http://x/ccs.bin", "-pk-url", "http://x/pk.bin", "-vk-url", "http://x/vk.bin"}
+	cases := []struct {
+		extra []string
+		want  string
+	}{
+		{[]string{"-pk-url", "http://x/pk.bin"}, "must be given together"},
+		{append([]string{"-setup", "setup"}, urls...), "cannot be combined with -setup"},
+		{append([]string{"-mmap"}, urls...), "-mmap requires -setup"},
+		{append([]string{"-parallel-load"}, urls...), "-parallel-load requires -setup"},
+	}
+	for _, tc := range cases {
+		var out, err bytes.Buffer
+		code := run(append(append([]string{}, base...), tc.extra...), &out, &err)
+		if code != 2 {
+			t.Fatalf("%v: want 2 got %d stderr=%q", tc.extra, code, err.String())
+		}
+		if !strings.Contains(err.String(), tc.want) {
+			t.Fatalf("%v: unexpected stderr: %q", tc.extra, err.String())
+		}
+	}
+}
+
+func TestRun_Prove_VerifyAfterExportConflicts(t *testing.T) {
+	g := g1Hex(mustG1Base(2))
+	base := []string{"prove", "-a", "3", "-r", "5", "-v", g, "-w0", g, "-w1", g, "-verify-after-export"}
+	cases := []struct {
+		extra []string
+		want  string
+	}{
+		{[]string{"-no-export"}, "cannot be combined with -no-export"},
+		{[]string{"-output-format", "bin"}, "cannot be combined with -output-format bin"},
+	}
+	for _, tc := range cases {
+		var out, err bytes.Buffer
+		code := run(append(append([]string{}, base...), tc.extra...), &out, &err)
+		if code != 2 || !strings.Contains(err.String(), tc.want) {
+			t.Fatalf("%v: want 2 with %q, got %d stderr=%q", tc.extra, tc.want, code, err.String())
+		}
+	}
+}
+
+func TestRun_CommitmentWire(t *testing.T) {
+	dir := filepath.Join("..", "out")
+	raw, err := os.ReadFile(filepath.Join(dir, "public.json"))
+	if err != nil {
+		t.Skipf("no proof artifacts in ../out: %v", err)
+	}
+	var pubj PublicJSON
+	if err := json.Unmarshal(raw, &pubj); err != nil {
+		t.Fatal(err)
+	}
+	if pubj.CommitmentWire == "" {
+		t.Skip("../out/public.json has no commitmentWire")
+	}
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"commitment-wire", "-proof", filepath.Join(dir, "proof.json"), "-public", filepath.Join(dir, "public.json")}, &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != pubj.CommitmentWire {
+		t.Fatalf("wire %s != recorded %s", got, pubj.CommitmentWire)
+	}
+
+	errBuf.Reset()
+	if code := run([]string{"commitment-wire", "-proof", filepath.Join(dir, "proof.json")}, &out, &errBuf); code != 2 {
+		t.Fatalf("missing -public: want 2 got %d", code)
+	}
+	errBuf.Reset()
+	code = run([]string{"commitment-wire", "-proof", filepath.Join(dir, "proof.json"), "-public", filepath.Join(dir, "public.json"), "-committed", "x"}, &out, &errBuf)
+	if code != 2 || !strings.Contains(errBuf.String(), "-committed") {
+		t.Fatalf("bad -committed: want 2 got %d stderr=%q", code, errBuf.String())
+	}
+}
+
+func TestRun_Reduce(t *testing.T) {
+	q := fr.Modulus()
+	aStr := new(big.Int).Add(q, big.NewInt(5)).String()
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"reduce", "-a", aStr, "-r", "0x10"}, &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	want := "a: 5\na hex: " + strings.Repeat("00", 31) + "05\nr: 16\nr hex: " + strings.Repeat("00", 31) + "10\n"
+	if out.String() != want {
+		t.Fatalf("stdout:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if !strings.Contains(errBuf.String(), "note: a is outside") || strings.Contains(errBuf.String(), "note: r") {
+		t.Fatalf("unexpected notes: %q", errBuf.String())
+	}
+
+	out.Reset()
+	errBuf.Reset()
+	if code := run([]string{"reduce", "-a", "nope"}, &out, &errBuf); code != 2 {
+		t.Fatalf("bad -a: want 2 got %d", code)
+	}
+	if code := run([]string{"reduce"}, &out, &errBuf); code != 2 {
+		t.Fatalf("missing -a: want 2 got %d", code)
+	}
+}
+
+func TestRun_Reduce_WASMLog(t *testing.T) {
+	q := fr.Modulus()
+	aStr := new(big.Int).Add(new(big.Int).Lsh(q, 1), big.NewInt(9)).String()
+
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"reduce", "-wasm-log", "-a", aStr, "-r", "-1"}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	want := "[WASM] wasmProve: reduced a = 9, r = " + new(big.Int).Sub(q, big.NewInt(1)).String() + "\n"
+	if out.String() != want {
+		t.Fatalf("stdout %q, want %q", out.String(), want)
+	}
+	if code := run([]string{"reduce", "-wasm-log", "-a", "1"}, &out, &errBuf); code != 2 {
+		t.Fatalf("-wasm-log without -r: want 2 got %d", code)
+	}
+}
+
+func TestRun_Prove_OutputFormatFlag(t *testing.T) {
+	g := g1Hex(mustG1Base(2))
+	base := []string{"prove", "-a", "3", "-r", "5", "-v", g, "-w0", g, "-w1", g}
+	cases := []struct {
+		extra []string
+		want  string
+	}{
+		{[]string{"-output-format", "xml"}, "unknown output format"},
+		{[]string{"-output-format", "bin", "-bundle"}, "cannot be combined with -bundle"},
+	}
+	for _, tc := range cases {
+		var out, err bytes.Buffer
+		code := run(append(append([]string{}, base...), tc.extra...), &out, &err)
+		if code != 2 {
+			t.Fatalf("%v: want 2 got %d stderr=%q", tc.extra, code, err.String())
+		}
+		if !strings.Contains(err.String(), tc.want) {
+			t.Fatalf("%v: unexpected stderr: %q", tc.extra, err.String())
+		}
+	}
+}
+
+func TestRun_Verify_CanonicalUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"verify", "-canonical", "-v", "aa"},
+		{"verify", "-v", "aa"},
+	} {
+		var out, err bytes.Buffer
+		if code := run(args, &out, &err); code != 2 {
+			t.Fatalf("%v: want 2 got %d stderr=%q", args, code, err.String())
+		}
+	}
+}
+
+func TestRun_CCSInfo(t *testing.T) {
+	dir := saveTinySetup(t, &commitCircuit{})
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"ccs-info", "-setup", dir}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	var s CCSSummary
+	if err := json.Unmarshal(out.Bytes(), &s); err != nil {
+		t.Fatalf("decode %q: %v", out.String(), err)
+	}
+	if s.Curve != "bls12381" || s.PublicVariables != 2 || len(s.Commitments) != 1 {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+	if c := s.Commitments[0]; len(c.PublicCommitted) != 1 || c.PublicCommitted[0] != 1 || c.PrivateCommitted == 0 || c.Wire == 0 {
+		t.Fatalf("unexpected commitment metadata: %+v", c)
+	}
+
+	errBuf.Reset()
+	if code := run([]string{"ccs-info", "-setup", t.TempDir()}, &out, &errBuf); code != 1 {
+		t.Fatalf("missing ccs.bin: want 1 got %d", code)
+	}
+	errBuf.Reset()
+	code := run([]string{"commitment-wire", "-proof", "p", "-public", "q", "-committed", "1", "-ccs", "c"}, &out, &errBuf)
+	if code != 2 || !strings.Contains(errBuf.String(), "mutually exclusive") {
+		t.Fatalf("-committed with -ccs: want 2 got %d stderr=%q", code, errBuf.String())
+	}
+}
+
+func TestRun_VerifyOnly(t *testing.T) {
+	dir := saveTinySetup(t, &commitCircuit{})
+	ext := wasmResultFixture(t, dir)
+	write := func(name string, v interface{}) string {
+		t.Helper()
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	badWire := ext
+	badWire.Public.CommitmentWire = "1"
+	noWire := ext
+	noWire.Public.CommitmentWire = ""
+	otherSetup := saveTinySetup(t, &commitCircuit{})
+
+	cases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"result", []string{"-setup", dir, "-result", write("result.json", ext)}, 0},
+		{"separate files", []string{"-setup", dir, "-proof", write("proof.json", ext.Proof), "-public", write("public.json", ext.Public)}, 0},
+		{"wrong wire", []string{"-setup", dir, "-result", write("result.json", badWire)}, 3},
+		{"missing wire", []string{"-setup", dir, "-result", write("result.json", noWire)}, 1},
+		{"other setup", []string{"-setup", otherSetup, "-result", write("result.json", ext)}, 1},
+		{"no setup", []string{"-result", write("result.json", ext)}, 2},
+		{"both inputs", []string{"-setup", dir, "-result", "r.json", "-proof", "p.json"}, 2},
+		{"public only", []string{"-setup", dir, "-public", "p.json"}, 2},
+		{"bad leading wire", []string{"-setup", dir, "-result", "r.json", "-leading-wire", "maybe"}, 2},
+		{"declared without the one-wire", []string{"-setup", dir, "-result", write("result.json", ext), "-leading-wire", "exclude"}, 1},
+	}
+	for _, tc := range cases {
+		var out, errBuf bytes.Buffer
+		if code := run(append([]string{"verify-only"}, tc.args...), &out, &errBuf); code != tc.want {
+			t.Fatalf("%s: want %d got %d stdout=%q stderr=%q", tc.name, tc.want, code, out.String(), errBuf.String())
+		}
+	}
+}
+
+func TestRun_Blake2b224(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"blake2b224", "-hex", "0xDEADBEEF", "-with-domain-tag"}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != "8daf85a2ba90c670b991b93eea13afdb5823572f506d95b09529903b" {
+		t.Fatalf("unexpected digest %s", got)
+	}
+	if code := run([]string{"blake2b224", "-hex", "abc"}, &out, &errBuf); code != 2 {
+		t.Fatalf("odd-length hex: want 2 got %d", code)
+	}
+
+	out.Reset()
+	if code := run([]string{"blake2b224", "-hex", "deadbeef", "-hash-algo", "sha256-224"}, &out, &errBuf); code != 0 {
+		t.Fatalf("sha256-224: want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != "55b9eee5f60cc362ddc07676f620372611e22272f60fdbec94f243f8" {
+		t.Fatalf("unexpected sha256-224 digest %s", got)
+	}
+	if code := run([]string{"blake2b224", "-hex", "deadbeef", "-hash-algo", "sha1"}, &out, &errBuf); code != 2 {
+		t.Fatalf("unknown -hash-algo: want 2 got %d", code)
+	}
+}
+
+func TestRun_VerifySetup(t *testing.T) {
+	dir := saveTinySetup(t, &squareCircuit{})
+	if err := WriteSetupManifest(dir); err != nil {
+		t.Fatal(err)
+	}
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"verify-setup", "-dir", dir}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if strings.Count(out.String(), " OK") != 3 {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "ccs.bin"), []byte("not a ccs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	errBuf.Reset()
+	if code := run([]string{"verify-setup", "-dir", dir}, &out, &errBuf); code != 1 {
+		t.Fatalf("tampered ccs.bin: want 1 got %d", code)
+	}
+	if !strings.Contains(out.String(), "ccs.bin FAIL") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	if code := run([]string{"verify-setup"}, &out, &errBuf); code != 2 {
+		t.Fatalf("missing -dir: want 2 got %d", code)
+	}
+}
+
+func TestRun_VerifyKeys_MixedSetups(t *testing.T) {
+	mixed := mixKeyPair(t, saveTinySetup(t, &commitCircuit{}), saveTinySetup(t, &commitCircuit{}))
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"verify-keys", "-dir", mixed}, &out, &errBuf); code != 1 {
+		t.Fatalf("mixed keys: want 1 got %d stderr=%q", code, errBuf.String())
+	}
+	if !strings.Contains(out.String(), "sizes OK") || !strings.Contains(out.String(), "shared elements FAIL") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	if !strings.Contains(errBuf.String(), ErrKeyPairMismatch.Error()) {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+	if code := run([]string{"verify-keys"}, &out, &errBuf); code != 2 {
+		t.Fatalf("missing -dir: want 2 got %d", code)
+	}
+}
+
+func TestRun_CircuitHash_Setup(t *testing.T) {
+	dir := saveTinySetup(t, &squareCircuit{})
+	want, err := fileHash(filepath.Join(dir, "ccs.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"circuit-hash", "-setup", dir}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Fatalf("printed %q, want %q", got, want)
+	}
+	if code := run([]string{"circuit-hash", "-setup", t.TempDir()}, &out, &errBuf); code != 1 {
+		t.Fatalf("missing ccs.bin: want 1 got %d", code)
+	}
+}
+
+func TestRun_FromChain(t *testing.T) {
+	datum := strings.TrimSpace(string(mustReadFile(t, filepath.Join("testdata", "encryption_datum_full.hex"))))
+	shared := g2Hex(mustG2Base(17))
+	want, err := DecryptToHash(chainDatumFullG, chainDatumFullH, chainDatumFullR, shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"from-chain", "-datum", datum, "-shared", shared, "-expect", want}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if out.String() != "level: full\n"+want+"\n" {
+		t.Fatalf("unexpected stdout: %q", out.String())
+	}
+
+	out.Reset()
+	errBuf.Reset()
+	if code := run([]string{"from-chain", "-datum", datum, "-shared", shared, "-level", "half", "-expect", want}, &out, &errBuf); code != 1 {
+		t.Fatalf("expected key of the other level: want 1 got %d", code)
+	}
+	if !strings.Contains(errBuf.String(), "FAIL: "+ErrKeyMismatch.Error()) {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+
+	for _, args := range [][]string{
+		{"from-chain", "-datum", datum},
+		{"from-chain", "-datum", datum, "-shared", shared, "-level", "latest"},
+	} {
+		if code := run(args, &out, &errBuf); code != 2 {
+			t.Fatalf("%v: want 2 got %d", args, code)
+		}
+	}
+}
+
+func TestRun_Serve_UsageErrors(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"serve"}, "-setup is required"},
+		{[]string{"serve", "-setup", t.TempDir(), "-max-concurrent", "0"}, "-max-concurrent must be >= 1"},
+		{[]string{"serve", "-setup", t.TempDir(), "-cache-size", "-1"}, "-cache-size must be >= 0"},
+		{[]string{"serve", "-setup", t.TempDir()}, "setup files not found"},
+	}
+	for _, tc := range cases {
+		var out, err bytes.Buffer
+		if code := run(tc.args, &out, &err); code != 2 {
+			t.Fatalf("%v: want 2 got %d stderr=%q", tc.args, code, err.String())
+		}
+		if !strings.Contains(err.String(), tc.want) {
+			t.Fatalf("%v: unexpected stderr: %q", tc.args, err.String())
+		}
+	}
+}
+
+func TestRun_Info(t *testing.T) {
+	bi := ReadBuildInfo()
+	for _, cmd := range []string{"info", "version"} {
+		var out, errBuf bytes.Buffer
+		if code := run([]string{cmd}, &out, &errBuf); code != 0 {
+			t.Fatalf("%s: want 0 got %d stderr=%q", cmd, code, errBuf.String())
+		}
+		for _, line := range []string{
+			"go: " + runtime.Version(),
+			"gnark: " + bi.Gnark,
+			"gnark-crypto: " + bi.GnarkCrypto,
+		} {
+			if !strings.Contains(out.String(), line+"\n") {
+				t.Fatalf("%s: missing %q in %q", cmd, line, out.String())
+			}
+		}
+	}
+}
+
+func TestRun_CheckConstants(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"check-constants"}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stdout=%q stderr=%q", code, out.String(), errBuf.String())
+	}
+	for _, name := range []string{"G1 generator OK", "G2 generator OK", "H0 OK", "SUCCESS"} {
+		if !strings.Contains(out.String(), name) {
+			t.Fatalf("missing %q in %q", name, out.String())
+		}
+	}
+}
+
+func TestRun_Reencode(t *testing.T) {
+	in := g1Hex(mustG1Base(2))
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"reencode", "-type", "g1", "-in", in, "-to", "uncompressed"}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	u := strings.TrimSpace(out.String())
+	if len(u) != 192 {
+		t.Fatalf("unexpected uncompressed hex %q", u)
+	}
+	out.Reset()
+	if code := run([]string{"reencode", "-type", "g1", "-in", u, "-to", "compressed"}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != in {
+		t.Fatalf("round trip = %s, want %s", got, in)
+	}
+	for _, args := range [][]string{
+		{"reencode", "-type", "g1", "-in", in},
+		{"reencode", "-type", "g2", "-in", in, "-to", "compressed"},
+		{"reencode", "-type", "g1", "-in", in, "-to", "raw"},
+	} {
+		if code := run(args, &out, &errBuf); code != 2 {
+			t.Fatalf("%v: want 2 got %d", args, code)
+		}
+	}
+}
+
+func TestRun_ConformanceCheck(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"conformance-check"}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stdout=%q stderr=%q", code, out.String(), errBuf.String())
+	}
+	if n := strings.Count(out.String(), " OK\n"); n != len(gtToHashGolden) || !strings.Contains(out.String(), "SUCCESS") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestRun_Ceremony_Contribute_InAndDirConflict(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"ceremony", "contribute", "-phase", "1", "-dir", t.TempDir(), "-in", t.TempDir()}, &out, &errBuf)
+	if code != 2 || !strings.Contains(errBuf.String(), "pass only one") {
+		t.Fatalf("want 2 with a conflict error, got %d stderr=%q", code, errBuf.String())
+	}
+}
+
+func TestRun_Ceremony_Verify_SinceNeedsHash(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"ceremony", "verify", "-phase", "1", "-since", "3"}, &out, &errBuf)
+	if code != 2 || !strings.Contains(errBuf.String(), "must be given together") {
+		t.Fatalf("want 2 got %d stderr=%q", code, errBuf.String())
+	}
+}
+
+func TestRun_Decrypt_EmitGT(t *testing.T) {
+	g1b := g1Hex(mustG1Base(11))
+	g2b := g2Hex(mustG2Base(13))
+	r1 := g1Hex(mustG1Base(17))
+	shared := g2Hex(mustG2Base(19))
+
+	want, e := DecryptToHash(g1b, g2b, r1, shared)
+	if e != nil {
+		t.Fatalf("DecryptToHash: %v", e)
+	}
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"decrypt", "-emit-gt", "-g1b", g1b, "-g2b", g2b, "-r1", r1, "-shared", shared}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "gt: ") || lines[1] != want {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	// The emitted encoding decodes to the element that was hashed.
+	raw, e := hex.DecodeString(strings.TrimPrefix(lines[0], "gt: "))
+	if e != nil {
+		t.Fatal(e)
+	}
+	k, e := gtFromCanonicalBytes(raw)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !bytes.Equal(fq12CanonicalBytes(k), raw) {
+		t.Fatal("GT encoding does not round-trip")
+	}
+	if got, _ := gtToHashFromGT(k); got != want {
+		t.Fatalf("hash of decoded GT = %s, want %s", got, want)
+	}
+}
+
+func TestRun_ExportVK(t *testing.T) {
+	dir := saveTinySetup(t, &commitCircuit{})
+	f, err := os.Open(filepath.Join(dir, "vk.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	vk, err := readVerifyingKey(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportVKOnly(vk, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// vk.bin and vk.json render to the same datum.
+	var datums []string
+	for _, name := range []string{"vk.bin", "vk.json"} {
+		var out, errBuf bytes.Buffer
+		if code := run([]string{"export-vk", "-vk", filepath.Join(dir, name), "-format", "datum"}, &out, &errBuf); code != 0 {
+			t.Fatalf("%s: want 0 got %d stderr=%q", name, code, errBuf.String())
+		}
+		datums = append(datums, out.String())
+	}
+	if datums[0] != datums[1] {
+		t.Fatalf("datum from vk.bin and vk.json differ:\n%s\n%s", datums[0], datums[1])
+	}
+	var d PlutusData
+	if err := json.Unmarshal([]byte(datums[0]), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Constructor == nil || *d.Constructor != 0 || len(d.Fields) != 7 {
+		t.Fatalf("unexpected datum shape: %s", datums[0])
+	}
+
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"export-vk", "-vk", filepath.Join(dir, "vk.bin"), "-format", "aiken"}, &out, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if !strings.HasPrefix(out.String(), "SnarkVerificationKey {\n") || !strings.Contains(out.String(), "    CommitmentKey {\n") {
+		t.Fatalf("unexpected aiken output:\n%s", out.String())
+	}
+
+	for _, args := range [][]string{
+		{"export-vk"},
+		{"export-vk", "-vk", filepath.Join(dir, "vk.bin"), "-format", "cbor"},
+	} {
+		if code := run(args, &out, &errBuf); code != 2 {
+			t.Fatalf("%v: want 2 got %d", args, code)
+		}
+	}
+	if code := run([]string{"export-vk", "-vk", filepath.Join(dir, "missing.bin")}, &out, &errBuf); code != 1 {
+		t.Fatalf("missing vk: want 1 got %d", code)
+	}
+
+	// A failed write to stdout is reported, not swallowed.
+	closed, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+	errBuf.Reset()
+	if code := run([]string{"export-vk", "-vk", filepath.Join(dir, "vk.bin")}, closed, &errBuf); code != 1 {
+		t.Fatalf("closed stdout: want 1 got %d", code)
+	}
+	if !strings.HasPrefix(errBuf.String(), "error: ") || !strings.Contains(errBuf.String(), "closed") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
+func TestRun_Verify_Explain(t *testing.T) {
+	h, err := OpenSetup(saveTinySetup(t, &commitCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	if err := SaveNativeFiles(h.VK, proof, publicWitness, out); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, errBuf bytes.Buffer
+	if code := run([]string{"verify", "-out", out, "-explain"}, &stdout, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	for _, want := range []string{"step 1: vk_x", "e(A, B)", "equation holds", "SUCCESS: proof verified"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Fatalf("stdout lacks %q:\n%s", want, stdout.String())
+		}
+	}
+
+	if code := run([]string{"verify", "-each", out, "-explain"}, &stdout, &errBuf); code != 2 {
+		t.Fatalf("-explain with -each: want 2 got %d", code)
+	}
+}
+
+func TestRun_Normalize_BundleOnly(t *testing.T) {
+	h, err := OpenSetup(saveTinySetup(t, &commitCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	if err := ExportAllWithOptions(h.VK, proof, publicWitness, out, ExportOptions{BundleOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	var bundle map[string]any
+	if err := json.Unmarshal(mustReadFile(t, filepath.Join(out, "all.json")), &bundle); err != nil {
+		t.Fatal(err)
+	}
+	delete(bundle, "commitmentWire")
+	delete(bundle["public"].(map[string]any), "commitmentWire")
+	delete(bundle["vk"].(map[string]any), "curve")
+	b, _ := json.Marshal(bundle)
+	if err := os.WriteFile(filepath.Join(out, "all.json"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, errBuf bytes.Buffer
+	if code := run([]string{"normalize", "-out", out}, &stdout, &errBuf); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, errBuf.String())
+	}
+	if !strings.Contains(stdout.String(), "rewrote all.json") {
+		t.Fatalf("unexpected stdout: %q", stdout.String())
+	}
+	var got BundleJSON
+	if err := decodeJSONFile(filepath.Join(out, "all.json"), &got, true); err != nil {
+		t.Fatal(err)
+	}
+	if got.CommitmentWire == "" || got.Public.CommitmentWire != got.CommitmentWire || got.VK.Curve != CurveName {
+		t.Fatalf("all.json not upgraded: wire=%q public wire=%q curve=%q", got.CommitmentWire, got.Public.CommitmentWire, got.VK.Curve)
+	}
+	if _, err := os.Stat(filepath.Join(out, "vk.json")); !os.IsNotExist(err) {
+		t.Fatalf("normalize added vk.json to a bundle-only layout: %v", err)
+	}
+
+	if code := run([]string{"normalize", "-out", out, "-leading-wire", "maybe"}, &stdout, &errBuf); code != 2 {
+		t.Fatalf("bad -leading-wire: want 2 got %d", code)
+	}
+}
+
+func TestRun_CanonicalizePublic(t *testing.T) {
+	src := filepath.Join("..", "out", "public.json")
+	var orig PublicJSON
+	if err := readJSONFile(src, &orig); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	hexPath, decPath := filepath.Join(dir, "hex.json"), filepath.Join(dir, "dec.json")
+
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"canonicalize-public", "-in", src, "-form", "hex", "-leading-wire", "exclude", "-out", hexPath}, &out, &errBuf); code != 0 {
+		t.Fatalf("to hex: code %d stderr=%q", code, errBuf.String())
+	}
+	var h PublicJSON
+	if err := decodeJSONFile(hexPath, &h, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.InputsHex) != VW0W1NbPublic || h.Inputs != nil || h.CommitmentWire != orig.CommitmentWire {
+		t.Fatalf("unexpected hex form: %+v", h)
+	}
+
+	// Back to decimal with the one-wire: the original file, byte for byte.
+	if code := run([]string{"canonicalize-public", "-in", hexPath, "-form", "decimal", "-leading-wire", "include", "-out", decPath}, &out, &errBuf); code != 0 {
+		t.Fatalf("to decimal: code %d stderr=%q", code, errBuf.String())
+	}
+	if got, want := mustReadFile(t, decPath), mustReadFile(t, src); !bytes.Equal(got, want) {
+		t.Fatalf("round trip differs from %s:\n%s", src, got)
+	}
+
+	for _, tc := range []struct {
+		args    []string
+		code    int
+		wantErr string
+	}{
+		{[]string{"-in", src, "-form", "hex"}, 2, "-in, -form and -leading-wire are required"},
+		{[]string{"-in", src, "-form", "octal", "-leading-wire", "include"}, 2, "unknown public input form"},
+		{[]string{"-in", src, "-form", "hex", "-leading-wire", "auto"}, 2, "-leading-wire must be include or exclude"},
+		{[]string{"-in", src, "-form", "hex", "-leading-wire", "include", "-in-leading-wire", "exclude"}, 1, "declared without the one-wire"},
+		{[]string{"-in", src, "-form", "hex", "-leading-wire", "include", "-nb-public", "35"}, 1, "length mismatch"},
+	} {
+		errBuf.Reset()
+		if code := run(append([]string{"canonicalize-public"}, tc.args...), &out, &errBuf); code != tc.code || !strings.Contains(errBuf.String(), tc.wantErr) {
+			t.Fatalf("%v: code %d stderr=%q, want %d and %q", tc.args, code, errBuf.String(), tc.code, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+//go:build !js || !wasm
+
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// clierrors.go implements the global -json-errors flag. The subcommand's
+// stderr is passed through line by line as it is written, except for the
+// diagnostic lines ("error:", "FAIL:", ... and their indented
+// continuations), which are held back. On a non-zero exit they are replaced
+// by a single JSON object, written last, so wrappers can branch on "kind"
+// instead of scraping message text. The kind comes from the typed error the
+// handler reported through printErr, never from the message. Exit codes are
+// unchanged.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// CLIError is the object written to stderr under -json-errors.
+type CLIError struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+	Kind  string `json:"kind"`
+}
+
+// Error kinds reported by -json-errors.
+const (
+	KindUsage              = "usage"
+	KindMissingFile        = "missing_file"
+	KindBadHex             = "bad_hex"
+	KindBadPoint           = "bad_point"
+	KindBadScalar          = "bad_scalar"
+	KindWitnessMismatch    = "witness_mismatch"
+	KindVerificationFailed = "verification_failed"
+	KindWireMismatch       = "wire_mismatch"
+	KindInterrupted        = "interrupted"
+	KindFailure            = "failure"
+)
+
+// diagnosticPrefixes are the line prefixes run uses for failures.
+var diagnosticPrefixes = []string{"error:", "FAIL:", "WIRE MISMATCH:"}
+
+// jsonErrorsStderr is the stderr a subcommand sees under -json-errors. It
+// passes every complete line through to out as it arrives, holds back the
+// diagnostic lines, and keeps the last error the handler reported so
+// runJSONErrors can classify the failure.
+type jsonErrorsStderr struct {
+	mu      sync.Mutex
+	out     io.Writer
+	partial []byte // trailing bytes not yet ended by a newline
+	held    bytes.Buffer
+	first   string // first non-empty line, the message when none is held
+	inDiag  bool
+	err     error
+}
+
+func (s *jsonErrorsStderr) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(s.partial[:i+1])
+		s.partial = s.partial[i+1:]
+		s.line(line)
+	}
+}
+
+func (s *jsonErrorsStderr) recordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// line routes one line, newline included, to out or to the held
+// diagnostics, using the same rules as diagnosticMessage.
+func (s *jsonErrorsStderr) line(line string) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed != "" && s.first == "" {
+		s.first = trimmed
+	}
+	switch {
+	case trimmed == "":
+		s.inDiag = false
+	case hasDiagnosticPrefix(trimmed):
+		s.inDiag = true
+	case s.inDiag && (line[0] == ' ' || line[0] == '\t'):
+	default:
+		s.inDiag = false
+	}
+	if s.inDiag {
+		s.held.WriteString(line)
+		return
+	}
+	_, _ = io.WriteString(s.out, line)
+}
+
+// finish routes an unterminated last line and, after a successful run,
+// writes the held diagnostics through as well. It returns the held text.
+func (s *jsonErrorsStderr) finish(code int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.partial) > 0 {
+		s.line(string(s.partial) + "\n")
+		s.partial = nil
+	}
+	if code == 0 {
+		_, _ = s.out.Write(s.held.Bytes())
+	}
+	return s.held.String()
+}
+
+// errorRecorder is implemented by stderr writers that want the typed error
+// behind a diagnostic line.
+type errorRecorder interface {
+	recordError(err error)
+}
+
+// recordError hands err to stderr if it is an errorRecorder.
+func recordError(stderr io.Writer, err error) {
+	if rec, ok := stderr.(errorRecorder); ok {
+		rec.recordError(err)
+	}
+}
+
+// printErr writes "prefix err" to stderr, as fmt.Fprintln would, and records
+// err for -json-errors.
+func printErr(stderr io.Writer, prefix string, err error) {
+	fmt.Fprintln(stderr, prefix, err)
+	recordError(stderr, err)
+}
+
+// printErrf reports a diagnostic that has no underlying error as
+// "error: <msg>", recording it with kind attached.
+func printErrf(stderr io.Writer, kind error, format string, args ...any) {
+	printErr(stderr, "error:", withKind(kind, fmt.Errorf(format, args...)))
+}
+
+// runJSONErrors runs args like run. Stderr streams through as it is written;
+// on failure the held diagnostics become one JSON object on the last line.
+// On success they are written through unchanged.
+func runJSONErrors(args []string, stdout, stderr io.Writer) int {
+	captured := &jsonErrorsStderr{out: stderr}
+	code := run(args, stdout, captured)
+	held := captured.finish(code)
+	if code == 0 {
+		return 0
+	}
+
+	msg := diagnosticMessage(held)
+	if msg == "" {
+		msg = captured.first
+	}
+	enc := json.NewEncoder(stderr)
+	_ = enc.Encode(CLIError{Error: msg, Code: code, Kind: classifyCLIError(captured.err, code)})
+	return code
+}
+
+// hasDiagnosticPrefix reports whether a trimmed line starts a diagnostic.
+func hasDiagnosticPrefix(trimmed string) bool {
+	for _, p := range diagnosticPrefixes {
+		if strings.HasPrefix(trimmed, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// diagnosticMessage extracts the failure text from captured stderr: the
+// prefixed diagnostic lines (and their indented continuations) joined with
+// "; ", or the first non-empty line when nothing is prefixed (e.g. flag
+// parse errors followed by usage text).
+func diagnosticMessage(out string) string {
+	var parts []string
+	var first string
+	inDiag := false
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			inDiag = false
+			continue
+		}
+		if first == "" {
+			first = trimmed
+		}
+
+		matched := false
+		for _, p := range diagnosticPrefixes {
+			if strings.HasPrefix(trimmed, p) {
+				parts = append(parts, strings.TrimSpace(strings.TrimPrefix(trimmed, p)))
+				matched = true
+				break
+			}
+		}
+		switch {
+		case matched:
+			inDiag = true
+		case inDiag && line != trimmed:
+			parts = append(parts, trimmed)
+		default:
+			inDiag = false
+		}
+	}
+	if len(parts) == 0 {
+		return first
+	}
+	return strings.Join(parts, "; ")
+}
+
+// classifyCLIError maps the error a handler reported (nil if it printed
+// only a plain usage message) and the exit code to an error kind. Without a
+// typed error, exit code 2 is a usage error and anything else a failure.
+func classifyCLIError(err error, code int) string {
+	switch {
+	case code == 130 || errors.Is(err, ErrInterrupted):
+		return KindInterrupted
+	case code == 3 || errors.Is(err, ErrWireMismatch):
+		return KindWireMismatch
+	case errors.Is(err, fs.ErrNotExist):
+		return KindMissingFile
+	case errors.Is(err, ErrBadScalar):
+		return KindBadScalar
+	case errors.Is(err, ErrBadHex):
+		return KindBadHex
+	case errors.Is(err, ErrBadPoint):
+		return KindBadPoint
+	case errors.Is(err, ErrWitnessMismatch):
+		return KindWitnessMismatch
+	case errors.Is(err, ErrVerificationFailed), errors.Is(err, ErrPairingEquation), errors.Is(err, ErrProofsFailed):
+		return KindVerificationFailed
+	case code == 2:
+		return KindUsage
+	default:
+		return KindFailure
+	}
+}
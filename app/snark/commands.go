@@ -0,0 +1,215 @@
+//go:build !js || !wasm
+
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// commands.go is the registry of CLI subcommands: the name, aliases and a
+// one-line summary of every case run dispatches on. `snark help` lists it,
+// `snark help <command>` prints that command's flags (by running it with -h,
+// so the flag set stays the single source of its flags), and an unknown
+// command is answered with the closest registered names. A test checks the
+// registry against the cases in run, so a new subcommand cannot be left out.
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// command describes one subcommand of run.
+type command struct {
+	Name    string
+	Aliases []string
+	Summary string
+}
+
+// commands lists every subcommand of run, in the order `snark help` prints
+// them.
+var commands = []command{
+	{Name: "setup", Summary: "compile the vw0w1 circuit and run a local trusted setup"},
+	{Name: "ceremony", Summary: "run a multi-party setup ceremony (" + strings.Join(ceremonySubcommands, ", ") + ")"},
+	{Name: "hash", Summary: "hash a secret a to its key hash"},
+	{Name: "blake2b224", Summary: "print the blake2b-224 digest of hex data"},
+	{Name: "reduce", Summary: "reduce a scalar into the BLS12-381 scalar field"},
+	{Name: "reencode", Summary: "convert a point between compressed and uncompressed encoding"},
+	{Name: "decrypt", Summary: "derive the key of one encryption level"},
+	{Name: "decrypt-chain", Summary: "walk a decryption path and print the key of every hop"},
+	{Name: "from-chain", Summary: "decrypt straight from an encryption UTxO datum"},
+	{Name: "prove", Summary: "prove the vw0w1 statement and export the artifacts"},
+	{Name: "prove-batch", Summary: "prove many vw0w1 statements from one setup"},
+	{Name: "verify", Summary: "verify proof artifacts"},
+	{Name: "verify-only", Summary: "verify a proof made elsewhere against a setup's vk.bin"},
+	{Name: "verify-setup", Summary: "check a setup directory against its manifest"},
+	{Name: "verify-keys", Summary: "check that pk.bin and vk.bin are a pair"},
+	{Name: "re-export", Summary: "rewrite the JSON artifacts from the native binaries"},
+	{Name: "normalize", Summary: "upgrade JSON artifacts to the current schema"},
+	{Name: "export-vk", Summary: "print a verifying key as Aiken, a datum or JSON"},
+	{Name: "commitment-wire", Summary: "compute the commitment wire of a proof without a VK"},
+	{Name: "canonicalize-public", Summary: "convert a public.json to decimal or hex, with or without the one-wire"},
+	{Name: "ccs-info", Summary: "print an audit summary of the compiled circuit"},
+	{Name: "circuit-hash", Summary: "print the circuit hash"},
+	{Name: "check-constants", Summary: "check the hard-coded curve constants"},
+	{Name: "conformance-check", Summary: "check gtToHash against the golden vectors"},
+	{Name: "serve", Summary: "serve proofs over HTTP"},
+	{Name: "info", Aliases: []string{"version"}, Summary: "print the tool, Go and gnark versions"},
+	{Name: "debug-verify", Summary: "try several pairing formulations against out/ (diagnostic)"},
+	{Name: "test-verify", Summary: "rebuild a verifier from out/*.json (diagnostic)"},
+	{Name: "help", Summary: "list the subcommands, or print the flags of one"},
+}
+
+// ceremonySubcommands are the subcommands of `snark ceremony`.
+var ceremonySubcommands = []string{"init", "contribute", "challenge-hash", "verify", "finalize", "export-keys"}
+
+// lookupCommand returns the registered command called name, by name or alias.
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.Name == name {
+			return c, true
+		}
+		for _, a := range c.Aliases {
+			if a == name {
+				return c, true
+			}
+		}
+	}
+	return command{}, false
+}
+
+// isHelpArg reports whether arg asks for help instead of naming a command.
+func isHelpArg(arg string) bool {
+	switch arg {
+	case "help", "-h", "-help", "--help":
+		return true
+	}
+	return false
+}
+
+// writeCommandList prints the usage line and every registered command with
+// its summary.
+func writeCommandList(w io.Writer) {
+	fmt.Fprintln(w, "usage: snark [-json-errors] <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	width := 0
+	for _, c := range commands {
+		if n := len(commandLabel(c)); n > width {
+			width = n
+		}
+	}
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, commandLabel(c), c.Summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'snark help <command>' for the flags of a command.")
+}
+
+// commandLabel is the name of c followed by its aliases.
+func commandLabel(c command) string {
+	if len(c.Aliases) == 0 {
+		return c.Name
+	}
+	return c.Name + " (" + strings.Join(c.Aliases, ", ") + ")"
+}
+
+// runHelp implements `snark help [command]`. With a command it prints the
+// summary and then the command's flags, which the command's own flag set
+// prints when run with -h.
+func runHelp(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		writeCommandList(stdout)
+		return 0
+	}
+	c, ok := lookupCommand(args[0])
+	if !ok {
+		return unknownCommand(stderr, "command", args[0], commandNames())
+	}
+	fmt.Fprintf(stdout, "snark %s: %s\n", c.Name, c.Summary)
+	switch c.Name {
+	case "help", "debug-verify", "test-verify":
+		// No flags; running them would do the work instead.
+		return 0
+	case "ceremony":
+		for _, sub := range ceremonySubcommands {
+			fmt.Fprintf(stdout, "\nsnark ceremony %s:\n", sub)
+			run([]string{c.Name, sub, "-h"}, stdout, stdout)
+		}
+		return 0
+	}
+	fmt.Fprintln(stdout)
+	run([]string{c.Name, "-h"}, stdout, stdout)
+	return 0
+}
+
+// commandNames returns every registered name and alias.
+func commandNames() []string {
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.Name)
+		names = append(names, c.Aliases...)
+	}
+	return names
+}
+
+// unknownCommand reports an unknown (sub)command, suggesting the closest
+// known names, and returns the usage exit status.
+func unknownCommand(stderr io.Writer, what, name string, known []string) int {
+	fmt.Fprintf(stderr, "error: unknown %s %q\n", what, name)
+	if s := suggest(name, known); len(s) > 0 {
+		fmt.Fprintf(stderr, "did you mean %s?\n", strings.Join(s, " or "))
+	}
+	fmt.Fprintln(stderr, "Run 'snark help' for the list of commands.")
+	return 2
+}
+
+// suggest returns the known names close to name: those it is a prefix of,
+// or within an edit distance of a third of its length (at least 1), closest
+// first.
+func suggest(name string, known []string) []string {
+	maxDist := len(name) / 3
+	if maxDist < 1 {
+		maxDist = 1
+	}
+	type scored struct {
+		name string
+		dist int
+	}
+	var found []scored
+	for _, k := range known {
+		d := editDistance(name, k)
+		if strings.HasPrefix(k, name) && name != "" {
+			d = 0
+		}
+		if d <= maxDist {
+			found = append(found, scored{k, d})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].dist < found[j].dist })
+	names := make([]string, len(found))
+	for i, f := range found {
+		names[i] = f.name
+	}
+	return names
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
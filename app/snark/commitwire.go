@@ -0,0 +1,156 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// commitwire.go recomputes the BSB22 commitment wire from a proof and its
+// public inputs alone. The verifying key only contributes the list of
+// committed public indices, and for the vw0w1 circuits that list is every
+// public input, so the wire can be checked offline without a VK. The WASM
+// prover relies on the same shortcut to avoid deserializing the VK.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	groth16bls "github.com/consensys/gnark/backend/groth16/bls12-381"
+)
+
+// CommitmentWireOptions tunes CommitmentWireFromJSON. The zero value matches
+// the artifacts `prove` writes.
+type CommitmentWireOptions struct {
+	// Committed lists the 1-based public input indices bound by the
+	// commitment, as in vk.PublicAndCommitmentCommitted[0]. Empty means all
+	// public inputs, which holds for the vw0w1 circuits.
+	Committed []int
+
+	// NoOneWire says the public vector does not start with the one-wire "1"
+	// that ExportAll prepends.
+	NoOneWire bool
+}
+
+// allCommittedIndices returns 1..n.
+func allCommittedIndices(n int) []int {
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i + 1
+	}
+	return idx
+}
+
+// ComputeCommitmentWireNoVK computes the commitment wire of proof over the
+// public witness pubFr (without the one-wire). committed holds the 1-based
+// committed indices; nil commits every public input. A proof without
+// commitments yields "".
+func ComputeCommitmentWireNoVK(proof *groth16bls.Proof, pubFr []fr.Element, committed []int) (string, error) {
+	if len(proof.Commitments) == 0 {
+		return "", nil // No commitment extension
+	}
+	if len(committed) == 0 {
+		committed = allCommittedIndices(len(pubFr))
+	}
+	return commitmentWireFromIndices(proof.Commitments[0], committed, pubFr)
+}
+
+// CommitmentWireFromJSON rebuilds the proof and public witness from their
+// JSON exports and computes the commitment wire without a verifying key.
+func CommitmentWireFromJSON(pj ProofJSON, pubj PublicJSON, opts CommitmentWireOptions) (string, error) {
+	proof, err := proofFromJSON(pj)
+	if err != nil {
+		return "", fmt.Errorf("proof: %w", err)
+	}
+	if len(proof.Commitments) == 0 {
+		return "", fmt.Errorf("proof has no commitments, so there is no commitment wire")
+	}
+
+	witness, err := publicInputs(pubj)
+	if err != nil {
+		return "", err
+	}
+	if !opts.NoOneWire {
+		if len(witness) == 0 || !witness[0].IsOne() {
+			return "", fmt.Errorf("public inputs do not start with the one-wire 1 (pass NoOneWire for a bare public vector)")
+		}
+		witness = witness[1:]
+	}
+	return ComputeCommitmentWireNoVK(proof, witness, opts.Committed)
+}
+
+// CommitmentWireFromFiles is CommitmentWireFromJSON over proof.json and
+// public.json on disk.
+func CommitmentWireFromFiles(proofPath, publicPath string, opts CommitmentWireOptions) (string, error) {
+	var pj ProofJSON
+	if err := readJSONFile(proofPath, &pj); err != nil {
+		return "", err
+	}
+	var pubj PublicJSON
+	if err := readJSONFile(publicPath, &pubj); err != nil {
+		return "", err
+	}
+	return CommitmentWireFromJSON(pj, pubj, opts)
+}
+
+// readJSONFile decodes the JSON file at path into v.
+func readJSONFile(path string, v interface{}) error {
+	return decodeJSONFile(path, v, false)
+}
+
+// decodeJSONFile decodes the JSON file at path into v. With strict set,
+// fields v has no place for and data after the JSON value are errors rather
+// than silently dropped.
+func decodeJSONFile(path string, v interface{}, strict bool) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := decodeJSON(b, v, strict); err != nil {
+		return fmt.Errorf("unmarshal %s: %w", path, err)
+	}
+	return nil
+}
+
+// decodeJSON decodes b into v, strictly as described at decodeJSONFile.
+func decodeJSON(b []byte, v interface{}, strict bool) error {
+	if !strict {
+		return json.Unmarshal(b, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the JSON value")
+	}
+	return nil
+}
+
+// ParseIndexList parses a comma-separated list of 1-based indices and
+// inclusive ranges, e.g. "1-36" or "1,2,5-9".
+func ParseIndexList(s string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil || first < 1 {
+			return nil, fmt.Errorf("invalid index %q (want a positive integer or a range like 1-36)", part)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || last < first {
+				return nil, fmt.Errorf("invalid index range %q", part)
+			}
+		}
+		for i := first; i <= last; i++ {
+			out = append(out, i)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,217 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// constants.go checks the curve constants the whole scheme rests on against
+// values written down independently of gnark-crypto: the G1 and G2
+// generators from the BLS12-381 specification, and the fixed point H0 that
+// kappa pairs with. hk, the contracts and every published commitment depend
+// on them, so a dependency bump that changed any of these would silently
+// break compatibility with everything already on-chain. The same goes for the
+// in-circuit point compression of wFromHKCircuit, which rests on gnark's
+// EmulatedToBytes layout. `check-constants` runs the checks after an upgrade.
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fp"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/algebra/emulated/sw_emulated"
+	"github.com/consensys/gnark/std/math/emulated"
+	"github.com/consensys/gnark/std/math/emulated/emparams"
+	"github.com/consensys/gnark/std/math/uints"
+)
+
+// Reference coordinates of the BLS12-381 generators, as given in the curve
+// specification (draft-irtf-cfrg-pairing-friendly-curves). G2 coordinates
+// are x = x0 + x1*u.
+const (
+	refG1X = "0x17f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb"
+	refG1Y = "0x08b3f481e3aaa0f1a09e30ed741d8ae4fcf5e095d5d00af600db18cb2c04b3edd03cc744a2888ae40caa232946c5e7e1"
+
+	refG2X0 = "0x024aa2b2f08f0a91260805272dc51051c6e47ad4fa403b02b4510b647ae3d1770bac0326a805bbefd48056c8c121bdb8"
+	refG2X1 = "0x13e02b6052719f607dacd3a088274f65596bd0d09920b61ab5da61bbdc7f5049334cf11213945d57e5ac7d055d042b7e"
+	refG2Y0 = "0x0ce5d527727d6e118cc9cdc6da2e351aadfd9baa8cbdd3a76d429a695160d12c923ac9cc3baca289e193548608b82801"
+	refG2Y1 = "0x0606c4a02ea734cc32acd2b02bc28b99cb3e287e85a763af267492ab572e99ab3f370d275cec1da1aaa9075ff05f79be"
+
+	// refG1CompressedHex and refG2CompressedHex are the generators in the
+	// compressed encoding used by every *Hex value in this package.
+	refG1CompressedHex = "97f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb"
+	refG2CompressedHex = "93e02b6052719f607dacd3a088274f65596bd0d09920b61ab5da61bbdc7f5049334cf11213945d57e5ac7d055d042b7e024aa2b2f08f0a91260805272dc51051c6e47ad4fa403b02b4510b647ae3d1770bac0326a805bbefd48056c8c121bdb8"
+)
+
+// ErrConstantMismatch is returned (wrapped) when a curve constant differs
+// from its reference value.
+var ErrConstantMismatch = errors.New("curve constant differs from its reference value")
+
+// ConstantCheck is the outcome of one check-constants item.
+type ConstantCheck struct {
+	Name string
+	Err  error // nil when the constant matches
+}
+
+// CheckConstants runs every constant check. It returns one ConstantCheck
+// per item; the error wraps ErrConstantMismatch if any failed.
+func CheckConstants() ([]ConstantCheck, error) {
+	checks := []ConstantCheck{
+		{Name: "G1 generator", Err: checkG1Generator()},
+		{Name: "G2 generator", Err: checkG2Generator()},
+		{Name: "H0", Err: checkH0()},
+		{Name: "in-circuit G1 compression", Err: checkCircuitG1Compression()},
+	}
+	failed := 0
+	for _, c := range checks {
+		if c.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return checks, fmt.Errorf("%w: %d of %d checks failed", ErrConstantMismatch, failed, len(checks))
+	}
+	return checks, nil
+}
+
+// fpFromHex parses reference coordinates, in order.
+func fpFromHex(coords ...string) ([]fp.Element, error) {
+	out := make([]fp.Element, len(coords))
+	for i, s := range coords {
+		if _, err := out[i].SetString(s); err != nil {
+			return nil, fmt.Errorf("reference coordinate %s: %w", s, err)
+		}
+	}
+	return out, nil
+}
+
+func checkG1Generator() error {
+	_, _, g1, _ := bls12381.Generators()
+	c, err := fpFromHex(refG1X, refG1Y)
+	if err != nil {
+		return err
+	}
+	want := bls12381.G1Affine{X: c[0], Y: c[1]}
+	if !g1.Equal(&want) {
+		return fmt.Errorf("gnark-crypto G1 generator is (%s, %s)", g1.X.String(), g1.Y.String())
+	}
+	got, err := g1CompressedHex(g1)
+	if err != nil {
+		return err
+	}
+	if got != refG1CompressedHex {
+		return fmt.Errorf("G1 generator compresses to %s, want %s", got, refG1CompressedHex)
+	}
+	return nil
+}
+
+func checkG2Generator() error {
+	_, _, _, g2 := bls12381.Generators()
+	c, err := fpFromHex(refG2X0, refG2X1, refG2Y0, refG2Y1)
+	if err != nil {
+		return err
+	}
+	var want bls12381.G2Affine
+	want.X.A0, want.X.A1 = c[0], c[1]
+	want.Y.A0, want.Y.A1 = c[2], c[3]
+	if !g2.Equal(&want) {
+		return fmt.Errorf("gnark-crypto G2 generator is (%s, %s)", g2.X.String(), g2.Y.String())
+	}
+	got, err := g2CompressedHex(g2)
+	if err != nil {
+		return err
+	}
+	if got != refG2CompressedHex {
+		return fmt.Errorf("G2 generator compresses to %s, want %s", got, refG2CompressedHex)
+	}
+	return nil
+}
+
+// checkH0 parses H0Hex with the subgroup check on and confirms it round-trips
+// to the same bytes and is not the point at infinity.
+func checkH0() error {
+	h0, err := parseG2CompressedHex(H0Hex)
+	if err != nil {
+		return fmt.Errorf("H0Hex does not parse as a G2 subgroup point: %w", err)
+	}
+	if h0.IsInfinity() {
+		return fmt.Errorf("H0Hex is the point at infinity")
+	}
+	raw := h0.Bytes()
+	if got := hex.EncodeToString(raw[:]); got != H0Hex {
+		return fmt.Errorf("H0Hex re-encodes to %s", got)
+	}
+	return nil
+}
+
+// g1CompressionCircuit asserts that compressG1InCircuit encodes P as Want.
+type g1CompressionCircuit struct {
+	P        sw_emulated.AffinePoint[emparams.BLS12381Fp]
+	SignHint frontend.Variable
+	Want     [bls12381.SizeOfG1AffineCompressed]uints.U8
+}
+
+// Define implements frontend.Circuit.
+func (c *g1CompressionCircuit) Define(api frontend.API) error {
+	got, bapi, err := compressG1InCircuit(api, &c.P, c.SignHint)
+	if err != nil {
+		return err
+	}
+	if len(got) != len(c.Want) {
+		return fmt.Errorf("in-circuit compression gave %d bytes, want %d", len(got), len(c.Want))
+	}
+	for i := range got {
+		bapi.AssertIsEqual(got[i], c.Want[i])
+	}
+	return nil
+}
+
+// checkCircuitG1Compression solves g1CompressionCircuit for G1 and -G1, one
+// of which has the sign bit set, against the bytes g1CompressedHex gives.
+// It catches an EmulatedToBytes change (length, byte order) that would
+// otherwise only surface as a failing W proof.
+func checkCircuitG1Compression() error {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &g1CompressionCircuit{})
+	if err != nil {
+		return fmt.Errorf("compile compression circuit: %w", err)
+	}
+	_, _, g, _ := bls12381.Generators()
+	var negG bls12381.G1Affine
+	negG.Neg(&g)
+	for _, p := range []bls12381.G1Affine{g, negG} {
+		if err := solveG1Compression(ccs, p, p.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// solveG1Compression checks that the compiled g1CompressionCircuit accepts
+// p with the expected encoding want.
+func solveG1Compression(ccs constraint.ConstraintSystem, p bls12381.G1Affine, want [bls12381.SizeOfG1AffineCompressed]byte) error {
+	signHint := 0
+	if p.Y.LexicographicallyLargest() {
+		signHint = 1
+	}
+	assignment := &g1CompressionCircuit{
+		P: sw_emulated.AffinePoint[emparams.BLS12381Fp]{
+			X: emulated.ValueOf[emparams.BLS12381Fp](p.X),
+			Y: emulated.ValueOf[emparams.BLS12381Fp](p.Y),
+		},
+		SignHint: signHint,
+	}
+	for i := range want {
+		assignment.Want[i] = uints.NewU8(want[i])
+	}
+	w, err := frontend.NewWitness(assignment, ecc.BLS12_381.ScalarField())
+	if err != nil {
+		return fmt.Errorf("compression witness: %w", err)
+	}
+	if err := ccs.IsSolved(w); err != nil {
+		return fmt.Errorf("in-circuit compression does not give %s: %v", hex.EncodeToString(want[:]), err)
+	}
+	return nil
+}
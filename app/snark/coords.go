@@ -0,0 +1,63 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// coords.go renders curve points as decimal affine coordinates, the form
+// tooling outside gnark (snarkjs, Solidity verifier generators) exchanges
+// keys and proofs in, instead of the compressed hex used everywhere else in
+// this package. A G2 coordinate is an Fp2 element A0 + A1*u, and consumers
+// disagree on the order of its two halves:
+//
+//	Fp2OrderC0C1  [A0, A1]  gnark-crypto; snarkjs verification_key.json and
+//	                        proof.json (vk_beta_2, pi_b, ...); EIP-2537 input
+//	Fp2OrderC1C0  [A1, A0]  Solidity verifiers generated by snarkjs and the
+//	                        calldata for them (EIP-197 order, kept from BN254)
+//
+// Both orders parse as valid numbers, so the wrong one is never reported;
+// the key just stops verifying. Callers must name the order they need.
+package main
+
+import (
+	"math/big"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fp"
+)
+
+// Fp2Order is the order in which the halves of an Fp2 element are listed.
+// The zero value is gnark's own order.
+type Fp2Order int
+
+const (
+	// Fp2OrderC0C1 lists A0 (c0) before A1 (c1).
+	Fp2OrderC0C1 Fp2Order = iota
+	// Fp2OrderC1C0 lists A1 (c1) before A0 (c0).
+	Fp2OrderC1C0
+)
+
+// fpDec returns e in canonical decimal. fp.Element.Text(10) does not: it
+// prints values just below the modulus as small negatives ("-1").
+func fpDec(e *fp.Element) string {
+	return e.BigInt(new(big.Int)).String()
+}
+
+// fp2Dec returns a0 + a1*u as two decimal strings in order.
+func fp2Dec(a0, a1 *fp.Element, order Fp2Order) [2]string {
+	if order == Fp2OrderC1C0 {
+		return [2]string{fpDec(a1), fpDec(a0)}
+	}
+	return [2]string{fpDec(a0), fpDec(a1)}
+}
+
+// g1ToXYDec returns the affine coordinates of p in decimal.
+func g1ToXYDec(p bls12381.G1Affine) [2]string {
+	return [2]string{fpDec(&p.X), fpDec(&p.Y)}
+}
+
+// g2ToXYDec returns the affine coordinates of p in decimal, each Fp2
+// coordinate listed in order.
+func g2ToXYDec(p bls12381.G2Affine, order Fp2Order) [2][2]string {
+	return [2][2]string{
+		fp2Dec(&p.X.A0, &p.X.A1, order),
+		fp2Dec(&p.Y.A0, &p.Y.A1, order),
+	}
+}
@@ -11,10 +11,10 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"math/big"
 	"os"
 	"path/filepath"
 
+	"github.com/consensys/gnark-crypto/ecc"
 	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
 	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
 )
@@ -39,6 +39,10 @@ func debugVerify() {
 		fmt.Fprintf(os.Stderr, "unmarshal vk.json: %v\n", err)
 		os.Exit(1)
 	}
+	if err := checkCurve("vk.json", vkJSON.Curve); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	// Load public inputs
 	publicData, err := os.ReadFile(filepath.Join(outDir, "public.json"))
@@ -71,60 +75,45 @@ func debugVerify() {
 		}
 	}
 
-	// Compute vk_x using the exported public inputs (including leading "1")
-	fmt.Println("\n=== vk_x with all 37 public inputs (including leading '1') ===")
-	vkx_full := IC[0]
-	for i := 0; i < len(publicJSON.Inputs); i++ {
-		var s fr.Element
-		if _, err := s.SetString(publicJSON.Inputs[i]); err != nil {
+	inputs := make([]fr.Element, len(publicJSON.Inputs))
+	for i, in := range publicJSON.Inputs {
+		if _, err := inputs[i].SetString(in); err != nil {
 			fmt.Fprintf(os.Stderr, "parse input[%d]: %v\n", i, err)
 			os.Exit(1)
 		}
-		var sBig big.Int
-		s.BigInt(&sBig)
-		var term bls12381.G1Affine
-		term.ScalarMultiplication(&IC[i+1], &sBig)
-		vkx_full.Add(&vkx_full, &term)
+	}
+
+	// Compute vk_x using the exported public inputs (including leading "1")
+	fmt.Println("\n=== vk_x with all 37 public inputs (including leading '1') ===")
+	vkx_full, err := computeVKX(IC, inputs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vk_x: %v\n", err)
+		os.Exit(1)
 	}
 	vkx_full_bytes := vkx_full.Bytes()
 	fmt.Printf("vk_x (hex): %s\n", hex.EncodeToString(vkx_full_bytes[:]))
 
 	// Compute vk_x using only the 36 public inputs (skipping leading "1")
 	fmt.Println("\n=== vk_x with 36 public inputs (skipping leading '1') ===")
-	vkx_36 := IC[0]
-	for i := 1; i < len(publicJSON.Inputs); i++ {
-		var s fr.Element
-		if _, err := s.SetString(publicJSON.Inputs[i]); err != nil {
-			fmt.Fprintf(os.Stderr, "parse input[%d]: %v\n", i, err)
-			os.Exit(1)
-		}
-		var sBig big.Int
-		s.BigInt(&sBig)
-		var term bls12381.G1Affine
-		term.ScalarMultiplication(&IC[i], &sBig)
-		vkx_36.Add(&vkx_36, &term)
+	vkx_36, err := computeVKX(IC, inputs[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vk_x: %v\n", err)
+		os.Exit(1)
 	}
 	vkx_36_bytes := vkx_36.Bytes()
 	fmt.Printf("vk_x (hex): %s\n", hex.EncodeToString(vkx_36_bytes[:]))
 
 	// Compute vk_x using 36 inputs with only 37 IC elements
 	fmt.Println("\n=== vk_x with 36 inputs and first 37 IC elements ===")
-	vkx_37ic := IC[0]
-	for i := 1; i < len(publicJSON.Inputs); i++ {
-		var s fr.Element
-		if _, err := s.SetString(publicJSON.Inputs[i]); err != nil {
-			fmt.Fprintf(os.Stderr, "parse input[%d]: %v\n", i, err)
-			os.Exit(1)
-		}
-		if i >= 37 {
-			fmt.Println("  WARNING: i >= 37, skipping")
-			continue
-		}
-		var sBig big.Int
-		s.BigInt(&sBig)
-		var term bls12381.G1Affine
-		term.ScalarMultiplication(&IC[i], &sBig)
-		vkx_37ic.Add(&vkx_37ic, &term)
+	inputs36 := inputs[1:]
+	if len(inputs36) > 36 {
+		fmt.Println("  WARNING: more than 36 inputs, ignoring the rest")
+		inputs36 = inputs36[:36]
+	}
+	vkx_37ic, err := computeVKX(IC[:min(len(IC), 37)], inputs36)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vk_x: %v\n", err)
+		os.Exit(1)
 	}
 	vkx_37ic_bytes := vkx_37ic.Bytes()
 	fmt.Printf("vk_x (hex): %s\n", hex.EncodeToString(vkx_37ic_bytes[:]))
@@ -143,6 +132,10 @@ func debugVerify() {
 		fmt.Fprintf(os.Stderr, "unmarshal proof.json: %v\n", err)
 		os.Exit(1)
 	}
+	if err := checkCurve("proof.json", proofJSON.Curve); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	// Parse proof elements
 	var A, C bls12381.G1Affine
@@ -224,3 +217,25 @@ func debugVerify() {
 
 	fmt.Printf("product == 1 (with 36-input vk_x, -A): %v\n", product.Equal(&one))
 }
+
+// computeVKX returns the Groth16 input accumulator
+//
+//	vk_x = IC[0] + sum_i inputs[i] * IC[i+1]
+//
+// as a single multi-scalar multiplication. IC must hold at least
+// len(inputs)+1 points; any extra points are ignored.
+func computeVKX(IC []bls12381.G1Affine, inputs []fr.Element) (bls12381.G1Affine, error) {
+	if len(IC) < len(inputs)+1 {
+		return bls12381.G1Affine{}, fmt.Errorf("%d inputs need %d IC points, have %d", len(inputs), len(inputs)+1, len(IC))
+	}
+	if len(inputs) == 0 {
+		return IC[0], nil
+	}
+	var sum bls12381.G1Affine
+	if _, err := sum.MultiExp(IC[1:len(inputs)+1], inputs, ecc.MultiExpConfig{}); err != nil {
+		return bls12381.G1Affine{}, fmt.Errorf("multiexp: %w", err)
+	}
+	var vkx bls12381.G1Affine
+	vkx.Add(&IC[0], &sum)
+	return vkx, nil
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// decryptbatch.go decrypts many entries against one shared value. Every entry
+// pairs with the same two fixed G2 points, H0 and shared, so their Miller-loop
+// lines are computed once for the batch and each entry only runs the G1 side
+// of the loop. The two fixed pairings and the inverse of e(r1, shared) also
+// fold into a single final exponentiation:
+//
+//	k = e(g1b, H0) * e(r1, g2b) * e(-r1, shared)
+//
+// which is the same GT element DecryptToHash computes, so the hashes match
+// exactly.
+package main
+
+import (
+	"fmt"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+)
+
+// DecryptInputs holds the compressed points of one entry, as returned by
+// EntryDecryptInputs. G2b is empty when the entry has no extra term.
+type DecryptInputs struct {
+	G1b, G2b, R1 string
+}
+
+// fixedG2Lines are the precomputed Miller-loop lines of a fixed G2 point.
+type fixedG2Lines = [2][len(bls12381.LoopCounter) - 1]bls12381.LineEvaluationAff
+
+// DecryptBatch computes the DecryptToHash key of every entry at the shared
+// value sharedHex, in order.
+func DecryptBatch(entries []DecryptInputs, sharedHex string) ([]string, error) {
+	return DecryptBatchWithOptions(entries, sharedHex, DecryptOptions{})
+}
+
+// DecryptBatchWithOptions is DecryptBatch with explicit DecryptOptions,
+// applied to every entry. It stops at the first entry that fails.
+func DecryptBatchWithOptions(entries []DecryptInputs, sharedHex string, opts DecryptOptions) ([]string, error) {
+	skip := opts.UnsafeSkipSubgroupCheck
+
+	tag := domainTagFr()
+	if opts.DomainTagHex != "" {
+		var err error
+		if tag, err = parseDomainTagFr(opts.DomainTagHex); err != nil {
+			return nil, err
+		}
+	}
+
+	h0, err := parseG2CompressedHex(H0Hex)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := decodeG2CompressedHex(sharedHex, skip)
+	if err != nil {
+		return nil, fmt.Errorf("parse shared: %w", err)
+	}
+	h0Lines := bls12381.PrecomputeLines(h0)
+	sharedLines := bls12381.PrecomputeLines(shared)
+
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		g1b, err := decodeG1CompressedHex(e.G1b, skip)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: parse g1b: %w", i, err)
+		}
+		r1, err := decodeG1CompressedHex(e.R1, skip)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: parse r1: %w", i, err)
+		}
+		var negR1 bls12381.G1Affine
+		negR1.Neg(&r1)
+
+		// Pair skips points at infinity; the fixed-Q loop does not, so they
+		// are left out here (their pairing is 1).
+		var ps []bls12381.G1Affine
+		var lines []fixedG2Lines
+		if !g1b.IsInfinity() {
+			ps = append(ps, g1b)
+			lines = append(lines, h0Lines)
+		}
+		if !negR1.IsInfinity() && !shared.IsInfinity() {
+			ps = append(ps, negR1)
+			lines = append(lines, sharedLines)
+		}
+		var f bls12381.GT
+		f.SetOne()
+		if len(ps) > 0 {
+			if f, err = bls12381.MillerLoopFixedQ(ps, lines); err != nil {
+				return nil, fmt.Errorf("entry %d: miller loop: %w", i, err)
+			}
+		}
+
+		if e.G2b != "" {
+			g2b, err := decodeG2CompressedHex(e.G2b, skip)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: parse g2b: %w", i, err)
+			}
+			t, err := bls12381.MillerLoop([]bls12381.G1Affine{r1}, []bls12381.G2Affine{g2b})
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: miller loop(r1, g2b): %w", i, err)
+			}
+			f.Mul(&f, &t)
+		}
+
+		k := bls12381.FinalExponentiation(&f)
+		if keys[i], err = gtToHashFromGTWithTag(k, tag); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// detrand_test.go provides a deterministic randomness source for tests.
+//
+// groth16.Prove samples its blinding scalars (r, s) from crypto/rand.Reader and
+// gnark exposes no prover option to supply them, so the only way to make proof
+// bytes reproducible is to swap crypto/rand.Reader for the duration of the
+// call. That affects every goroutine in the process, and a proof generated
+// with a known seed is not zero-knowledge, so the hook lives in a _test.go
+// file where no shipped binary can reach it.
+//
+// groth16.Setup samples its toxic waste the same way, and the same hook lets
+// tests pin golden VK vectors. A seeded setup is worse than a seeded proof:
+// anyone who knows the seed can forge proofs for it.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+// randSwapMu serializes swaps of crypto/rand.Reader.
+var randSwapMu sync.Mutex
+
+// deterministicReader yields the stream SHA-256(seed || counter) for
+// counter = 0, 1, 2, ...
+type deterministicReader struct {
+	seed []byte
+	ctr  uint64
+	buf  []byte
+}
+
+func (d *deterministicReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(d.buf) == 0 {
+			var ctr [8]byte
+			binary.BigEndian.PutUint64(ctr[:], d.ctr)
+			d.ctr++
+			h := sha256.Sum256(append(append([]byte(nil), d.seed...), ctr[:]...))
+			d.buf = h[:]
+		}
+		c := copy(p[n:], d.buf)
+		d.buf = d.buf[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// withDeterministicRand runs fn with crypto/rand.Reader replaced by a
+// deterministicReader seeded with seed, restoring the original afterwards.
+func withDeterministicRand(seed []byte, fn func() error) error {
+	randSwapMu.Lock()
+	defer randSwapMu.Unlock()
+
+	orig := rand.Reader
+	rand.Reader = &deterministicReader{seed: append([]byte(nil), seed...)}
+	defer func() { rand.Reader = orig }()
+
+	return fn()
+}
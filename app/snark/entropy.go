@@ -0,0 +1,44 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// entropy.go holds the opt-in guard against short secrets. Tests prove with
+// a = 2 or a = r-1 on purpose, so nothing here runs unless a minimum is
+// asked for (`-min-entropy-bits`). Bit length is only an upper bound on the
+// entropy of a secret; the guard catches typos and test values that reach a
+// production listing, not a badly generated random number.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+)
+
+// ErrWeakSecret is returned (wrapped) when a secret has fewer bits than
+// the configured minimum.
+var ErrWeakSecret = errors.New("secret is shorter than the minimum bit length")
+
+// checkSecretBits rejects a when its value reduced into Fr, which is what
+// the circuit and hk see, is shorter than minBits. minBits <= 0 disables the
+// check.
+func checkSecretBits(a *big.Int, minBits int) error {
+	if minBits <= 0 {
+		return nil
+	}
+	reduced := new(big.Int).Mod(a, fr.Modulus())
+	if n := reduced.BitLen(); n < minBits {
+		return fmt.Errorf("%w: a has %d bits after reduction into Fr, want at least %d", ErrWeakSecret, n, minBits)
+	}
+	return nil
+}
+
+// validateMinEntropyBits checks a -min-entropy-bits value. Fr elements have
+// at most fr.Bits bits, so a larger minimum would reject every secret.
+func validateMinEntropyBits(n int) error {
+	if n < 0 || n > fr.Bits {
+		return fmt.Errorf("-min-entropy-bits must be in [0, %d] (got %d)", fr.Bits, n)
+	}
+	return nil
+}
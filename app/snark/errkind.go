@@ -0,0 +1,43 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// errkind.go defines the sentinels that classify input and verification
+// failures across the library. They are attached with withKind, which keeps
+// the message unchanged, so callers (notably -json-errors) branch with
+// errors.Is instead of matching text.
+package main
+
+import "errors"
+
+var (
+	// ErrBadHex marks input that is not valid hex or has the wrong length.
+	ErrBadHex = errors.New("malformed hex")
+	// ErrBadPoint marks bytes that do not decode to a valid curve point in
+	// the prime-order subgroup.
+	ErrBadPoint = errors.New("invalid curve point")
+	// ErrBadScalar marks a scalar that does not parse or is out of range.
+	ErrBadScalar = errors.New("invalid scalar")
+	// ErrWitnessMismatch marks public points that do not match the secrets
+	// they were supposedly derived from.
+	ErrWitnessMismatch = errors.New("public points do not match the secrets")
+	// ErrVerificationFailed marks a Groth16 proof that does not verify.
+	ErrVerificationFailed = errors.New("verification failed")
+)
+
+// kindError attaches a classification sentinel to err without changing its
+// message.
+type kindError struct {
+	err  error
+	kind error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.err, e.kind} }
+
+// withKind returns err tagged with kind, or nil if err is nil.
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{err: err, kind: kind}
+}
@@ -0,0 +1,344 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// explain.go walks through the Groth16 verification of one proof the way
+// gnark performs it, keeping every intermediate value so it can be printed.
+// debugVerify tries several formulations against out/ to find which one a
+// broken artifact disagrees with; this is the single documented version of
+// the equation, usable on any artifact verify accepts (`verify -explain`):
+//
+//	x      = public inputs || commitment wires (hash_to_field(D || committed publics))
+//	vk_x   = K[0] + Σ x[i]·K[i]
+//	kSum   = vk_x + Σ D[j]                       (the commitment term)
+//	e(A, B) == e(α, β) · e(kSum, γ) · e(C, δ)
+//
+// plus the Pedersen proof of knowledge for the commitments D[j].
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr/pedersen"
+	groth16bls "github.com/consensys/gnark/backend/groth16/bls12-381"
+	backend_witness "github.com/consensys/gnark/backend/witness"
+)
+
+// VerifyExplanation holds the intermediate values of one Groth16
+// verification. Holds and PoKErr together give gnark's verdict.
+type VerifyExplanation struct {
+	PublicInputs    fr.Vector // the circuit's public inputs, one-wire implicit
+	CommitmentWires fr.Vector // one per commitment, appended to PublicInputs
+	Committed       [][]int   // 1-based public input indices hashed into each wire
+
+	VKX            bls12381.G1Affine // K[0] + Σ x[i]·K[i] over publics and wires
+	CommitmentTerm bls12381.G1Affine // Σ D[j]; the identity without commitments
+	KSum           bls12381.G1Affine // VKX + CommitmentTerm, paired with γ
+
+	PoKErr error // Pedersen proof of knowledge check; nil when it passed or there are no commitments
+
+	EAB        bls12381.GT // e(A, B)
+	EAlphaBeta bls12381.GT // e(α, β)
+	EKSumGamma bls12381.GT // e(kSum, γ)
+	ECDelta    bls12381.GT // e(C, δ)
+	Right      bls12381.GT // e(α, β) · e(kSum, γ) · e(C, δ)
+	Holds      bool        // EAB == Right
+}
+
+// Valid reports whether the proof verifies: the pairing equation holds and
+// the commitment proof of knowledge checks out.
+func (ex *VerifyExplanation) Valid() bool {
+	return ex.Holds && ex.PoKErr == nil
+}
+
+// ExplainVerification recomputes the verification of proof against vk and
+// the public inputs pub (without the one-wire), keeping each step. It only
+// fails when the inputs do not fit the key; an invalid proof is reported in
+// the explanation.
+func ExplainVerification(vk *groth16bls.VerifyingKey, proof *groth16bls.Proof, pub fr.Vector) (*VerifyExplanation, error) {
+	ex, err := explainKSum(vk, proof, pub)
+	if err != nil {
+		return nil, err
+	}
+
+	// Four separate pairings, so each term can be shown; VerifyProofInMemory
+	// checks the same equation with one multi-pairing.
+	pairs := []struct {
+		out *bls12381.GT
+		p   bls12381.G1Affine
+		q   bls12381.G2Affine
+	}{
+		{&ex.EAB, proof.Ar, proof.Bs},
+		{&ex.EAlphaBeta, vk.G1.Alpha, vk.G2.Beta},
+		{&ex.EKSumGamma, ex.KSum, vk.G2.Gamma},
+		{&ex.ECDelta, proof.Krs, vk.G2.Delta},
+	}
+	for _, pr := range pairs {
+		if *pr.out, err = bls12381.Pair([]bls12381.G1Affine{pr.p}, []bls12381.G2Affine{pr.q}); err != nil {
+			return nil, fmt.Errorf("pairing: %w", err)
+		}
+	}
+	ex.Right.Mul(&ex.EAlphaBeta, &ex.EKSumGamma)
+	ex.Right.Mul(&ex.Right, &ex.ECDelta)
+	ex.Holds = ex.EAB.Equal(&ex.Right)
+	return ex, nil
+}
+
+// explainKSum computes the steps before the pairings: the commitment wires,
+// the commitment proof of knowledge, vk_x and kSum.
+func explainKSum(vk *groth16bls.VerifyingKey, proof *groth16bls.Proof, pub fr.Vector) (*VerifyExplanation, error) {
+	nCommit := len(vk.PublicAndCommitmentCommitted)
+	if want := len(vk.G1.K) - nCommit - 1; len(pub) != want {
+		return nil, fmt.Errorf("public inputs length mismatch: got %d, vk expects %d", len(pub), want)
+	}
+	if len(proof.Commitments) != nCommit {
+		return nil, fmt.Errorf("proof has %d commitments, vk expects %d", len(proof.Commitments), nCommit)
+	}
+
+	ex := &VerifyExplanation{PublicInputs: pub, Committed: vk.PublicAndCommitmentCommitted}
+
+	// Commitment wires, each hashed over the publics only (gnark indexes
+	// the committed inputs before any wire is appended).
+	commitmentsSerialized := make([]byte, 0, nCommit*fr.Bytes)
+	for i, idx := range vk.PublicAndCommitmentCommitted {
+		dec, err := commitmentWireFromIndices(proof.Commitments[i], idx, pub)
+		if err != nil {
+			return nil, fmt.Errorf("commitment wire %d: %w", i, err)
+		}
+		var w fr.Element
+		if _, err := w.SetString(dec); err != nil {
+			return nil, fmt.Errorf("commitment wire %d: %w", i, err)
+		}
+		ex.CommitmentWires = append(ex.CommitmentWires, w)
+		commitmentsSerialized = append(commitmentsSerialized, w.Marshal()...)
+	}
+
+	if len(vk.CommitmentKeys) > 0 {
+		challenge, err := fr.Hash(commitmentsSerialized, []byte("G16-BSB22"), 1)
+		if err != nil {
+			return nil, fmt.Errorf("commitment challenge: %w", err)
+		}
+		ex.PoKErr = pedersen.BatchVerifyMultiVk(vk.CommitmentKeys, proof.Commitments, []bls12381.G1Affine{proof.CommitmentPok}, challenge[0])
+	}
+
+	x := append(append(fr.Vector{}, pub...), ex.CommitmentWires...)
+	vkx, err := computeVKX(vk.G1.K, x)
+	if err != nil {
+		return nil, err
+	}
+	ex.VKX = vkx
+	for i := range proof.Commitments {
+		ex.CommitmentTerm.Add(&ex.CommitmentTerm, &proof.Commitments[i])
+	}
+	ex.KSum.Add(&ex.VKX, &ex.CommitmentTerm)
+	return ex, nil
+}
+
+// ErrPairingEquation is returned (wrapped) by VerifyProofInMemory when the
+// Groth16 pairing equation does not hold.
+var ErrPairingEquation = errors.New("pairing equation does not hold")
+
+// VerifyProofInMemory verifies proof against vk and the public inputs pub
+// (without the one-wire) by hand, independently of groth16.Verify: the
+// commitment proof of knowledge, then the pairing equation in its
+// multi-pairing form
+//
+//	e(A, B) · e(-α, β) · e(-kSum, γ) · e(-C, δ) == 1
+//
+// which runs one Miller loop over the four pairs and a single final
+// exponentiation instead of four full pairings.
+func VerifyProofInMemory(vk *groth16bls.VerifyingKey, proof *groth16bls.Proof, pub fr.Vector) error {
+	ex, err := explainKSum(vk, proof, pub)
+	if err != nil {
+		return err
+	}
+	if ex.PoKErr != nil {
+		return fmt.Errorf("commitment proof of knowledge: %w", ex.PoKErr)
+	}
+	ok, err := pairingEquationHolds(vk, proof, &ex.KSum)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPairingEquation
+	}
+	return nil
+}
+
+// pairingEquationHolds checks e(A, B) == e(α, β) · e(kSum, γ) · e(C, δ) as a
+// single pairing check, negating the G1 side of the right-hand terms.
+func pairingEquationHolds(vk *groth16bls.VerifyingKey, proof *groth16bls.Proof, kSum *bls12381.G1Affine) (bool, error) {
+	var negAlpha, negKSum, negC bls12381.G1Affine
+	negAlpha.Neg(&vk.G1.Alpha)
+	negKSum.Neg(kSum)
+	negC.Neg(&proof.Krs)
+	ok, err := bls12381.PairingCheck(
+		[]bls12381.G1Affine{proof.Ar, negAlpha, negKSum, negC},
+		[]bls12381.G2Affine{proof.Bs, vk.G2.Beta, vk.G2.Gamma, vk.G2.Delta},
+	)
+	if err != nil {
+		return false, fmt.Errorf("pairing: %w", err)
+	}
+	return ok, nil
+}
+
+// gtFingerprint names a GT element by the SHA-256 of its 576-byte encoding;
+// the full value is too long to compare by eye.
+func gtFingerprint(z *bls12381.GT) string {
+	sum := sha256.Sum256(z.Marshal())
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// WriteText prints the explanation step by step.
+func (ex *VerifyExplanation) WriteText(w io.Writer) {
+	g1Hex := func(p bls12381.G1Affine) string {
+		b := p.Bytes()
+		return hex.EncodeToString(b[:])
+	}
+	fmt.Fprintf(w, "public inputs (%d, one-wire implicit):\n", len(ex.PublicInputs))
+	for i := range ex.PublicInputs {
+		fmt.Fprintf(w, "  x[%d] = %s\n", i+1, ex.PublicInputs[i].String())
+	}
+	if len(ex.CommitmentWires) > 0 {
+		fmt.Fprintf(w, "commitment wires (%d), hash_to_field(D[j] || committed publics):\n", len(ex.CommitmentWires))
+		for j := range ex.CommitmentWires {
+			fmt.Fprintf(w, "  x[%d] = %s  (D[%d] over x%v)\n", len(ex.PublicInputs)+j+1, ex.CommitmentWires[j].String(), j, ex.Committed[j])
+		}
+	}
+
+	fmt.Fprintf(w, "step 1: vk_x = K[0] + Σ x[i]·K[i]\n  vk_x = %s\n", g1Hex(ex.VKX))
+	if len(ex.CommitmentWires) > 0 {
+		fmt.Fprintf(w, "step 2: fold in the commitments, kSum = vk_x + Σ D[j]\n  Σ D  = %s\n  kSum = %s\n", g1Hex(ex.CommitmentTerm), g1Hex(ex.KSum))
+		if ex.PoKErr != nil {
+			fmt.Fprintf(w, "  commitment proof of knowledge: FAIL (%v)\n", ex.PoKErr)
+		} else {
+			fmt.Fprintln(w, "  commitment proof of knowledge: ok")
+		}
+	} else {
+		fmt.Fprintln(w, "step 2: no commitments, kSum = vk_x")
+	}
+
+	fmt.Fprintln(w, "step 3: pairings (GT values as the SHA-256 of their encoding)")
+	fmt.Fprintf(w, "  e(A, B)    = %s\n", gtFingerprint(&ex.EAB))
+	fmt.Fprintf(w, "  e(α, β)    = %s\n", gtFingerprint(&ex.EAlphaBeta))
+	fmt.Fprintf(w, "  e(kSum, γ) = %s\n", gtFingerprint(&ex.EKSumGamma))
+	fmt.Fprintf(w, "  e(C, δ)    = %s\n", gtFingerprint(&ex.ECDelta))
+
+	fmt.Fprintln(w, "step 4: e(A, B) == e(α, β) · e(kSum, γ) · e(C, δ)")
+	fmt.Fprintf(w, "  right side = %s\n", gtFingerprint(&ex.Right))
+	if ex.Holds {
+		fmt.Fprintln(w, "  equation holds")
+	} else {
+		fmt.Fprintln(w, "  equation does NOT hold")
+	}
+}
+
+// ExplainFromDir loads the artifacts verify would check in dir (binary, or
+// JSON when fromJSON is set or only JSON is present) and explains their
+// verification. VKPath, LeadingWire, StrictJSON, ExpectedICLen and
+// RequireCommitment of opts apply as they do for verify; the other options
+// are ignored.
+func ExplainFromDir(dir string, fromJSON bool, opts VerifyOptions) (*VerifyExplanation, error) {
+	binMarker := "vk.bin"
+	if opts.VKPath != "" {
+		binMarker = "proof.bin"
+	}
+	if _, err := os.Stat(filepath.Join(dir, binMarker)); errors.Is(err, os.ErrNotExist) {
+		fromJSON = true
+	}
+
+	var (
+		vk    *groth16bls.VerifyingKey
+		proof *groth16bls.Proof
+		pub   fr.Vector
+		err   error
+	)
+	if opts.VKPath != "" {
+		if vk, err = loadVerifyingKeyFile(opts.VKPath, opts.StrictJSON, opts.AcceptUncompressedVK); err != nil {
+			return nil, err
+		}
+	}
+
+	if fromJSON {
+		var pj ProofJSON
+		var pubj PublicJSON
+		if vk != nil {
+			pj, pubj, err = loadProofArtifacts(dir, opts.StrictJSON)
+		} else {
+			var vkj VKJSON
+			vkj, pj, pubj, err = loadJSONArtifacts(dir, opts.StrictJSON)
+			if err == nil {
+				vk, err = vkFromJSON(vkj)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if proof, err = proofFromJSON(pj); err != nil {
+			return nil, fmt.Errorf("proof: %w", err)
+		}
+		if pub, err = publicInputs(pubj); err != nil {
+			return nil, err
+		}
+		want := len(vk.G1.K) - len(vk.PublicAndCommitmentCommitted) - 1
+		if pub, err = dropLeadingWire(pub, want, opts.LeadingWire); err != nil {
+			return nil, err
+		}
+	} else {
+		if vk == nil {
+			if vk, err = loadVerifyingKeyFile(filepath.Join(dir, "vk.bin"), opts.StrictJSON, false); err != nil {
+				return nil, err
+			}
+		}
+		if proof, pub, err = loadBinaryProof(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkICLen(vk, opts.ExpectedICLen); err != nil {
+		return nil, err
+	}
+	if err := checkCommitment(proof, opts.RequireCommitment); err != nil {
+		return nil, err
+	}
+	return ExplainVerification(vk, proof, pub)
+}
+
+// loadBinaryProof reads proof.bin and the public witness.bin from dir.
+func loadBinaryProof(dir string) (*groth16bls.Proof, fr.Vector, error) {
+	pf, err := os.Open(filepath.Join(dir, "proof.bin"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("open proof.bin: %w", err)
+	}
+	defer pf.Close()
+	proof := new(groth16bls.Proof)
+	if _, err := proof.ReadFrom(pf); err != nil {
+		return nil, nil, fmt.Errorf("read proof.bin: %w", err)
+	}
+
+	wf, err := os.Open(filepath.Join(dir, "witness.bin"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("open witness.bin: %w", err)
+	}
+	defer wf.Close()
+	witness, err := backend_witness.New(ecc.BLS12_381.ScalarField())
+	if err != nil {
+		return nil, nil, fmt.Errorf("new witness: %w", err)
+	}
+	if _, err := witness.ReadFrom(wf); err != nil {
+		return nil, nil, fmt.Errorf("read witness.bin: %w", err)
+	}
+	pub, err := witnessFrElements(witness)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proof, pub, nil
+}
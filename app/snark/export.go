@@ -7,13 +7,18 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
@@ -37,6 +42,9 @@ type CommitmentKeyJSON struct {
 }
 
 type VKJSON struct {
+	Curve          string              `json:"curve,omitempty"`       // CurveName; empty in artifacts exported before it was recorded
+	GnarkCrypto    string              `json:"gnarkCrypto,omitempty"` // gnark-crypto version of the exporting binary; informational only
+	CircuitHash    string              `json:"circuitHash,omitempty"` // see circuithash.go; empty when the exporter had no constraint system
 	NPublic        int                 `json:"nPublic"`
 	VkAlpha        string              `json:"vkAlpha"` // G1 compressed hex
 	VkBeta         string              `json:"vkBeta"`  // G2 compressed hex
@@ -51,6 +59,7 @@ type VKJSON struct {
 }
 
 type ProofJSON struct {
+	Curve         string   `json:"curve,omitempty"`         // CurveName; empty in artifacts exported before it was recorded
 	PiA           string   `json:"piA"`                     // G1 compressed hex
 	PiB           string   `json:"piB"`                     // G2 compressed hex
 	PiC           string   `json:"piC"`                     // G1 compressed hex
@@ -60,7 +69,19 @@ type ProofJSON struct {
 
 type PublicJSON struct {
 	Inputs         []string `json:"inputs"`                   // decimal strings in Fr
+	InputsHex      []string `json:"inputsHex,omitempty"`      // alternative to Inputs: 32-byte big-endian hex (written only by CanonicalizePublic)
 	CommitmentWire string   `json:"commitmentWire,omitempty"` // the computed commitment wire value (decimal Fr)
+	WitnessHash    string   `json:"witnessHash,omitempty"`    // sha256 of v||w0||w1 compressed, hex (vw0w1 proofs only; see witnesshash.go)
+}
+
+// BundleJSON is the combined all.json artifact: the three JSON exports in one
+// file, with the commitment wire lifted to the top level for convenience.
+type BundleJSON struct {
+	VK             VKJSON     `json:"vk"`
+	Proof          ProofJSON  `json:"proof"`
+	Public         PublicJSON `json:"public"`
+	CommitmentWire string     `json:"commitmentWire,omitempty"` // same value as Public.CommitmentWire
+	WitnessHash    string     `json:"witnessHash,omitempty"`    // same value as Public.WitnessHash
 }
 
 // ---------- extract proof/vk using concrete BLS12-381 Groth16 types ----------
@@ -88,7 +109,7 @@ func exportProofBLS(proof groth16.Proof) (ProofJSON, error) {
 		return ProofJSON{}, err
 	}
 
-	out := ProofJSON{PiA: piA, PiB: piB, PiC: piC}
+	out := ProofJSON{Curve: CurveName, PiA: piA, PiB: piB, PiC: piC}
 
 	// export commitment extension fields (if present)
 	if len(p.Commitments) > 0 {
@@ -151,12 +172,14 @@ func exportVKBLS(vk groth16.VerifyingKey, nPublic int) (VKJSON, error) {
 	}
 
 	out := VKJSON{
-		NPublic: nPublic,
-		VkAlpha: vkAlpha,
-		VkBeta:  vkBeta,
-		VkGamma: vkGamma,
-		VkDelta: vkDelta,
-		VkIC:    ic,
+		Curve:       CurveName,
+		GnarkCrypto: ReadBuildInfo().GnarkCrypto,
+		NPublic:     nPublic,
+		VkAlpha:     vkAlpha,
+		VkBeta:      vkBeta,
+		VkGamma:     vkGamma,
+		VkDelta:     vkDelta,
+		VkIC:        ic,
 	}
 
 	// export pedersen vk(s) used for the PoK check
@@ -362,9 +385,18 @@ func computeCommitmentWire(
 	}
 
 	// Get public witness as Fr elements
+	pubFr, err := witnessFrElements(publicWitness)
+	if err != nil {
+		return "", err
+	}
+	return computeCommitmentWireFr(proof, vk, pubFr)
+}
+
+// witnessFrElements returns the public witness vector as Fr elements.
+func witnessFrElements(publicWitness backend_witness.Witness) ([]fr.Element, error) {
 	vecAny := publicWitness.Vector()
 	if vecAny == nil {
-		return "", fmt.Errorf("publicWitness.Vector() returned nil")
+		return nil, fmt.Errorf("publicWitness.Vector() returned nil")
 	}
 
 	// Convert to []fr.Element
@@ -376,7 +408,7 @@ func computeCommitmentWire(
 		// Try reflection to extract Fr elements
 		rv := reflect.ValueOf(vecAny)
 		if rv.Kind() != reflect.Slice {
-			return "", fmt.Errorf("unexpected witness vector type: %T", vecAny)
+			return nil, fmt.Errorf("unexpected witness vector type: %T", vecAny)
 		}
 		pubFr = make([]fr.Element, rv.Len())
 		for i := 0; i < rv.Len(); i++ {
@@ -395,19 +427,30 @@ func computeCommitmentWire(
 					m.Call([]reflect.Value{reflect.ValueOf(&bi)})
 					pubFr[i].SetBigInt(&bi)
 				} else {
-					return "", fmt.Errorf("cannot convert witness[%d] to Fr: type %T", i, ev.Interface())
+					return nil, fmt.Errorf("cannot convert witness[%d] to Fr: type %T", i, ev.Interface())
 				}
 			}
 		}
 	}
+	return pubFr, nil
+}
+
+// computeCommitmentWireFr is computeCommitmentWire over an already decoded
+// public witness.
+func computeCommitmentWireFr(proof *groth16bls.Proof, vk *groth16bls.VerifyingKey, pubFr []fr.Element) (string, error) {
+	if len(proof.Commitments) == 0 || len(vk.PublicAndCommitmentCommitted) == 0 {
+		return "", nil // No commitment extension
+	}
 
-	// Build the prehash: D.RawBytes() || committed_publics.Marshal()
-	// gnark uses uncompressed point serialization (RawBytes, 96 bytes) for the hash
 	// Note: We only process the first commitment (gnark's standard case)
-	commitment := proof.Commitments[0]
-	committedIndices := vk.PublicAndCommitmentCommitted[0]
+	return commitmentWireFromIndices(proof.Commitments[0], vk.PublicAndCommitmentCommitted[0], pubFr)
+}
 
-	// Serialize commitment point
+// commitmentWireFromIndices hashes the commitment point together with the
+// public inputs at committedIndices, which are 1-based as in
+// vk.PublicAndCommitmentCommitted. It returns the wire as a decimal string.
+func commitmentWireFromIndices(commitment bls12381.G1Affine, committedIndices []int, pubFr []fr.Element) (string, error) {
+	// Build the prehash: D.RawBytes() || committed_publics.Marshal()
 	// gnark uses Marshal() which returns RawBytes() = uncompressed form (96 bytes)
 	commitmentBytes := commitment.Marshal()
 
@@ -447,32 +490,353 @@ func computeCommitmentWire(
 // ---------- main export ----------
 
 func ExportAll(vk groth16.VerifyingKey, proof groth16.Proof, publicWitness backend_witness.Witness, dir string) error {
+	return ExportAllWithOptions(vk, proof, publicWitness, dir, ExportOptions{})
+}
+
+// ExportOptions tunes ExportAllWithOptions. The zero value writes vk.json,
+// proof.json and public.json only.
+type ExportOptions struct {
+	// Bundle additionally writes all.json (see BundleJSON).
+	Bundle bool
+
+	// BundleOnly writes all.json instead of the three individual files.
+	// It implies Bundle.
+	BundleOnly bool
+
+	// WASMResult additionally writes result.json, the {proof, public} object
+	// the WASM prover returns (see ExternalProof). It carries the commitments,
+	// commitmentPok and commitmentWire the on-chain verifier needs, so it can
+	// be passed to `verify-only -result` or submitted as is. A proof with
+	// commitments but no computable wire is rejected.
+	WASMResult bool
+
+	// ExpectedICLen, if positive, is the number of vkIC points the on-chain
+	// verifier was compiled for; any other length fails before anything is
+	// written (see checkICLen).
+	ExpectedICLen int
+
+	// RequireCommitment rejects a proof without a BSB22 commitment before
+	// anything is written (see checkCommitment).
+	RequireCommitment bool
+
+	// VerifyAfterExport re-reads vk.json, proof.json and public.json (and
+	// all.json, if written) through the JSON importers and verifies them, so
+	// a serialization bug fails the export instead of the on-chain check.
+	VerifyAfterExport bool
+
+	// NbPublic, if positive, is the number of public inputs (without the
+	// one-wire) the compiled circuit declares (see circuitNbPublic). The
+	// witness must hold exactly that many, and public.json is laid out from
+	// it directly instead of reconciling the witness with the VK's IC length
+	// (choosePublicInputs). The prove paths set it; artifacts produced
+	// elsewhere leave it zero and keep the heuristic.
+	NbPublic int
+
+	// CircuitHash, if set, is stamped into vk.json (and all.json) as
+	// circuitHash. The prove paths set it from the constraint system they
+	// proved with (see SetupHandle.circuitHash).
+	CircuitHash string
+}
+
+// files lists the JSON artifacts ExportAllWithOptions writes for opts.
+func (opts ExportOptions) files() []string {
+	var names []string
+	if !opts.BundleOnly {
+		names = append(names, "vk.json", "proof.json", "public.json")
+	}
+	if opts.Bundle || opts.BundleOnly {
+		names = append(names, "all.json")
+	}
+	if opts.WASMResult {
+		names = append(names, "result.json")
+	}
+	return names
+}
+
+// nativeFiles lists the binaries written by SaveNativeFiles.
+var nativeFiles = []string{"vk.bin", "proof.bin", "witness.bin"}
+
+// OutputFormat selects which artifact families the prove paths write.
+type OutputFormat string
+
+const (
+	FormatBoth OutputFormat = ""     // JSON artifacts and native binaries (the default)
+	FormatJSON OutputFormat = "json" // vk.json, proof.json, public.json (and all.json) only
+	FormatBin  OutputFormat = "bin"  // vk.bin, proof.bin, witness.bin only
+)
+
+// ParseOutputFormat parses the -output-format flag: "both", "json" or "bin".
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch s {
+	case "", "both":
+		return FormatBoth, nil
+	case "json":
+		return FormatJSON, nil
+	case "bin":
+		return FormatBin, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (want both, json or bin)", s)
+}
+
+// artifactFiles lists every file the prove paths write to outDir for opts.
+func (opts ProveOptions) artifactFiles() []string {
+	var names []string
+	if opts.Format != FormatBin {
+		names = append(names, opts.Export.files()...)
+	}
+	if opts.Format != FormatJSON {
+		names = append(names, nativeFiles...)
+	}
+	return names
+}
+
+// checkArtifacts validates the artifact selection in opts and, unless
+// opts.Force is set, refuses to overwrite any file that would be written.
+func checkArtifacts(outDir string, opts ProveOptions) error {
+	if opts.NoExport {
+		return nil
+	}
+	if _, err := ParseOutputFormat(string(opts.Format)); err != nil {
+		return err
+	}
+	if opts.Format == FormatBin && (opts.Export.Bundle || opts.Export.BundleOnly) {
+		return fmt.Errorf("the bin output format writes no JSON, so it cannot be combined with Bundle or BundleOnly")
+	}
+	if opts.Force {
+		return nil
+	}
+	return checkOverwrite(outDir, opts.artifactFiles())
+}
+
+// writeArtifacts writes the JSON artifacts and/or the native binaries
+// selected by opts.Format to outDir. The public witness must hold the number
+// of public inputs h.CCS declares.
+func writeArtifacts(h *SetupHandle, proof groth16.Proof, publicWitness backend_witness.Witness, outDir string, opts ProveOptions) error {
+	ccs, vk := h.CCS, h.VK
+	// Checked here as well so -output-format bin is covered.
+	if err := checkICLen(vk, opts.Export.ExpectedICLen); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err := checkCommitment(proof, opts.Export.RequireCommitment); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	opts.Export.NbPublic = circuitNbPublic(ccs)
+	if opts.Format != FormatBin {
+		var err error
+		if opts.Export.CircuitHash, err = h.circuitHash(); err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+	}
+	pub, err := witnessFrElements(publicWitness)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err := checkPublicCount(len(pub), opts.Export.NbPublic); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if opts.Format != FormatBin {
+		if err := ExportAllWithOptions(vk, proof, publicWitness, outDir, opts.Export); err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+	}
+	if opts.Format != FormatJSON {
+		// gnark native binary files for standalone verification
+		if err := SaveNativeFiles(vk, proof, publicWitness, outDir); err != nil {
+			return fmt.Errorf("save native files: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkOverwrite returns an error naming every file in names that already
+// exists in dir. A missing dir is fine. It runs before any proving work so a
+// stale -out directory is reported up front rather than after a long prove.
+func checkOverwrite(dir string, names []string) error {
+	var existing []string
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			existing = append(existing, name)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("stat %s: %w", name, err)
+		}
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("output directory %s already contains %s (use -force to overwrite)",
+			dir, strings.Join(existing, ", "))
+	}
+	return nil
+}
+
+// ExportAllWithOptions is ExportAll with explicit ExportOptions.
+func ExportAllWithOptions(vk groth16.VerifyingKey, proof groth16.Proof, publicWitness backend_witness.Witness, dir string, opts ExportOptions) error {
+	bundle, err := buildBundleJSON(vk, proof, publicWitness, opts.NbPublic)
+	if err != nil {
+		return err
+	}
+	bundle.VK.CircuitHash = opts.CircuitHash
+	if err := checkICLen(vk, opts.ExpectedICLen); err != nil {
+		return err
+	}
+	if err := checkCommitment(proof, opts.RequireCommitment); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	writeFile := func(name string, write func(io.Writer) error) error {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return write(f)
+	}
+	writeJSON := func(name string, val interface{}) error {
+		return writeFile(name, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(val)
+		})
+	}
+
+	if !opts.BundleOnly {
+		if err := writeJSON("vk.json", bundle.VK); err != nil {
+			return err
+		}
+		if err := writeJSON("proof.json", bundle.Proof); err != nil {
+			return err
+		}
+		if err := writeFile("public.json", func(w io.Writer) error { return writePublicJSON(w, bundle.Public) }); err != nil {
+			return err
+		}
+	}
+
+	if opts.Bundle || opts.BundleOnly {
+		if err := writeJSON("all.json", bundle); err != nil {
+			return err
+		}
+	}
+
+	if opts.WASMResult {
+		if len(bundle.Proof.Commitments) > 0 && bundle.Public.CommitmentWire == "" {
+			return fmt.Errorf("result.json: the proof has commitments but no commitment wire was computed")
+		}
+		if err := writeJSON("result.json", ExternalProof{Proof: bundle.Proof, Public: bundle.Public}); err != nil {
+			return err
+		}
+	}
+
+	if opts.VerifyAfterExport {
+		if err := verifyExported(dir, opts); err != nil {
+			return fmt.Errorf("verify after export: %w", err)
+		}
+	}
+	return nil
+}
+
+// writePublicJSON writes pub to w exactly as an indented json.Encoder would,
+// but one input at a time, so a batch proof's public vector is never encoded
+// into a single buffer. The field layout mirrors PublicJSON's tags;
+// TestWritePublicJSON_CoversEveryField fails when a field is added there
+// and not here.
+func writePublicJSON(w io.Writer, pub PublicJSON) error {
+	bw := bufio.NewWriter(w)
+	writeArray := func(key string, vals []string) {
+		switch {
+		case vals == nil:
+			fmt.Fprintf(bw, "  %q: null", key)
+			return
+		case len(vals) == 0:
+			fmt.Fprintf(bw, "  %q: []", key)
+			return
+		}
+		fmt.Fprintf(bw, "  %q: [\n", key)
+		for i, v := range vals {
+			bw.WriteString("    ")
+			writeJSONString(bw, v)
+			if i < len(vals)-1 {
+				bw.WriteByte(',')
+			}
+			bw.WriteByte('\n')
+		}
+		bw.WriteString("  ]")
+	}
+
+	bw.WriteString("{\n")
+	writeArray("inputs", pub.Inputs)
+	if len(pub.InputsHex) > 0 {
+		bw.WriteString(",\n")
+		writeArray("inputsHex", pub.InputsHex)
+	}
+	if pub.CommitmentWire != "" {
+		bw.WriteString(",\n  \"commitmentWire\": ")
+		writeJSONString(bw, pub.CommitmentWire)
+	}
+	if pub.WitnessHash != "" {
+		bw.WriteString(",\n  \"witnessHash\": ")
+		writeJSONString(bw, pub.WitnessHash)
+	}
+	bw.WriteString("\n}\n")
+	// bufio.Writer errors are sticky, so Flush reports any earlier failure.
+	return bw.Flush()
+}
+
+// writeJSONString writes s as a JSON string literal, escaped as json.Encoder
+// escapes it. json.Marshal of a string cannot fail.
+func writeJSONString(bw *bufio.Writer, s string) {
+	b, _ := json.Marshal(s)
+	bw.Write(b)
+}
+
+// BuildBundleJSON converts a proof, its public witness and the verifying key
+// to the exported JSON forms in memory, as ExportAllWithOptions writes them.
+func BuildBundleJSON(vk groth16.VerifyingKey, proof groth16.Proof, publicWitness backend_witness.Witness) (BundleJSON, error) {
+	return buildBundleJSON(vk, proof, publicWitness, 0)
+}
+
+// buildBundleJSON is BuildBundleJSON for a circuit declaring nbPublic public
+// inputs; 0 means unknown (see ExportOptions.NbPublic).
+func buildBundleJSON(vk groth16.VerifyingKey, proof groth16.Proof, publicWitness backend_witness.Witness, nbPublic int) (BundleJSON, error) {
 	// 1) Export proof.
 	pj, err := exportProofBLS(proof)
 	if err != nil {
-		return err
+		return BundleJSON{}, err
 	}
 
 	// 2) Export raw publics (ground truth from witness.Vector()).
 	pubRaw, err := exportPublicInputs(publicWitness)
 	if err != nil {
-		return err
+		return BundleJSON{}, err
 	}
 
 	// 3) Determine IC length from VK.
 	v, ok := vk.(*groth16bls.VerifyingKey)
 	if !ok {
-		return fmt.Errorf("unexpected vk type (need *groth16/bls12-381.VerifyingKey): %T", vk)
+		return BundleJSON{}, fmt.Errorf("unexpected vk type (need *groth16/bls12-381.VerifyingKey): %T", vk)
 	}
 	if len(v.G1.K) < 1 {
-		return fmt.Errorf("invalid vk: IC empty")
+		return BundleJSON{}, fmt.Errorf("invalid vk: IC empty")
 	}
 	icLen := len(v.G1.K)
 
 	// 4) Choose which publics to export (must match IC length semantics).
-	pub, err := choosePublicInputs(pubRaw, icLen)
-	if err != nil {
-		return err
+	//    When the circuit's count is known the layout follows from it: the
+	//    one-wire "1" leads exactly when the VK has commitments, which is
+	//    where choosePublicInputs lands for our circuits.
+	nCommitments := len(v.CommitmentKeys)
+	var pub []string
+	if nbPublic > 0 {
+		if err := checkPublicCount(len(pubRaw), nbPublic); err != nil {
+			return BundleJSON{}, err
+		}
+		pub = pubRaw
+		if nCommitments > 0 {
+			pub = append([]string{"1"}, pubRaw...)
+		}
+	} else if pub, err = choosePublicInputs(pubRaw, icLen); err != nil {
+		return BundleJSON{}, err
 	}
 	nPublic := len(pub)
 
@@ -480,10 +844,9 @@ func ExportAll(vk groth16.VerifyingKey, proof groth16.Proof, publicWitness backe
 	// where nRawPublic is the original circuit's public input count (before any "1" is prepended)
 	// The "1" added by choosePublicInputs is just for export format, not an actual IC element.
 	nRawPublic := len(pubRaw)
-	nCommitments := len(v.CommitmentKeys)
 	expectedICLen := nRawPublic + 1 + nCommitments
 	if icLen != expectedICLen {
-		return fmt.Errorf(
+		return BundleJSON{}, fmt.Errorf(
 			"export invariant failed: len(vk.IC)=%d but expected %d (nRawPublic=%d, nCommitments=%d)",
 			icLen, expectedICLen, nRawPublic, nCommitments,
 		)
@@ -492,52 +855,31 @@ func ExportAll(vk groth16.VerifyingKey, proof groth16.Proof, publicWitness backe
 	// 5) Export VK sliced to nPublic+1 (matches the exported public vector).
 	vkj, err := exportVKBLS(vk, nPublic)
 	if err != nil {
-		return err
+		return BundleJSON{}, err
 	}
 
 	// 6) Final consistency checks.
 	if len(vkj.VkIC) != expectedICLen {
-		return fmt.Errorf("IC length mismatch: len(IC)=%d, expected %d", len(vkj.VkIC), expectedICLen)
-	}
-
-	// 7) Write JSONs.
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err
-	}
-
-	writeJSON := func(name string, val interface{}) error {
-		f, err := os.Create(filepath.Join(dir, name))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		enc := json.NewEncoder(f)
-		enc.SetIndent("", "  ")
-		return enc.Encode(val)
+		return BundleJSON{}, fmt.Errorf("IC length mismatch: len(IC)=%d, expected %d", len(vkj.VkIC), expectedICLen)
 	}
 
-	if err := writeJSON("vk.json", vkj); err != nil {
-		return err
-	}
-	if err := writeJSON("proof.json", pj); err != nil {
-		return err
-	}
-
-	// 8) Compute commitment wire if applicable
+	// 7) Compute commitment wire if applicable
 	p, ok := proof.(*groth16bls.Proof)
 	if !ok {
-		return fmt.Errorf("unexpected proof type: %T", proof)
+		return BundleJSON{}, fmt.Errorf("unexpected proof type: %T", proof)
 	}
 	commitmentWire, err := computeCommitmentWire(p, v, publicWitness)
 	if err != nil {
-		return fmt.Errorf("compute commitment wire: %w", err)
+		return BundleJSON{}, fmt.Errorf("compute commitment wire: %w", err)
 	}
-
-	if err := writeJSON("public.json", PublicJSON{Inputs: pub, CommitmentWire: commitmentWire}); err != nil {
-		return err
+	// 8) witnessHash, when the publics are vw0w1 statements.
+	var witnessHash string
+	if vec, ok := publicWitness.Vector().(fr.Vector); ok {
+		witnessHash = witnessHashFromPublic(vec)
 	}
+	pubj := PublicJSON{Inputs: pub, CommitmentWire: commitmentWire, WitnessHash: witnessHash}
 
-	return nil
+	return BundleJSON{VK: vkj, Proof: pj, Public: pubj, CommitmentWire: commitmentWire, WitnessHash: witnessHash}, nil
 }
 
 // ---------- compression helpers ----------
@@ -566,6 +908,8 @@ func g2CompressedHex(p bls12381.G2Affine) (string, error) {
 
 // SaveNativeFiles writes gnark's native binary serialization of VK, Proof, and public witness.
 // These files can be loaded later for standalone verification without recompiling the circuit.
+// witness.bin only ever holds the public part: a full witness is reduced with
+// Public() first, so no secret value reaches the files handed to a verifier.
 func SaveNativeFiles(vk groth16.VerifyingKey, proof groth16.Proof, publicWitness backend_witness.Witness, dir string) error {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return err
@@ -591,31 +935,195 @@ func SaveNativeFiles(vk groth16.VerifyingKey, proof groth16.Proof, publicWitness
 		return fmt.Errorf("write proof.bin: %w", err)
 	}
 
-	// Write public witness
+	// Write public witness. Public() is a no-op copy for a public witness and
+	// strips the secret values from a full one.
+	publicOnly, err := publicWitness.Public()
+	if err != nil {
+		return fmt.Errorf("public witness: %w", err)
+	}
 	witnessFile, err := os.Create(filepath.Join(dir, "witness.bin"))
 	if err != nil {
 		return fmt.Errorf("create witness.bin: %w", err)
 	}
 	defer witnessFile.Close()
-	if _, err := publicWitness.WriteTo(witnessFile); err != nil {
+	if _, err := publicOnly.WriteTo(witnessFile); err != nil {
 		return fmt.Errorf("write witness.bin: %w", err)
 	}
 
 	return nil
 }
 
+// ErrWireMismatch is returned (wrapped) when a proof verifies but its
+// commitment wire differs from VerifyOptions.ExpectWire.
+var ErrWireMismatch = errors.New("commitment wire mismatch")
+
+// VerifyOptions tunes VerifyFromFilesWithOptions and VerifyJSONWithOptions.
+// The zero value only verifies the proof.
+type VerifyOptions struct {
+	// ExpectWire, if set, is the decimal commitment wire the caller computed.
+	// After the proof verifies, the wire is recomputed from the proof and
+	// public witness and compared; a difference yields ErrWireMismatch.
+	ExpectWire string
+
+	// Canonical, if set, holds the points the proof is about. Before the
+	// proof is verified, its public inputs are checked against the
+	// canonical vw0w1 order for them; a difference yields
+	// ErrPublicInputOrder.
+	Canonical *VW0W1Points
+
+	// LeadingWire declares whether JSON public inputs start with the
+	// one-wire. The default infers it. It does not apply to witness.bin.
+	LeadingWire LeadingWire
+
+	// ExpectedICLen, if positive, is checked against the verifying key's IC
+	// length before the proof is verified (see checkICLen).
+	ExpectedICLen int
+
+	// RequireCommitment rejects a proof without a BSB22 commitment before it
+	// is verified (see checkCommitment).
+	RequireCommitment bool
+
+	// VKPath, if set, is a vk.bin or vk.json to verify against instead of the
+	// verifying key stored with the proof. Pointing it at the ceremony's VK
+	// keeps a stale or substituted VK next to a proof from vouching for it.
+	// Directory-based verifiers only; VerifyJSONWithOptions is handed its VK.
+	VKPath string
+
+	// AcceptUncompressedVK lets VKPath be a verifying key in decimal affine
+	// coordinates, as snarkjs and the Solidity verifier generators write it
+	// (see vkdecimal.go). Such a key has no commitment keys, so it only
+	// verifies proofs without a BSB22 commitment.
+	AcceptUncompressedVK bool
+
+	// StrictJSON rejects JSON artifacts (vk.json, proof.json, public.json,
+	// all.json) that carry a field the importers do not know, instead of
+	// ignoring it. A misspelled "piAA" would otherwise decode as a missing
+	// point. Binary artifacts are unaffected.
+	StrictJSON bool
+}
+
+// ErrICLenMismatch is returned (wrapped) when a verifying key does not have
+// the number of IC points the caller expects.
+var ErrICLenMismatch = errors.New("vk IC length mismatch")
+
+// checkICLen compares the IC length of vk (vkIC in vk.json) with want. An
+// on-chain verifier is compiled for one length,
+//
+//	len(IC) = nPublic + 1 + nCommitments
+//
+// where nPublic counts the public inputs without the one-wire and each BSB22
+// commitment adds a point; vw0w1 has 36 + 1 + 1 = 38. A VK of any other
+// length cannot verify there, so the mismatch is reported with its
+// breakdown. want <= 0 skips the check.
+func checkICLen(vk groth16.VerifyingKey, want int) error {
+	if want <= 0 {
+		return nil
+	}
+	v, ok := vk.(*groth16bls.VerifyingKey)
+	if !ok {
+		return fmt.Errorf("unexpected vk type (need *groth16/bls12-381.VerifyingKey): %T", vk)
+	}
+	got := len(v.G1.K)
+	if got == want {
+		return nil
+	}
+	nCommitments := len(v.CommitmentKeys)
+	return fmt.Errorf("%w: vk has %d IC points (nPublic=%d + 1 one-wire + nCommitments=%d), expected %d",
+		ErrICLenMismatch, got, got-1-nCommitments, nCommitments, want)
+}
+
+// ErrMissingCommitment is returned (wrapped) when a proof that must carry a
+// BSB22 commitment has none.
+var ErrMissingCommitment = errors.New("proof has no commitment")
+
+// checkCommitment rejects a proof without a BSB22 commitment when require is
+// set. Every vw0w1 proof carries one; a proof from a circuit built without
+// it still verifies against its own VK, and computes an empty commitment
+// wire (see ComputeCommitmentWireNoVK), but fails on chain, where the
+// verifier always folds the commitment in.
+func checkCommitment(proof groth16.Proof, require bool) error {
+	if !require {
+		return nil
+	}
+	p, ok := proof.(*groth16bls.Proof)
+	if !ok {
+		return fmt.Errorf("unexpected proof type (need *groth16/bls12-381.Proof): %T", proof)
+	}
+	if len(p.Commitments) == 0 {
+		return fmt.Errorf("%w: the circuit is expected to use the commitment extension", ErrMissingCommitment)
+	}
+	return nil
+}
+
+// checkExpectedWire compares the recomputed commitment wire with expect.
+func checkExpectedWire(proof *groth16bls.Proof, vk *groth16bls.VerifyingKey, pubFr []fr.Element, expect string) error {
+	if expect == "" {
+		return nil
+	}
+	want, ok := new(big.Int).SetString(expect, 10)
+	if !ok {
+		return fmt.Errorf("expected wire is not a decimal integer: %q", expect)
+	}
+	gotStr, err := computeCommitmentWireFr(proof, vk, pubFr)
+	if err != nil {
+		return fmt.Errorf("recompute commitment wire: %w", err)
+	}
+	if gotStr == "" {
+		return fmt.Errorf("%w: proof has no commitment, expected %s", ErrWireMismatch, want)
+	}
+	got, _ := new(big.Int).SetString(gotStr, 10)
+	if got.Cmp(want) != 0 {
+		return fmt.Errorf("%w: recomputed %s, expected %s", ErrWireMismatch, got, want)
+	}
+	return nil
+}
+
 // VerifyFromFiles loads VK, Proof, and public witness from binary files and verifies.
+// If dir has no vk.bin it falls back to the JSON artifacts (all.json, or
+// vk.json/proof.json/public.json) via VerifyJSONFromDir.
 func VerifyFromFiles(dir string) error {
-	// Load VK
-	vkFile, err := os.Open(filepath.Join(dir, "vk.bin"))
-	if err != nil {
-		return fmt.Errorf("open vk.bin: %w", err)
+	return VerifyFromFilesWithOptions(dir, VerifyOptions{})
+}
+
+// VerifyFromFilesWithOptions is VerifyFromFiles with explicit VerifyOptions.
+func VerifyFromFilesWithOptions(dir string, opts VerifyOptions) error {
+	// With an explicit VK the directory only has to hold the proof, so the
+	// binary proof is what tells the two layouts apart.
+	binMarker, jsonMarker := "vk.bin", "vk.json"
+	if opts.VKPath != "" {
+		binMarker, jsonMarker = "proof.bin", "proof.json"
+	}
+	if _, err := os.Stat(filepath.Join(dir, binMarker)); errors.Is(err, os.ErrNotExist) {
+		if _, jerr := os.Stat(filepath.Join(dir, "all.json")); jerr == nil {
+			return VerifyJSONFromDirWithOptions(dir, opts)
+		}
+		if _, jerr := os.Stat(filepath.Join(dir, jsonMarker)); jerr == nil {
+			return VerifyJSONFromDirWithOptions(dir, opts)
+		}
 	}
-	defer vkFile.Close()
 
-	vk := groth16.NewVerifyingKey(ecc.BLS12_381)
-	if _, err := vk.ReadFrom(vkFile); err != nil {
-		return fmt.Errorf("read vk.bin: %w", err)
+	// Load VK
+	var vk groth16.VerifyingKey
+	if opts.VKPath != "" {
+		v, err := loadVerifyingKeyFile(opts.VKPath, opts.StrictJSON, opts.AcceptUncompressedVK)
+		if err != nil {
+			return err
+		}
+		vk = v
+	} else {
+		vkFile, err := os.Open(filepath.Join(dir, "vk.bin"))
+		if err != nil {
+			return fmt.Errorf("open vk.bin: %w", err)
+		}
+		defer vkFile.Close()
+
+		vk = groth16.NewVerifyingKey(ecc.BLS12_381)
+		if _, err := vk.ReadFrom(vkFile); err != nil {
+			return fmt.Errorf("read vk.bin: %w", err)
+		}
+	}
+	if err := checkICLen(vk, opts.ExpectedICLen); err != nil {
+		return err
 	}
 
 	// Load Proof
@@ -629,6 +1137,9 @@ func VerifyFromFiles(dir string) error {
 	if _, err := proof.ReadFrom(proofFile); err != nil {
 		return fmt.Errorf("read proof.bin: %w", err)
 	}
+	if err := checkCommitment(proof, opts.RequireCommitment); err != nil {
+		return err
+	}
 
 	// Load public witness
 	witnessFile, err := os.Open(filepath.Join(dir, "witness.bin"))
@@ -645,9 +1156,35 @@ func VerifyFromFiles(dir string) error {
 		return fmt.Errorf("read witness.bin: %w", err)
 	}
 
+	if opts.Canonical != nil {
+		pubFr, err := witnessFrElements(witness)
+		if err != nil {
+			return err
+		}
+		if err := checkCanonical(pubFr, opts.Canonical); err != nil {
+			return err
+		}
+	}
+
 	// Verify using gnark's built-in verification
 	if err := groth16.Verify(proof, vk, witness); err != nil {
-		return fmt.Errorf("verification failed: %w", err)
+		return fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+	}
+
+	if opts.ExpectWire != "" {
+		p, ok := proof.(*groth16bls.Proof)
+		if !ok {
+			return fmt.Errorf("unexpected proof type: %T", proof)
+		}
+		v, ok := vk.(*groth16bls.VerifyingKey)
+		if !ok {
+			return fmt.Errorf("unexpected vk type: %T", vk)
+		}
+		pubFr, err := witnessFrElements(witness)
+		if err != nil {
+			return err
+		}
+		return checkExpectedWire(p, v, pubFr, opts.ExpectWire)
 	}
 
 	return nil
@@ -658,91 +1195,385 @@ func VerifyFromFiles(dir string) error {
 // SaveSetupFiles writes the compiled constraint system, proving key, and verifying key.
 // These files are generated once during setup and reused for all future proofs.
 func SaveSetupFiles(ccs constraint.ConstraintSystem, pk groth16.ProvingKey, vk groth16.VerifyingKey, dir string) error {
+	return SaveSetupFilesWithOptions(ccs, pk, vk, dir, SaveOptions{})
+}
+
+// SaveOptions tunes how setup keys are written. The zero value writes the
+// default compressed encoding.
+type SaveOptions struct {
+	// Raw writes pk.bin with WriteRawTo (uncompressed points). The file is
+	// roughly twice as large but loads much faster because no point has to be
+	// decompressed. Loaders detect the encoding on their own. ccs.bin has a
+	// single encoding and is unaffected.
+	Raw bool
+
+	// PKSplit, when positive, writes the proving key as that many byte-range
+	// shards pk_0000.bin.. plus pk_shards.json instead of one pk.bin (see
+	// pksplit.go). Loaders reassemble the shards on their own.
+	PKSplit int
+}
+
+// SaveSetupFilesWithOptions is SaveSetupFiles with explicit SaveOptions.
+func SaveSetupFilesWithOptions(ccs constraint.ConstraintSystem, pk groth16.ProvingKey, vk groth16.VerifyingKey, dir string, opts SaveOptions) error {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return err
 	}
 
 	// Write CCS (compiled constraint system)
-	ccsFile, err := os.Create(filepath.Join(dir, "ccs.bin"))
-	if err != nil {
-		return fmt.Errorf("create ccs.bin: %w", err)
-	}
-	defer ccsFile.Close()
-	if _, err := ccs.WriteTo(ccsFile); err != nil {
-		return fmt.Errorf("write ccs.bin: %w", err)
+	if err := saveCCS(filepath.Join(dir, "ccs.bin"), ccs); err != nil {
+		return err
 	}
 
 	// Write PK (proving key)
-	pkFile, err := os.Create(filepath.Join(dir, "pk.bin"))
-	if err != nil {
-		return fmt.Errorf("create pk.bin: %w", err)
-	}
-	defer pkFile.Close()
-	if _, err := pk.WriteTo(pkFile); err != nil {
-		return fmt.Errorf("write pk.bin: %w", err)
+	if err := writeProvingKeyFiles(dir, pk, opts); err != nil {
+		return err
 	}
 
 	// Write VK (verifying key)
-	vkFile, err := os.Create(filepath.Join(dir, "vk.bin"))
+	return writeVerifyingKey(filepath.Join(dir, "vk.bin"), vk)
+}
+
+// writeVerifyingKey writes vk to path atomically.
+func writeVerifyingKey(path string, vk groth16.VerifyingKey) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := vk.WriteTo(w)
+		return err
+	})
+}
+
+// writeProvingKey writes pk to path atomically, uncompressed when raw is set.
+func writeProvingKey(path string, pk groth16.ProvingKey, raw bool) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return writeProvingKeyTo(w, pk, raw)
+	})
+}
+
+// writeProvingKeyTo serializes pk to w, uncompressed when raw is set.
+func writeProvingKeyTo(w io.Writer, pk groth16.ProvingKey, raw bool) error {
+	var err error
+	if raw {
+		_, err = pk.WriteRawTo(w)
+	} else {
+		_, err = pk.WriteTo(w)
+	}
+	return err
+}
+
+// LoadSetupFiles loads the compiled constraint system, proving key, and verifying key from disk.
+// Returns (ccs, pk, vk, error).
+func LoadSetupFiles(dir string) (constraint.ConstraintSystem, groth16.ProvingKey, groth16.VerifyingKey, error) {
+	return LoadSetupFilesWithOptions(dir, LoadOptions{})
+}
+
+// LoadOptions tunes LoadSetupFilesWithOptions. The zero value is the default read path.
+type LoadOptions struct {
+	// MmapPK deserializes pk.bin from a read-only memory mapping instead of
+	// streaming it through a file reader, lowering peak RSS while loading.
+	// Platforms without mmap silently fall back to the regular read path.
+	MmapPK bool
+
+	// Parallel deserializes ccs.bin and pk.bin in two goroutines instead of
+	// one after the other, cutting cold-start time on multicore machines. The
+	// loaded structures are the same either way; only the two decoders run at
+	// once. At most one parallel load runs per process, so concurrent opens
+	// cannot stack their peaks.
+	Parallel bool
+
+	// UnsafeUncheckedPK reads a raw (WriteRawTo) pk.bin with UnsafeReadFrom,
+	// which skips the subgroup check ReadFrom runs on every point. Only use
+	// it for a key you trust: a corrupted one then loads without error and
+	// yields proofs that fail verification. Compressed keys are always
+	// checked.
+	UnsafeUncheckedPK bool
+}
+
+// parallelLoadSlot admits one parallel setup load at a time.
+var parallelLoadSlot = make(chan struct{}, 1)
+
+// errMmapUnsupported is returned by mmapFile on platforms without mmap.
+var errMmapUnsupported = errors.New("mmap not supported on this platform")
+
+// LoadSetupFilesWithOptions is LoadSetupFiles with explicit LoadOptions.
+func LoadSetupFilesWithOptions(dir string, opts LoadOptions) (constraint.ConstraintSystem, groth16.ProvingKey, groth16.VerifyingKey, error) {
+	// Load CCS and PK
+	ccs, pk, err := loadCCSAndPK(dir, opts)
 	if err != nil {
-		return fmt.Errorf("create vk.bin: %w", err)
+		return nil, nil, nil, err
+	}
+
+	// Load VK
+	vkFile, err := os.Open(filepath.Join(dir, "vk.bin"))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("open vk.bin: %w", err)
 	}
 	defer vkFile.Close()
-	if _, err := vk.WriteTo(vkFile); err != nil {
-		return fmt.Errorf("write vk.bin: %w", err)
+
+	vk, err := readVerifyingKey(vkFile)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("read vk.bin: %w", err)
 	}
 
-	return nil
+	if err := checkSetupConsistency(ccs, pk, vk); err != nil {
+		return nil, nil, nil, err
+	}
+	return ccs, pk, vk, nil
 }
 
-// LoadSetupFiles loads the compiled constraint system, proving key, and verifying key from disk.
-// Returns (ccs, pk, vk, error).
-func LoadSetupFiles(dir string) (constraint.ConstraintSystem, groth16.ProvingKey, groth16.VerifyingKey, error) {
-	// Load CCS
-	ccsFile, err := os.Open(filepath.Join(dir, "ccs.bin"))
+// loadCCSAndPK loads ccs.bin and pk.bin from dir, concurrently when
+// opts.Parallel is set. A ccs.bin error is reported before a pk.bin error.
+func loadCCSAndPK(dir string, opts LoadOptions) (constraint.ConstraintSystem, groth16.ProvingKey, error) {
+	if !opts.Parallel {
+		ccs, err := loadCCS(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		pk, err := loadSetupProvingKey(dir, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ccs, pk, nil
+	}
+
+	parallelLoadSlot <- struct{}{}
+	defer func() { <-parallelLoadSlot }()
+
+	var ccs constraint.ConstraintSystem
+	var ccsErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ccs, ccsErr = loadCCS(dir)
+	}()
+	pk, pkErr := loadSetupProvingKey(dir, opts)
+	<-done
+	if ccsErr != nil {
+		return nil, nil, ccsErr
+	}
+	if pkErr != nil {
+		return nil, nil, pkErr
+	}
+	return ccs, pk, nil
+}
+
+// loadCCS deserializes ccs.bin from dir.
+func loadCCS(dir string) (constraint.ConstraintSystem, error) {
+	f, err := os.Open(filepath.Join(dir, "ccs.bin"))
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("open ccs.bin: %w", err)
+		return nil, fmt.Errorf("open ccs.bin: %w", err)
 	}
-	defer ccsFile.Close()
+	defer f.Close()
 
-	ccs := groth16.NewCS(ecc.BLS12_381)
-	if _, err := ccs.ReadFrom(ccsFile); err != nil {
-		return nil, nil, nil, fmt.Errorf("read ccs.bin: %w", err)
+	ccs, err := readCCS(f)
+	if err != nil {
+		return nil, fmt.Errorf("read ccs.bin: %w", err)
 	}
+	return ccs, nil
+}
+
+// loadSetupProvingKey loads dir's proving key from pk.bin, or from its shards
+// when the key was written with SaveOptions.PKSplit. Shards are streamed, so
+// opts.MmapPK only applies to pk.bin.
+func loadSetupProvingKey(dir string, opts LoadOptions) (groth16.ProvingKey, error) {
+	if hasPKShards(dir) {
+		return loadProvingKeyShards(dir, opts.UnsafeUncheckedPK)
+	}
+	return loadProvingKey(filepath.Join(dir, "pk.bin"), opts)
+}
 
-	// Load PK
-	pkFile, err := os.Open(filepath.Join(dir, "pk.bin"))
+// loadProvingKey deserializes the proving key at path, from a memory mapping
+// when opts.MmapPK is set and the platform supports it, otherwise from the
+// file.
+func loadProvingKey(path string, opts LoadOptions) (groth16.ProvingKey, error) {
+	if opts.MmapPK {
+		data, unmap, err := mmapFile(path)
+		switch {
+		case err == nil:
+			defer unmap()
+			pk, err := readProvingKey(bytes.NewReader(data), opts.UnsafeUncheckedPK)
+			if err != nil {
+				return nil, fmt.Errorf("read pk.bin: %w", err)
+			}
+			return pk, nil
+		case !errors.Is(err, errMmapUnsupported):
+			return nil, err
+		}
+		// fall through to the regular read path
+	}
+
+	pkFile, err := os.Open(path)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("open pk.bin: %w", err)
+		return nil, fmt.Errorf("open pk.bin: %w", err)
 	}
 	defer pkFile.Close()
 
-	pk := groth16.NewProvingKey(ecc.BLS12_381)
-	if _, err := pk.ReadFrom(pkFile); err != nil {
-		return nil, nil, nil, fmt.Errorf("read pk.bin: %w", err)
+	pk, err := readProvingKey(pkFile, opts.UnsafeUncheckedPK)
+	if err != nil {
+		return nil, fmt.Errorf("read pk.bin: %w", err)
 	}
+	return pk, nil
+}
 
-	// Load VK
-	vkFile, err := os.Open(filepath.Join(dir, "vk.bin"))
+// pkDomainSize is the size of the fft.Domain header that starts every
+// serialized proving key: cardinality (8 bytes), five Fr elements and the
+// withPrecompute flag. The first curve point follows it.
+const pkDomainSize = 8 + 5*fr.Bytes + 1
+
+// checkProvingKeySize compares the size of a proving key buffer with the
+// size its producer announced (e.g. the download's Content-Length), so an
+// interrupted download fails at once instead of deep inside pk.ReadFrom after
+// minutes of deserializing. expected <= 0 means unknown and skips the check.
+func checkProvingKeySize(got, expected int64) error {
+	switch {
+	case expected <= 0 || got == expected:
+		return nil
+	case got < expected:
+		return fmt.Errorf("PK truncated: got %d bytes, expected %d", got, expected)
+	default:
+		return fmt.Errorf("PK size mismatch: got %d bytes, expected %d", got, expected)
+	}
+}
+
+// provingKeyIsRaw reports whether the proving key in r was written with
+// WriteRawTo, by looking at the encoding flags of its first G1 point
+// (G1.Alpha): compressed encodings set the most significant bit.
+func provingKeyIsRaw(r io.Reader) (bool, error) {
+	var head [pkDomainSize + 1]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return false, fmt.Errorf("read proving key header: %w", err)
+	}
+	return head[pkDomainSize]&0x80 == 0, nil
+}
+
+// readProvingKey deserializes a proving key in either encoding. ReadFrom
+// handles both and checks every point; only when unchecked is set is a raw
+// key read with UnsafeReadFrom instead (see LoadOptions.UnsafeUncheckedPK).
+func readProvingKey(r io.ReadSeeker, unchecked bool) (groth16.ProvingKey, error) {
+	raw := false
+	if unchecked {
+		var err error
+		if raw, err = provingKeyIsRaw(r); err != nil {
+			return nil, err
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	pk := groth16.NewProvingKey(ecc.BLS12_381)
+	var err error
+	if raw {
+		_, err = pk.UnsafeReadFrom(r)
+	} else {
+		_, err = pk.ReadFrom(r)
+	}
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("open vk.bin: %w", err)
+		return nil, err
 	}
-	defer vkFile.Close()
+	return pk, nil
+}
 
+// readCCS deserializes a BLS12-381 constraint system.
+func readCCS(r io.Reader) (constraint.ConstraintSystem, error) {
+	ccs := groth16.NewCS(ecc.BLS12_381)
+	if _, err := ccs.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return ccs, nil
+}
+
+// readVerifyingKey deserializes a BLS12-381 verifying key.
+func readVerifyingKey(r io.Reader) (groth16.VerifyingKey, error) {
 	vk := groth16.NewVerifyingKey(ecc.BLS12_381)
-	if _, err := vk.ReadFrom(vkFile); err != nil {
-		return nil, nil, nil, fmt.Errorf("read vk.bin: %w", err)
+	if _, err := vk.ReadFrom(r); err != nil {
+		return nil, err
 	}
+	return vk, nil
+}
 
-	return ccs, pk, vk, nil
+// ErrPublicCount is returned (wrapped) when a public witness does not hold
+// the number of public inputs its circuit declares.
+var ErrPublicCount = errors.New("public input count does not match the circuit")
+
+// circuitNbPublic is the number of public inputs ccs declares, without the
+// one-wire gnark counts among its public variables. For vw0w1 it is
+// VW0W1NbPublic.
+func circuitNbPublic(ccs constraint.ConstraintSystem) int {
+	return ccs.GetNbPublicVariables() - 1
+}
+
+// checkPublicCount rejects a public witness of got inputs for a circuit
+// declaring want.
+func checkPublicCount(got, want int) error {
+	if got != want {
+		return fmt.Errorf("%w: the witness has %d public inputs, the circuit declares %d", ErrPublicCount, got, want)
+	}
+	return nil
+}
+
+// ErrSetupMismatch is returned (wrapped) when ccs.bin, pk.bin and vk.bin do
+// not describe the same circuit.
+var ErrSetupMismatch = errors.New("setup files are from different circuits")
+
+// checkSetupConsistency compares the sizes the constraint system implies with
+// the proving key's FFT domain and wire bitmaps, and with the verifying key's
+// public input count, so mixing files from different circuits fails at load
+// time rather than deep inside groth16.Prove. vk may be nil.
+func checkSetupConsistency(ccs constraint.ConstraintSystem, pk groth16.ProvingKey, vk groth16.VerifyingKey) error {
+	nbConstraints := ccs.GetNbConstraints()
+	nbWires := ccs.GetNbInternalVariables() + ccs.GetNbPublicVariables() + ccs.GetNbSecretVariables()
+
+	if p, ok := pk.(*groth16bls.ProvingKey); ok {
+		if want := ecc.NextPowerOfTwo(uint64(nbConstraints)); p.Domain.Cardinality != want {
+			return fmt.Errorf("%w: ccs.bin has %d constraints (domain %d) but pk.bin has domain %d",
+				ErrSetupMismatch, nbConstraints, want, p.Domain.Cardinality)
+		}
+		if len(p.InfinityA) != nbWires || len(p.InfinityB) != nbWires {
+			return fmt.Errorf("%w: ccs.bin has %d wires but pk.bin covers %d",
+				ErrSetupMismatch, nbWires, len(p.InfinityA))
+		}
+	}
+	if v, ok := vk.(*groth16bls.VerifyingKey); ok {
+		// len(K) counts the one-wire and one point per commitment besides the publics.
+		want := ccs.GetNbPublicVariables() - 1
+		if got := len(v.G1.K) - len(v.PublicAndCommitmentCommitted) - 1; got != want {
+			return fmt.Errorf("%w: ccs.bin has %d public inputs but vk.bin has %d",
+				ErrSetupMismatch, want, got)
+		}
+	}
+	return nil
 }
 
 // ExportVKOnly exports the verifying key to vk.json without needing a proof or witness.
 // This is useful for getting the constant VK immediately after setup.
 func ExportVKOnly(vk groth16.VerifyingKey, dir string) error {
+	return exportVKOnly(vk, dir, "")
+}
+
+// exportVKOnly is ExportVKOnly stamping circuitHash into vk.json when set.
+func exportVKOnly(vk groth16.VerifyingKey, dir, circuitHash string) error {
+	vkj, err := exportVKOnlyJSON(vk)
+	if err != nil {
+		return err
+	}
+	vkj.CircuitHash = circuitHash
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(dir, "vk.json"), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(vkj)
+	})
+}
+
+// exportVKOnlyJSON is exportVKBLS with nPublic taken from the VK itself, for
+// callers that have no public witness at hand.
+func exportVKOnlyJSON(vk groth16.VerifyingKey) (VKJSON, error) {
 	v, ok := vk.(*groth16bls.VerifyingKey)
 	if !ok {
-		return fmt.Errorf("unexpected vk type (need *groth16/bls12-381.VerifyingKey): %T", vk)
+		return VKJSON{}, fmt.Errorf("unexpected vk type (need *groth16/bls12-381.VerifyingKey): %T", vk)
 	}
 
 	// Calculate nPublic from VK structure
@@ -751,32 +1582,20 @@ func ExportVKOnly(vk groth16.VerifyingKey, dir string) error {
 	nPublic := len(v.G1.K) - nCommitments
 
 	if nPublic < 1 {
-		return fmt.Errorf("invalid vk: nPublic=%d (IC=%d, commitments=%d)", nPublic, len(v.G1.K), nCommitments)
-	}
-
-	vkj, err := exportVKBLS(vk, nPublic)
-	if err != nil {
-		return err
+		return VKJSON{}, fmt.Errorf("invalid vk: nPublic=%d (IC=%d, commitments=%d)", nPublic, len(v.G1.K), nCommitments)
 	}
 
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err
-	}
-
-	f, err := os.Create(filepath.Join(dir, "vk.json"))
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	return enc.Encode(vkj)
+	return exportVKBLS(vk, nPublic)
 }
 
-// SetupFilesExist checks if all setup files exist in the given directory.
+// SetupFilesExist checks if all setup files exist in the given directory. A
+// sharded proving key counts when its pk_shards.json and every shard exist.
 func SetupFilesExist(dir string) bool {
-	for _, name := range []string{"ccs.bin", "pk.bin", "vk.bin"} {
+	pkNames, err := provingKeyArtifacts(dir)
+	if err != nil {
+		return false
+	}
+	for _, name := range append([]string{"ccs.bin", "vk.bin"}, pkNames...) {
 		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
 			return false
 		}
@@ -786,6 +1605,13 @@ func SetupFilesExist(dir string) bool {
 
 // ReExportJSON loads VK, Proof, and public witness from binary files and re-exports JSON files.
 func ReExportJSON(dir string) error {
+	return ReExportJSONWithOptions(dir, ExportOptions{})
+}
+
+// ReExportJSONWithOptions is ReExportJSON with the artifact selection of
+// ExportAllWithOptions. With opts.WASMResult it recovers an on-chain-ready
+// result.json from vk.bin, proof.bin and witness.bin alone.
+func ReExportJSONWithOptions(dir string, opts ExportOptions) error {
 	// Load VK
 	vkFile, err := os.Open(filepath.Join(dir, "vk.bin"))
 	if err != nil {
@@ -826,5 +1652,5 @@ func ReExportJSON(dir string) error {
 	}
 
 	// Re-export JSON files
-	return ExportAll(vk, proof, witness, dir)
+	return ExportAllWithOptions(vk, proof, witness, dir, opts)
 }
@@ -0,0 +1,137 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// fromchain.go decrypts straight from the inline datum of an encryption UTxO,
+// as a chain indexer returns it, so an operator does not have to pull r1, g1b
+// and g2b out by hand. The datum is EncryptionDatum from
+// app/contracts/lib/types/encryption.ak:
+//
+//	Constr 0 [owner_vkh, owner_g1, token, half_level, full_level, capsule, status]
+//
+// with half_level = Constr 0 [r1b, r2_g1b, r4b] and full_level an
+// Option<FullEncryptionLevel>: Constr 0 [Constr 0 [r1b, r2_g1b, r2_g2b, r4b]]
+// or Constr 1 []. These are exactly the level entries decrypt -entry takes.
+// The shared value is not on chain; the caller supplies it as for decrypt.
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ChainLevel selects which level of an encryption datum DecryptFromChain
+// decrypts.
+type ChainLevel string
+
+const (
+	// ChainLevelAuto decrypts the full level when the datum has one and the
+	// half level otherwise.
+	ChainLevelAuto ChainLevel = "auto"
+	ChainLevelHalf ChainLevel = "half"
+	ChainLevelFull ChainLevel = "full"
+)
+
+// ParseChainLevel parses the -level flag: auto, half or full.
+func ParseChainLevel(s string) (ChainLevel, error) {
+	switch l := ChainLevel(s); l {
+	case ChainLevelAuto, ChainLevelHalf, ChainLevelFull:
+		return l, nil
+	}
+	return "", fmt.Errorf("unknown level %q (want auto, half or full)", s)
+}
+
+// encryptionDatumFields is the number of fields of EncryptionDatum.
+const encryptionDatumFields = 7
+
+// ErrKeyMismatch is returned (wrapped) when the decrypted key differs from
+// the one the caller expected.
+var ErrKeyMismatch = errors.New("decrypted key differs from the expected key")
+
+// EncryptionDatumEntry returns the level entry of datum that level selects,
+// and the level it resolved to. A datum that is not an EncryptionDatum is
+// rejected with an error naming the field that does not fit.
+func EncryptionDatumEntry(datum PlutusData, level ChainLevel) (PlutusData, ChainLevel, error) {
+	if datum.Constructor == nil || *datum.Constructor != 0 || len(datum.Fields) != encryptionDatumFields {
+		return PlutusData{}, "", fmt.Errorf("datum: not an encryption datum (want constructor 0 with %d fields)", encryptionDatumFields)
+	}
+	half := datum.Fields[3]
+	if half.Constructor == nil || *half.Constructor != 0 {
+		return PlutusData{}, "", fmt.Errorf("datum.fields[3] (half_level): not constructor 0")
+	}
+	full := datum.Fields[4]
+	if full.Constructor == nil || *full.Constructor > 1 {
+		return PlutusData{}, "", fmt.Errorf("datum.fields[4] (full_level): not an Option")
+	}
+	hasFull := *full.Constructor == 0
+
+	switch level {
+	case ChainLevelAuto:
+		if hasFull {
+			return full, ChainLevelFull, nil
+		}
+		return half, ChainLevelHalf, nil
+	case ChainLevelHalf:
+		return half, ChainLevelHalf, nil
+	case ChainLevelFull:
+		if !hasFull {
+			return PlutusData{}, "", fmt.Errorf("datum.fields[4] (full_level): None, the datum has no full level")
+		}
+		return full, ChainLevelFull, nil
+	}
+	return PlutusData{}, "", fmt.Errorf("unknown level %q", level)
+}
+
+// FromChainOptions tunes DecryptFromChain. The zero value decrypts the
+// latest level and checks nothing.
+type FromChainOptions struct {
+	// Level selects the level to decrypt; empty means ChainLevelAuto.
+	Level ChainLevel
+
+	// ExpectKey, if set, is the hex key the caller expects (for example one
+	// recorded on chain). A different key yields ErrKeyMismatch.
+	ExpectKey string
+
+	// Decrypt is passed to DecryptToHashWithOptions.
+	Decrypt DecryptOptions
+}
+
+// FromChainResult is what DecryptFromChain extracted and derived.
+type FromChainResult struct {
+	Level ChainLevel
+	G1b   string
+	G2b   string // empty for the half level
+	R1    string
+	Key   string
+}
+
+// DecryptFromChain parses raw as an encryption datum (JSON or CBOR, see
+// ParsePlutusDatum), picks the level opts.Level selects and runs
+// DecryptToHash on it with sharedHex. On ErrKeyMismatch the result is still
+// returned.
+func DecryptFromChain(raw []byte, sharedHex string, opts FromChainOptions) (FromChainResult, error) {
+	datum, err := ParsePlutusDatum(raw)
+	if err != nil {
+		return FromChainResult{}, err
+	}
+	level := opts.Level
+	if level == "" {
+		level = ChainLevelAuto
+	}
+	entry, level, err := EncryptionDatumEntry(datum, level)
+	if err != nil {
+		return FromChainResult{}, err
+	}
+	g1b, g2b, r1, err := EntryDecryptInputs(entry)
+	if err != nil {
+		return FromChainResult{}, fmt.Errorf("%s level: %w", level, err)
+	}
+	key, err := DecryptToHashWithOptions(g1b, g2b, r1, sharedHex, opts.Decrypt)
+	if err != nil {
+		return FromChainResult{}, err
+	}
+	res := FromChainResult{Level: level, G1b: g1b, G2b: g2b, R1: r1, Key: key}
+	if want := normalizeHex(opts.ExpectKey); want != "" && want != key {
+		return res, fmt.Errorf("%w: got %s, expected %s", ErrKeyMismatch, key, want)
+	}
+	return res, nil
+}
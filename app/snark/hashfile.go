@@ -0,0 +1,160 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// hashfile.go hashes a list of secrets in one pass for `hash -file`. Every
+// entry is processed; failures are recorded per entry rather than aborting
+// the whole file. Each entry costs a pairing and entries are independent, so
+// they are spread over worker goroutines; results are written by index, so
+// the output order never depends on scheduling. With HashOptions.Dedupe a
+// secret that appears more than once is hashed once and its result copied.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+// HashResult is one element of the `hash -file` output. Error is set (and Hash
+// empty) when the entry could not be hashed.
+type HashResult struct {
+	A     string `json:"a"`
+	Hash  string `json:"hash,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ParseSecretList decodes a JSON array of secrets, each a decimal or 0x-hex
+// string.
+func ParseSecretList(raw []byte) ([]string, error) {
+	var secrets []string
+	if err := json.Unmarshal(raw, &secrets); err != nil {
+		return nil, fmt.Errorf("secrets file must be a JSON array of strings: %w", err)
+	}
+	return secrets, nil
+}
+
+// HashOptions tunes HashSecretsWithOptions.
+type HashOptions struct {
+	// Jobs is the number of secrets hashed concurrently. Zero or less means
+	// runtime.GOMAXPROCS(0).
+	Jobs int
+	// Dedupe hashes each distinct secret once, keyed by its integer value
+	// (so "10" and "0xa" share a pairing), and copies the result to every
+	// repeat. The output still has one entry per input, in order.
+	Dedupe bool
+}
+
+// HashSecrets runs gtToHash on every secret. The returned slice has one entry
+// per input, in order; failed indices are also returned so callers can report
+// them without scanning the results.
+func HashSecrets(secrets []string) ([]HashResult, []int) {
+	return HashSecretsWithOptions(secrets, HashOptions{})
+}
+
+// HashSecretsWithOptions is HashSecrets with explicit HashOptions. The output
+// does not depend on opts.Jobs.
+func HashSecretsWithOptions(secrets []string, opts HashOptions) ([]HashResult, []int) {
+	var results []HashResult
+	if opts.Dedupe {
+		unique, index := dedupeSecrets(secrets)
+		hashed := hashEach(unique, opts.Jobs)
+		results = make([]HashResult, len(secrets))
+		for i := range results {
+			results[i] = hashed[index[i]]
+			results[i].A = secrets[i]
+		}
+	} else {
+		results = hashEach(secrets, opts.Jobs)
+	}
+	var failed []int
+	for i := range results {
+		if results[i].Error != "" {
+			failed = append(failed, i)
+		}
+	}
+	return results, failed
+}
+
+// hashEach runs hashSecret on every secret over jobs goroutines.
+func hashEach(secrets []string, jobs int) []HashResult {
+	results := make([]HashResult, len(secrets))
+	parallelFor(len(secrets), jobs, func(i int) {
+		results[i].A = secrets[i]
+		hk, err := hashSecret(secrets[i])
+		if err != nil {
+			results[i].Error = err.Error()
+			return
+		}
+		results[i].Hash = hk
+	})
+	return results
+}
+
+// dedupeSecrets returns the distinct secrets, in order of first appearance,
+// and for each input the index of its distinct secret. Secrets are compared
+// by integer value; unparsable entries by their text, which cannot collide
+// with a value since they fail to parse.
+func dedupeSecrets(secrets []string) (unique []string, index []int) {
+	seen := make(map[string]int, len(secrets))
+	index = make([]int, len(secrets))
+	for i, s := range secrets {
+		key := s
+		if a, ok := new(big.Int).SetString(s, 0); ok {
+			key = a.String()
+		}
+		j, ok := seen[key]
+		if !ok {
+			j = len(unique)
+			seen[key] = j
+			unique = append(unique, s)
+		}
+		index[i] = j
+	}
+	return unique, index
+}
+
+// parallelFor calls fn(i) for every i in [0, n) on up to jobs goroutines and
+// waits for all of them. jobs <= 0 means runtime.GOMAXPROCS(0). fn must only
+// write state owned by index i.
+func parallelFor(n, jobs int, fn func(i int)) {
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	if jobs > n {
+		jobs = n
+	}
+	if jobs <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// hashSecret parses s like `hash -a` does and returns its hk hex.
+func hashSecret(s string) (string, error) {
+	a, ok := new(big.Int).SetString(s, 0)
+	if !ok || a.Sign() == 0 {
+		return "", fmt.Errorf("could not parse %q (must be a non-zero integer; decimal or 0x.. hex)", s)
+	}
+	hk, _, err := gtToHash(a)
+	return hk, err
+}
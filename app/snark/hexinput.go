@@ -0,0 +1,55 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// hexinput.go canonicalizes hex strings at the user-facing boundaries (CLI
+// flags and WASM arguments). The library parsers stay strict; callers
+// normalize first and then enforce exact lengths with checkHexLen.
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// normalizeHex canonicalizes a user-supplied hex string for the strict library
+// parsers: surrounding whitespace and an optional 0x/0X prefix are removed and
+// the digits are lowercased. Inputs copied from JS toString(16) or block
+// explorers commonly carry either.
+func normalizeHex(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		s = s[2:]
+	}
+	return strings.ToLower(s)
+}
+
+// checkHexLen enforces that the normalized form of raw is exactly want hex
+// characters. The error reports both the normalized length and, when
+// normalization changed it, the length actually received.
+func checkHexLen(name, raw string, want int) error {
+	h := normalizeHex(raw)
+	if len(h) == want {
+		return nil
+	}
+	if len(h) != len(raw) {
+		return withKind(ErrBadHex, fmt.Errorf("%s must be %d hex chars (got %d after trimming whitespace/0x; received %d chars)", name, want, len(h), len(raw)))
+	}
+	return withKind(ErrBadHex, fmt.Errorf("%s must be %d hex chars (got %d)", name, want, len(h)))
+}
+
+// hexArg names a hex argument and its required length in hex characters.
+type hexArg struct {
+	name string
+	hex  string
+	want int
+}
+
+// checkHexArgs runs checkHexLen over args in order and returns the first error.
+func checkHexArgs(args ...hexArg) error {
+	for _, a := range args {
+		if err := checkHexLen(a.name, a.hex, a.want); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -11,12 +11,16 @@
 package main
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"math/big"
 
 	"github.com/consensys/gnark/backend/groth16"
+	backend_witness "github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
@@ -37,6 +41,8 @@ import (
 	"github.com/consensys/gnark/std/math/emulated"
 	"github.com/consensys/gnark/std/math/emulated/emparams"
 	"github.com/consensys/gnark/std/math/uints"
+
+	"golang.org/x/crypto/blake2b"
 )
 
 // Fixed, public G2 point (compressed hex).
@@ -45,6 +51,20 @@ const H0Hex = "a5acbe8bdb762cf7b4bfa9171b9ffa23b6ed710b290280b271a0258e285354aac
 // IMPORTANT: FIXED and appended as BYTES (hex-decoded) before hashing.
 const DomainTagHex = "4631327c546f7c4865787c76317c"
 
+// CurveName identifies the curve every artifact is built on. It is recorded
+// in exported vk/proof JSON and checked on import.
+const CurveName = "bls12381"
+
+// ProtocolParams returns the public constants front-ends must agree with, so
+// they can be read from this single source instead of copied into JS.
+func ProtocolParams() map[string]interface{} {
+	return map[string]interface{}{
+		"domainTag": DomainTagHex,
+		"h0":        H0Hex,
+		"curve":     CurveName,
+	}
+}
+
 // --- Fp→Fr limb-based conversion constants ---
 // pow64[i] = 2^(64*i) mod r, where r is the BLS12-381 scalar field modulus.
 // Used for efficient in-circuit Fp→Fr conversion without bit decomposition.
@@ -67,28 +87,39 @@ var (
 // --- out-of-circuit helpers ---
 
 // g1MulBase computes [a]q where q is the G1 generator.
-// a can be arbitrarily large (e.g., 255 bytes); gnark-crypto will effectively reduce mod group order.
+// a can be arbitrarily large (e.g., 255 bytes); it is reduced mod the group order.
 func g1MulBase(a *big.Int) bls12381.G1Affine {
-	if a == nil {
-		a = new(big.Int)
-	}
-	var p bls12381.G1Affine
-	p.ScalarMultiplicationBase(new(big.Int).Set(a))
-	return p
+	return ScalarBaseMulG1(a)
 }
 
 // parseG2CompressedHex decodes a hex-encoded compressed BLS12-381 G2 point.
 // The input must be a 192-character hex string (96 bytes compressed).
 // Returns the deserialized G2Affine point or an error if the hex is malformed
-// or the bytes do not represent a valid curve point.
+// or the bytes do not represent a valid curve point in the prime-order subgroup.
 func parseG2CompressedHex(h string) (bls12381.G2Affine, error) {
+	return decodeG2CompressedHex(h, false)
+}
+
+// decodeG2CompressedHex is parseG2CompressedHex with the subgroup check made
+// optional. skipSubgroup still requires a valid curve point; it exists only
+// for the -unsafe-skip-subgroup-check escape hatch.
+func decodeG2CompressedHex(h string, skipSubgroup bool) (bls12381.G2Affine, error) {
 	raw, err := hex.DecodeString(h)
 	if err != nil {
-		return bls12381.G2Affine{}, fmt.Errorf("decode G2 hex: %w", err)
+		return bls12381.G2Affine{}, withKind(ErrBadHex, fmt.Errorf("decode G2 hex: %w", err))
 	}
 	var p bls12381.G2Affine
+	if skipSubgroup {
+		if len(raw) != bls12381.SizeOfG2AffineCompressed {
+			return bls12381.G2Affine{}, withKind(ErrBadPoint, fmt.Errorf("G2 decode: got %d bytes, want %d", len(raw), bls12381.SizeOfG2AffineCompressed))
+		}
+		if err := bls12381.NewDecoder(bytes.NewReader(raw), bls12381.NoSubgroupChecks()).Decode(&p); err != nil {
+			return bls12381.G2Affine{}, withKind(ErrBadPoint, fmt.Errorf("G2 decode: %w", err))
+		}
+		return p, nil
+	}
 	if _, err := p.SetBytes(raw); err != nil {
-		return bls12381.G2Affine{}, fmt.Errorf("G2.SetBytes: %w", err)
+		return bls12381.G2Affine{}, withKind(ErrBadPoint, fmt.Errorf("G2.SetBytes: %w", err))
 	}
 	return p, nil
 }
@@ -96,15 +127,29 @@ func parseG2CompressedHex(h string) (bls12381.G2Affine, error) {
 // parseG1CompressedHex decodes a hex-encoded compressed BLS12-381 G1 point.
 // The input must be a 96-character hex string (48 bytes compressed).
 // Returns the deserialized G1Affine point or an error if the hex is malformed
-// or the bytes do not represent a valid curve point.
+// or the bytes do not represent a valid curve point in the prime-order subgroup.
 func parseG1CompressedHex(h string) (bls12381.G1Affine, error) {
+	return decodeG1CompressedHex(h, false)
+}
+
+// decodeG1CompressedHex is the G1 counterpart of decodeG2CompressedHex.
+func decodeG1CompressedHex(h string, skipSubgroup bool) (bls12381.G1Affine, error) {
 	raw, err := hex.DecodeString(h)
 	if err != nil {
-		return bls12381.G1Affine{}, fmt.Errorf("decode G1 hex: %w", err)
+		return bls12381.G1Affine{}, withKind(ErrBadHex, fmt.Errorf("decode G1 hex: %w", err))
 	}
 	var p bls12381.G1Affine
+	if skipSubgroup {
+		if len(raw) != bls12381.SizeOfG1AffineCompressed {
+			return bls12381.G1Affine{}, withKind(ErrBadPoint, fmt.Errorf("G1 decode: got %d bytes, want %d", len(raw), bls12381.SizeOfG1AffineCompressed))
+		}
+		if err := bls12381.NewDecoder(bytes.NewReader(raw), bls12381.NoSubgroupChecks()).Decode(&p); err != nil {
+			return bls12381.G1Affine{}, withKind(ErrBadPoint, fmt.Errorf("G1 decode: %w", err))
+		}
+		return p, nil
+	}
 	if _, err := p.SetBytes(raw); err != nil {
-		return bls12381.G1Affine{}, fmt.Errorf("G1.SetBytes: %w", err)
+		return bls12381.G1Affine{}, withKind(ErrBadPoint, fmt.Errorf("G1.SetBytes: %w", err))
 	}
 	return p, nil
 }
@@ -145,6 +190,27 @@ func fq12CanonicalBytes(k bls12381.GT) []byte {
 	return out
 }
 
+// gtFromCanonicalBytes is the inverse of fq12CanonicalBytes. Each
+// coefficient must be below the field modulus.
+func gtFromCanonicalBytes(b []byte) (bls12381.GT, error) {
+	if len(b) != 12*fp.Bytes {
+		return bls12381.GT{}, fmt.Errorf("GT encoding must be %d bytes (got %d)", 12*fp.Bytes, len(b))
+	}
+	var k bls12381.GT
+	coeffs := []*fp.Element{
+		&k.C0.B0.A0, &k.C0.B0.A1, &k.C0.B1.A0, &k.C0.B1.A1, &k.C0.B2.A0, &k.C0.B2.A1,
+		&k.C1.B0.A0, &k.C1.B0.A1, &k.C1.B1.A0, &k.C1.B1.A1, &k.C1.B2.A0, &k.C1.B2.A1,
+	}
+	for i, c := range coeffs {
+		e, err := fp.BigEndian.Element((*[fp.Bytes]byte)(b[i*fp.Bytes : (i+1)*fp.Bytes]))
+		if err != nil {
+			return bls12381.GT{}, fmt.Errorf("GT coefficient %d: %w", i, err)
+		}
+		*c = e
+	}
+	return k, nil
+}
+
 // fq12ToFrElements extracts the 12 Fp coefficients from a GT element
 // and converts each to an Fr element (reduced mod r).
 // This is the MiMC-compatible representation of the pairing output.
@@ -178,10 +244,23 @@ func fq12ToFrElements(k bls12381.GT) []fr.Element {
 
 // domainTagFr returns the domain tag as an Fr element for MiMC hashing.
 func domainTagFr() fr.Element {
-	tagBytes, _ := hex.DecodeString(DomainTagHex)
+	tag, _ := parseDomainTagFr(DomainTagHex)
+	return tag
+}
+
+// parseDomainTagFr decodes a hex domain tag into the Fr element appended
+// before MiMC hashing. Tags must fit in 31 bytes so they are never reduced.
+func parseDomainTagFr(tagHex string) (fr.Element, error) {
+	tagBytes, err := hex.DecodeString(tagHex)
+	if err != nil {
+		return fr.Element{}, fmt.Errorf("decode domain tag hex: %w", err)
+	}
+	if len(tagBytes) == 0 || len(tagBytes) > fr.Bytes-1 {
+		return fr.Element{}, fmt.Errorf("domain tag must be 1..%d bytes (got %d)", fr.Bytes-1, len(tagBytes))
+	}
 	var tag fr.Element
 	tag.SetBytes(tagBytes)
-	return tag
+	return tag, nil
 }
 
 // mimcHashFr hashes a slice of Fr elements using MiMC and returns the result.
@@ -304,40 +383,12 @@ func (c *wFromHKCircuit) Define(api frontend.API) error {
 	// W = [hk]G
 	w := curve.ScalarMulBase(&c.HK)
 
-	// X,Y -> 48-byte big-endian each
-	xBytes, err := conversion.EmulatedToBytes(api, &w.X)
-	if err != nil {
-		return fmt.Errorf("X to bytes: %w", err)
-	}
-	yBytes, err := conversion.EmulatedToBytes(api, &w.Y)
+	// IETF compressed encoding of W (48 bytes)
+	compressed, bapi, err := compressG1InCircuit(api, w, c.SignHint)
 	if err != nil {
-		return fmt.Errorf("Y to bytes: %w", err)
-	}
-	if len(xBytes) != 48 || len(yBytes) != 48 {
-		return fmt.Errorf("unexpected fp byte length: X=%d Y=%d", len(xBytes), len(yBytes))
-	}
-
-	// Build compressed G1:
-	// out = X (48 bytes), set:
-	//   out[0] |= 0x80 (compression flag)
-	//   out[0] |= 0x20 iff Y is lexicographically largest (Y > (p-1)/2)
-	// Note: BLS12-381 uses lexicographic comparison, not Y's LSB.
-	// SignHint is provided as witness and verified via SHA256 hash match.
-	bapi, err := uints.NewBytes(api)
-	if err != nil {
-		return fmt.Errorf("NewBytes: %w", err)
+		return err
 	}
 
-	// Ensure SignHint is boolean (0 or 1)
-	api.AssertIsBoolean(c.SignHint)
-
-	// Use SignHint for the sign bit (0x20 if Y is lex largest)
-	signMask := api.Mul(c.SignHint, 0x20)
-	first := bapi.Or(xBytes[0], bapi.ValueOf(0x80), bapi.ValueOf(signMask))
-	xBytes[0] = first
-
-	compressed := xBytes // 48 bytes
-
 	// SHA256(compressed)
 	h, err := sha2.New(api)
 	if err != nil {
@@ -369,31 +420,108 @@ func (c *wFromHKCircuit) Define(api frontend.API) error {
 	return nil
 }
 
+// compressG1InCircuit returns the 48-byte IETF compressed encoding of p, as
+// g1CompressedHex produces it out of circuit, together with the byte API it
+// used. signHint must be 1 iff p.Y is lexicographically largest; it is only
+// constrained to be boolean, so callers must bind the result (wFromHKCircuit
+// hashes it into a public digest). check-constants compares the two encodings
+// for a fixed point (see checkCircuitG1Compression).
+func compressG1InCircuit(api frontend.API, p *sw_emulated.AffinePoint[emparams.BLS12381Fp], signHint frontend.Variable) ([]uints.U8, *uints.Bytes, error) {
+	// X,Y -> 48-byte big-endian each
+	xBytes, err := conversion.EmulatedToBytes(api, &p.X)
+	if err != nil {
+		return nil, nil, fmt.Errorf("X to bytes: %w", err)
+	}
+	yBytes, err := conversion.EmulatedToBytes(api, &p.Y)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Y to bytes: %w", err)
+	}
+	if len(xBytes) != 48 || len(yBytes) != 48 {
+		return nil, nil, fmt.Errorf("unexpected fp byte length: X=%d Y=%d", len(xBytes), len(yBytes))
+	}
+
+	// Build compressed G1:
+	// out = X (48 bytes), set:
+	//   out[0] |= 0x80 (compression flag)
+	//   out[0] |= 0x20 iff Y is lexicographically largest (Y > (p-1)/2)
+	// Note: BLS12-381 uses lexicographic comparison, not Y's LSB.
+	bapi, err := uints.NewBytes(api)
+	if err != nil {
+		return nil, nil, fmt.Errorf("NewBytes: %w", err)
+	}
+
+	// Ensure signHint is boolean (0 or 1)
+	api.AssertIsBoolean(signHint)
+
+	// Use signHint for the sign bit (0x20 if Y is lex largest)
+	signMask := api.Mul(signHint, 0x20)
+	xBytes[0] = bapi.Or(xBytes[0], bapi.ValueOf(0x80), bapi.ValueOf(signMask))
+	return xBytes, bapi, nil
+}
+
 // ProveAndVerifyW builds the circuit proof and immediately verifies it.
 // It binds the proof to the provided compressed point by using public inputs:
 //
 //	HW0,HW1 = sha256(wCompressedBytes) split into 2×16-byte big-endian ints.
 func ProveAndVerifyW(a *big.Int, wCompressedHex string) error {
+	// 1-3) Derive hk, check W and build the assignment
+	assignment, err := prepareW(a, wCompressedHex)
+	if err != nil {
+		return err
+	}
+
+	// 4) Compile circuit over BLS12-381 scalar field
+	ccs, err := CompileWCircuit()
+	if err != nil {
+		return err
+	}
+
+	// 5) Setup keys
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		return fmt.Errorf("setup: %w", err)
+	}
+
+	// 6-7) Prove + verify
+	h := &SetupHandle{CCS: ccs, PK: pk, VK: vk}
+	proof, publicWitness, err := h.proveAssignment(assignment, ProveOptions{})
+	if err != nil {
+		return err
+	}
+
+	if err := ExportAll(vk, proof, publicWitness, "out"); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	return nil
+}
+
+// prepareW computes the wFromHKCircuit assignment for (a, W):
+//
+//	HK       = hk(a)
+//	SignHint = 1 iff W.Y is lexicographically largest
+//	HW0,HW1  = sha256(W compressed) split into 2×16-byte big-endian ints
+func prepareW(a *big.Int, wCompressedHex string) (*wFromHKCircuit, error) {
 	// 1) Compute hk scalar from a (out-of-circuit)
 	hkBi, err := hkScalarFromA(a)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if hkBi.Sign() == 0 {
-		return fmt.Errorf("hk reduced to 0; refuse (W would be infinity)")
+	if err := checkNonZeroHk(hkBi); err != nil {
+		return nil, err
 	}
 
 	// 2) Decode compressed W bytes and sanity-check it parses
 	rawW, err := hex.DecodeString(wCompressedHex)
 	if err != nil {
-		return fmt.Errorf("decode -w hex: %w", err)
+		return nil, fmt.Errorf("decode -w hex: %w", err)
 	}
 	if len(rawW) != 48 {
-		return fmt.Errorf("invalid -w length: got %d bytes, want 48", len(rawW))
+		return nil, fmt.Errorf("invalid -w length: got %d bytes, want 48", len(rawW))
 	}
 	wPoint, err := parseG1CompressedHex(wCompressedHex)
 	if err != nil {
-		return fmt.Errorf("invalid compressed G1: %w", err)
+		return nil, fmt.Errorf("invalid compressed G1: %w", err)
 	}
 
 	// Compute sign hint: 1 if Y is lexicographically largest, 0 otherwise
@@ -403,55 +531,69 @@ func ProveAndVerifyW(a *big.Int, wCompressedHex string) error {
 	}
 
 	// 3) Public inputs = sha256(W_compressed) split into two 16-byte big-endian ints
-	d := sha256.Sum256(rawW)
-	var hw0, hw1 big.Int
+	hw0, hw1 := SplitDigest(rawW)
+
+	return &wFromHKCircuit{
+		HK:       emulated.ValueOf[emparams.BLS12381Fr](hkBi),
+		SignHint: signHint,
+		HW0:      &hw0,
+		HW1:      &hw1,
+	}, nil
+}
+
+// SplitDigest returns the wFromHKCircuit public inputs for the compressed
+// point w: sha256(w) split into its first and last 16 bytes, each read as a
+// big-endian integer.
+func SplitDigest(w []byte) (hw0, hw1 big.Int) {
+	d := sha256.Sum256(w)
 	hw0.SetBytes(d[:16])
 	hw1.SetBytes(d[16:])
+	return hw0, hw1
+}
 
-	// 4) Compile circuit over BLS12-381 scalar field
+// CompileWCircuit compiles wFromHKCircuit over the BLS12-381 scalar field.
+func CompileWCircuit() (constraint.ConstraintSystem, error) {
 	var circuit wFromHKCircuit
 	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &circuit)
 	if err != nil {
-		return fmt.Errorf("compile: %w", err)
+		return nil, fmt.Errorf("compile: %w", err)
 	}
+	return ccs, nil
+}
 
-	// 5) Setup keys
+// SetupWCircuit compiles wFromHKCircuit, runs groth16.Setup and writes
+// ccs.bin, pk.bin and vk.bin to dir, overwriting any existing files. Use a
+// directory separate from the vw0w1 setup: the file names are the same.
+func SetupWCircuit(dir string) error {
+	ccs, err := CompileWCircuit()
+	if err != nil {
+		return err
+	}
 	pk, vk, err := groth16.Setup(ccs)
 	if err != nil {
 		return fmt.Errorf("setup: %w", err)
 	}
-
-	// 6) Create witness assignment
-	assignment := wFromHKCircuit{
-		HK:       emulated.ValueOf[emparams.BLS12381Fr](hkBi),
-		SignHint: signHint,
-		HW0:      &hw0,
-		HW1:      &hw1,
+	if err := SaveSetupFiles(ccs, pk, vk, dir); err != nil {
+		return fmt.Errorf("save setup files: %w", err)
 	}
+	return nil
+}
 
-	witness, err := frontend.NewWitness(&assignment, ecc.BLS12_381.ScalarField())
+// ProveWFromSetup loads a SetupWCircuit directory and proves (and verifies)
+// knowledge of hk(a) for W. Nothing is written to disk; the proof and public
+// witness are returned for the caller to export.
+func ProveWFromSetup(dir string, a *big.Int, wCompressedHex string) (groth16.Proof, backend_witness.Witness, error) {
+	// Validate inputs before the (slow) setup load
+	assignment, err := prepareW(a, wCompressedHex)
 	if err != nil {
-		return fmt.Errorf("new witness: %w", err)
-	}
-	publicWitness, err := witness.Public()
-	if err != nil {
-		return fmt.Errorf("public witness: %w", err)
+		return nil, nil, err
 	}
 
-	// 7) Prove + verify
-	proof, err := groth16.Prove(ccs, pk, witness)
+	h, err := OpenSetup(dir)
 	if err != nil {
-		return fmt.Errorf("prove: %w", err)
-	}
-	if err := groth16.Verify(proof, vk, publicWitness); err != nil {
-		return fmt.Errorf("verify failed: %w", err)
+		return nil, nil, fmt.Errorf("load setup files: %w", err)
 	}
-
-	if err := ExportAll(vk, proof, publicWitness, "out"); err != nil {
-		return fmt.Errorf("export: %w", err)
-	}
-
-	return nil
+	return h.proveAssignment(assignment, ProveOptions{})
 }
 
 // --- hop derivation: fq12_encoding(r2 / b, DomainTagHex) ---
@@ -463,11 +605,67 @@ func domainTagBytes() ([]byte, error) {
 	return hex.DecodeString(DomainTagHex)
 }
 
+// DigestAlgo names a 28-byte digest for Digest224Hex.
+type DigestAlgo string
+
+const (
+	DigestBlake2b224 DigestAlgo = "blake2b-224" // what the protocol uses
+	DigestSHA224     DigestAlgo = "sha256-224"  // SHA-224 (FIPS 180-4), for interop with sha256-based tools
+)
+
+// ParseDigestAlgo parses the -hash-algo flag: "blake2b-224" or "sha256-224".
+func ParseDigestAlgo(s string) (DigestAlgo, error) {
+	switch a := DigestAlgo(s); a {
+	case DigestBlake2b224, DigestSHA224:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown hash algorithm %q (want blake2b-224 or sha256-224)", s)
+}
+
+// Blake2b224Hex hashes data with the 28-byte blake2b the Python tooling and
+// the contracts use (hashlib.blake2b(digest_size=28), crypto.blake2b_224),
+// appending the raw domain tag bytes first when withDomainTag is set. It is a
+// debugging aid: hk itself is MiMC over Fr (see gtToHash).
+func Blake2b224Hex(data []byte, withDomainTag bool) (string, error) {
+	return Digest224Hex(DigestBlake2b224, data, withDomainTag)
+}
+
+// Digest224Hex is Blake2b224Hex with a selectable algorithm. SHA-224 matches
+// hashlib.sha224; it is not used anywhere in the protocol.
+func Digest224Hex(algo DigestAlgo, data []byte, withDomainTag bool) (string, error) {
+	var h hash.Hash
+	switch algo {
+	case DigestBlake2b224:
+		var err error
+		if h, err = blake2b.New(28, nil); err != nil {
+			return "", err
+		}
+	case DigestSHA224:
+		h = sha256.New224()
+	default:
+		return "", fmt.Errorf("unknown hash algorithm %q", algo)
+	}
+	h.Write(data)
+	if withDomainTag {
+		tag, err := domainTagBytes()
+		if err != nil {
+			return "", fmt.Errorf("domain tag: %w", err)
+		}
+		h.Write(tag)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // gtToHashFromGT hashes a GT element exactly like gtToHash does:
 // hk = mimc( fq12ToFrElements(k) || domainTagFr )
 func gtToHashFromGT(k bls12381.GT) (string, error) {
+	return gtToHashFromGTWithTag(k, domainTagFr())
+}
+
+// gtToHashFromGTWithTag is gtToHashFromGT with an explicit domain tag.
+func gtToHashFromGTWithTag(k bls12381.GT, tag fr.Element) (string, error) {
 	elements := fq12ToFrElements(k)
-	elements = append(elements, domainTagFr())
+	elements = append(elements, tag)
 
 	hk := mimcHashFr(elements)
 	return hex.EncodeToString(hk.Marshal()), nil
@@ -502,41 +700,90 @@ func gtDiv(num, den bls12381.GT) bls12381.GT {
 //	r1Hex    : G1 (entry["fields"][0]["bytes"])
 //	sharedHex: G2 (current shared)
 func DecryptToHash(g1bHex, g2bHex, r1Hex, sharedHex string) (string, error) {
+	return DecryptToHashWithOptions(g1bHex, g2bHex, r1Hex, sharedHex, DecryptOptions{})
+}
+
+// DecryptOptions tunes how decrypt inputs are parsed. The zero value is the
+// safe default.
+type DecryptOptions struct {
+	// UnsafeSkipSubgroupCheck accepts g1b, g2b, r1 and shared points that are
+	// on the curve but outside the prime-order subgroup. Only for replaying
+	// historical data or testing adversarial inputs.
+	UnsafeSkipSubgroupCheck bool
+
+	// DomainTagHex overrides the domain tag hashed into the hop key (hex of
+	// the tag bytes, e.g. the tag of another protocol version). Empty means
+	// DomainTagHex.
+	DomainTagHex string
+}
+
+// DecryptToHashWithOptions is DecryptToHash with explicit DecryptOptions.
+func DecryptToHashWithOptions(g1bHex, g2bHex, r1Hex, sharedHex string, opts DecryptOptions) (string, error) {
+	key, _, err := DecryptToHashAndGTWithOptions(g1bHex, g2bHex, r1Hex, sharedHex, opts)
+	return key, err
+}
+
+// DecryptToHashAndGTWithOptions is DecryptToHashWithOptions that also returns
+// the GT element k = r2 / b before hashing, as fq12CanonicalBytes hex (1152
+// chars). It is for comparing k itself with another implementation when the
+// keys disagree.
+func DecryptToHashAndGTWithOptions(g1bHex, g2bHex, r1Hex, sharedHex string, opts DecryptOptions) (key, gtHex string, err error) {
+	tag := domainTagFr()
+	if opts.DomainTagHex != "" {
+		if tag, err = parseDomainTagFr(opts.DomainTagHex); err != nil {
+			return "", "", err
+		}
+	}
+	k, err := decryptGT(g1bHex, g2bHex, r1Hex, sharedHex, opts.UnsafeSkipSubgroupCheck)
+	if err != nil {
+		return "", "", err
+	}
+	key, err = gtToHashFromGTWithTag(k, tag)
+	if err != nil {
+		return "", "", err
+	}
+	return key, hex.EncodeToString(fq12CanonicalBytes(k)), nil
+}
+
+// decryptGT computes k = r2 / b for DecryptToHash.
+func decryptGT(g1bHex, g2bHex, r1Hex, sharedHex string, skip bool) (bls12381.GT, error) {
+	var none bls12381.GT
+
 	// Parse fixed H0
 	h0, err := parseG2CompressedHex(H0Hex)
 	if err != nil {
-		return "", err
+		return none, err
 	}
 
 	// Parse inputs
-	g1b, err := parseG1CompressedHex(g1bHex)
+	g1b, err := decodeG1CompressedHex(g1bHex, skip)
 	if err != nil {
-		return "", fmt.Errorf("parse g1b: %w", err)
+		return none, fmt.Errorf("parse g1b: %w", err)
 	}
-	r1, err := parseG1CompressedHex(r1Hex)
+	r1, err := decodeG1CompressedHex(r1Hex, skip)
 	if err != nil {
-		return "", fmt.Errorf("parse r1: %w", err)
+		return none, fmt.Errorf("parse r1: %w", err)
 	}
-	shared, err := parseG2CompressedHex(sharedHex)
+	shared, err := decodeG2CompressedHex(sharedHex, skip)
 	if err != nil {
-		return "", fmt.Errorf("parse shared: %w", err)
+		return none, fmt.Errorf("parse shared: %w", err)
 	}
 
 	// r2 = e(g1b, H0)
 	r2, err := bls12381.Pair([]bls12381.G1Affine{g1b}, []bls12381.G2Affine{h0})
 	if err != nil {
-		return "", fmt.Errorf("pair(g1b, H0): %w", err)
+		return none, fmt.Errorf("pair(g1b, H0): %w", err)
 	}
 
 	// Optional: r2 *= e(r1, g2b)
 	if g2bHex != "" {
-		g2b, err := parseG2CompressedHex(g2bHex)
+		g2b, err := decodeG2CompressedHex(g2bHex, skip)
 		if err != nil {
-			return "", fmt.Errorf("parse g2b: %w", err)
+			return none, fmt.Errorf("parse g2b: %w", err)
 		}
 		t, err := bls12381.Pair([]bls12381.G1Affine{r1}, []bls12381.G2Affine{g2b})
 		if err != nil {
-			return "", fmt.Errorf("pair(r1, g2b): %w", err)
+			return none, fmt.Errorf("pair(r1, g2b): %w", err)
 		}
 		r2.Mul(&r2, &t)
 	}
@@ -544,14 +791,11 @@ func DecryptToHash(g1bHex, g2bHex, r1Hex, sharedHex string) (string, error) {
 	// b = e(r1, shared)
 	b, err := bls12381.Pair([]bls12381.G1Affine{r1}, []bls12381.G2Affine{shared})
 	if err != nil {
-		return "", fmt.Errorf("pair(r1, shared): %w", err)
+		return none, fmt.Errorf("pair(r1, shared): %w", err)
 	}
 
 	// k = r2 / b
-	k := gtDiv(r2, b)
-
-	// hash(k)
-	return gtToHashFromGT(k)
+	return gtDiv(r2, b), nil
 }
 
 // --- in-circuit: prove
@@ -687,11 +931,35 @@ func hashToFrMiMC(api frontend.API, elements []frontend.Variable) (emulated.Elem
 // The pairing e([a]G, H0) is computed in-circuit using the emulated BLS12-381 pairing
 // gadget, and MiMC hashing uses native field arithmetic for efficiency.
 func (c *vw0w1Circuit) Define(api frontend.API) error {
+	return c.define(api, &vw0w1Gadgets{})
+}
+
+// vw0w1Gadgets holds the emulated curve and pairing gadgets. A batch circuit
+// shares one instance across its statements. Both are created lazily, at the
+// same points of define as before batching existed, so the single-statement
+// constraint system is unchanged.
+type vw0w1Gadgets struct {
+	curve   *sw_emulated.Curve[emparams.BLS12381Fp, emparams.BLS12381Fr]
+	pairing *sw_bls12381.Pairing
+	h0      sw_bls12381.G2Affine
+
+	// only, if set, restricts define to that one relation. Used by the
+	// constraint tracer (trace.go); production circuits leave it zero.
+	only vw0w1Relation
+}
+
+// define adds the constraints of one vw0w1 statement, creating any gadget
+// missing from g.
+func (c *vw0w1Circuit) define(api frontend.API, g *vw0w1Gadgets) error {
 	// G1 arithmetic (emulated)
-	curve, err := sw_emulated.New[emparams.BLS12381Fp, emparams.BLS12381Fr](api, sw_emulated.GetBLS12381Params())
-	if err != nil {
-		return err
+	if g.curve == nil {
+		curve, err := sw_emulated.New[emparams.BLS12381Fp, emparams.BLS12381Fr](api, sw_emulated.GetBLS12381Params())
+		if err != nil {
+			return err
+		}
+		g.curve = curve
 	}
+	curve := g.curve
 
 	v := sw_emulated.AffinePoint[emparams.BLS12381Fp]{X: c.VX, Y: c.VY}
 	w0 := sw_emulated.AffinePoint[emparams.BLS12381Fp]{X: c.W0X, Y: c.W0Y}
@@ -704,19 +972,44 @@ func (c *vw0w1Circuit) Define(api frontend.API) error {
 	// qa = [a]q
 	qa := curve.ScalarMulBase(&c.A)
 
+	if g.only != relationW1 {
+		if err := c.assertW0(api, g, qa, &w0); err != nil {
+			return err
+		}
+	}
+
+	if g.only != relationW0 {
+		// p1 = [a]q + [r]v
+		rv := curve.ScalarMul(&v, &c.R)
+		p1 := curve.Add(qa, rv)
+		curve.AssertIsEqual(p1, &w1)
+	}
+
+	return nil
+}
+
+// assertW0 constrains w0 == [hk]q, with hk = mimc(fq12ToFr(e(qa, H0)) ||
+// DomainTag) computed in-circuit.
+func (c *vw0w1Circuit) assertW0(api frontend.API, g *vw0w1Gadgets, qa, w0 *sw_emulated.AffinePoint[emparams.BLS12381Fp]) error {
+	curve := g.curve
+
 	// --- compute hk IN-CIRCUIT from kappa = e(qa, H0) ---
 
 	// Pairing gadget (emulated)
-	pairing, err := sw_bls12381.NewPairing(api)
-	if err != nil {
-		return err
-	}
+	if g.pairing == nil {
+		pairing, err := sw_bls12381.NewPairing(api)
+		if err != nil {
+			return err
+		}
 
-	h0Native, err := parseG2CompressedHex(H0Hex)
-	if err != nil {
-		return fmt.Errorf("parse H0Hex: %w", err)
+		h0Native, err := parseG2CompressedHex(H0Hex)
+		if err != nil {
+			return fmt.Errorf("parse H0Hex: %w", err)
+		}
+		g.pairing = pairing
+		g.h0 = sw_bls12381.NewG2AffineFixed(h0Native)
 	}
-	h0 := sw_bls12381.NewG2AffineFixed(h0Native)
+	pairing, h0 := g.pairing, g.h0
 
 	qaForPair := sw_bls12381.G1Affine{X: qa.X, Y: qa.Y}
 	// NOTE: Skipping AssertIsOnG1/G2 - qa comes from ScalarMulBase (always valid),
@@ -748,13 +1041,7 @@ func (c *vw0w1Circuit) Define(api frontend.API) error {
 
 	// p0 = [hk]q
 	p0 := curve.ScalarMulBase(&hk)
-	curve.AssertIsEqual(p0, &w0)
-
-	// p1 = [a]q + [r]v
-	rv := curve.ScalarMul(&v, &c.R)
-	p1 := curve.Add(qa, rv)
-	curve.AssertIsEqual(p1, &w1)
-
+	curve.AssertIsEqual(p0, w0)
 	return nil
 }
 
@@ -774,65 +1061,22 @@ func (c *vw0w1Circuit) Define(api frontend.API) error {
 // Exports:
 //   - writes vk.json / proof.json / public.json to outDir via ExportAll(...)
 func ProveAndVerifyVW0W1(a, r *big.Int, vHex, w0Hex, w1Hex, outDir string) error {
-	if a == nil || a.Sign() == 0 {
-		return fmt.Errorf("a must be > 0")
-	}
-	if r == nil {
-		r = new(big.Int)
-	}
+	return ProveAndVerifyVW0W1WithOptions(a, r, vHex, w0Hex, w1Hex, outDir, ProveOptions{})
+}
 
-	// 1) Parse public points (and sanity-check compressed form)
-	parse48 := func(name, h string) ([]byte, error) {
-		raw, err := hex.DecodeString(h)
-		if err != nil {
-			return nil, fmt.Errorf("decode %s hex: %w", name, err)
-		}
-		if len(raw) != 48 {
-			return nil, fmt.Errorf("invalid %s length: got %d bytes, want 48", name, len(raw))
-		}
-		return raw, nil
-	}
-	if _, err := parse48("v", vHex); err != nil {
-		return err
-	}
-	if _, err := parse48("w0", w0Hex); err != nil {
+// ProveAndVerifyVW0W1WithOptions is ProveAndVerifyVW0W1 with explicit ProveOptions.
+// The proof is always verified here; opts.SkipVerify is ignored.
+func ProveAndVerifyVW0W1WithOptions(a, r *big.Int, vHex, w0Hex, w1Hex, outDir string, opts ProveOptions) error {
+	// 1-3) Parse public points, pre-flight the relation and build the assignment
+	assignment, err := prepareAssignment(a, r, vHex, w0Hex, w1Hex, opts)
+	if err != nil {
 		return err
 	}
-	if _, err := parse48("w1", w1Hex); err != nil {
+	defer assignment.wipeSecrets()
+	if err := checkArtifacts(outDir, opts); err != nil {
 		return err
 	}
 
-	vAff, err := parseG1CompressedHex(vHex)
-	if err != nil {
-		return fmt.Errorf("invalid compressed G1 v: %w", err)
-	}
-	w0Aff, err := parseG1CompressedHex(w0Hex)
-	if err != nil {
-		return fmt.Errorf("invalid compressed G1 w0: %w", err)
-	}
-	w1Aff, err := parseG1CompressedHex(w1Hex)
-	if err != nil {
-		return fmt.Errorf("invalid compressed G1 w1: %w", err)
-	}
-
-	// 2) Reduce secrets into Fr (important if caller passes huge ints)
-	var aFr, rFr fr.Element
-	aFr.SetBigInt(a)
-	rFr.SetBigInt(r)
-
-	var aRed, rRed big.Int
-	aFr.BigInt(&aRed)
-	rFr.BigInt(&rRed)
-
-	// 3) Extract affine coords to big.Int (regular big-endian)
-	var vx, vy, w0x, w0y, w1x, w1y big.Int
-	vAff.X.ToBigIntRegular(&vx)
-	vAff.Y.ToBigIntRegular(&vy)
-	w0Aff.X.ToBigIntRegular(&w0x)
-	w0Aff.Y.ToBigIntRegular(&w0y)
-	w1Aff.X.ToBigIntRegular(&w1x)
-	w1Aff.Y.ToBigIntRegular(&w1y)
-
 	// 4) Compile circuit over BLS12-381 scalar field
 	var circuit vw0w1Circuit
 	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &circuit)
@@ -846,50 +1090,19 @@ func ProveAndVerifyVW0W1(a, r *big.Int, vHex, w0Hex, w1Hex, outDir string) error
 		return fmt.Errorf("setup: %w", err)
 	}
 
-	// 6) Create witness assignment
-	assignment := vw0w1Circuit{
-		A: emulated.ValueOf[emparams.BLS12381Fr](&aRed),
-		R: emulated.ValueOf[emparams.BLS12381Fr](&rRed),
-
-		VX: emulated.ValueOf[emparams.BLS12381Fp](&vx),
-		VY: emulated.ValueOf[emparams.BLS12381Fp](&vy),
-
-		W0X: emulated.ValueOf[emparams.BLS12381Fp](&w0x),
-		W0Y: emulated.ValueOf[emparams.BLS12381Fp](&w0y),
-
-		W1X: emulated.ValueOf[emparams.BLS12381Fp](&w1x),
-		W1Y: emulated.ValueOf[emparams.BLS12381Fp](&w1y),
-	}
-
-	witness, err := frontend.NewWitness(&assignment, ecc.BLS12_381.ScalarField())
-	if err != nil {
-		return fmt.Errorf("new witness: %w", err)
-	}
-	publicWitness, err := witness.Public()
-	if err != nil {
-		return fmt.Errorf("public witness: %w", err)
-	}
-
-	// 7) Prove + verify
-	proof, err := groth16.Prove(ccs, pk, witness)
+	// 6-7) Prove + verify
+	h := &SetupHandle{CCS: ccs, PK: pk, VK: vk}
+	opts.SkipVerify = false
+	proof, publicWitness, err := h.proveAssignment(assignment, opts)
 	if err != nil {
-		return fmt.Errorf("prove: %w", err)
-	}
-	if err := groth16.Verify(proof, vk, publicWitness); err != nil {
-		return fmt.Errorf("verify failed: %w", err)
-	}
-
-	// 8) Export artifacts
-	if err := ExportAll(vk, proof, publicWitness, outDir); err != nil {
-		return fmt.Errorf("export: %w", err)
+		return err
 	}
-
-	// 9) Save gnark native binary files for standalone verification
-	if err := SaveNativeFiles(vk, proof, publicWitness, outDir); err != nil {
-		return fmt.Errorf("save native files: %w", err)
+	if opts.NoExport {
+		return nil
 	}
 
-	return nil
+	// 8) Export the artifacts selected by opts.Format
+	return writeArtifacts(h, proof, publicWitness, outDir, opts)
 }
 
 // ---------- Production Setup/Prove Workflow ----------
@@ -915,6 +1128,24 @@ func CompileVW0W1Circuit() (constraint.ConstraintSystem, error) {
 }
 
 func SetupVW0W1Circuit(outDir string, force bool) error {
+	return SetupVW0W1CircuitWithOptions(outDir, force, SetupOptions{})
+}
+
+// SetupOptions tunes SetupVW0W1CircuitWithOptions. The zero value is the default.
+type SetupOptions struct {
+	// ProfileDir, if set, receives setup.cpu.pprof and setup.heap.pprof
+	// bracketing the groth16.Setup call.
+	ProfileDir string
+
+	// Raw writes pk.bin uncompressed for faster loading (see SaveOptions.Raw).
+	Raw bool
+
+	// PKSplit writes the proving key as that many shards (see SaveOptions.PKSplit).
+	PKSplit int
+}
+
+// SetupVW0W1CircuitWithOptions is SetupVW0W1Circuit with explicit SetupOptions.
+func SetupVW0W1CircuitWithOptions(outDir string, force bool, opts SetupOptions) error {
 	// Check if setup files already exist
 	if !force && SetupFilesExist(outDir) {
 		return nil // Already set up
@@ -926,42 +1157,245 @@ func SetupVW0W1Circuit(outDir string, force bool) error {
 	}
 
 	// Setup keys (trusted setup)
+	stopProfile, err := startProfile(opts.ProfileDir, "setup")
+	if err != nil {
+		return err
+	}
 	pk, vk, err := groth16.Setup(ccs)
+	if stopErr := stopProfile(); stopErr != nil && err == nil {
+		return stopErr
+	}
 	if err != nil {
 		return fmt.Errorf("setup: %w", err)
 	}
 
 	// Save setup files
-	if err := SaveSetupFiles(ccs, pk, vk, outDir); err != nil {
+	if err := SaveSetupFilesWithOptions(ccs, pk, vk, outDir, SaveOptions{Raw: opts.Raw, PKSplit: opts.PKSplit}); err != nil {
 		return fmt.Errorf("save setup files: %w", err)
 	}
 
 	// Also export vk.json for easy transfer to Aiken
-	if err := ExportVKOnly(vk, outDir); err != nil {
+	circuitHash, err := setupCircuitHash(outDir)
+	if err != nil {
+		return err
+	}
+	if err := exportVKOnly(vk, outDir, circuitHash); err != nil {
 		return fmt.Errorf("export vk.json: %w", err)
 	}
 
-	return nil
+	return WriteSetupManifest(outDir)
 }
 
-// ProveVW0W1FromSetup loads the setup files and generates a proof for the given inputs.
-// This is the production proving path that reuses pre-computed setup files.
+// SetupHandle holds a deserialized constraint system and key pair so that
+// repeated proofs reuse a single load of the setup files. Loading pk.bin is by
+// far the most expensive step of the production path, so long-running callers
+// (benchmarks, servers) should open the setup once and prove many times.
+type SetupHandle struct {
+	CCS constraint.ConstraintSystem
+	PK  groth16.ProvingKey
+	VK  groth16.VerifyingKey
+
+	hash memoHash // circuit hash of CCS, see circuitHash
+}
+
+// OpenSetup loads ccs.bin, pk.bin and vk.bin from dir into a SetupHandle.
+func OpenSetup(dir string) (*SetupHandle, error) {
+	return OpenSetupWithOptions(dir, LoadOptions{})
+}
+
+// OpenSetupWithOptions is OpenSetup with explicit LoadOptions.
+func OpenSetupWithOptions(dir string, opts LoadOptions) (*SetupHandle, error) {
+	ccs, pk, vk, err := LoadSetupFilesWithOptions(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &SetupHandle{CCS: ccs, PK: pk, VK: vk}, nil
+}
+
+// ProveOptions tunes the vw0w1 prove paths. The zero value is the default
+// behavior: pre-flight the public points, prove, then verify.
+type ProveOptions struct {
+	// SkipVerify skips groth16.Verify after proving.
+	SkipVerify bool
+
+	// SkipPreflight skips the out-of-circuit check that w0/w1 match (a, r, v).
+	// Only adversarial tests that want to exercise in-circuit rejection need this.
+	SkipPreflight bool
+
+	// ProfileDir, if set, receives prove.cpu.pprof and prove.heap.pprof
+	// bracketing the groth16.Prove call.
+	ProfileDir string
+
+	// MmapPK loads pk.bin through a memory mapping when proving from a setup
+	// directory (see LoadOptions.MmapPK).
+	MmapPK bool
+
+	// ParallelLoad deserializes ccs.bin and pk.bin concurrently when proving
+	// from a setup directory (see LoadOptions.Parallel).
+	ParallelLoad bool
+
+	// UnsafeUncheckedPK skips the subgroup checks on a raw pk.bin when
+	// proving from a setup directory (see LoadOptions.UnsafeUncheckedPK).
+	UnsafeUncheckedPK bool
+
+	// Export controls which JSON artifacts are written to outDir.
+	Export ExportOptions
+
+	// Format selects JSON artifacts, native binaries or both (the default).
+	Format OutputFormat
+
+	// Force allows overwriting artifacts already present in outDir. Without
+	// it, proving refuses to start if any file it would write exists.
+	Force bool
+
+	// UnsafeSkipSubgroupCheck accepts v, w0 and w1 points that are on the
+	// curve but outside the prime-order subgroup, in both the parser and the
+	// pre-flight. Only for replaying historical data or adversarial tests.
+	UnsafeSkipSubgroupCheck bool
+
+	// NoExport skips writing the JSON and native artifacts to outDir, so the
+	// call only reports whether proving (and verification) succeeded.
+	NoExport bool
+
+	// WitnessOut, if set, receives the full assignment as JSON (see
+	// VW0W1WitnessJSON) before proving, for debugging in-circuit failures.
+	// The file contains the secrets a and r and is written with mode 0600.
+	WitnessOut string
+
+	// TraceConstraints, when proving fails, re-solves the witness against
+	// each vw0w1 relation separately and names the ones that do not hold
+	// (see trace.go). It costs about one extra circuit compilation, and only
+	// on failure.
+	TraceConstraints bool
+}
+
+// ProveVW0W1 generates a proof for the given inputs using the loaded setup and
+// returns the proof together with its public witness. Nothing is written to disk.
+func (h *SetupHandle) ProveVW0W1(a, r *big.Int, vHex, w0Hex, w1Hex string, opts ProveOptions) (groth16.Proof, backend_witness.Witness, error) {
+	assignment, err := prepareAssignment(a, r, vHex, w0Hex, w1Hex, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer assignment.wipeSecrets()
+	return h.proveAssignment(assignment, opts)
+}
+
+// prepareAssignment is prepareVW0W1 as the native single-statement prove
+// paths call it; tests swap it to inspect the assignment after proving.
+var prepareAssignment = prepareVW0W1
+
+// proveAssignment builds the witness for assignment, proves it with the handle's
+// CCS/PK and, unless opts.SkipVerify, verifies the result with the handle's VK.
+func (h *SetupHandle) proveAssignment(assignment frontend.Circuit, opts ProveOptions) (groth16.Proof, backend_witness.Witness, error) {
+	witness, err := frontend.NewWitness(assignment, ecc.BLS12_381.ScalarField())
+	if err != nil {
+		return nil, nil, fmt.Errorf("new witness: %w", err)
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		return nil, nil, fmt.Errorf("public witness: %w", err)
+	}
+	defer wipeSecretWitness(witness, publicWitness)
+
+	stopProfile, err := startProfile(opts.ProfileDir, "prove")
+	if err != nil {
+		return nil, nil, err
+	}
+	proof, err := groth16.Prove(h.CCS, h.PK, witness)
+	if stopErr := stopProfile(); stopErr != nil && err == nil {
+		return nil, nil, stopErr
+	}
+	if err != nil {
+		err = fmt.Errorf("prove: %w", err)
+		if opts.TraceConstraints {
+			err = traceProveError(err, assignment)
+		}
+		return nil, nil, err
+	}
+
+	if !opts.SkipVerify {
+		if err := groth16.Verify(proof, h.VK, publicWitness); err != nil {
+			return nil, nil, withKind(ErrVerificationFailed, fmt.Errorf("verify failed: %w", err))
+		}
+	}
+
+	return proof, publicWitness, nil
+}
+
+// validateR checks that the blinding scalar r lies in [1, fr.Modulus()-1].
 //
-// Inputs:
-//   - setupDir: directory containing ccs.bin, pk.bin, vk.bin
-//   - outDir: directory for proof output (proof.bin, witness.bin, JSON files)
-//   - a, r: secret scalars
-//   - vHex, w0Hex, w1Hex: public G1 points as compressed hex
-//   - verify: if true, also verify the proof after generation
-func ProveVW0W1FromSetup(setupDir, outDir string, a, r *big.Int, vHex, w0Hex, w1Hex string, verify bool) error {
+// Values >= the modulus are rejected rather than silently reduced, since a
+// caller passing such an r almost certainly computed w1 from a different value.
+// r = 0 is rejected too: it makes w1 = [a]G, removing the blinding entirely,
+// and the emulated ScalarMul cannot represent [0]v (gnark fails with a
+// "no modular inverse" error while solving).
+func validateR(r *big.Int) error {
+	switch {
+	case r.Sign() < 0:
+		return withKind(ErrBadScalar, fmt.Errorf("r must be >= 0 (got %s)", r))
+	case r.Cmp(fr.Modulus()) >= 0:
+		reduced := new(big.Int).Mod(r, fr.Modulus())
+		return withKind(ErrBadScalar, fmt.Errorf("r must be < the BLS12-381 scalar field modulus; it would be reduced to %s", reduced))
+	case r.Sign() == 0:
+		return withKind(ErrBadScalar, errors.New("r = 0 is not supported: w1 would be [a]G with no blinding, and the circuit cannot compute [0]v"))
+	}
+	return nil
+}
+
+// prepareVW0W1 validates the prover inputs, dumps the assignment to
+// opts.WitnessOut if set, runs the pre-flight relation check (unless
+// opts.SkipPreflight) and builds the witness assignment. Its own copies of
+// the secrets (the reduced a and r, and hk) are wiped before it returns; a
+// and r belong to the caller and are left alone. The returned assignment
+// still holds the secrets in its A and R limbs, and the caller wipes it with
+// wipeSecrets once it is done proving.
+func prepareVW0W1(a, r *big.Int, vHex, w0Hex, w1Hex string, opts ProveOptions) (*vw0w1Circuit, error) {
 	if a == nil || a.Sign() == 0 {
-		return fmt.Errorf("a must be > 0")
+		return nil, fmt.Errorf("a must be > 0")
+	}
+	hk, err := hkScalarFromA(a)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBigInt(hk)
+	if err := checkNonZeroHk(hk); err != nil {
+		return nil, err
 	}
 	if r == nil {
 		r = new(big.Int)
 	}
 
-	// 1) Parse public points (and sanity-check compressed form)
+	vAff, w0Aff, w1Aff, err := parseVW0W1Points(vHex, w0Hex, w1Hex, opts.UnsafeSkipSubgroupCheck)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateR(r); err != nil {
+		return nil, err
+	}
+
+	values := newVW0W1Values(a, r, vAff, w0Aff, w1Aff)
+	defer values.wipeSecrets()
+	if opts.WitnessOut != "" {
+		// Written before the pre-flight so a failing case can still be inspected.
+		if err := values.writeJSON(opts.WitnessOut); err != nil {
+			return nil, err
+		}
+	}
+
+	if !opts.SkipPreflight {
+		if err := checkVW0W1Relation(a, r, vAff, w0Aff, w1Aff, opts.UnsafeSkipSubgroupCheck); err != nil {
+			return nil, err
+		}
+	}
+
+	return values.assignment(), nil
+}
+
+// parseVW0W1Points decodes the three public points, checking that each is a
+// 48-byte compressed G1 encoding before handing it to the curve parser.
+// skipSubgroup is forwarded to decodeG1CompressedHex.
+func parseVW0W1Points(vHex, w0Hex, w1Hex string, skipSubgroup bool) (v, w0, w1 bls12381.G1Affine, err error) {
 	parse48 := func(name, h string) ([]byte, error) {
 		raw, err := hex.DecodeString(h)
 		if err != nil {
@@ -972,99 +1406,337 @@ func ProveVW0W1FromSetup(setupDir, outDir string, a, r *big.Int, vHex, w0Hex, w1
 		}
 		return raw, nil
 	}
-	if _, err := parse48("v", vHex); err != nil {
-		return err
+	if _, err = parse48("v", vHex); err != nil {
+		return
 	}
-	if _, err := parse48("w0", w0Hex); err != nil {
-		return err
+	if _, err = parse48("w0", w0Hex); err != nil {
+		return
 	}
-	if _, err := parse48("w1", w1Hex); err != nil {
-		return err
+	if _, err = parse48("w1", w1Hex); err != nil {
+		return
 	}
 
-	vAff, err := parseG1CompressedHex(vHex)
-	if err != nil {
-		return fmt.Errorf("invalid compressed G1 v: %w", err)
+	if v, err = decodeG1CompressedHex(vHex, skipSubgroup); err != nil {
+		err = fmt.Errorf("invalid compressed G1 v: %w", err)
+		return
 	}
-	w0Aff, err := parseG1CompressedHex(w0Hex)
-	if err != nil {
-		return fmt.Errorf("invalid compressed G1 w0: %w", err)
+	if w0, err = decodeG1CompressedHex(w0Hex, skipSubgroup); err != nil {
+		err = fmt.Errorf("invalid compressed G1 w0: %w", err)
+		return
 	}
-	w1Aff, err := parseG1CompressedHex(w1Hex)
-	if err != nil {
-		return fmt.Errorf("invalid compressed G1 w1: %w", err)
+	if w1, err = decodeG1CompressedHex(w1Hex, skipSubgroup); err != nil {
+		err = fmt.Errorf("invalid compressed G1 w1: %w", err)
+		return
+	}
+	return
+}
+
+// NewVW0W1Assignment reduces (a, r) into Fr and builds the vw0w1Circuit
+// witness assignment from the affine coordinates of the public points. It is
+// the one place the emulated field elements are constructed: the native and
+// WASM prove paths go through the same vw0w1Values, adding only the witness
+// dump between extraction and assignment.
+func NewVW0W1Assignment(a, r *big.Int, vAff, w0Aff, w1Aff bls12381.G1Affine) *vw0w1Circuit {
+	return newVW0W1Values(a, r, vAff, w0Aff, w1Aff).assignment()
+}
+
+// vw0w1Values holds the integers behind a vw0w1Circuit assignment: (a, r)
+// reduced into Fr and the affine coordinates of v, w0, w1.
+type vw0w1Values struct {
+	A, R               big.Int
+	VX, VY             big.Int
+	W0X, W0Y, W1X, W1Y big.Int
+}
+
+// newVW0W1Values reduces (a, r) into Fr and extracts the point coordinates.
+// Every value is in range by construction: the scalars are reduced here (the
+// native path has already rejected r outside [1, q) in validateR), and the
+// coordinates come from canonical fp.Elements.
+func newVW0W1Values(a, r *big.Int, vAff, w0Aff, w1Aff bls12381.G1Affine) *vw0w1Values {
+	var x vw0w1Values
+
+	// Reduce secrets into Fr
+	x.A.Set(reduceFr(a))
+	x.R.Set(reduceFr(r))
+
+	// Extract affine coords to big.Int
+	vAff.X.ToBigIntRegular(&x.VX)
+	vAff.Y.ToBigIntRegular(&x.VY)
+	w0Aff.X.ToBigIntRegular(&x.W0X)
+	w0Aff.Y.ToBigIntRegular(&x.W0Y)
+	w1Aff.X.ToBigIntRegular(&x.W1X)
+	w1Aff.Y.ToBigIntRegular(&x.W1Y)
+
+	return &x
+}
+
+// ErrZeroHk is returned (wrapped) for a secret whose hk is 0 mod r. Then
+// [hk]G is the point at infinity, which neither circuit can represent; the
+// prove paths refuse such a secret up front instead of failing in the
+// solver.
+var ErrZeroHk = errors.New("hk reduced to 0")
+
+// checkNonZeroHk rejects hk when it is 0 mod r.
+func checkNonZeroHk(hk *big.Int) error {
+	if new(big.Int).Mod(hk, fr.Modulus()).Sign() == 0 {
+		return fmt.Errorf("%w; refuse ([hk]G would be the point at infinity)", ErrZeroHk)
+	}
+	return nil
+}
+
+// assignment builds the vw0w1Circuit witness assignment from x.
+func (x *vw0w1Values) assignment() *vw0w1Circuit {
+	return &vw0w1Circuit{
+		A: secretElement(&x.A),
+		R: secretElement(&x.R),
+
+		VX: emulated.ValueOf[emparams.BLS12381Fp](&x.VX),
+		VY: emulated.ValueOf[emparams.BLS12381Fp](&x.VY),
+
+		W0X: emulated.ValueOf[emparams.BLS12381Fp](&x.W0X),
+		W0Y: emulated.ValueOf[emparams.BLS12381Fp](&x.W0Y),
+
+		W1X: emulated.ValueOf[emparams.BLS12381Fp](&x.W1X),
+		W1Y: emulated.ValueOf[emparams.BLS12381Fp](&x.W1Y),
 	}
+}
+
+// wipeSecrets zeroes the reduced secrets A and R, words included, once the
+// assignment has been built from them (secretElement splits them into new
+// limbs). The point coordinates are public and left alone.
+func (x *vw0w1Values) wipeSecrets() {
+	wipeBigInt(&x.A)
+	wipeBigInt(&x.R)
+}
 
-	// 2) Reduce secrets into Fr
-	var aFr, rFr fr.Element
-	aFr.SetBigInt(a)
-	rFr.SetBigInt(r)
+// secretElement splits k, already reduced into Fr, into the limbs of an
+// emulated witness element: the same limbs emulated.ValueOf yields once the
+// witness is parsed. ValueOf would also keep a private copy of k that nothing
+// here can reach, so the limbs are built directly and stay the only copy,
+// for wipeSecrets to zero.
+func secretElement(k *big.Int) emulated.Element[emparams.BLS12381Fr] {
+	nbLimbs, nbBits := emulated.GetEffectiveFieldParams[emparams.BLS12381Fr](ecc.BLS12_381.ScalarField())
+	mask := new(big.Int).Lsh(big.NewInt(1), nbBits)
+	mask.Sub(mask, big.NewInt(1))
+	limbs := make([]frontend.Variable, nbLimbs)
+	for i := range limbs {
+		limb := new(big.Int).Rsh(k, uint(i)*nbBits)
+		limbs[i] = limb.And(limb, mask)
+	}
+	return emulated.Element[emparams.BLS12381Fr]{Limbs: limbs}
+}
 
-	var aRed, rRed big.Int
-	aFr.BigInt(&aRed)
-	rFr.BigInt(&rRed)
+// wipeSecrets zeroes the A and R limbs of an assignment once it has been
+// proven. The public coordinates are left alone.
+func (c *vw0w1Circuit) wipeSecrets() {
+	wipeLimbs(c.A.Limbs)
+	wipeLimbs(c.R.Limbs)
+}
+
+// wipeLimbs zeroes the big.Int limbs of an emulated witness element.
+func wipeLimbs(limbs []frontend.Variable) {
+	for _, l := range limbs {
+		if b, ok := l.(*big.Int); ok {
+			wipeBigInt(b)
+		}
+	}
+}
 
-	// 3) Extract affine coords to big.Int
-	var vx, vy, w0x, w0y, w1x, w1y big.Int
-	vAff.X.ToBigIntRegular(&vx)
-	vAff.Y.ToBigIntRegular(&vy)
-	w0Aff.X.ToBigIntRegular(&w0x)
-	w0Aff.Y.ToBigIntRegular(&w0y)
-	w1Aff.X.ToBigIntRegular(&w1x)
-	w1Aff.Y.ToBigIntRegular(&w1y)
+// wipeAssignment wipes assignment if it has a wipeSecrets method. The server
+// calls it on whatever circuit its assign hook returned.
+func wipeAssignment(assignment frontend.Circuit) {
+	if w, ok := assignment.(interface{ wipeSecrets() }); ok {
+		w.wipeSecrets()
+	}
+}
 
-	// 4) Load setup files
-	ccs, pk, vk, err := LoadSetupFiles(setupDir)
+// wipeBigInt overwrites the words of k and sets it to 0. big.Int keeps its
+// backing array when set to a smaller value, so SetInt64(0) alone would leave
+// the old words in memory.
+func wipeBigInt(k *big.Int) {
+	if k == nil {
+		return
+	}
+	words := k.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	k.SetInt64(0)
+}
+
+// wipeSecretWitness zeroes the secret part of the full witness after
+// proving, leaving its leading public entries intact. public, the copy
+// witness.Public returned, is only read for its length.
+func wipeSecretWitness(full, public backend_witness.Witness) {
+	vec, ok := full.Vector().(fr.Vector)
+	pub, okPub := public.Vector().(fr.Vector)
+	if !ok || !okPub || len(pub) > len(vec) {
+		return
+	}
+	for i := len(pub); i < len(vec); i++ {
+		vec[i].SetZero()
+	}
+}
+
+// PreflightVW0W1 recomputes the public points the circuit will check from
+// (a, r, v) and compares them to the supplied w0/w1. It costs one pairing and a
+// few scalar multiplications, so callers can catch a wrong secret or point in
+// milliseconds instead of after minutes of proving ends in an unsatisfied
+// constraint.
+func PreflightVW0W1(a, r *big.Int, vHex, w0Hex, w1Hex string) error {
+	if a == nil || a.Sign() == 0 {
+		return fmt.Errorf("a must be > 0")
+	}
+	if r == nil {
+		r = new(big.Int)
+	}
+	v, w0, w1, err := parseVW0W1Points(vHex, w0Hex, w1Hex, false)
 	if err != nil {
-		return fmt.Errorf("load setup files: %w", err)
+		return err
 	}
+	return checkVW0W1Relation(a, r, v, w0, w1, false)
+}
 
-	// 5) Create witness assignment
-	assignment := vw0w1Circuit{
-		A: emulated.ValueOf[emparams.BLS12381Fr](&aRed),
-		R: emulated.ValueOf[emparams.BLS12381Fr](&rRed),
+// checkVW0W1Relation checks that v, w0, w1 lie in the prime-order subgroup and
+// that w0 == [hk(a)]q and w1 == [a]q + [r]v, naming the first relation that fails.
+// skipSubgroup drops the subgroup checks but still compares the relations.
+func checkVW0W1Relation(a, r *big.Int, v, w0, w1 bls12381.G1Affine, skipSubgroup bool) error {
+	if skipSubgroup {
+		wantW0, wantW1, err := computeW0W1Unchecked(a, r, v)
+		if err != nil {
+			return err
+		}
+		return compareW0W1(w0, w1, wantW0, wantW1)
+	}
 
-		VX: emulated.ValueOf[emparams.BLS12381Fp](&vx),
-		VY: emulated.ValueOf[emparams.BLS12381Fp](&vy),
+	for _, p := range []struct {
+		name string
+		pt   *bls12381.G1Affine
+	}{{"v", &v}, {"w0", &w0}, {"w1", &w1}} {
+		if !p.pt.IsInSubGroup() {
+			return withKind(ErrBadPoint, fmt.Errorf("%s is not in the prime-order G1 subgroup", p.name))
+		}
+	}
 
-		W0X: emulated.ValueOf[emparams.BLS12381Fp](&w0x),
-		W0Y: emulated.ValueOf[emparams.BLS12381Fp](&w0y),
+	wantW0, wantW1, err := computeW0W1(a, r, v)
+	if err != nil {
+		return err
+	}
+	return compareW0W1(w0, w1, wantW0, wantW1)
+}
 
-		W1X: emulated.ValueOf[emparams.BLS12381Fp](&w1x),
-		W1Y: emulated.ValueOf[emparams.BLS12381Fp](&w1y),
+// compareW0W1 names the first of w0, w1 that differs from its expected value.
+func compareW0W1(w0, w1, wantW0, wantW1 bls12381.G1Affine) error {
+	if !w0.Equal(&wantW0) {
+		return withKind(ErrWitnessMismatch, errors.New("w0 mismatch: w0 != [hk(a)]q (you likely used the wrong a)"))
+	}
+	if !w1.Equal(&wantW1) {
+		return withKind(ErrWitnessMismatch, errors.New("w1 mismatch: w1 != [a]q + [r]v (check a, r and v)"))
 	}
+	return nil
+}
 
-	witness, err := frontend.NewWitness(&assignment, ecc.BLS12_381.ScalarField())
+// computeW0W1 derives the public points the vw0w1 circuit expects for (a, r, v):
+//
+//	w0 = [hk(a)]q
+//	w1 = [a]q + [r]v
+func computeW0W1(a, r *big.Int, v bls12381.G1Affine) (bls12381.G1Affine, bls12381.G1Affine, error) {
+	hk, err := hkScalarFromA(a)
 	if err != nil {
-		return fmt.Errorf("new witness: %w", err)
+		return bls12381.G1Affine{}, bls12381.G1Affine{}, err
 	}
-	publicWitness, err := witness.Public()
+
+	w0 := g1MulBase(hk)
+
+	rv, err := ScalarMulG1(v, r)
 	if err != nil {
-		return fmt.Errorf("public witness: %w", err)
+		return bls12381.G1Affine{}, bls12381.G1Affine{}, fmt.Errorf("v: %w", err)
 	}
+	w1, err := AddG1(g1MulBase(a), rv)
+	if err != nil {
+		return bls12381.G1Affine{}, bls12381.G1Affine{}, err
+	}
+
+	return w0, w1, nil
+}
 
-	// 6) Prove
-	proof, err := groth16.Prove(ccs, pk, witness)
+// computeW0W1Unchecked is computeW0W1 without the subgroup checks on v, for
+// the -unsafe-skip-subgroup-check pre-flight.
+func computeW0W1Unchecked(a, r *big.Int, v bls12381.G1Affine) (bls12381.G1Affine, bls12381.G1Affine, error) {
+	hk, err := hkScalarFromA(a)
 	if err != nil {
-		return fmt.Errorf("prove: %w", err)
+		return bls12381.G1Affine{}, bls12381.G1Affine{}, err
 	}
 
-	// 7) Optionally verify
-	if verify {
-		if err := groth16.Verify(proof, vk, publicWitness); err != nil {
-			return fmt.Errorf("verify failed: %w", err)
-		}
+	var rv, w1 bls12381.G1Affine
+	rv.ScalarMultiplication(&v, reduceScalar(r))
+	aq := g1MulBase(a)
+	w1.Add(&aq, &rv)
+
+	return g1MulBase(hk), w1, nil
+}
+
+// ProveVW0W1FromSetup loads the setup files and generates a proof for the given inputs.
+// This is the production proving path that reuses pre-computed setup files.
+//
+// Inputs:
+//   - setupDir: directory containing ccs.bin, pk.bin, vk.bin
+//   - outDir: directory for proof output (proof.bin, witness.bin, JSON files)
+//   - a, r: secret scalars
+//   - vHex, w0Hex, w1Hex: public G1 points as compressed hex
+//   - verify: if true, also verify the proof after generation
+func ProveVW0W1FromSetup(setupDir, outDir string, a, r *big.Int, vHex, w0Hex, w1Hex string, verify bool) error {
+	return ProveVW0W1FromSetupWithOptions(setupDir, outDir, a, r, vHex, w0Hex, w1Hex, ProveOptions{SkipVerify: !verify})
+}
+
+// ProveVW0W1FromSetupWithOptions is ProveVW0W1FromSetup with explicit ProveOptions.
+// Inputs are validated and pre-flighted before the setup files are loaded.
+func ProveVW0W1FromSetupWithOptions(setupDir, outDir string, a, r *big.Int, vHex, w0Hex, w1Hex string, opts ProveOptions) error {
+	open := func() (*SetupHandle, error) {
+		return OpenSetupWithOptions(setupDir, LoadOptions{MmapPK: opts.MmapPK, Parallel: opts.ParallelLoad, UnsafeUncheckedPK: opts.UnsafeUncheckedPK})
 	}
+	return proveVW0W1WithSetup(open, outDir, a, r, vHex, w0Hex, w1Hex, opts)
+}
 
-	// 8) Export artifacts
-	if err := ExportAll(vk, proof, publicWitness, outDir); err != nil {
-		return fmt.Errorf("export: %w", err)
+// ProveVW0W1FromURLsWithOptions is ProveVW0W1FromSetupWithOptions with the
+// setup files streamed through fetcher (see OpenSetupFromURLs) instead of
+// read from a directory. opts.MmapPK, opts.ParallelLoad and
+// opts.UnsafeUncheckedPK do not apply: a streamed key is always checked.
+func ProveVW0W1FromURLsWithOptions(urls SetupURLs, fetcher SetupFetcher, outDir string, a, r *big.Int, vHex, w0Hex, w1Hex string, opts ProveOptions) error {
+	open := func() (*SetupHandle, error) {
+		return OpenSetupFromURLs(urls, fetcher)
 	}
+	return proveVW0W1WithSetup(open, outDir, a, r, vHex, w0Hex, w1Hex, opts)
+}
 
-	// 9) Save gnark native binary files for standalone verification
-	if err := SaveNativeFiles(vk, proof, publicWitness, outDir); err != nil {
-		return fmt.Errorf("save native files: %w", err)
+// proveVW0W1WithSetup validates and pre-flights the inputs, then opens the
+// setup with open, proves, and exports the artifacts to outDir.
+func proveVW0W1WithSetup(open func() (*SetupHandle, error), outDir string, a, r *big.Int, vHex, w0Hex, w1Hex string, opts ProveOptions) error {
+	// 1) Validate inputs and build the assignment before touching the setup files
+	assignment, err := prepareAssignment(a, r, vHex, w0Hex, w1Hex, opts)
+	if err != nil {
+		return err
+	}
+	defer assignment.wipeSecrets()
+	if err := checkArtifacts(outDir, opts); err != nil {
+		return err
 	}
 
-	return nil
+	// 2) Load setup files
+	h, err := open()
+	if err != nil {
+		return fmt.Errorf("load setup files: %w", err)
+	}
+
+	// 3) Prove (and optionally verify)
+	proof, publicWitness, err := h.proveAssignment(assignment, opts)
+	if err != nil {
+		return err
+	}
+	if opts.NoExport {
+		return nil
+	}
+
+	// 4) Export the artifacts selected by opts.Format
+	return writeArtifacts(h, proof, publicWitness, outDir, opts)
 }
@@ -0,0 +1,120 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// keypair.go checks that pk.bin and vk.bin in a setup directory belong to
+// the same setup or ceremony finalization. checkSetupConsistency only
+// compares sizes, and two finalizations of the same circuit have identical
+// sizes, so a pk.bin copied from one and a vk.bin from the other load fine
+// and produce proofs no verifier accepts. Here the group elements both keys
+// carry ([α]₁, [β]₁, [δ]₁, [β]₂, [δ]₂) are compared, and a proof of a fixed,
+// known-good vw0w1 witness is produced with the PK and verified with the VK.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/backend/groth16"
+	groth16bls "github.com/consensys/gnark/backend/groth16/bls12-381"
+	"github.com/consensys/gnark/frontend"
+)
+
+// ErrKeyPairMismatch is returned (wrapped) when a proving key and a
+// verifying key do not come from the same setup.
+var ErrKeyPairMismatch = errors.New("proving and verifying keys are not a pair")
+
+// KeyPairCheck is the outcome of one key pair check.
+type KeyPairCheck struct {
+	Name string
+	Err  error // nil when the check passed
+}
+
+// keyCheckA, keyCheckR and keyCheckV define the vw0w1 statement VerifyKeys
+// proves: a = 2, r = 3, v = [42]G. Any valid statement would do; a fixed one
+// keeps the check reproducible.
+var (
+	keyCheckA = big.NewInt(2)
+	keyCheckR = big.NewInt(3)
+	keyCheckV = big.NewInt(42)
+)
+
+// CheckKeyPair checks that h.PK and h.VK are a pair: their sizes agree with
+// h.CCS, the elements they share are equal, and a proof of assignment made
+// with h.PK verifies with h.VK. It stops at the first failing check and
+// returns the checks run so far. A failed check wraps ErrKeyPairMismatch; an
+// assignment the circuit rejects does not.
+func CheckKeyPair(h *SetupHandle, assignment frontend.Circuit) ([]KeyPairCheck, error) {
+	var checks []KeyPairCheck
+	fail := func(err error) ([]KeyPairCheck, error) {
+		checks[len(checks)-1].Err = err
+		return checks, fmt.Errorf("%w: %w", ErrKeyPairMismatch, err)
+	}
+
+	checks = append(checks, KeyPairCheck{Name: "sizes"})
+	if err := checkSetupConsistency(h.CCS, h.PK, h.VK); err != nil {
+		return fail(err)
+	}
+
+	checks = append(checks, KeyPairCheck{Name: "shared elements"})
+	if err := compareKeyElements(h.PK, h.VK); err != nil {
+		return fail(err)
+	}
+
+	checks = append(checks, KeyPairCheck{Name: "test proof"})
+	proof, publicWitness, err := h.proveAssignment(assignment, ProveOptions{SkipVerify: true})
+	if err != nil {
+		checks[len(checks)-1].Err = err
+		return checks, err
+	}
+	if err := groth16.Verify(proof, h.VK, publicWitness); err != nil {
+		return fail(fmt.Errorf("proof made with pk.bin does not verify with vk.bin: %w", err))
+	}
+	return checks, nil
+}
+
+// compareKeyElements reports the first of [α]₁, [β]₁, [δ]₁, [β]₂, [δ]₂ on
+// which pk and vk disagree.
+func compareKeyElements(pk groth16.ProvingKey, vk groth16.VerifyingKey) error {
+	p, ok := pk.(*groth16bls.ProvingKey)
+	if !ok {
+		return fmt.Errorf("unexpected pk type %T", pk)
+	}
+	v, ok := vk.(*groth16bls.VerifyingKey)
+	if !ok {
+		return fmt.Errorf("unexpected vk type %T", vk)
+	}
+	switch {
+	case !p.G1.Alpha.Equal(&v.G1.Alpha):
+		return fmt.Errorf("pk and vk have different [α]₁")
+	case !p.G1.Beta.Equal(&v.G1.Beta):
+		return fmt.Errorf("pk and vk have different [β]₁")
+	case !p.G1.Delta.Equal(&v.G1.Delta):
+		return fmt.Errorf("pk and vk have different [δ]₁")
+	case !p.G2.Beta.Equal(&v.G2.Beta):
+		return fmt.Errorf("pk and vk have different [β]₂")
+	case !p.G2.Delta.Equal(&v.G2.Delta):
+		return fmt.Errorf("pk and vk have different [δ]₂")
+	}
+	return nil
+}
+
+// VerifyKeys loads the vw0w1 setup in dir and runs CheckKeyPair with the
+// fixed statement a = 2, r = 3, v = [42]G. Loading already rejects keys whose
+// sizes disagree (ErrSetupMismatch); that is reported as a failed "sizes"
+// check too.
+func VerifyKeys(dir string) ([]KeyPairCheck, error) {
+	h, err := OpenSetup(dir)
+	if errors.Is(err, ErrSetupMismatch) {
+		return []KeyPairCheck{{Name: "sizes", Err: err}}, fmt.Errorf("%w: %w", ErrKeyPairMismatch, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load setup files: %w", err)
+	}
+	v := g1MulBase(keyCheckV)
+	w0, w1, err := computeW0W1(keyCheckA, keyCheckR, v)
+	if err != nil {
+		return nil, err
+	}
+	return CheckKeyPair(h, NewVW0W1Assignment(keyCheckA, keyCheckR, v, w0, w1))
+}
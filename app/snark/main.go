@@ -7,12 +7,24 @@
 package main
 
 import (
+	"context"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"math/big"
+	"net"
 	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
 )
 
 // main is the native CLI entry point. It delegates to run() and exits with
@@ -21,220 +33,1477 @@ func main() {
 	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
 }
 
+// stdin is what `prove -secrets-stdin` reads the secrets from. Tests replace
+// it.
+var stdin io.Reader = os.Stdin
+
 // run implements the CLI command dispatch. It parses the first positional argument
-// as a subcommand (setup, hash, decrypt, prove, verify, re-export, debug-verify,
-// test-verify) and delegates to the appropriate handler. Returns 0 on success,
-// 1 on operational failure, or 2 on usage/argument errors.
+// as a subcommand (setup, hash, blake2b224, reduce, reencode, decrypt, decrypt-chain, from-chain, prove, prove-batch, verify, verify-only, verify-setup, verify-keys, info/version, check-constants, conformance-check, re-export, normalize, export-vk,
+// commitment-wire, canonicalize-public, ccs-info, circuit-hash, serve, ceremony, debug-verify, test-verify, help; see commands.go) and delegates to the appropriate handler. Returns 0 on success,
+// 1 on operational failure, 2 on usage/argument errors, or 3 when `verify -expect-wire`
+// (or `verify-only`) finds a valid proof whose commitment wire differs from the expected one, or 130
+// when SIGINT/SIGTERM interrupts a setup or ceremony step (see interruptible). A leading
+// -json-errors flag reports failures as JSON on stderr (see clierrors.go).
 func run(args []string, stdout, stderr io.Writer) int {
 	if len(args) < 1 {
+		writeCommandList(stderr)
 		return 2
 	}
+	if args[0] == "-json-errors" || args[0] == "--json-errors" {
+		return runJSONErrors(args[1:], stdout, stderr)
+	}
+	if isHelpArg(args[0]) {
+		return runHelp(args[1:], stdout, stderr)
+	}
 
 	switch args[0] {
 	case "setup":
 		setupCmd := flag.NewFlagSet("setup", flag.ContinueOnError)
 		setupCmd.SetOutput(stderr)
 
-		var outDir string
-		var force bool
+		var outDir, profileDir string
+		var force, raw bool
 		setupCmd.StringVar(&outDir, "out", "setup", "output directory for setup files (ccs.bin, pk.bin, vk.bin)")
 		setupCmd.BoolVar(&force, "force", false, "overwrite existing setup files")
+		setupCmd.BoolVar(&raw, "raw", false, "write pk.bin uncompressed (larger, much faster to load)")
+		var pkSplit int
+		setupCmd.IntVar(&pkSplit, "pk-split", 0, "write the proving key as N shards pk_0000.bin.. plus pk_shards.json instead of pk.bin (0 = one pk.bin)")
+		setupCmd.StringVar(&profileDir, "profile", "", "write setup.cpu.pprof / setup.heap.pprof around groth16.Setup into this directory")
 		if err := setupCmd.Parse(args[1:]); err != nil {
 			return 2
 		}
+		if pkSplit < 0 {
+			fmt.Fprintln(stderr, "error: -pk-split must be >= 0")
+			return 2
+		}
+
+		if SetupFilesExist(outDir) && !force {
+			fmt.Fprintln(stdout, "Setup files already exist in", outDir, "(use -force to overwrite)")
+			return 0
+		}
+
+		fmt.Fprintln(stdout, "Compiling circuit and running trusted setup...")
+		if err := interruptible(func() error {
+			return SetupVW0W1CircuitWithOptions(outDir, force, SetupOptions{ProfileDir: profileDir, Raw: raw, PKSplit: pkSplit})
+		}); err != nil {
+			return failStep(stderr, err)
+		}
+
+		fmt.Fprintln(stdout, "SUCCESS: setup files written to", outDir)
+		return 0
+
+	case "hash":
+		hashCmd := flag.NewFlagSet("hash", flag.ContinueOnError)
+		hashCmd.SetOutput(stderr)
+
+		var aStr, filePath string
+		var minBits, jobs int
+		var allowWeak bool
+		hashCmd.StringVar(&aStr, "a", "", "secret integer a (decimal by default; or 0x... hex)")
+		hashCmd.StringVar(&filePath, "file", "", "JSON array of secrets to hash; prints a JSON array of {a, hash}")
+		hashCmd.IntVar(&jobs, "jobs", runtime.GOMAXPROCS(0), "with -file: number of secrets hashed concurrently")
+		var dedupe bool
+		hashCmd.BoolVar(&dedupe, "dedupe", false, "with -file: hash each distinct secret once and reuse the result for repeats; reports the cache hits to stderr")
+		hashCmd.IntVar(&minBits, "min-entropy-bits", 0, "reject secrets shorter than this many bits (0 disables)")
+		hashCmd.BoolVar(&allowWeak, "allow-weak", false, "only warn about secrets below -min-entropy-bits")
+		var kappaPath string
+		hashCmd.StringVar(&kappaPath, "emit-kappa", "", "also write kappa = e([a]q, h0) to this file as its raw 576-byte canonical Fq12 encoding (not hex)")
+		if err := hashCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if err := validateMinEntropyBits(minBits); err != nil {
+			printErr(stderr, "error:", err)
+			return 2
+		}
+		if jobs < 1 {
+			fmt.Fprintln(stderr, "error: -jobs must be >= 1")
+			return 2
+		}
+
+		if filePath != "" {
+			if aStr != "" {
+				fmt.Fprintln(stderr, "error: -file cannot be combined with -a")
+				return 2
+			}
+			if kappaPath != "" {
+				fmt.Fprintln(stderr, "error: -emit-kappa writes one kappa and requires -a, not -file")
+				return 2
+			}
+			raw, err := os.ReadFile(filePath)
+			if err != nil {
+				printErr(stderr, "error: read secrets file:", err)
+				return 2
+			}
+			secrets, err := ParseSecretList(raw)
+			if err != nil {
+				printErr(stderr, "error:", err)
+				return 2
+			}
+			weak := false
+			for i, s := range secrets {
+				// Unparsable entries are reported by HashSecrets.
+				if a, ok := new(big.Int).SetString(s, 0); ok && !guardSecretBits(stderr, fmt.Sprintf("entry %d: ", i), a, minBits, allowWeak) {
+					weak = true
+				}
+			}
+			if weak {
+				return 2
+			}
+
+			results, failed := HashSecretsWithOptions(secrets, HashOptions{Jobs: jobs, Dedupe: dedupe})
+			if dedupe {
+				unique, _ := dedupeSecrets(secrets)
+				fmt.Fprintf(stderr, "dedupe: %d of %d entries reused a cached hash\n", len(secrets)-len(unique), len(secrets))
+			}
+			enc := json.NewEncoder(stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(results); err != nil {
+				printErr(stderr, "error:", err)
+				return 1
+			}
+			for _, i := range failed {
+				fmt.Fprintf(stderr, "error: entry %d: %s\n", i, results[i].Error)
+			}
+			if len(failed) > 0 {
+				return 1
+			}
+			return 0
+		}
+
+		if dedupe {
+			fmt.Fprintln(stderr, "error: -dedupe requires -file")
+			return 2
+		}
+		if aStr == "" {
+			fmt.Fprintln(stderr, "error: -a is required (or pass -file)")
+			hashCmd.Usage()
+			return 2
+		}
+
+		a := new(big.Int)
+		if _, ok := a.SetString(aStr, 0); !ok || a.Sign() == 0 {
+			printErrf(stderr, ErrBadScalar, "could not parse -a (must be a non-zero integer; decimal or 0x.. hex)")
+			return 2
+		}
+		if !guardSecretBits(stderr, "", a, minBits, allowWeak) {
+			return 2
+		}
+
+		hkHex, kappaHex, err := gtToHash(a)
+		if err != nil {
+			printErr(stderr, "error:", err)
+			return 1
+		}
+		if kappaPath != "" {
+			kappa, err := hex.DecodeString(kappaHex)
+			if err != nil {
+				printErr(stderr, "error: kappa encoding:", err)
+				return 1
+			}
+			if err := os.WriteFile(kappaPath, kappa, 0o644); err != nil {
+				printErr(stderr, "error: -emit-kappa:", err)
+				return 1
+			}
+		}
+
+		fmt.Fprintln(stdout, hkHex)
+		return 0
+
+	case "blake2b224":
+		b2Cmd := flag.NewFlagSet("blake2b224", flag.ContinueOnError)
+		b2Cmd.SetOutput(stderr)
+
+		var dataHex string
+		var withTag bool
+		b2Cmd.StringVar(&dataHex, "hex", "", "bytes to hash, as hex (0x prefix optional; may be empty)")
+		b2Cmd.BoolVar(&withTag, "with-domain-tag", false, "append the domain tag bytes (DomainTagHex) before hashing")
+		var algoName string
+		b2Cmd.StringVar(&algoName, "hash-algo", string(DigestBlake2b224), "digest: blake2b-224 (the protocol's) or sha256-224 (SHA-224, for interop testing)")
+		if err := b2Cmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		algo, err := ParseDigestAlgo(algoName)
+		if err != nil {
+			printErr(stderr, "error: -hash-algo:", err)
+			return 2
+		}
+		data, err := hex.DecodeString(normalizeHex(dataHex))
+		if err != nil {
+			printErr(stderr, "error: -hex:", withKind(ErrBadHex, err))
+			return 2
+		}
+		digest, err := Digest224Hex(algo, data, withTag)
+		if err != nil {
+			printErr(stderr, "error:", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, digest)
+		return 0
+
+	case "reencode":
+		reCmd := flag.NewFlagSet("reencode", flag.ContinueOnError)
+		reCmd.SetOutput(stderr)
+
+		var group, in, to string
+		reCmd.StringVar(&group, "type", "", "point group: g1 or g2")
+		reCmd.StringVar(&in, "in", "", "point hex, compressed or uncompressed (0x prefix optional)")
+		reCmd.StringVar(&to, "to", "", "output encoding: compressed or uncompressed")
+		if err := reCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if group == "" || in == "" || to == "" {
+			fmt.Fprintln(stderr, "error: -type, -in and -to are required")
+			reCmd.Usage()
+			return 2
+		}
+		out, err := ReencodePointHex(group, in, to)
+		if err != nil {
+			printErr(stderr, "error:", err)
+			return 2
+		}
+		fmt.Fprintln(stdout, out)
+		return 0
+
+	case "reduce":
+		reduceCmd := flag.NewFlagSet("reduce", flag.ContinueOnError)
+		reduceCmd.SetOutput(stderr)
+
+		var aStr, rStr string
+		reduceCmd.StringVar(&aStr, "a", "", "secret integer a (decimal by default; or 0x... hex)")
+		reduceCmd.StringVar(&rStr, "r", "", "optional secret integer r (decimal by default; or 0x... hex)")
+		var wasmLog bool
+		reduceCmd.BoolVar(&wasmLog, "wasm-log", false, "print only the \"reduced a = ..., r = ...\" line the WASM prover logs, for diffing against the browser console (requires -r)")
+		if err := reduceCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if aStr == "" {
+			fmt.Fprintln(stderr, "error: -a is required")
+			reduceCmd.Usage()
+			return 2
+		}
+
+		a, ok := new(big.Int).SetString(aStr, 0)
+		if !ok {
+			printErrf(stderr, ErrBadScalar, "could not parse -a (must be an integer; decimal or 0x.. hex)")
+			return 2
+		}
+		if wasmLog {
+			if rStr == "" {
+				fmt.Fprintln(stderr, "error: -wasm-log requires -r")
+				return 2
+			}
+			r, ok := new(big.Int).SetString(rStr, 0)
+			if !ok {
+				printErrf(stderr, ErrBadScalar, "could not parse -r (must be an integer; decimal or 0x.. hex)")
+				return 2
+			}
+			fmt.Fprintln(stdout, wasmReducedLogLine(reduceFr(a), reduceFr(r)))
+			return 0
+		}
+		ra := ReduceScalar(a)
+		fmt.Fprintln(stdout, "a:", ra.Decimal)
+		fmt.Fprintln(stdout, "a hex:", ra.Hex)
+		if ra.Reduced {
+			fmt.Fprintln(stderr, "note: a is outside [0, q) for the BLS12-381 scalar field order q; the reduced value above is what gets used")
+		}
+
+		if rStr != "" {
+			r, ok := new(big.Int).SetString(rStr, 0)
+			if !ok {
+				printErrf(stderr, ErrBadScalar, "could not parse -r (must be an integer; decimal or 0x.. hex)")
+				return 2
+			}
+			rr := ReduceScalar(r)
+			fmt.Fprintln(stdout, "r:", rr.Decimal)
+			fmt.Fprintln(stdout, "r hex:", rr.Hex)
+			if rr.Reduced {
+				fmt.Fprintln(stderr, "note: r is outside [0, q); the WASM prover reduces it, but `prove` rejects it")
+			}
+		}
+		return 0
+
+	case "decrypt":
+		decryptCmd := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+		decryptCmd.SetOutput(stderr)
+
+		var g1b, g2b, r1, shared, entryPath string
+		decryptCmd.StringVar(&g1b, "g1b", "", "G1 compressed hex (entry fields[1].fields[0].bytes)")
+		decryptCmd.StringVar(&g2b, "g2b", "", "optional G2 compressed hex (entry fields[1].fields[1].fields[0].bytes); omit/empty for constructor==1 branch")
+		decryptCmd.StringVar(&r1, "r1", "", "G1 compressed hex (entry fields[0].bytes)")
+		decryptCmd.StringVar(&shared, "shared", "", "G2 compressed hex (current shared)")
+		decryptCmd.StringVar(&entryPath, "entry", "", "file with the level entry datum (JSON or CBOR hex); replaces -g1b/-g2b/-r1")
+		var domainTag string
+		decryptCmd.StringVar(&domainTag, "domain-tag", "", "hex domain tag to hash the key with (default: "+DomainTagHex+")")
+		var skipSubgroup, emitGT bool
+		decryptCmd.BoolVar(&skipSubgroup, "unsafe-skip-subgroup-check", false, "accept points outside the prime-order subgroup (historical data / adversarial testing only)")
+		decryptCmd.BoolVar(&emitGT, "emit-gt", false, "also print the GT element k = r2/b (canonical Fq12 hex) on a line before the key")
+		if err := decryptCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if skipSubgroup {
+			warnSkipSubgroupCheck(stderr)
+		}
+		decryptOpts := DecryptOptions{UnsafeSkipSubgroupCheck: skipSubgroup, DomainTagHex: normalizeHex(domainTag)}
+		printKey := func(g1b, g2b, r1, shared string) int {
+			key, gtHex, err := DecryptToHashAndGTWithOptions(g1b, g2b, r1, shared, decryptOpts)
+			if err != nil {
+				printErr(stderr, "error:", err)
+				return 1
+			}
+			if emitGT {
+				fmt.Fprintln(stdout, "gt:", gtHex)
+			}
+			fmt.Fprintln(stdout, key)
+			return 0
+		}
+
+		if entryPath != "" {
+			if g1b != "" || g2b != "" || r1 != "" {
+				fmt.Fprintln(stderr, "error: -entry cannot be combined with -g1b, -g2b or -r1")
+				return 2
+			}
+			if shared == "" {
+				fmt.Fprintln(stderr, "error: -shared is required")
+				decryptCmd.Usage()
+				return 2
+			}
+			raw, err := os.ReadFile(entryPath)
+			if err != nil {
+				printErr(stderr, "error:", err)
+				return 1
+			}
+			entry, err := ParsePlutusDatum(raw)
+			if err != nil {
+				printErr(stderr, "error:", err)
+				return 1
+			}
+			g1b, g2b, r1, err := EntryDecryptInputs(entry)
+			if err != nil {
+				printErr(stderr, "error:", err)
+				return 1
+			}
+			return printKey(g1b, g2b, r1, normalizeHex(shared))
+		}
+
+		g1b, g2b, r1, shared = normalizeHex(g1b), normalizeHex(g2b), normalizeHex(r1), normalizeHex(shared)
+
+		if g1b == "" || r1 == "" || shared == "" {
+			fmt.Fprintln(stderr, "error: -g1b, -r1, and -shared are required (and optionally -g2b)")
+			decryptCmd.Usage()
+			return 2
+		}
+
+		return printKey(g1b, g2b, r1, shared)
+
+	case "decrypt-chain":
+		chainCmd := flag.NewFlagSet("decrypt-chain", flag.ContinueOnError)
+		chainCmd.SetOutput(stderr)
+
+		var sharedInit, entriesPath string
+		chainCmd.StringVar(&sharedInit, "shared-init", "", "G2 compressed hex of the initial shared value ([sk]H0)")
+		chainCmd.StringVar(&entriesPath, "entries", "", "JSON file with the ordered list of level entry datums (half level first)")
+		var domainTag string
+		chainCmd.StringVar(&domainTag, "domain-tag", "", "hex domain tag to hash every hop key with (default: "+DomainTagHex+")")
+		var skipSubgroup bool
+		chainCmd.BoolVar(&skipSubgroup, "unsafe-skip-subgroup-check", false, "accept points outside the prime-order subgroup (historical data / adversarial testing only)")
+		var levels int
+		chainCmd.IntVar(&levels, "levels", 0, "declared depth of the chain (half level + full levels); reject a chain of any other length (0 accepts any)")
+		if err := chainCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if levels < 0 {
+			fmt.Fprintln(stderr, "error: -levels must be >= 0")
+			return 2
+		}
+		if skipSubgroup {
+			warnSkipSubgroupCheck(stderr)
+		}
+
+		if sharedInit == "" || entriesPath == "" {
+			fmt.Fprintln(stderr, "error: -shared-init and -entries are required")
+			chainCmd.Usage()
+			return 2
+		}
+
+		raw, err := os.ReadFile(entriesPath)
+		if err != nil {
+			printErr(stderr, "error:", err)
+			return 1
+		}
+		var entries []PlutusData
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			printErr(stderr, "error: parse -entries:", err)
+			return 1
+		}
+
+		chainOpts := ChainOptions{
+			Levels:  levels,
+			Decrypt: DecryptOptions{UnsafeSkipSubgroupCheck: skipSubgroup, DomainTagHex: normalizeHex(domainTag)},
+		}
+		keys, err := DecryptChainWithOptions(normalizeHex(sharedInit), entries, chainOpts)
+		if err != nil {
+			printErr(stderr, "error:", err)
+			return 1
+		}
+
+		// One key per hop; the last line is the capsule key.
+		for _, k := range keys {
+			fmt.Fprintln(stdout, k)
+		}
+		return 0
+
+	case "from-chain":
+		fcCmd := flag.NewFlagSet("from-chain", flag.ContinueOnError)
+		fcCmd.SetOutput(stderr)
+
+		var datum, shared, levelStr, expect, domainTag string
+		var skipSubgroup bool
+		fcCmd.StringVar(&datum, "datum", "", "inline datum of the encryption UTxO (CBOR hex, or detailed-schema JSON)")
+		fcCmd.StringVar(&shared, "shared", "", "G2 compressed hex (current shared)")
+		fcCmd.StringVar(&levelStr, "level", string(ChainLevelAuto), "level to decrypt: auto (full if present, else half), half or full")
+		fcCmd.StringVar(&expect, "expect", "", "hex key the decryption must produce (e.g. one recorded on chain); FAIL if it differs")
+		fcCmd.StringVar(&domainTag, "domain-tag", "", "hex domain tag to hash the key with (default: "+DomainTagHex+")")
+		fcCmd.BoolVar(&skipSubgroup, "unsafe-skip-subgroup-check", false, "accept points outside the prime-order subgroup (historical data / adversarial testing only)")
+		if err := fcCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if skipSubgroup {
+			warnSkipSubgroupCheck(stderr)
+		}
+		if datum == "" || shared == "" {
+			fmt.Fprintln(stderr, "error: -datum and -shared are required")
+			fcCmd.Usage()
+			return 2
+		}
+		level, err := ParseChainLevel(levelStr)
+		if err != nil {
+			printErr(stderr, "error: -level:", err)
+			return 2
+		}
+
+		res, err := DecryptFromChain([]byte(datum), normalizeHex(shared), FromChainOptions{
+			Level:     level,
+			ExpectKey: expect,
+			Decrypt:   DecryptOptions{UnsafeSkipSubgroupCheck: skipSubgroup, DomainTagHex: normalizeHex(domainTag)},
+		})
+		if errors.Is(err, ErrKeyMismatch) {
+			fmt.Fprintln(stdout, "level:", res.Level)
+			fmt.Fprintln(stdout, res.Key)
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+		if err != nil {
+			printErr(stderr, "error:", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, "level:", res.Level)
+		fmt.Fprintln(stdout, res.Key)
+		return 0
+
+	case "prove":
+		proveCmd := flag.NewFlagSet("prove", flag.ContinueOnError)
+		proveCmd.SetOutput(stderr)
+
+		var aStr, rStr, v, w0, w1, outDir, setupDir, profileDir, witnessOut string
+		var noVerify, noExport, skipPreflight, mmapPK, parallelLoad, uncheckedPK, bundle, bundleOnly, force, skipSubgroup, traceConstraints bool
+		var count, minBits, expectedICLen int
+		var allowWeak bool
+		proveCmd.StringVar(&aStr, "a", "", "secret integer a (decimal by default; or 0x... hex)")
+		proveCmd.StringVar(&rStr, "r", "", "secret integer r in [1, q) (decimal by default; or 0x... hex)")
+		var secretsStdin bool
+		proveCmd.BoolVar(&secretsStdin, "secrets-stdin", false, "read a and r from stdin, one per line, instead of -a/-r (keeps them out of ps and shell history)")
+		proveCmd.StringVar(&v, "v", "", "public G1 point V (compressed hex, 96 chars)")
+		proveCmd.StringVar(&w0, "w0", "", "public G1 point W0 (compressed hex, 96 chars)")
+		proveCmd.StringVar(&w1, "w1", "", "public G1 point W1 (compressed hex, 96 chars)")
+		proveCmd.StringVar(&outDir, "out", "out", "output directory for vk.json / proof.json / public.json")
+		proveCmd.StringVar(&setupDir, "setup", "", "directory containing setup files (ccs.bin, pk.bin, vk.bin); if empty, compiles circuit fresh")
+		proveCmd.BoolVar(&noVerify, "no-verify", false, "skip verification after proving (only valid with -setup or the -*-url flags)")
+		proveCmd.BoolVar(&noExport, "no-export", false, "prove and verify only; write nothing to -out")
+		proveCmd.BoolVar(&skipPreflight, "skip-preflight", false, "skip the out-of-circuit w0/w1 consistency check (adversarial testing only)")
+		proveCmd.BoolVar(&mmapPK, "mmap", false, "memory-map pk.bin when loading from -setup (lower peak RSS; falls back to a normal read without mmap)")
+		proveCmd.BoolVar(&parallelLoad, "parallel-load", false, "deserialize ccs.bin and pk.bin concurrently when loading from -setup (faster cold start on multicore machines)")
+		proveCmd.BoolVar(&uncheckedPK, "unsafe-unchecked-pk", false, "read a raw pk.bin from -setup without subgroup checks (faster load; trusted keys only)")
+		proveCmd.BoolVar(&bundle, "bundle", false, "also write all.json combining vk, proof, public and commitmentWire")
+		proveCmd.BoolVar(&bundleOnly, "bundle-only", false, "write all.json instead of vk.json / proof.json / public.json")
+		proveCmd.BoolVar(&force, "force", false, "overwrite proof artifacts already present in -out")
+		var verifyAfterExport bool
+		proveCmd.BoolVar(&verifyAfterExport, "verify-after-export", false, "re-read the written JSON artifacts through the importers and verify them; fail if they do not verify")
+		var outputFormat string
+		proveCmd.StringVar(&outputFormat, "output-format", "both", "artifacts to write to -out: both, json (vk/proof/public.json) or bin (vk/proof/witness.bin)")
+		proveCmd.BoolVar(&skipSubgroup, "unsafe-skip-subgroup-check", false, "accept v/w0/w1 outside the prime-order subgroup (historical data / adversarial testing only)")
+		proveCmd.IntVar(&expectedICLen, "expected-ic-len", 0, "refuse to export unless vk has exactly this many IC points (nPublic + 1 + nCommitments; 38 for vw0w1); 0 skips")
+		var requireCommitment bool
+		proveCmd.BoolVar(&requireCommitment, "require-commitment", false, "refuse to export a proof without a BSB22 commitment (every vw0w1 proof has one)")
+		proveCmd.IntVar(&minBits, "min-entropy-bits", 0, "reject an -a shorter than this many bits (0 disables)")
+		proveCmd.BoolVar(&allowWeak, "allow-weak", false, "only warn about an -a below -min-entropy-bits")
+		proveCmd.BoolVar(&traceConstraints, "trace-constraints", false, "if proving fails, name the circuit relation (W0 or W1) the witness violates; costs one extra compile")
+		proveCmd.StringVar(&witnessOut, "witness-out", "", "write the full circuit assignment (a, r, vx..w1y as decimal) to this JSON file before proving; contains the secrets")
+		proveCmd.StringVar(&profileDir, "profile", "", "write prove.cpu.pprof / prove.heap.pprof around groth16.Prove into this directory")
+		var ccsURL, pkURL, vkURL string
+		proveCmd.StringVar(&ccsURL, "ccs-url", "", "stream ccs.bin from this URL instead of -setup (requires -pk-url and -vk-url)")
+		proveCmd.StringVar(&pkURL, "pk-url", "", "stream pk.bin from this URL instead of -setup, without a local copy")
+		proveCmd.StringVar(&vkURL, "vk-url", "", "stream vk.bin from this URL instead of -setup")
+		var retries int
+		proveCmd.IntVar(&retries, "retries", 3, "retry a failed -ccs-url/-pk-url/-vk-url request up to N times, with exponential backoff (5xx, 408, 429 and network errors only)")
+		proveCmd.IntVar(&count, "count", 1, "prove N times with varying witnesses and report latency stats (requires -setup; -w0/-w1 are derived per iteration; no artifacts written)")
+		if err := proveCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if skipSubgroup {
+			warnSkipSubgroupCheck(stderr)
+		}
+		v, w0, w1 = normalizeHex(v), normalizeHex(w0), normalizeHex(w1)
+
+		aName, rName := "-a", "-r"
+		if secretsStdin {
+			if aStr != "" || rStr != "" {
+				fmt.Fprintln(stderr, "error: -secrets-stdin cannot be combined with -a or -r")
+				return 2
+			}
+			var err error
+			if aStr, rStr, err = readSecrets(stdin); err != nil {
+				printErr(stderr, "error: -secrets-stdin:", err)
+				return 2
+			}
+			aName, rName = "a (stdin line 1)", "r (stdin line 2)"
+		}
+
+		missing := false
+		if aStr == "" {
+			fmt.Fprintln(stderr, "error: -a is required")
+			missing = true
+		}
+		if rStr == "" {
+			fmt.Fprintln(stderr, "error: -r is required")
+			missing = true
+		}
+		if v == "" {
+			fmt.Fprintln(stderr, "error: -v is required")
+			missing = true
+		}
+		// -count derives w0/w1 for every iteration, so it needs neither.
+		if count > 1 {
+			if w0 != "" || w1 != "" {
+				fmt.Fprintln(stderr, "warning: -w0/-w1 are ignored with -count (each iteration derives its own)")
+			}
+		} else {
+			if w0 == "" {
+				fmt.Fprintln(stderr, "error: -w0 is required")
+				missing = true
+			}
+			if w1 == "" {
+				fmt.Fprintln(stderr, "error: -w1 is required")
+				missing = true
+			}
+		}
+		if missing {
+			proveCmd.Usage()
+			return 2
+		}
+
+		a := new(big.Int)
+		defer wipeBigInt(a)
+		if _, ok := a.SetString(aStr, 0); !ok || a.Sign() == 0 {
+			printErrf(stderr, ErrBadScalar, "could not parse %s (must be a non-zero integer; decimal or 0x.. hex)", aName)
+			return 2
+		}
+
+		if err := validateMinEntropyBits(minBits); err != nil {
+			printErr(stderr, "error:", err)
+			return 2
+		}
+		if !guardSecretBits(stderr, "", a, minBits, allowWeak) {
+			return 2
+		}
+
+		r := new(big.Int)
+		defer wipeBigInt(r)
+		if _, ok := r.SetString(rStr, 0); !ok {
+			printErrf(stderr, ErrBadScalar, "could not parse %s (must be an integer; decimal or 0x.. hex)", rName)
+			return 2
+		}
+		if r.Sign() <= 0 || r.Cmp(fr.Modulus()) >= 0 {
+			printErrf(stderr, ErrBadScalar, "%s must be in [1, q) where q is the BLS12-381 scalar field order", rName)
+			return 2
+		}
+
+		if noExport && noVerify {
+			fmt.Fprintln(stderr, "error: -no-export cannot be combined with -no-verify (nothing would be checked or written)")
+			return 2
+		}
+		if noExport && (bundle || bundleOnly) {
+			fmt.Fprintln(stderr, "error: -no-export cannot be combined with -bundle or -bundle-only")
+			return 2
+		}
+		format, err := parseOutputFormatFlag(outputFormat, bundle || bundleOnly)
+		if err != nil {
+			printErr(stderr, "error:", err)
+			return 2
+		}
+		if err := checkVerifyAfterExportFlag(verifyAfterExport, noExport, format); err != nil {
+			printErr(stderr, "error:", err)
+			return 2
+		}
+
+		remote := ccsURL != "" || pkURL != "" || vkURL != ""
+		if remote {
+			if ccsURL == "" || pkURL == "" || vkURL == "" {
+				fmt.Fprintln(stderr, "error: -ccs-url, -pk-url and -vk-url must be given together")
+				return 2
+			}
+			if setupDir != "" {
+				fmt.Fprintln(stderr, "error: -ccs-url/-pk-url/-vk-url cannot be combined with -setup")
+				return 2
+			}
+			if mmapPK {
+				fmt.Fprintln(stderr, "error: -mmap requires -setup (a streamed pk.bin cannot be memory-mapped)")
+				return 2
+			}
+			if parallelLoad {
+				fmt.Fprintln(stderr, "error: -parallel-load requires -setup")
+				return 2
+			}
+			if uncheckedPK {
+				fmt.Fprintln(stderr, "error: -unsafe-unchecked-pk requires -setup")
+				return 2
+			}
+			if retries < 0 {
+				fmt.Fprintln(stderr, "error: -retries must be >= 0")
+				return 2
+			}
+		}
+
+		if count < 1 {
+			fmt.Fprintln(stderr, "error: -count must be >= 1")
+			return 2
+		}
+		if count > 1 {
+			if setupDir == "" {
+				fmt.Fprintln(stderr, "error: -count requires -setup (the setup is loaded once and reused)")
+				return 2
+			}
+			if !SetupFilesExist(setupDir) {
+				printErrf(stderr, fs.ErrNotExist, "setup files not found in %s", setupDir)
+				fmt.Fprintln(stderr, "       run 'snark setup -out", setupDir+"' first")
+				return 2
+			}
+			return runProveBenchmark(setupDir, LoadOptions{MmapPK: mmapPK, Parallel: parallelLoad, UnsafeUncheckedPK: uncheckedPK}, count, a, r, v, !noVerify, stdout, stderr)
+		}
+
+		if expectedICLen < 0 {
+			fmt.Fprintln(stderr, "error: -expected-ic-len must be >= 0")
+			return 2
+		}
+		exportOpts := ExportOptions{Bundle: bundle, BundleOnly: bundleOnly, ExpectedICLen: expectedICLen, RequireCommitment: requireCommitment, VerifyAfterExport: verifyAfterExport}
+
+		// Stream the setup from URLs, use setup files if provided, otherwise compile fresh
+		if remote {
+			opts := ProveOptions{
+				SkipVerify:              noVerify,
+				SkipPreflight:           skipPreflight,
+				ProfileDir:              profileDir,
+				Export:                  exportOpts,
+				Force:                   force,
+				UnsafeSkipSubgroupCheck: skipSubgroup,
+				WitnessOut:              witnessOut,
+				TraceConstraints:        traceConstraints,
+				NoExport:                noExport,
+				Format:                  format,
+			}
+			urls := SetupURLs{CCS: ccsURL, PK: pkURL, VK: vkURL}
+			if err := ProveVW0W1FromURLsWithOptions(urls, RetryFetcher{Retries: retries}, outDir, a, r, v, w0, w1, opts); err != nil {
+				printErr(stderr, "FAIL:", err)
+				return 1
+			}
+		} else if setupDir != "" {
+			if !SetupFilesExist(setupDir) {
+				printErrf(stderr, fs.ErrNotExist, "setup files not found in %s", setupDir)
+				fmt.Fprintln(stderr, "       run 'snark setup -out", setupDir+"' first")
+				return 2
+			}
+			opts := ProveOptions{
+				SkipVerify:              noVerify,
+				SkipPreflight:           skipPreflight,
+				ProfileDir:              profileDir,
+				MmapPK:                  mmapPK,
+				ParallelLoad:            parallelLoad,
+				UnsafeUncheckedPK:       uncheckedPK,
+				Export:                  exportOpts,
+				Force:                   force,
+				UnsafeSkipSubgroupCheck: skipSubgroup,
+				WitnessOut:              witnessOut,
+				TraceConstraints:        traceConstraints,
+				NoExport:                noExport,
+				Format:                  format,
+			}
+			if err := ProveVW0W1FromSetupWithOptions(setupDir, outDir, a, r, v, w0, w1, opts); err != nil {
+				printErr(stderr, "FAIL:", err)
+				return 1
+			}
+		} else {
+			if noVerify {
+				fmt.Fprintln(stderr, "warning: -no-verify is ignored without -setup")
+			}
+			opts := ProveOptions{
+				SkipPreflight:           skipPreflight,
+				ProfileDir:              profileDir,
+				Export:                  exportOpts,
+				Force:                   force,
+				UnsafeSkipSubgroupCheck: skipSubgroup,
+				WitnessOut:              witnessOut,
+				TraceConstraints:        traceConstraints,
+				NoExport:                noExport,
+				Format:                  format,
+			}
+			if err := ProveAndVerifyVW0W1WithOptions(a, r, v, w0, w1, outDir, opts); err != nil {
+				printErr(stderr, "FAIL:", err)
+				return 1
+			}
+		}
+
+		fmt.Fprintln(stdout, "SUCCESS: proof verified (w0 == [hk]q AND w1 == [a]q + [r]v)")
+		return 0
+
+	case "prove-batch":
+		batchCmd := flag.NewFlagSet("prove-batch", flag.ContinueOnError)
+		batchCmd.SetOutput(stderr)
+
+		var statementsPath, outDir string
+		var noExport, skipPreflight, bundle, bundleOnly, force, constraintsOnly, traceConstraints bool
+		batchCmd.StringVar(&statementsPath, "statements", "", "JSON array of {a, r, v, w0, w1} statements to prove with one proof")
+		batchCmd.StringVar(&outDir, "out", "out", "output directory for vk.json / proof.json / public.json")
+		batchCmd.BoolVar(&noExport, "no-export", false, "prove and verify only; write nothing to -out")
+		batchCmd.BoolVar(&skipPreflight, "skip-preflight", false, "skip the out-of-circuit w0/w1 consistency check (adversarial testing only)")
+		batchCmd.BoolVar(&bundle, "bundle", false, "also write all.json combining vk, proof, public and commitmentWire")
+		batchCmd.BoolVar(&bundleOnly, "bundle-only", false, "write all.json instead of vk.json / proof.json / public.json")
+		batchCmd.BoolVar(&force, "force", false, "overwrite proof artifacts already present in -out")
+		var verifyAfterExport bool
+		batchCmd.BoolVar(&verifyAfterExport, "verify-after-export", false, "re-read the written JSON artifacts through the importers and verify them; fail if they do not verify")
+		var outputFormat string
+		batchCmd.StringVar(&outputFormat, "output-format", "both", "artifacts to write to -out: both, json (vk/proof/public.json) or bin (vk/proof/witness.bin)")
+		batchCmd.BoolVar(&traceConstraints, "trace-constraints", false, "if proving fails, name the statement and relation (W0 or W1) the witness violates")
+		batchCmd.BoolVar(&constraintsOnly, "constraints-only", false, "compile the batch circuit, report its constraint count and exit without proving")
+		if err := batchCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if statementsPath == "" {
+			fmt.Fprintln(stderr, "error: -statements is required")
+			batchCmd.Usage()
+			return 2
+		}
+		if noExport && (bundle || bundleOnly) {
+			fmt.Fprintln(stderr, "error: -no-export cannot be combined with -bundle or -bundle-only")
+			return 2
+		}
+		format, err := parseOutputFormatFlag(outputFormat, bundle || bundleOnly)
+		if err != nil {
+			printErr(stderr, "error:", err)
+			return 2
+		}
+		if err := checkVerifyAfterExportFlag(verifyAfterExport, noExport, format); err != nil {
+			printErr(stderr, "error:", err)
+			return 2
+		}
+
+		raw, err := os.ReadFile(statementsPath)
+		if err != nil {
+			printErr(stderr, "error: read statements file:", err)
+			return 2
+		}
+		stmts, err := ParseStatementList(raw)
+		if err != nil {
+			printErr(stderr, "error:", err)
+			return 2
+		}
+		if len(stmts) == 0 {
+			fmt.Fprintln(stderr, "error: -statements contains no statements")
+			return 2
+		}
+
+		if constraintsOnly {
+			ccs, err := CompileVW0W1BatchCircuit(len(stmts))
+			if err != nil {
+				printErr(stderr, "error:", err)
+				return 1
+			}
+			report := BatchReport{Statements: len(stmts), Constraints: ccs.GetNbConstraints()}
+			fmt.Fprintf(stdout, "statements: %d\nconstraints: %d (%d per statement)\n", report.Statements, report.Constraints, report.PerStatement())
+			return 0
+		}
+
+		opts := ProveOptions{
+			SkipPreflight:    skipPreflight,
+			Export:           ExportOptions{Bundle: bundle, BundleOnly: bundleOnly, VerifyAfterExport: verifyAfterExport},
+			Force:            force,
+			NoExport:         noExport,
+			Format:           format,
+			TraceConstraints: traceConstraints,
+		}
+		report, err := ProveBatchVW0W1(stmts, outDir, opts)
+		if err != nil {
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "statements: %d\nconstraints: %d (%d per statement)\n", report.Statements, report.Constraints, report.PerStatement())
+		fmt.Fprintf(stdout, "SUCCESS: one proof verified for %d statements\n", report.Statements)
+		return 0
+
+	case "verify":
+		verifyCmd := flag.NewFlagSet("verify", flag.ContinueOnError)
+		verifyCmd.SetOutput(stderr)
+
+		var outDir, expectWire, vHex, w0Hex, w1Hex, leadingWire, vkPath string
+		var fromJSON, canonical, strictJSON bool
+		var expectedICLen int
+		verifyCmd.StringVar(&outDir, "out", "out", "directory containing vk.bin, proof.bin, and witness.bin (or the JSON artifacts)")
+		verifyCmd.BoolVar(&strictJSON, "strict-json", true, "reject JSON artifacts with unknown fields (e.g. a misspelled \"piAA\"); -strict-json=false ignores them")
+		verifyCmd.StringVar(&vkPath, "vk", "", "verify against this vk.bin or vk.json (e.g. the ceremony's) instead of the VK in -out")
+		var eachDir string
+		var continueOnError bool
+		verifyCmd.StringVar(&eachDir, "each", "", "verify every subdirectory of this directory holding proof.bin, proof.json or all.json, instead of -out")
+		verifyCmd.BoolVar(&continueOnError, "continue-on-error", false, "with -each: verify every proof and report all failures instead of stopping at the first")
+		var explain bool
+		verifyCmd.BoolVar(&explain, "explain", false, "print vk_x, each pairing term and the final equation step by step before verifying")
+		verifyCmd.BoolVar(&fromJSON, "json", false, "verify from all.json or vk.json/proof.json/public.json instead of the binary files")
+		verifyCmd.StringVar(&expectWire, "expect-wire", "", "decimal commitment wire to compare against the one recomputed from the proof (exit 3 on mismatch)")
+		verifyCmd.StringVar(&leadingWire, "leading-wire", "auto", "whether JSON public inputs start with the one-wire 1: auto, include or exclude")
+		verifyCmd.BoolVar(&canonical, "canonical", false, "check the public inputs against the canonical vw0w1 order for -v, -w0, -w1 before verifying")
+		verifyCmd.StringVar(&vHex, "v", "", "with -canonical: compressed G1 hex of v")
+		verifyCmd.StringVar(&w0Hex, "w0", "", "with -canonical: compressed G1 hex of w0")
+		verifyCmd.StringVar(&w1Hex, "w1", "", "with -canonical: compressed G1 hex of w1")
+		verifyCmd.IntVar(&expectedICLen, "expected-ic-len", 0, "fail unless vk has exactly this many IC points (nPublic + 1 + nCommitments; 38 for vw0w1); 0 skips")
+		var requireCommitment bool
+		verifyCmd.BoolVar(&requireCommitment, "require-commitment", false, "fail if the proof has no BSB22 commitment (every vw0w1 proof has one)")
+		var acceptDecimal bool
+		verifyCmd.BoolVar(&acceptDecimal, "accept-uncompressed-vk", false, "let -vk be a verifying key in decimal affine coordinates (snarkjs / Solidity verification_key.json); verifies only proofs without a commitment")
+		if err := verifyCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if expectedICLen < 0 {
+			fmt.Fprintln(stderr, "error: -expected-ic-len must be >= 0")
+			return 2
+		}
+		if acceptDecimal && vkPath == "" {
+			fmt.Fprintln(stderr, "error: -accept-uncompressed-vk applies to -vk")
+			return 2
+		}
+
+		opts := VerifyOptions{ExpectWire: expectWire, ExpectedICLen: expectedICLen, RequireCommitment: requireCommitment, VKPath: vkPath, AcceptUncompressedVK: acceptDecimal, StrictJSON: strictJSON}
+		var err error
+		if opts.LeadingWire, err = ParseLeadingWire(leadingWire); err != nil {
+			printErr(stderr, "error: -leading-wire:", err)
+			return 2
+		}
+		if canonical {
+			if vHex == "" || w0Hex == "" || w1Hex == "" {
+				fmt.Fprintln(stderr, "error: -canonical requires -v, -w0 and -w1")
+				return 2
+			}
+			opts.Canonical = &VW0W1Points{V: vHex, W0: w0Hex, W1: w1Hex}
+		} else if vHex != "" || w0Hex != "" || w1Hex != "" {
+			fmt.Fprintln(stderr, "error: -v, -w0 and -w1 are only used with -canonical")
+			return 2
+		}
+
+		if expectWire != "" {
+			if _, ok := new(big.Int).SetString(expectWire, 10); !ok {
+				fmt.Fprintln(stderr, "error: -expect-wire must be a decimal integer")
+				return 2
+			}
+		}
+
+		if continueOnError && eachDir == "" {
+			fmt.Fprintln(stderr, "error: -continue-on-error requires -each")
+			return 2
+		}
+		if eachDir != "" {
+			if explain {
+				fmt.Fprintln(stderr, "error: -explain describes one proof and cannot be combined with -each")
+				return 2
+			}
+			if expectWire != "" || canonical {
+				fmt.Fprintln(stderr, "error: -expect-wire and -canonical describe one proof and cannot be combined with -each")
+				return 2
+			}
+			results, err := VerifyEach(eachDir, VerifyEachOptions{Verify: opts, JSON: fromJSON, ContinueOnError: continueOnError})
+			for _, r := range results {
+				if r.Err != nil {
+					fmt.Fprintf(stdout, "%s FAIL: %v\n", r.Name, r.Err)
+				} else {
+					fmt.Fprintf(stdout, "%s OK\n", r.Name)
+				}
+			}
+			if err != nil {
+				printErr(stderr, "FAIL:", err)
+				return 1
+			}
+			fmt.Fprintf(stdout, "SUCCESS: all %d proofs verified\n", len(results))
+			return 0
+		}
+
+		if explain {
+			ex, err := ExplainFromDir(outDir, fromJSON, opts)
+			if err != nil {
+				printErr(stderr, "FAIL:", err)
+				return 1
+			}
+			ex.WriteText(stdout)
+		}
+
+		verify := VerifyFromFilesWithOptions
+		if fromJSON {
+			verify = VerifyJSONFromDirWithOptions
+		}
+		if err := verify(outDir, opts); err != nil {
+			if errors.Is(err, ErrWireMismatch) {
+				printErr(stderr, "WIRE MISMATCH: proof is valid but", err)
+				return 3
+			}
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+
+		if expectWire != "" {
+			fmt.Fprintln(stdout, "SUCCESS: proof verified and commitment wire matches")
+			return 0
+		}
+		fmt.Fprintln(stdout, "SUCCESS: proof verified")
+		return 0
+
+	case "verify-only":
+		vonlyCmd := flag.NewFlagSet("verify-only", flag.ContinueOnError)
+		vonlyCmd.SetOutput(stderr)
+
+		var setupDir, resultPath, proofPath, publicPath, leadingWire string
+		vonlyCmd.StringVar(&setupDir, "setup", "", "setup directory whose vk.bin is authoritative")
+		vonlyCmd.StringVar(&resultPath, "result", "", "WASM prover result {proof, public} (instead of -proof and -public)")
+		vonlyCmd.StringVar(&proofPath, "proof", "", "proof.json to verify")
+		vonlyCmd.StringVar(&publicPath, "public", "", "public.json to verify, with its commitmentWire")
+		vonlyCmd.StringVar(&leadingWire, "leading-wire", "auto", "whether the public inputs start with the one-wire 1: auto, include or exclude")
+		var expectedICLen int
+		vonlyCmd.IntVar(&expectedICLen, "expected-ic-len", 0, "fail unless vk has exactly this many IC points (nPublic + 1 + nCommitments; 38 for vw0w1); 0 skips")
+		var requireCommitment bool
+		vonlyCmd.BoolVar(&requireCommitment, "require-commitment", false, "fail if the proof has no BSB22 commitment (every vw0w1 proof has one)")
+		if err := vonlyCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if expectedICLen < 0 {
+			fmt.Fprintln(stderr, "error: -expected-ic-len must be >= 0")
+			return 2
+		}
+		if setupDir == "" {
+			fmt.Fprintln(stderr, "error: -setup is required")
+			vonlyCmd.Usage()
+			return 2
+		}
+		separate := proofPath != "" || publicPath != ""
+		if resultPath != "" && separate {
+			fmt.Fprintln(stderr, "error: -result and -proof/-public are mutually exclusive")
+			return 2
+		}
+		if resultPath == "" && (proofPath == "" || publicPath == "") {
+			fmt.Fprintln(stderr, "error: pass -result, or both -proof and -public")
+			vonlyCmd.Usage()
+			return 2
+		}
+		mode, err := ParseLeadingWire(leadingWire)
+		if err != nil {
+			printErr(stderr, "error: -leading-wire:", err)
+			return 2
+		}
+
+		ext, err := LoadExternalProof(resultPath, proofPath, publicPath)
+		if err != nil {
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+		if err := VerifyExternalProof(setupDir, ext, VerifyOptions{LeadingWire: mode, ExpectedICLen: expectedICLen, RequireCommitment: requireCommitment}); err != nil {
+			if errors.Is(err, ErrWireMismatch) {
+				printErr(stderr, "WIRE MISMATCH: proof is valid but", err)
+				return 3
+			}
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, "SUCCESS: proof verified against the vk.bin in", setupDir)
+		return 0
+
+	case "verify-setup":
+		vsCmd := flag.NewFlagSet("verify-setup", flag.ContinueOnError)
+		vsCmd.SetOutput(stderr)
+
+		var dir string
+		vsCmd.StringVar(&dir, "dir", "", "setup directory containing manifest.json")
+		if err := vsCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if dir == "" {
+			fmt.Fprintln(stderr, "error: -dir is required")
+			vsCmd.Usage()
+			return 2
+		}
+
+		checks, err := VerifySetupManifest(dir)
+		for _, c := range checks {
+			if c.Err != nil {
+				fmt.Fprintf(stdout, "%s FAIL: %v\n", c.Name, c.Err)
+			} else {
+				fmt.Fprintf(stdout, "%s %d bytes sha256=%s OK\n", c.Name, c.Size, c.SHA256)
+			}
+		}
+		if err != nil {
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, "SUCCESS: setup matches", manifestFile)
+		return 0
+
+	case "verify-keys":
+		vkCmd := flag.NewFlagSet("verify-keys", flag.ContinueOnError)
+		vkCmd.SetOutput(stderr)
+
+		var dir string
+		vkCmd.StringVar(&dir, "dir", "", "setup directory containing ccs.bin, pk.bin and vk.bin")
+		if err := vkCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if dir == "" {
+			fmt.Fprintln(stderr, "error: -dir is required")
+			vkCmd.Usage()
+			return 2
+		}
+
+		checks, err := VerifyKeys(dir)
+		for _, c := range checks {
+			if c.Err != nil {
+				fmt.Fprintf(stdout, "%s FAIL: %v\n", c.Name, c.Err)
+			} else {
+				fmt.Fprintf(stdout, "%s OK\n", c.Name)
+			}
+		}
+		if err != nil {
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, "SUCCESS: pk.bin and vk.bin are a pair")
+		return 0
+
+	case "info", "version":
+		infoCmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+		infoCmd.SetOutput(stderr)
+		if err := infoCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		bi := ReadBuildInfo()
+		orUnknown := func(s string) string {
+			if s == "" {
+				return "unknown"
+			}
+			return s
+		}
+		fmt.Fprintln(stdout, "snark:", orUnknown(bi.Version))
+		if bi.Revision != "" {
+			fmt.Fprintln(stdout, "revision:", bi.Revision)
+		}
+		fmt.Fprintln(stdout, "go:", bi.GoVersion)
+		fmt.Fprintln(stdout, "gnark:", orUnknown(bi.Gnark))
+		fmt.Fprintln(stdout, "gnark-crypto:", orUnknown(bi.GnarkCrypto))
+		fmt.Fprintln(stdout, "curve:", CurveName)
+		return 0
+
+	case "check-constants":
+		ccCmd := flag.NewFlagSet("check-constants", flag.ContinueOnError)
+		ccCmd.SetOutput(stderr)
+		if err := ccCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		checks, err := CheckConstants()
+		for _, c := range checks {
+			if c.Err != nil {
+				fmt.Fprintf(stdout, "%s FAIL: %v\n", c.Name, c.Err)
+			} else {
+				fmt.Fprintf(stdout, "%s OK\n", c.Name)
+			}
+		}
+		if err != nil {
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, "SUCCESS: curve constants match their reference values")
+		return 0
+
+	case "conformance-check":
+		confCmd := flag.NewFlagSet("conformance-check", flag.ContinueOnError)
+		confCmd.SetOutput(stderr)
+		var jobs int
+		confCmd.IntVar(&jobs, "jobs", runtime.GOMAXPROCS(0), "number of vectors checked concurrently")
+		if err := confCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if jobs < 1 {
+			fmt.Fprintln(stderr, "error: -jobs must be >= 1")
+			return 2
+		}
+
+		checks, err := CheckConformanceWithOptions(HashOptions{Jobs: jobs})
+		for _, c := range checks {
+			if c.Err != nil {
+				fmt.Fprintf(stdout, "%s FAIL: %v\n", c.Name, c.Err)
+			} else {
+				fmt.Fprintf(stdout, "%s OK\n", c.Name)
+			}
+		}
+		if err != nil {
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, "SUCCESS: gtToHash matches every golden vector")
+		return 0
+
+	case "re-export":
+		reexportCmd := flag.NewFlagSet("re-export", flag.ContinueOnError)
+		reexportCmd.SetOutput(stderr)
+
+		var outDir string
+		var opts ExportOptions
+		reexportCmd.StringVar(&outDir, "out", "out", "directory containing vk.bin, proof.bin, and witness.bin")
+		reexportCmd.BoolVar(&opts.Bundle, "bundle", false, "also write all.json combining vk, proof, public and commitmentWire")
+		reexportCmd.BoolVar(&opts.WASMResult, "wasm-result", false, "also write result.json in the WASM prover's {proof, public} format, with commitments and commitmentWire")
+		reexportCmd.BoolVar(&opts.VerifyAfterExport, "verify-after-export", false, "re-read the written JSON artifacts through the importers and verify them; fail if they do not verify")
+		reexportCmd.IntVar(&opts.ExpectedICLen, "expected-ic-len", 0, "fail unless vk has exactly this many IC points (nPublic + 1 + nCommitments; 38 for vw0w1); 0 skips")
+		reexportCmd.BoolVar(&opts.RequireCommitment, "require-commitment", false, "fail if the proof has no BSB22 commitment (every vw0w1 proof has one)")
+		if err := reexportCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if opts.ExpectedICLen < 0 {
+			fmt.Fprintln(stderr, "error: -expected-ic-len must be >= 0")
+			return 2
+		}
+
+		if err := ReExportJSONWithOptions(outDir, opts); err != nil {
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+
+		fmt.Fprintln(stdout, "SUCCESS: JSON files re-exported")
+		return 0
+
+	case "normalize":
+		normCmd := flag.NewFlagSet("normalize", flag.ContinueOnError)
+		normCmd.SetOutput(stderr)
+
+		var outDir, leadingWire string
+		normCmd.StringVar(&outDir, "out", "out", "directory with legacy vk.json/proof.json/public.json and/or all.json, rewritten in place")
+		normCmd.StringVar(&leadingWire, "leading-wire", "auto", "whether the legacy public inputs start with the one-wire 1: auto, include or exclude")
+		if err := normCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		mode, err := ParseLeadingWire(leadingWire)
+		if err != nil {
+			printErr(stderr, "error: -leading-wire:", err)
+			return 2
+		}
 
-		if SetupFilesExist(outDir) && !force {
-			fmt.Fprintln(stdout, "Setup files already exist in", outDir, "(use -force to overwrite)")
-			return 0
-		}
-
-		fmt.Fprintln(stdout, "Compiling circuit and running trusted setup...")
-		if err := SetupVW0W1Circuit(outDir, force); err != nil {
-			fmt.Fprintln(stderr, "FAIL:", err)
+		written, err := Normalize(outDir, NormalizeOptions{LeadingWire: mode})
+		if err != nil {
+			printErr(stderr, "FAIL:", err)
 			return 1
 		}
-
-		fmt.Fprintln(stdout, "SUCCESS: setup files written to", outDir)
+		fmt.Fprintf(stdout, "SUCCESS: rewrote %s in %s in the current schema\n", strings.Join(written, ", "), outDir)
 		return 0
 
-	case "hash":
-		hashCmd := flag.NewFlagSet("hash", flag.ContinueOnError)
-		hashCmd.SetOutput(stderr)
+	case "export-vk":
+		evCmd := flag.NewFlagSet("export-vk", flag.ContinueOnError)
+		evCmd.SetOutput(stderr)
 
-		var aStr string
-		hashCmd.StringVar(&aStr, "a", "", "secret integer a (decimal by default; or 0x... hex)")
-		if err := hashCmd.Parse(args[1:]); err != nil {
+		var vkPath, formatStr string
+		evCmd.StringVar(&vkPath, "vk", "", "verifying key to export: vk.bin or vk.json")
+		evCmd.StringVar(&formatStr, "format", string(VKFormatJSON), "output format: json (vk.json), aiken (SnarkVerificationKey literal) or datum (cardano-cli JSON datum)")
+		var acceptDecimal bool
+		evCmd.BoolVar(&acceptDecimal, "accept-uncompressed-vk", false, "also read a verifying key in decimal affine coordinates (snarkjs / Solidity verification_key.json)")
+		if err := evCmd.Parse(args[1:]); err != nil {
 			return 2
 		}
-
-		if aStr == "" {
-			fmt.Fprintln(stderr, "error: -a is required")
-			hashCmd.Usage()
+		if vkPath == "" {
+			fmt.Fprintln(stderr, "error: -vk is required")
+			evCmd.Usage()
 			return 2
 		}
-
-		a := new(big.Int)
-		if _, ok := a.SetString(aStr, 0); !ok || a.Sign() == 0 {
-			fmt.Fprintln(stderr, "error: could not parse -a (must be a non-zero integer; decimal or 0x.. hex)")
+		format, err := ParseVKFormat(formatStr)
+		if err != nil {
+			printErr(stderr, "error: -format:", err)
 			return 2
 		}
 
-		hkHex, _, err := gtToHash(a)
+		vkj, err := LoadVKWithOptions(vkPath, LoadVKOptions{AcceptUncompressedVK: acceptDecimal})
 		if err != nil {
-			fmt.Fprintln(stderr, "error:", err)
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+		out, err := FormatVK(vkj, format)
+		if err != nil {
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+		if _, err := stdout.Write(out); err != nil {
+			printErr(stderr, "error:", err)
 			return 1
 		}
-
-		fmt.Fprintln(stdout, hkHex)
 		return 0
 
-	case "decrypt":
-		decryptCmd := flag.NewFlagSet("decrypt", flag.ContinueOnError)
-		decryptCmd.SetOutput(stderr)
+	case "commitment-wire":
+		wireCmd := flag.NewFlagSet("commitment-wire", flag.ContinueOnError)
+		wireCmd.SetOutput(stderr)
 
-		var g1b, g2b, r1, shared string
-		decryptCmd.StringVar(&g1b, "g1b", "", "G1 compressed hex (entry fields[1].fields[0].bytes)")
-		decryptCmd.StringVar(&g2b, "g2b", "", "optional G2 compressed hex (entry fields[1].fields[1].fields[0].bytes); omit/empty for constructor==1 branch")
-		decryptCmd.StringVar(&r1, "r1", "", "G1 compressed hex (entry fields[0].bytes)")
-		decryptCmd.StringVar(&shared, "shared", "", "G2 compressed hex (current shared)")
-		if err := decryptCmd.Parse(args[1:]); err != nil {
+		var proofPath, publicPath, committed, ccsPath string
+		var noOneWire bool
+		wireCmd.StringVar(&proofPath, "proof", "", "proof.json to read")
+		wireCmd.StringVar(&publicPath, "public", "", "public.json to read (decimal inputs or inputsHex)")
+		wireCmd.StringVar(&committed, "committed", "", "1-based committed public indices, e.g. 1-36 or 1,2,5-9 (default: all public inputs)")
+		wireCmd.StringVar(&ccsPath, "ccs", "", "take the committed indices from this compiled ccs.bin instead of -committed")
+		wireCmd.BoolVar(&noOneWire, "no-one-wire", false, "the public inputs do not start with the one-wire 1 that prove writes")
+		if err := wireCmd.Parse(args[1:]); err != nil {
 			return 2
 		}
-
-		if g1b == "" || r1 == "" || shared == "" {
-			fmt.Fprintln(stderr, "error: -g1b, -r1, and -shared are required (and optionally -g2b)")
-			decryptCmd.Usage()
+		if proofPath == "" || publicPath == "" {
+			fmt.Fprintln(stderr, "error: -proof and -public are required")
+			wireCmd.Usage()
+			return 2
+		}
+		if committed != "" && ccsPath != "" {
+			fmt.Fprintln(stderr, "error: -committed and -ccs are mutually exclusive")
 			return 2
 		}
 
-		out, err := DecryptToHash(g1b, g2b, r1, shared)
+		opts := CommitmentWireOptions{NoOneWire: noOneWire}
+		if ccsPath != "" {
+			ccs, err := ReadCCSFile(ccsPath)
+			if err != nil {
+				printErr(stderr, "error:", err)
+				return 1
+			}
+			if opts.Committed, err = committedPublicIndices(ccs); err != nil {
+				printErr(stderr, "error:", err)
+				return 1
+			}
+		}
+		if committed != "" {
+			idx, err := ParseIndexList(committed)
+			if err != nil {
+				printErr(stderr, "error: -committed:", err)
+				return 2
+			}
+			opts.Committed = idx
+		}
+
+		wire, err := CommitmentWireFromFiles(proofPath, publicPath, opts)
 		if err != nil {
-			fmt.Fprintln(stderr, "error:", err)
+			printErr(stderr, "error:", err)
 			return 1
 		}
-
-		fmt.Fprintln(stdout, out)
+		fmt.Fprintln(stdout, wire)
 		return 0
 
-	case "prove":
-		proveCmd := flag.NewFlagSet("prove", flag.ContinueOnError)
-		proveCmd.SetOutput(stderr)
+	case "canonicalize-public":
+		canonCmd := flag.NewFlagSet("canonicalize-public", flag.ContinueOnError)
+		canonCmd.SetOutput(stderr)
 
-		var aStr, rStr, v, w0, w1, outDir, setupDir string
-		var noVerify bool
-		proveCmd.StringVar(&aStr, "a", "", "secret integer a (decimal by default; or 0x... hex)")
-		proveCmd.StringVar(&rStr, "r", "", "secret integer r (decimal by default; or 0x... hex; can be 0)")
-		proveCmd.StringVar(&v, "v", "", "public G1 point V (compressed hex, 96 chars)")
-		proveCmd.StringVar(&w0, "w0", "", "public G1 point W0 (compressed hex, 96 chars)")
-		proveCmd.StringVar(&w1, "w1", "", "public G1 point W1 (compressed hex, 96 chars)")
-		proveCmd.StringVar(&outDir, "out", "out", "output directory for vk.json / proof.json / public.json")
-		proveCmd.StringVar(&setupDir, "setup", "", "directory containing setup files (ccs.bin, pk.bin, vk.bin); if empty, compiles circuit fresh")
-		proveCmd.BoolVar(&noVerify, "no-verify", false, "skip verification after proving (only valid with -setup)")
-		if err := proveCmd.Parse(args[1:]); err != nil {
+		var inPath, outPath, formStr, leadingWire, inLeadingWire string
+		var nbPublic int
+		canonCmd.StringVar(&inPath, "in", "", "public.json to convert (decimal inputs or inputsHex, with or without the one-wire)")
+		canonCmd.StringVar(&outPath, "out", "", "write the result to this file instead of stdout")
+		canonCmd.StringVar(&formStr, "form", "", "encoding to write: decimal (inputs) or hex (inputsHex)")
+		canonCmd.StringVar(&leadingWire, "leading-wire", "", "whether to write the one-wire 1 first: include or exclude")
+		canonCmd.StringVar(&inLeadingWire, "in-leading-wire", "auto", "whether -in starts with the one-wire: auto, include or exclude")
+		canonCmd.IntVar(&nbPublic, "nb-public", VW0W1NbPublic, "number of public inputs without the one-wire")
+		if err := canonCmd.Parse(args[1:]); err != nil {
 			return 2
 		}
-
-		missing := false
-		if aStr == "" {
-			fmt.Fprintln(stderr, "error: -a is required")
-			missing = true
-		}
-		if rStr == "" {
-			fmt.Fprintln(stderr, "error: -r is required")
-			missing = true
-		}
-		if v == "" {
-			fmt.Fprintln(stderr, "error: -v is required")
-			missing = true
-		}
-		if w0 == "" {
-			fmt.Fprintln(stderr, "error: -w0 is required")
-			missing = true
+		if inPath == "" || formStr == "" || leadingWire == "" {
+			fmt.Fprintln(stderr, "error: -in, -form and -leading-wire are required")
+			canonCmd.Usage()
+			return 2
 		}
-		if w1 == "" {
-			fmt.Fprintln(stderr, "error: -w1 is required")
-			missing = true
+		if nbPublic < 1 {
+			fmt.Fprintln(stderr, "error: -nb-public must be >= 1")
+			return 2
 		}
-		if missing {
-			proveCmd.Usage()
+		var opts CanonicalizeOptions
+		var err error
+		if opts.Form, err = ParsePublicForm(formStr); err != nil {
+			printErr(stderr, "error: -form:", err)
 			return 2
 		}
-
-		a := new(big.Int)
-		if _, ok := a.SetString(aStr, 0); !ok || a.Sign() == 0 {
-			fmt.Fprintln(stderr, "error: could not parse -a (must be a non-zero integer; decimal or 0x.. hex)")
+		if opts.LeadingWire, err = ParseLeadingWire(leadingWire); err != nil || opts.LeadingWire == LeadingWireAuto {
+			fmt.Fprintln(stderr, "error: -leading-wire must be include or exclude")
 			return 2
 		}
-
-		r := new(big.Int)
-		if _, ok := r.SetString(rStr, 0); !ok {
-			fmt.Fprintln(stderr, "error: could not parse -r (must be an integer; decimal or 0x.. hex)")
+		if opts.InputLeadingWire, err = ParseLeadingWire(inLeadingWire); err != nil {
+			printErr(stderr, "error: -in-leading-wire:", err)
 			return 2
 		}
+		opts.NbPublic = nbPublic
 
-		// Use setup files if provided, otherwise compile fresh
-		if setupDir != "" {
-			if !SetupFilesExist(setupDir) {
-				fmt.Fprintln(stderr, "error: setup files not found in", setupDir)
-				fmt.Fprintln(stderr, "       run 'snark setup -out", setupDir+"' first")
-				return 2
-			}
-			if err := ProveVW0W1FromSetup(setupDir, outDir, a, r, v, w0, w1, !noVerify); err != nil {
-				fmt.Fprintln(stderr, "FAIL:", err)
-				return 1
-			}
-		} else {
-			if noVerify {
-				fmt.Fprintln(stderr, "warning: -no-verify is ignored without -setup")
-			}
-			if err := ProveAndVerifyVW0W1(a, r, v, w0, w1, outDir); err != nil {
-				fmt.Fprintln(stderr, "FAIL:", err)
+		var pub PublicJSON
+		if err := decodeJSONFile(inPath, &pub, true); err != nil {
+			printErr(stderr, "error:", err)
+			return 1
+		}
+		canon, err := CanonicalizePublic(pub, opts)
+		if err != nil {
+			printErr(stderr, "error:", err)
+			return 1
+		}
+		write := func(w io.Writer) error { return writePublicJSON(w, canon) }
+		if outPath == "" {
+			if err := write(stdout); err != nil {
+				printErr(stderr, "error:", err)
 				return 1
 			}
+			return 0
+		}
+		if err := writeFileAtomic(outPath, write); err != nil {
+			printErr(stderr, "error:", err)
+			return 1
 		}
-
-		fmt.Fprintln(stdout, "SUCCESS: proof verified (w0 == [hk]q AND w1 == [a]q + [r]v)")
 		return 0
 
-	case "verify":
-		verifyCmd := flag.NewFlagSet("verify", flag.ContinueOnError)
-		verifyCmd.SetOutput(stderr)
+	case "ccs-info":
+		infoCmd := flag.NewFlagSet("ccs-info", flag.ContinueOnError)
+		infoCmd.SetOutput(stderr)
 
-		var outDir string
-		verifyCmd.StringVar(&outDir, "out", "out", "directory containing vk.bin, proof.bin, and public.json")
-		if err := verifyCmd.Parse(args[1:]); err != nil {
+		var setupDir string
+		infoCmd.StringVar(&setupDir, "setup", "", "read ccs.bin from this setup directory instead of compiling the circuit")
+		if err := infoCmd.Parse(args[1:]); err != nil {
 			return 2
 		}
 
-		if err := VerifyFromFiles(outDir); err != nil {
-			fmt.Fprintln(stderr, "FAIL:", err)
+		if setupDir == "" {
+			fmt.Fprintln(stderr, "Compiling vw0w1 circuit...")
+		}
+		summary, err := SummarizeVW0W1(setupDir)
+		if err != nil {
+			printErr(stderr, "error:", err)
 			return 1
 		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(summary); err != nil {
+			printErr(stderr, "error:", err)
+			return 1
+		}
+		return 0
 
-		fmt.Fprintln(stdout, "SUCCESS: proof verified")
+	case "circuit-hash":
+		chCmd := flag.NewFlagSet("circuit-hash", flag.ContinueOnError)
+		chCmd.SetOutput(stderr)
+
+		var setupDir string
+		chCmd.StringVar(&setupDir, "setup", "", "hash ccs.bin in this setup directory instead of compiling the circuit")
+		if err := chCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		var hash string
+		var err error
+		if setupDir != "" {
+			hash, err = setupCircuitHash(setupDir)
+		} else {
+			fmt.Fprintln(stderr, "Compiling vw0w1 circuit...")
+			hash, err = CircuitHash()
+		}
+		if err != nil {
+			printErr(stderr, "error:", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, hash)
 		return 0
 
-	case "re-export":
-		reexportCmd := flag.NewFlagSet("re-export", flag.ContinueOnError)
-		reexportCmd.SetOutput(stderr)
+	case "serve":
+		serveCmd := flag.NewFlagSet("serve", flag.ContinueOnError)
+		serveCmd.SetOutput(stderr)
 
-		var outDir string
-		reexportCmd.StringVar(&outDir, "out", "out", "directory containing vk.bin, proof.bin, and witness.bin")
-		if err := reexportCmd.Parse(args[1:]); err != nil {
+		var setupDir, addr string
+		var maxConcurrent, cacheSize int
+		var mmapPK, parallelLoad, uncheckedPK, noVerify bool
+		var shutdownTimeout time.Duration
+		serveCmd.StringVar(&setupDir, "setup", "", "directory containing setup files (ccs.bin, pk.bin, vk.bin)")
+		serveCmd.StringVar(&addr, "addr", ":8080", "address to listen on")
+		serveCmd.IntVar(&maxConcurrent, "max-concurrent", 1, "proofs run at once; each needs several GB, so size this to memory")
+		serveCmd.IntVar(&cacheSize, "cache-size", 0, "keep this many recent proofs and answer repeated statements from them (0 disables)")
+		serveCmd.BoolVar(&mmapPK, "mmap", false, "memory-map pk.bin while loading (lower peak RSS)")
+		serveCmd.BoolVar(&parallelLoad, "parallel-load", false, "deserialize ccs.bin and pk.bin concurrently (faster cold start on multicore machines)")
+		serveCmd.BoolVar(&uncheckedPK, "unsafe-unchecked-pk", false, "read a raw pk.bin without subgroup checks (faster load; trusted keys only)")
+		serveCmd.BoolVar(&noVerify, "no-verify", false, "return proofs without verifying them first")
+		serveCmd.DurationVar(&shutdownTimeout, "shutdown-timeout", 5*time.Minute, "on SIGINT/SIGTERM, how long to wait for in-flight proofs")
+		if err := serveCmd.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if setupDir == "" {
+			fmt.Fprintln(stderr, "error: -setup is required")
+			serveCmd.Usage()
+			return 2
+		}
+		if maxConcurrent < 1 {
+			fmt.Fprintln(stderr, "error: -max-concurrent must be >= 1")
+			return 2
+		}
+		if cacheSize < 0 {
+			fmt.Fprintln(stderr, "error: -cache-size must be >= 0")
+			return 2
+		}
+		if !SetupFilesExist(setupDir) {
+			printErrf(stderr, fs.ErrNotExist, "setup files not found in %s", setupDir)
+			fmt.Fprintln(stderr, "       run 'snark setup -out", setupDir+"' first")
 			return 2
 		}
 
-		if err := ReExportJSON(outDir); err != nil {
-			fmt.Fprintln(stderr, "FAIL:", err)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			printErr(stderr, "error:", err)
 			return 1
 		}
+		fmt.Fprintln(stdout, "Listening on", ln.Addr().String(), "(not ready until the setup is loaded)")
 
-		fmt.Fprintln(stdout, "SUCCESS: JSON files re-exported")
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		srv := NewProveServerWithOptions(nil, ProveServerOptions{
+			MaxConcurrent: maxConcurrent,
+			CacheSize:     cacheSize,
+			Prove:         ProveOptions{SkipVerify: noVerify},
+		})
+
+		// Load in the background so /healthz answers during the long pk.bin load.
+		loadErr := make(chan error, 1)
+		go func() {
+			h, err := OpenSetupWithOptions(setupDir, LoadOptions{MmapPK: mmapPK, Parallel: parallelLoad, UnsafeUncheckedPK: uncheckedPK})
+			if err != nil {
+				loadErr <- err
+				stop()
+				return
+			}
+			srv.SetHandle(h)
+			fmt.Fprintln(stdout, "Setup loaded from", setupDir+"; ready")
+		}()
+
+		if err := Serve(ctx, ln, srv.Handler(), shutdownTimeout); err != nil {
+			printErr(stderr, "FAIL:", err)
+			return 1
+		}
+		select {
+		case err := <-loadErr:
+			printErr(stderr, "FAIL: load setup files:", err)
+			return 1
+		default:
+		}
+		fmt.Fprintln(stdout, "Server stopped")
 		return 0
 
 	case "ceremony":
 		if len(args) < 2 {
-			fmt.Fprintln(stderr, "usage: snark ceremony <init|contribute|verify|finalize> [flags]")
+			fmt.Fprintf(stderr, "usage: snark ceremony <%s> [flags]\n", strings.Join(ceremonySubcommands, "|"))
 			return 2
 		}
 		switch args[1] {
@@ -245,13 +1514,20 @@ func run(args []string, stdout, stderr io.Writer) int {
 			var force bool
 			initCmd.StringVar(&dir, "dir", "ceremony", "ceremony directory")
 			initCmd.BoolVar(&force, "force", false, "overwrite existing ceremony")
+			var maxConstraints int
+			initCmd.IntVar(&maxConstraints, "max-constraints", 0, "abort before writing anything if the circuit has more than N constraints (0 = no limit)")
 			if err := initCmd.Parse(args[2:]); err != nil {
 				return 2
 			}
+			if maxConstraints < 0 {
+				fmt.Fprintln(stderr, "error: -max-constraints must be >= 0")
+				return 2
+			}
 			fmt.Fprintln(stdout, "Compiling circuit and initializing ceremony...")
-			if err := CeremonyInit(dir, force); err != nil {
-				fmt.Fprintln(stderr, "FAIL:", err)
-				return 1
+			if err := interruptible(func() error {
+				return CeremonyInitWithOptions(dir, force, CeremonyInitOptions{MaxConstraints: maxConstraints})
+			}); err != nil {
+				return failStep(stderr, err)
 			}
 			fmt.Fprintln(stdout, "SUCCESS: ceremony initialized in", dir)
 			return 0
@@ -259,9 +1535,11 @@ func run(args []string, stdout, stderr io.Writer) int {
 		case "contribute":
 			contribCmd := flag.NewFlagSet("ceremony contribute", flag.ContinueOnError)
 			contribCmd.SetOutput(stderr)
-			var dir string
+			var dir, inDir, outDir string
 			var phase int
 			contribCmd.StringVar(&dir, "dir", "ceremony", "ceremony directory")
+			contribCmd.StringVar(&inDir, "in", "", "read the latest contribution from this directory instead of -dir (it is not written to)")
+			contribCmd.StringVar(&outDir, "out", "", "write the new contribution to this directory instead of the input directory")
 			contribCmd.IntVar(&phase, "phase", 0, "phase number (1 or 2)")
 			if err := contribCmd.Parse(args[2:]); err != nil {
 				return 2
@@ -270,29 +1548,72 @@ func run(args []string, stdout, stderr io.Writer) int {
 				fmt.Fprintln(stderr, "error: -phase must be 1 or 2")
 				return 2
 			}
+			if inDir != "" {
+				dirSet := false
+				contribCmd.Visit(func(f *flag.Flag) { dirSet = dirSet || f.Name == "dir" })
+				if dirSet {
+					fmt.Fprintln(stderr, "error: -in and -dir name the same input; pass only one")
+					return 2
+				}
+				dir = inDir
+			}
+			opts := ContributeOptions{OutDir: outDir}
 			var idx int
 			var hash string
-			var err error
-			if phase == 1 {
-				idx, hash, err = CeremonyContributePhase1(dir)
-			} else {
-				idx, hash, err = CeremonyContributePhase2(dir)
-			}
+			err := interruptible(func() (err error) {
+				if phase == 1 {
+					idx, hash, err = CeremonyContributePhase1WithOptions(dir, opts)
+				} else {
+					idx, hash, err = CeremonyContributePhase2WithOptions(dir, opts)
+				}
+				return err
+			})
 			if err != nil {
-				fmt.Fprintln(stderr, "FAIL:", err)
-				return 1
+				return failStep(stderr, err)
 			}
 			fmt.Fprintf(stdout, "SUCCESS: phase %d contribution #%04d\n", phase, idx)
+			if outDir != "" {
+				fmt.Fprintf(stdout, "  file:   %s\n", contributionPath(outDir, phase, idx))
+			}
 			fmt.Fprintf(stdout, "  sha256: %s\n", hash)
 			return 0
 
+		case "challenge-hash":
+			challengeCmd := flag.NewFlagSet("ceremony challenge-hash", flag.ContinueOnError)
+			challengeCmd.SetOutput(stderr)
+			var dir string
+			var phase int
+			challengeCmd.StringVar(&dir, "dir", "ceremony", "ceremony directory")
+			challengeCmd.IntVar(&phase, "phase", 0, "phase number (1 or 2)")
+			if err := challengeCmd.Parse(args[2:]); err != nil {
+				return 2
+			}
+			if phase != 1 && phase != 2 {
+				fmt.Fprintln(stderr, "error: -phase must be 1 or 2")
+				return 2
+			}
+			info, err := CeremonyChallengeHash(dir, phase)
+			if err != nil {
+				printErr(stderr, "FAIL:", err)
+				return 1
+			}
+			fmt.Fprintf(stdout, "phase %d challenge: %s (#%04d)\n", info.Phase, info.File, info.Index)
+			fmt.Fprintf(stdout, "  sha256:      %s\n", info.SHA256)
+			fmt.Fprintf(stdout, "  constraints: %d\n", info.Constraints)
+			fmt.Fprintf(stdout, "  domain size: %d\n", info.DomainSize)
+			return 0
+
 		case "verify":
 			verifyCmd := flag.NewFlagSet("ceremony verify", flag.ContinueOnError)
 			verifyCmd.SetOutput(stderr)
-			var dir string
-			var phase int
+			var dir, sinceSHA256 string
+			var phase, since int
+			var details bool
 			verifyCmd.StringVar(&dir, "dir", "ceremony", "ceremony directory")
 			verifyCmd.IntVar(&phase, "phase", 0, "phase number (1 or 2)")
+			verifyCmd.BoolVar(&details, "details", false, "verify every contribution and print a status line for each instead of stopping at the first failure")
+			verifyCmd.IntVar(&since, "since", 0, "only verify the contributions after this index; the file at the index must match -since-sha256")
+			verifyCmd.StringVar(&sinceSHA256, "since-sha256", "", "with -since: the sha256 the contribution at -since had when it was last verified")
 			if err := verifyCmd.Parse(args[2:]); err != nil {
 				return 2
 			}
@@ -300,17 +1621,54 @@ func run(args []string, stdout, stderr io.Writer) int {
 				fmt.Fprintln(stderr, "error: -phase must be 1 or 2")
 				return 2
 			}
+			if since < 0 {
+				fmt.Fprintln(stderr, "error: -since must be >= 0")
+				return 2
+			}
+			if since > 0 && details {
+				fmt.Fprintln(stderr, "error: -since cannot be combined with -details")
+				return 2
+			}
+			if (since > 0) != (sinceSHA256 != "") {
+				fmt.Fprintln(stderr, "error: -since and -since-sha256 must be given together")
+				return 2
+			}
+			if details {
+				verifyDetails := CeremonyVerifyPhase1Details
+				if phase == 2 {
+					verifyDetails = CeremonyVerifyPhase2Details
+				}
+				statuses, err := verifyDetails(dir)
+				for _, st := range statuses {
+					if st.OK {
+						fmt.Fprintf(stdout, "contribution %d sha256=%s OK\n", st.Index, st.Hash)
+					} else {
+						fmt.Fprintf(stdout, "contribution %d sha256=%s FAIL: %v\n", st.Index, st.Hash, st.Err)
+					}
+				}
+				if err != nil {
+					printErr(stderr, "FAIL:", err)
+					return 1
+				}
+				fmt.Fprintf(stdout, "SUCCESS: all %d phase %d contributions verified\n", len(statuses), phase)
+				return 0
+			}
+			vopts := CeremonyVerifyOptions{Since: since, SinceSHA256: sinceSHA256}
 			var count int
 			var err error
 			if phase == 1 {
-				count, err = CeremonyVerifyPhase1(dir)
+				count, err = CeremonyVerifyPhase1WithOptions(dir, vopts)
 			} else {
-				count, err = CeremonyVerifyPhase2(dir)
+				count, err = CeremonyVerifyPhase2WithOptions(dir, vopts)
 			}
 			if err != nil {
-				fmt.Fprintln(stderr, "FAIL:", err)
+				printErr(stderr, "FAIL:", err)
 				return 1
 			}
+			if since > 0 {
+				fmt.Fprintf(stdout, "SUCCESS: %d phase %d contributions after #%04d verified\n", count, phase, since)
+				return 0
+			}
 			fmt.Fprintf(stdout, "SUCCESS: all %d phase %d contributions verified\n", count, phase)
 			return 0
 
@@ -320,9 +1678,17 @@ func run(args []string, stdout, stderr io.Writer) int {
 			var dir string
 			var phase int
 			var beaconHex string
+			var raw bool
 			finalizeCmd.StringVar(&dir, "dir", "ceremony", "ceremony directory")
 			finalizeCmd.IntVar(&phase, "phase", 0, "phase number (1 or 2)")
 			finalizeCmd.StringVar(&beaconHex, "beacon", "", "random beacon hex string")
+			var beaconFile string
+			finalizeCmd.StringVar(&beaconFile, "beacon-file", "", "read the beacon from this file instead of -beacon: hex digits are decoded as hex, anything else is used as raw bytes")
+			finalizeCmd.BoolVar(&raw, "raw", false, "phase 2: write pk.bin uncompressed (larger, much faster to load)")
+			var pkSplit int
+			finalizeCmd.IntVar(&pkSplit, "pk-split", 0, "phase 2: write the proving key as N shards pk_0000.bin.. plus pk_shards.json instead of pk.bin")
+			var dryRun bool
+			finalizeCmd.BoolVar(&dryRun, "dry-run", false, "verify every contribution and the beacon, print what finalize would write, and write nothing")
 			if err := finalizeCmd.Parse(args[2:]); err != nil {
 				return 2
 			}
@@ -330,38 +1696,93 @@ func run(args []string, stdout, stderr io.Writer) int {
 				fmt.Fprintln(stderr, "error: -phase must be 1 or 2")
 				return 2
 			}
-			if beaconHex == "" {
-				fmt.Fprintln(stderr, "error: -beacon is required")
+			if pkSplit < 0 {
+				fmt.Fprintln(stderr, "error: -pk-split must be >= 0")
 				return 2
 			}
-			beacon, err := hex.DecodeString(beaconHex)
-			if err != nil {
-				fmt.Fprintln(stderr, "error: invalid beacon hex:", err)
+			if beaconHex != "" && beaconFile != "" {
+				fmt.Fprintln(stderr, "error: -beacon and -beacon-file are mutually exclusive")
 				return 2
 			}
+			if beaconHex == "" && beaconFile == "" {
+				fmt.Fprintln(stderr, "error: -beacon is required (or pass -beacon-file)")
+				return 2
+			}
+			var beacon []byte
+			var err error
+			if beaconFile != "" {
+				if beacon, err = ReadBeaconFile(beaconFile); err != nil {
+					printErr(stderr, "error:", err)
+					return 2
+				}
+			} else if beacon, err = hex.DecodeString(beaconHex); err != nil {
+				printErr(stderr, "error: invalid beacon hex:", withKind(ErrBadHex, err))
+				return 2
+			}
+
+			if dryRun {
+				fmt.Fprintf(stdout, "Dry run: verifying phase %d (nothing is written)...\n", phase)
+				plan, err := CeremonyFinalizeDryRun(dir, phase, beacon)
+				if err != nil {
+					printErr(stderr, "FAIL:", err)
+					return 1
+				}
+				fmt.Fprintln(stdout, "  contributions verified:", plan.Contributions)
+				fmt.Fprintf(stdout, "  last contribution: %s sha256=%s\n", plan.Last, plan.LastSHA256)
+				fmt.Fprintln(stdout, "  beacon:", plan.Beacon)
+				fmt.Fprintln(stdout, "  would write:", strings.Join(plan.Writes, ", "))
+				fmt.Fprintf(stdout, "SUCCESS: phase %d is ready to finalize\n", phase)
+				return 0
+			}
 
 			if phase == 1 {
 				fmt.Fprintln(stdout, "Finalizing phase 1...")
-				if err := CeremonyFinalizePhase1(dir, beacon); err != nil {
-					fmt.Fprintln(stderr, "FAIL:", err)
-					return 1
+				if err := interruptible(func() error { return CeremonyFinalizePhase1(dir, beacon) }); err != nil {
+					return failStep(stderr, err)
 				}
 				fmt.Fprintln(stdout, "SUCCESS: phase 1 finalized, phase 2 initialized")
 				fmt.Fprintln(stdout, "  commons.bin and phase2_0000.bin written to", dir)
 			} else {
 				fmt.Fprintln(stdout, "Finalizing phase 2...")
-				if err := CeremonyFinalizePhase2(dir, beacon); err != nil {
-					fmt.Fprintln(stderr, "FAIL:", err)
-					return 1
+				if err := interruptible(func() error {
+					return CeremonyFinalizePhase2WithOptions(dir, beacon, SaveOptions{Raw: raw, PKSplit: pkSplit})
+				}); err != nil {
+					return failStep(stderr, err)
 				}
 				fmt.Fprintln(stdout, "SUCCESS: phase 2 finalized, keys extracted")
 				fmt.Fprintln(stdout, "  pk.bin, vk.bin, vk.json written to", dir)
 			}
 			return 0
 
+		case "export-keys":
+			exportCmd := flag.NewFlagSet("ceremony export-keys", flag.ContinueOnError)
+			exportCmd.SetOutput(stderr)
+			var dir string
+			var raw bool
+			exportCmd.StringVar(&dir, "dir", "ceremony", "ceremony directory (phase 2 must already be finalized)")
+			exportCmd.BoolVar(&raw, "raw", false, "write pk.bin uncompressed (larger, much faster to load)")
+			var pkSplit int
+			exportCmd.IntVar(&pkSplit, "pk-split", 0, "write the proving key as N shards pk_0000.bin.. plus pk_shards.json instead of pk.bin")
+			if err := exportCmd.Parse(args[2:]); err != nil {
+				return 2
+			}
+			if pkSplit < 0 {
+				fmt.Fprintln(stderr, "error: -pk-split must be >= 0")
+				return 2
+			}
+			fmt.Fprintln(stdout, "Extracting keys from sealed phase 2...")
+			if err := interruptible(func() error { return CeremonyExportKeysWithOptions(dir, SaveOptions{Raw: raw, PKSplit: pkSplit}) }); err != nil {
+				return failStep(stderr, err)
+			}
+			fmt.Fprintln(stdout, "SUCCESS: pk.bin, vk.bin, vk.json written to", dir)
+			return 0
+
 		default:
 			fmt.Fprintln(stderr, "unknown ceremony subcommand:", args[1])
-			fmt.Fprintln(stderr, "usage: snark ceremony <init|contribute|verify|finalize> [flags]")
+			if s := suggest(args[1], ceremonySubcommands); len(s) > 0 {
+				fmt.Fprintf(stderr, "did you mean %s?\n", strings.Join(s, " or "))
+			}
+			fmt.Fprintf(stderr, "usage: snark ceremony <%s> [flags]\n", strings.Join(ceremonySubcommands, "|"))
 			return 2
 		}
 
@@ -374,6 +1795,141 @@ func run(args []string, stdout, stderr io.Writer) int {
 		return 0
 
 	default:
-		return 2
+		return unknownCommand(stderr, "command", args[0], commandNames())
+	}
+}
+
+// runProveBenchmark opens the setup in setupDir once and proves count times via
+// RunProveBenchmark, printing per-iteration latency and a summary to stdout.
+// The supplied w0/w1 are not used: each iteration derives its own from (a+i, r+i, v).
+func runProveBenchmark(setupDir string, loadOpts LoadOptions, count int, a, r *big.Int, vHex string, verify bool, stdout, stderr io.Writer) int {
+	fmt.Fprintln(stdout, "Loading setup files from", setupDir+"...")
+	loadStart := time.Now()
+	h, err := OpenSetupWithOptions(setupDir, loadOpts)
+	if err != nil {
+		printErr(stderr, "FAIL: load setup files:", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "  loaded in %s\n", time.Since(loadStart).Round(time.Millisecond))
+
+	fmt.Fprintf(stdout, "Proving %d times...\n", count)
+	stats, err := RunProveBenchmark(h, count, a, r, vHex, verify, func(i int, d time.Duration) {
+		fmt.Fprintf(stdout, "  proof %d/%d: %s\n", i+1, count, d.Round(time.Millisecond))
+	})
+	if err != nil {
+		printErr(stderr, "FAIL:", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "SUCCESS: %d proofs generated\n", stats.Count)
+	fmt.Fprintf(stdout, "  total: %s\n", stats.Total.Round(time.Millisecond))
+	fmt.Fprintf(stdout, "  min:   %s\n", stats.Min.Round(time.Millisecond))
+	fmt.Fprintf(stdout, "  max:   %s\n", stats.Max.Round(time.Millisecond))
+	fmt.Fprintf(stdout, "  mean:  %s\n", stats.Mean.Round(time.Millisecond))
+	fmt.Fprintf(stdout, "  p95:   %s\n", stats.P95.Round(time.Millisecond))
+	return 0
+}
+
+// checkVerifyAfterExportFlag rejects -verify-after-export when no JSON
+// artifacts would be written for it to check.
+func checkVerifyAfterExportFlag(verifyAfterExport, noExport bool, format OutputFormat) error {
+	if !verifyAfterExport {
+		return nil
+	}
+	if noExport {
+		return fmt.Errorf("-verify-after-export cannot be combined with -no-export")
+	}
+	if format == FormatBin {
+		return fmt.Errorf("-verify-after-export checks the JSON artifacts and cannot be combined with -output-format bin")
+	}
+	return nil
+}
+
+// parseOutputFormatFlag parses -output-format and rejects the bin format
+// together with -bundle or -bundle-only, which only produce JSON.
+func parseOutputFormatFlag(s string, bundle bool) (OutputFormat, error) {
+	format, err := ParseOutputFormat(s)
+	if err != nil {
+		return "", fmt.Errorf("-output-format: %w", err)
+	}
+	if format == FormatBin && bundle {
+		return "", fmt.Errorf("-output-format bin cannot be combined with -bundle or -bundle-only")
+	}
+	return format, nil
+}
+
+// guardSecretBits applies -min-entropy-bits to a, prefixing messages with
+// label. A short secret is an error unless allowWeak, which downgrades it to a
+// warning. It returns false when the command must stop.
+func guardSecretBits(stderr io.Writer, label string, a *big.Int, minBits int, allowWeak bool) bool {
+	err := checkSecretBits(a, minBits)
+	switch {
+	case err == nil:
+		return true
+	case allowWeak:
+		fmt.Fprintf(stderr, "warning: %s%v (continuing because of -allow-weak)\n", label, err)
+		return true
+	}
+	fmt.Fprintf(stderr, "error: %s%v (pass -allow-weak to proceed anyway)\n", label, err)
+	return false
+}
+
+// maxSecretsInput bounds what readSecrets reads: two field elements in
+// decimal with some whitespace fit easily.
+const maxSecretsInput = 1024
+
+// readSecrets reads a and r from in, one per line, as `prove -secrets-stdin`
+// takes them. Surrounding whitespace, CRLF line endings and a final newline
+// are accepted; blank lines, extra lines and oversized input are not.
+func readSecrets(in io.Reader) (a, r string, err error) {
+	data, err := io.ReadAll(io.LimitReader(in, maxSecretsInput+1))
+	if err != nil {
+		return "", "", fmt.Errorf("read stdin: %w", err)
+	}
+	if len(data) > maxSecretsInput {
+		return "", "", fmt.Errorf("stdin is longer than %d bytes; expected a and r, one per line", maxSecretsInput)
+	}
+	text := strings.TrimRight(string(data), "\r\n")
+	if text == "" {
+		return "", "", errors.New("stdin is empty; expected a and r, one per line")
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) != 2 {
+		return "", "", fmt.Errorf("expected 2 lines on stdin (a, then r), got %d", len(lines))
+	}
+	for i, name := range []string{"a", "r"} {
+		lines[i] = strings.TrimSpace(lines[i])
+		if lines[i] == "" {
+			return "", "", fmt.Errorf("stdin line %d (%s) is empty", i+1, name)
+		}
+	}
+	return lines[0], lines[1], nil
+}
+
+// warnSkipSubgroupCheck prints the banner shown whenever a subcommand runs
+// with -unsafe-skip-subgroup-check, so the bypass is never silent.
+func warnSkipSubgroupCheck(stderr io.Writer) {
+	fmt.Fprintln(stderr, "WARNING: -unsafe-skip-subgroup-check is set: input points are NOT checked to be in the prime-order subgroup.")
+	fmt.Fprintln(stderr, "WARNING: only use this to replay historical data or test adversarial inputs, never for live funds.")
+}
+
+// interruptible runs fn, a setup or ceremony step that writes files, until it
+// returns or SIGINT/SIGTERM arrives. An interrupted step leaves no partial
+// file behind (see runInterruptible) and yields ErrInterrupted.
+func interruptible(fn func() error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return runInterruptible(ctx, fn)
+}
+
+// failStep reports the error of a setup or ceremony step and returns the
+// exit status: 130 (as for a shell's Ctrl-C) when it was interrupted, 1
+// otherwise.
+func failStep(stderr io.Writer, err error) int {
+	if errors.Is(err, ErrInterrupted) {
+		fmt.Fprintln(stderr, ErrInterrupted)
+		return 130
 	}
+	printErr(stderr, "FAIL:", err)
+	return 1
 }
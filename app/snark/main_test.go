@@ -5,21 +5,45 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/consensys/gnark-crypto/ecc"
 	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fp"
 	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
 	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr/mimc"
 
 	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr/pedersen"
+	"github.com/consensys/gnark/backend/groth16"
 	groth16bls "github.com/consensys/gnark/backend/groth16/bls12-381"
+	backend_witness "github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/math/emulated"
+	"github.com/consensys/gnark/std/math/emulated/emparams"
+	"github.com/fxamacker/cbor/v2"
 )
 
 // ---------- small helpers ----------
@@ -300,6 +324,72 @@ func TestDecryptToHash_MatchesManual_Constructor1(t *testing.T) {
 	}
 }
 
+func TestDecryptToHashWithOptions_DomainTag(t *testing.T) {
+	var g1b, r1 bls12381.G1Affine
+	g1b.ScalarMultiplicationBase(big.NewInt(3))
+	r1.ScalarMultiplicationBase(big.NewInt(5))
+	var shared bls12381.G2Affine
+	shared.ScalarMultiplicationBase(big.NewInt(7))
+	g1bHex, r1Hex, sharedHex := g1HexFromAffine(g1b), g1HexFromAffine(r1), g2HexFromAffine(shared)
+
+	h0, err := parseG2CompressedHex(H0Hex)
+	if err != nil {
+		t.Fatalf("parse H0 failed: %v", err)
+	}
+	r2, err := bls12381.Pair([]bls12381.G1Affine{g1b}, []bls12381.G2Affine{h0})
+	if err != nil {
+		t.Fatalf("Pair(g1b,H0) failed: %v", err)
+	}
+	b, err := bls12381.Pair([]bls12381.G1Affine{r1}, []bls12381.G2Affine{shared})
+	if err != nil {
+		t.Fatalf("Pair(r1,shared) failed: %v", err)
+	}
+	k := gtDiv(r2, b)
+
+	// manualHash is mimc( fq12ToFrElements(k) || tag ) with the tag bytes
+	// decoded here rather than through parseDomainTagFr.
+	manualHash := func(tagText string) string {
+		var tag fr.Element
+		tag.SetBytes([]byte(tagText))
+		h := mimc.NewMiMC()
+		for _, e := range append(fq12ToFrElements(k), tag) {
+			h.Write(e.Marshal())
+		}
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	v1 := hex.EncodeToString([]byte("F12|To|Hex|v1|"))
+	v2 := hex.EncodeToString([]byte("F12|To|Hex|v2|"))
+	if v1 != DomainTagHex {
+		t.Fatalf("v1 tag %s != DomainTagHex %s", v1, DomainTagHex)
+	}
+
+	def, err := DecryptToHash(g1bHex, "", r1Hex, sharedHex)
+	if err != nil {
+		t.Fatalf("DecryptToHash: %v", err)
+	}
+	for _, tc := range []struct {
+		tagHex, tagText string
+	}{{"", "F12|To|Hex|v1|"}, {v1, "F12|To|Hex|v1|"}, {v2, "F12|To|Hex|v2|"}} {
+		got, err := DecryptToHashWithOptions(g1bHex, "", r1Hex, sharedHex, DecryptOptions{DomainTagHex: tc.tagHex})
+		if err != nil {
+			t.Fatalf("tag %q: %v", tc.tagHex, err)
+		}
+		if want := manualHash(tc.tagText); got != want {
+			t.Fatalf("tag %q: got %s want %s", tc.tagHex, got, want)
+		}
+		if (got == def) != (tc.tagText == "F12|To|Hex|v1|") {
+			t.Fatalf("tag %q: default-tag equality is wrong (got %s, default %s)", tc.tagHex, got, def)
+		}
+	}
+
+	for _, bad := range []string{"zz", strings.Repeat("ab", 32)} {
+		if _, err := DecryptToHashWithOptions(g1bHex, "", r1Hex, sharedHex, DecryptOptions{DomainTagHex: bad}); err == nil || !strings.Contains(err.Error(), "domain tag") {
+			t.Fatalf("tag %q: expected domain tag error, got %v", bad, err)
+		}
+	}
+}
+
 func TestDecryptToHash_MatchesManual_Constructor2(t *testing.T) {
 	// deterministic points
 	var g1b bls12381.G1Affine
@@ -526,12 +616,306 @@ func TestProveAndVerifyVW0W1_FailsOnWrongW0(t *testing.T) {
 		w0BadHex := g1HexFromAffine(w0Bad)
 
 		outDir := filepath.Join(tmp, "bad")
-		if err := ProveAndVerifyVW0W1(a, r, vHex, w0BadHex, w1Hex, outDir); err == nil {
+		// Skip the pre-flight so the mismatch is rejected in-circuit.
+		err = ProveAndVerifyVW0W1WithOptions(a, r, vHex, w0BadHex, w1Hex, outDir, ProveOptions{SkipPreflight: true, TraceConstraints: true})
+		if err == nil {
 			t.Fatalf("expected failure for wrong W0 (constraints should be unsatisfied)")
 		}
+		if msg := err.Error(); !errors.Is(err, ErrRelationFailed) || !strings.Contains(msg, "W0 = [hk]G check failed") || strings.Contains(msg, "W1 =") {
+			t.Fatalf("trace should name only the W0 relation, got: %v", err)
+		}
 	})
 }
 
+// TestNewVW0W1Assignment_PublicWitness checks the public witness of a built
+// assignment against limbs computed by hand: every coordinate of v, w0, w1 in
+// circuit order, as six 64-bit limbs, least significant first. The secrets
+// are reduced into Fr and stay out of the public witness.
+func TestNewVW0W1Assignment_PublicWitness(t *testing.T) {
+	var v, w0, w1 bls12381.G1Affine
+	v.ScalarMultiplicationBase(big.NewInt(1))
+	w0.ScalarMultiplicationBase(big.NewInt(2))
+	w1.ScalarMultiplicationBase(big.NewInt(3))
+	a := new(big.Int).Add(fr.Modulus(), big.NewInt(5))
+
+	assignment := NewVW0W1Assignment(a, big.NewInt(7), v, w0, w1)
+	full, err := frontend.NewWitness(assignment, ecc.BLS12_381.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reduced, err := frontend.NewWitness(NewVW0W1Assignment(big.NewInt(5), big.NewInt(7), v, w0, w1), ecc.BLS12_381.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(full.Vector(), reduced.Vector()) {
+		t.Fatal("a = r + 5 and a = 5 give different witnesses; a was not reduced into Fr")
+	}
+	w, err := frontend.NewWitness(assignment, ecc.BLS12_381.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := w.Vector().(fr.Vector)
+
+	mask := new(big.Int).SetUint64(^uint64(0))
+	var want fr.Vector
+	for _, p := range []bls12381.G1Affine{v, w0, w1} {
+		for _, c := range []fp.Element{p.X, p.Y} {
+			n := c.BigInt(new(big.Int))
+			for i := 0; i < 6; i++ {
+				var e fr.Element
+				e.SetBigInt(new(big.Int).And(new(big.Int).Rsh(n, uint(64*i)), mask))
+				want = append(want, e)
+			}
+		}
+	}
+	if len(got) != VW0W1NbPublic || len(got) != len(want) {
+		t.Fatalf("public witness has %d inputs, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Equal(&want[i]) {
+			t.Fatalf("public input %d = %s, want %s", i, got[i].String(), want[i].String())
+		}
+	}
+}
+
+// TestWipeSecrets checks that the reduced secrets are cleared word by word
+// once the assignment is built, without changing the witness built from it,
+// and that wiping a witness after proving clears only its secret part.
+func TestWipeSecrets(t *testing.T) {
+	var v, w0, w1 bls12381.G1Affine
+	v.ScalarMultiplicationBase(big.NewInt(1))
+	w0.ScalarMultiplicationBase(big.NewInt(2))
+	w1.ScalarMultiplicationBase(big.NewInt(3))
+	a := new(big.Int).Sub(fr.Modulus(), big.NewInt(5))
+	r := new(big.Int).Lsh(big.NewInt(1), 200)
+
+	values := newVW0W1Values(a, r, v, w0, w1)
+	assignment := values.assignment()
+	aWords, rWords := values.A.Bits(), values.R.Bits()
+	values.wipeSecrets()
+	if values.A.Sign() != 0 || values.R.Sign() != 0 {
+		t.Fatalf("secrets not zeroed: a = %s, r = %s", &values.A, &values.R)
+	}
+	for _, words := range [][]big.Word{aWords, rWords} {
+		for i, w := range words {
+			if w != 0 {
+				t.Fatalf("word %d of a secret left in memory: %#x", i, w)
+			}
+		}
+	}
+
+	wiped, err := frontend.NewWitness(assignment, ecc.BLS12_381.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := frontend.NewWitness(NewVW0W1Assignment(a, r, v, w0, w1), ecc.BLS12_381.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(wiped.Vector(), fresh.Vector()) {
+		t.Fatal("wiping the values changed the assignment built from them")
+	}
+	viaValueOf := NewVW0W1Assignment(a, r, v, w0, w1)
+	viaValueOf.A = emulated.ValueOf[emparams.BLS12381Fr](a)
+	viaValueOf.R = emulated.ValueOf[emparams.BLS12381Fr](r)
+	lazy, err := frontend.NewWitness(viaValueOf, ecc.BLS12_381.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lazy.Vector(), fresh.Vector()) {
+		t.Fatal("secretElement limbs differ from the ones emulated.ValueOf yields")
+	}
+
+	assignment.wipeSecrets()
+	checkAssignmentWiped(t, assignment)
+
+	k := new(big.Int).Set(a)
+	kWords := k.Bits()
+	wipeBigInt(k)
+	if k.Sign() != 0 || kWords[0] != 0 || kWords[len(kWords)-1] != 0 {
+		t.Fatal("wipeBigInt left the value or its words")
+	}
+	wipeBigInt(nil)
+
+	full, err := frontend.NewWitness(&squareCircuit{X: 3, Y: 9}, ecc.BLS12_381.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := full.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wipeSecretWitness(full, public)
+	nine := fr.NewElement(9)
+	vec, pub := full.Vector().(fr.Vector), public.Vector().(fr.Vector)
+	if len(vec) != 2 || !vec[0].Equal(&nine) || !vec[1].IsZero() {
+		t.Fatalf("full witness after wipe = %v, want [9 0]", vec)
+	}
+	if len(pub) != 1 || !pub[0].Equal(&nine) {
+		t.Fatalf("public witness changed: %v", pub)
+	}
+}
+
+// checkAssignmentWiped fails t unless every A and R limb of assignment is a
+// zeroed big.Int.
+func checkAssignmentWiped(t *testing.T, assignment *vw0w1Circuit) {
+	t.Helper()
+	for name, limbs := range map[string][]frontend.Variable{"a": assignment.A.Limbs, "r": assignment.R.Limbs} {
+		if len(limbs) == 0 {
+			t.Fatalf("%s has no limbs", name)
+		}
+		for i, l := range limbs {
+			b, ok := l.(*big.Int)
+			if !ok {
+				t.Fatalf("%s limb %d is %T, want *big.Int", name, i, l)
+			}
+			if b.Sign() != 0 {
+				t.Fatalf("%s limb %d still holds %s", name, i, b)
+			}
+		}
+	}
+}
+
+// TestProveVW0W1_WipesAssignmentLimbs checks that the A and R limbs of the
+// assignment are zeroed once ProveVW0W1 and the server's prove handler
+// return.
+func TestProveVW0W1_WipesAssignmentLimbs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping expensive setup+prove test in -short mode")
+	}
+
+	ccs, err := CompileVW0W1Circuit()
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	h := &SetupHandle{CCS: ccs, PK: pk, VK: vk}
+	a := big.NewInt(31337)
+	r := big.NewInt(73313)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+
+	var prepared *vw0w1Circuit
+	orig := prepareAssignment
+	prepareAssignment = func(a, r *big.Int, vHex, w0Hex, w1Hex string, opts ProveOptions) (*vw0w1Circuit, error) {
+		c, err := orig(a, r, vHex, w0Hex, w1Hex, opts)
+		prepared = c
+		return c, err
+	}
+	defer func() { prepareAssignment = orig }()
+
+	if _, _, err := h.ProveVW0W1(a, r, vHex, w0Hex, w1Hex, ProveOptions{}); err != nil {
+		t.Fatalf("prove: %v", err)
+	}
+	checkAssignmentWiped(t, prepared)
+
+	srv := NewProveServer(h, 1, ProveOptions{})
+	assign := srv.assign
+	var served frontend.Circuit
+	srv.assign = func(req ProveRequest) (frontend.Circuit, error) {
+		c, err := assign(req)
+		served = c
+		return c, err
+	}
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	body := fmt.Sprintf(`{"a": %q, "r": %q, "v": %q, "w0": %q, "w1": %q}`, a.String(), r.String(), vHex, w0Hex, w1Hex)
+	if code, resp := postProve(t, ts.Client(), ts.URL, body); code != http.StatusOK {
+		t.Fatalf("server prove: want 200 got %d: %s", code, resp)
+	}
+	checkAssignmentWiped(t, served.(*vw0w1Circuit))
+}
+
+// TestVW0W1RelationCircuit_W1Alone solves the W1 relation by itself, which
+// needs no pairing and compiles quickly, against a good and a bad witness.
+func TestVW0W1RelationCircuit_W1Alone(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &vw0w1RelationCircuit{only: relationW1})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	a := big.NewInt(4242)
+	r := big.NewInt(7)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+	v, w0, w1, err := parseVW0W1Points(vHex, w0Hex, w1Hex, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solve := func(w1 bls12381.G1Affine) error {
+		t.Helper()
+		assignment := NewVW0W1Assignment(a, r, v, w0, w1)
+		w, err := frontend.NewWitness(&vw0w1RelationCircuit{Statement: *assignment, only: relationW1}, ecc.BLS12_381.ScalarField())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ccs.IsSolved(w)
+	}
+	if err := solve(w1); err != nil {
+		t.Fatalf("W1 relation rejected a valid witness: %v", err)
+	}
+	var gen, w1Bad bls12381.G1Affine
+	gen.ScalarMultiplicationBase(big.NewInt(1))
+	w1Bad.Add(&w1, &gen)
+	if err := solve(w1Bad); err == nil {
+		t.Fatal("W1 relation accepted w1 + G")
+	}
+	if got := relationW1.String(); got != "W1 = [a]G + [r]V" {
+		t.Fatalf("relationW1 = %q", got)
+	}
+}
+
+func TestTraceVW0W1_NamesFailedRelation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping relation compiles in -short mode")
+	}
+	a := big.NewInt(4242)
+	r := big.NewInt(7)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+	v, w0, w1, err := parseVW0W1Points(vHex, w0Hex, w1Hex, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gen, w0Bad, w1Bad bls12381.G1Affine
+	gen.ScalarMultiplicationBase(big.NewInt(1))
+	w0Bad.Add(&w0, &gen)
+	w1Bad.Add(&w1, &gen)
+
+	cases := []struct {
+		name     string
+		w0, w1   bls12381.G1Affine
+		want     []string
+		unwanted []string
+	}{
+		{"valid", w0, w1, nil, []string{"W0 =", "W1 ="}},
+		{"wrong w0", w0Bad, w1, []string{"W0 = [hk]G check failed"}, []string{"W1 ="}},
+		{"wrong w1", w0, w1Bad, []string{"W1 = [a]G + [r]V check failed"}, []string{"W0 ="}},
+		{"both wrong", w0Bad, w1Bad, []string{"W0 = [hk]G check failed", "W1 = [a]G + [r]V check failed"}, nil},
+	}
+	for _, tc := range cases {
+		err := traceVW0W1(NewVW0W1Assignment(a, r, v, tc.w0, tc.w1))
+		if len(tc.want) == 0 {
+			if err != nil {
+				t.Fatalf("%s: unexpected trace: %v", tc.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrRelationFailed) {
+			t.Fatalf("%s: expected ErrRelationFailed, got %v", tc.name, err)
+		}
+		for _, w := range tc.want {
+			if !strings.Contains(err.Error(), w) {
+				t.Fatalf("%s: %q missing from %q", tc.name, w, err)
+			}
+		}
+		for _, u := range tc.unwanted {
+			if strings.Contains(err.Error(), u) {
+				t.Fatalf("%s: %q unexpectedly in %q", tc.name, u, err)
+			}
+		}
+	}
+}
+
 func TestPublicHashSplitLogic_MatchesProveAndVerifyW(t *testing.T) {
 	// This is a pure logic test for the HW0/HW1 split used by ProveAndVerifyW.
 	// It helps catch accidental endianness/offset changes.
@@ -550,6 +934,47 @@ func TestPublicHashSplitLogic_MatchesProveAndVerifyW(t *testing.T) {
 	if hex.EncodeToString(recombined) != hex.EncodeToString(d[:]) {
 		t.Fatalf("HW0/HW1 recombination mismatch")
 	}
+
+	// The exported helper used by prepareW must agree with the inline split.
+	gotHW0, gotHW1 := SplitDigest(rawW)
+	if gotHW0.Cmp(&hw0) != 0 || gotHW1.Cmp(&hw1) != 0 {
+		t.Fatalf("SplitDigest disagrees with inline split")
+	}
+}
+
+func TestSplitDigest_GoldenVector(t *testing.T) {
+	// Pinned values: a change to hk derivation, point compression, or the
+	// digest split (endianness, offset) must show up here even if the
+	// circuit and its input derivation change together. The split was
+	// cross-checked with Python's hashlib + int.from_bytes(..., "big").
+	const (
+		a       = 555555
+		wantW   = "903614ff76bfeac4b270b325938fc53c24e6c139c75772c4e8948dff2950662d41dfcc42d6cbcaba5ecdf4ac6f187c94"
+		wantHW0 = "311337479910937187155228633650263217513"
+		wantHW1 = "203442813703112648129278295235661378698"
+	)
+
+	wHex := computeWCompressedHexFromA(t, big.NewInt(a))
+	if wHex != wantW {
+		t.Fatalf("W for a=%d: got %s want %s", a, wHex, wantW)
+	}
+
+	hw0, hw1 := SplitDigest(mustHexToBytes(t, wHex))
+	if hw0.String() != wantHW0 {
+		t.Fatalf("hw0: got %s want %s", hw0.String(), wantHW0)
+	}
+	if hw1.String() != wantHW1 {
+		t.Fatalf("hw1: got %s want %s", hw1.String(), wantHW1)
+	}
+
+	// prepareW must feed exactly these values to the circuit.
+	assignment, err := prepareW(big.NewInt(a), wHex)
+	if err != nil {
+		t.Fatalf("prepareW: %v", err)
+	}
+	if assignment.HW0.(*big.Int).String() != wantHW0 || assignment.HW1.(*big.Int).String() != wantHW1 {
+		t.Fatalf("prepareW public inputs: got (%v, %v)", assignment.HW0, assignment.HW1)
+	}
 }
 
 // ---------- Setup/Prove Workflow Tests ----------
@@ -709,9 +1134,14 @@ func TestProveAndVerifyVW0W1_FailsOnWrongW1(t *testing.T) {
 		w1BadHex := g1HexFromAffine(w1Bad)
 
 		outDir := filepath.Join(tmp, "bad-w1")
-		if err := ProveAndVerifyVW0W1(a, r, vHex, w0Hex, w1BadHex, outDir); err == nil {
+		// Skip the pre-flight so the mismatch is rejected in-circuit.
+		err = ProveAndVerifyVW0W1WithOptions(a, r, vHex, w0Hex, w1BadHex, outDir, ProveOptions{SkipPreflight: true, TraceConstraints: true})
+		if err == nil {
 			t.Fatalf("expected failure for wrong W1 (constraints should be unsatisfied)")
 		}
+		if msg := err.Error(); !errors.Is(err, ErrRelationFailed) || !strings.Contains(msg, "W1 = [a]G + [r]V check failed") || strings.Contains(msg, "W0 =") {
+			t.Fatalf("trace should name only the W1 relation, got: %v", err)
+		}
 	})
 }
 
@@ -735,7 +1165,8 @@ func TestProveAndVerifyVW0W1_FailsOnWrongV(t *testing.T) {
 		// w1 was computed as [a]G + [r]*[42]G, but now we claim V = [99]G.
 		// The circuit checks w1 == [a]G + [r]*V, so with wrong V this fails.
 		outDir := filepath.Join(tmp, "bad-v")
-		if err := ProveAndVerifyVW0W1(a, r, vBadHex, w0Hex, w1Hex, outDir); err == nil {
+		// Skip the pre-flight so the mismatch is rejected in-circuit.
+		if err := ProveAndVerifyVW0W1WithOptions(a, r, vBadHex, w0Hex, w1Hex, outDir, ProveOptions{SkipPreflight: true}); err == nil {
 			t.Fatalf("expected failure for wrong V (w1 constraint should be unsatisfied)")
 		}
 	})
@@ -757,7 +1188,8 @@ func TestProveAndVerifyVW0W1_FailsOnDifferentA(t *testing.T) {
 		aFake := big.NewInt(99999)
 
 		outDir := filepath.Join(tmp, "bad-a")
-		if err := ProveAndVerifyVW0W1(aFake, r, vHex, w0Hex, w1Hex, outDir); err == nil {
+		// Skip the pre-flight so the mismatch is rejected in-circuit.
+		if err := ProveAndVerifyVW0W1WithOptions(aFake, r, vHex, w0Hex, w1Hex, outDir, ProveOptions{SkipPreflight: true}); err == nil {
 			t.Fatalf("expected failure for wrong secret a (both w0 and w1 constraints should be unsatisfied)")
 		}
 	})
@@ -1097,6 +1529,273 @@ func TestReExportJSON_CorruptVK(t *testing.T) {
 	}
 }
 
+// TestReExportJSON_WASMResultVerifies recovers result.json from the native
+// binaries of a commitment-bearing proof and checks it the way a browser
+// proof is checked: against vk.bin, with the commitment wire present.
+func TestReExportJSON_WASMResultVerifies(t *testing.T) {
+	setupDir := saveTinySetup(t, &commitCircuit{})
+	h, err := OpenSetup(setupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	if err := SaveNativeFiles(h.VK, proof, publicWitness, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReExportJSONWithOptions(out, ExportOptions{WASMResult: true}); err != nil {
+		t.Fatalf("re-export: %v", err)
+	}
+	ext, err := LoadExternalProof(filepath.Join(out, "result.json"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ext.Proof.Commitments) != 1 || ext.Proof.CommitmentPok == "" || ext.Public.CommitmentWire == "" {
+		t.Fatalf("result.json lacks commitment fields: %+v", ext)
+	}
+	if err := VerifyExternalProof(setupDir, ext, VerifyOptions{}); err != nil {
+		t.Fatalf("verify re-exported result: %v", err)
+	}
+}
+
+func TestExportAllWithOptions_ExpectedICLen(t *testing.T) {
+	setupDir := saveTinySetup(t, &commitCircuit{})
+	h, err := OpenSetup(setupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One public input and one commitment: 1 + 1 + 1 IC points.
+	if err := ExportAllWithOptions(h.VK, proof, publicWitness, t.TempDir(), ExportOptions{ExpectedICLen: 3}); err != nil {
+		t.Fatalf("matching IC length rejected: %v", err)
+	}
+	out := t.TempDir()
+	err = ExportAllWithOptions(h.VK, proof, publicWitness, out, ExportOptions{ExpectedICLen: 38})
+	if !errors.Is(err, ErrICLenMismatch) {
+		t.Fatalf("expected ErrICLenMismatch, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "vk has 3 IC points (nPublic=1 + 1 one-wire + nCommitments=1), expected 38") {
+		t.Fatalf("mismatch lacks the breakdown: %v", err)
+	}
+	if entries, _ := os.ReadDir(out); len(entries) != 0 {
+		t.Fatalf("files written despite the mismatch: %v", entries)
+	}
+}
+
+func TestRequireCommitment_RejectsCommitmentlessProof(t *testing.T) {
+	setupDir := saveTinySetup(t, &squareCircuit{})
+	h, err := OpenSetup(setupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&squareCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := SaveNativeFiles(h.VK, proof, publicWitness, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// The proof is valid for its own VK; only the requirement rejects it.
+	if err := VerifyFromFilesWithOptions(dir, VerifyOptions{}); err != nil {
+		t.Fatalf("verify without the requirement: %v", err)
+	}
+	if err := VerifyFromFilesWithOptions(dir, VerifyOptions{RequireCommitment: true}); !errors.Is(err, ErrMissingCommitment) {
+		t.Fatalf("verify: expected ErrMissingCommitment, got %v", err)
+	}
+	if _, err := ExplainFromDir(dir, false, VerifyOptions{RequireCommitment: true}); !errors.Is(err, ErrMissingCommitment) {
+		t.Fatalf("explain: expected ErrMissingCommitment, got %v", err)
+	}
+	pj, err := exportProofBLS(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vk := h.VK.(*groth16bls.VerifyingKey)
+	if err := verifyJSONWithVK(vk, pj, PublicJSON{Inputs: []string{"9"}}, VerifyOptions{RequireCommitment: true}); !errors.Is(err, ErrMissingCommitment) {
+		t.Fatalf("verify json: expected ErrMissingCommitment, got %v", err)
+	}
+
+	out := t.TempDir()
+	if err := ExportAllWithOptions(h.VK, proof, publicWitness, out, ExportOptions{RequireCommitment: true}); !errors.Is(err, ErrMissingCommitment) {
+		t.Fatalf("export: expected ErrMissingCommitment, got %v", err)
+	}
+	if entries, _ := os.ReadDir(out); len(entries) != 0 {
+		t.Fatalf("files written despite the missing commitment: %v", entries)
+	}
+
+	// A proof with a commitment passes.
+	setupDir = saveTinySetup(t, &commitCircuit{})
+	if h, err = OpenSetup(setupDir); err != nil {
+		t.Fatal(err)
+	}
+	if proof, publicWitness, err = h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportAllWithOptions(h.VK, proof, publicWitness, t.TempDir(), ExportOptions{RequireCommitment: true}); err != nil {
+		t.Fatalf("export with a commitment rejected: %v", err)
+	}
+}
+
+func TestExportAllWithOptions_VerifyAfterExport(t *testing.T) {
+	setupDir := saveTinySetup(t, &commitCircuit{})
+	h, err := OpenSetup(setupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []ExportOptions{
+		{VerifyAfterExport: true},
+		{VerifyAfterExport: true, Bundle: true},
+		{VerifyAfterExport: true, BundleOnly: true},
+	} {
+		if err := ExportAllWithOptions(h.VK, proof, publicWitness, t.TempDir(), opts); err != nil {
+			t.Fatalf("%+v: sound export rejected: %v", opts, err)
+		}
+	}
+
+	// An exporter that writes the wrong piA leaves on disk exactly what
+	// exporting a proof with a different Ar does.
+	bad := *proof.(*groth16bls.Proof)
+	bad.Ar.Neg(&bad.Ar)
+	out := t.TempDir()
+	if err := ExportAllWithOptions(h.VK, &bad, publicWitness, out, ExportOptions{}); err != nil {
+		t.Fatalf("export without the check: %v", err)
+	}
+	err = ExportAllWithOptions(h.VK, &bad, publicWitness, t.TempDir(), ExportOptions{VerifyAfterExport: true, BundleOnly: true})
+	if err == nil || !strings.Contains(err.Error(), "verify after export: all.json") {
+		t.Fatalf("corrupted export not caught: %v", err)
+	}
+	if err := verifyExported(out, ExportOptions{}); err == nil || !strings.Contains(err.Error(), "verification failed") {
+		t.Fatalf("corrupted vk/proof/public.json not caught: %v", err)
+	}
+}
+
+// encodePublicJSON is the reference writePublicJSON must match byte for byte.
+func encodePublicJSON(t *testing.T, pub PublicJSON) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(pub); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWritePublicJSON_MatchesEncoder(t *testing.T) {
+	large := make([]string, 100000)
+	largeHex := make([]string, len(large))
+	for i := range large {
+		v := new(big.Int).Sub(fr.Modulus(), big.NewInt(int64(i+1)))
+		large[i] = v.String()
+		largeHex[i] = hex.EncodeToString(v.FillBytes(make([]byte, 32)))
+	}
+	cases := map[string]PublicJSON{
+		"nil inputs":   {},
+		"empty inputs": {Inputs: []string{}},
+		"empty hex":    {Inputs: []string{"1"}, InputsHex: []string{}},
+		"one input":    {Inputs: []string{"1"}},
+		"with wire":    {Inputs: []string{"1", "42"}, CommitmentWire: "123"},
+		"with hex":     {Inputs: []string{"1"}, InputsHex: []string{"00ff"}, CommitmentWire: "7"},
+		"hex only":     {InputsHex: []string{"00ff", "0a"}},
+		"escaped":      {Inputs: []string{"<a&b>", "q\"uote", "\u2028"}},
+		"with hash":    {Inputs: []string{"1"}, CommitmentWire: "7", WitnessHash: "ab01"},
+		"hash no wire": {Inputs: []string{"1"}, WitnessHash: "ab01"},
+		"large":        {Inputs: large, CommitmentWire: "98765", WitnessHash: "ab01"},
+		"large hex":    {InputsHex: largeHex, CommitmentWire: "98765"},
+	}
+	for name, pub := range cases {
+		var got bytes.Buffer
+		if err := writePublicJSON(&got, pub); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if want := encodePublicJSON(t, pub); !bytes.Equal(got.Bytes(), want) {
+			t.Fatalf("%s: streamed output differs from json.Encoder (got %d bytes, want %d)", name, got.Len(), len(want))
+		}
+	}
+}
+
+func TestWritePublicJSON_CoversEveryField(t *testing.T) {
+	// writePublicJSON spells out PublicJSON's layout by hand; a new field
+	// must be added there as well as to this list.
+	want := []string{"inputs", "inputsHex,omitempty", "commitmentWire,omitempty", "witnessHash,omitempty"}
+	typ := reflect.TypeOf(PublicJSON{})
+	var got []string
+	for i := 0; i < typ.NumField(); i++ {
+		got = append(got, typ.Field(i).Tag.Get("json"))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("PublicJSON fields changed: %q; update writePublicJSON and this test", got)
+	}
+}
+
+func TestExportAllWithOptions_PublicJSONMatchesEncoder(t *testing.T) {
+	dir := saveTinySetup(t, &commitCircuit{})
+	h, err := OpenSetup(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, pub, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatalf("prove: %v", err)
+	}
+	out := t.TempDir()
+	if err := ExportAll(h.VK, proof, pub, out); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	bundle, err := BuildBundleJSON(h.VK, proof, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mustReadFile(t, filepath.Join(out, "public.json")); !bytes.Equal(got, encodePublicJSON(t, bundle.Public)) {
+		t.Fatalf("public.json differs from json.Encoder output:\n%s", got)
+	}
+}
+
+func TestRun_CanonicalizePublic_LargeVectorRoundTrips(t *testing.T) {
+	const n = 100000
+	pub := PublicJSON{Inputs: make([]string, n), CommitmentWire: "98765"}
+	for i := range pub.Inputs {
+		pub.Inputs[i] = new(big.Int).Sub(fr.Modulus(), big.NewInt(int64(i+1))).String()
+	}
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "in.json"), filepath.Join(dir, "public.json")
+	raw, err := json.Marshal(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(in, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"canonicalize-public", "-in", in, "-out", out, "-form", "decimal",
+		"-leading-wire", "exclude", "-nb-public", strconv.Itoa(n)}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("want 0 got %d stderr=%q", code, stderr.String())
+	}
+
+	var got PublicJSON
+	if err := readJSONFile(out, &got); err != nil {
+		t.Fatalf("re-read: %v", err)
+	}
+	if !reflect.DeepEqual(got, pub) {
+		t.Fatal("re-read public.json differs from what was written")
+	}
+}
+
 // ---------- Step 2.5: input validation error paths (no proving) ----------
 
 func TestDecryptToHash_BadG1bHex(t *testing.T) {
@@ -1194,23 +1893,79 @@ func TestProveVW0W1FromSetup_RejectsBadVHex(t *testing.T) {
 	}
 }
 
-func TestProveAndVerifyW_RejectsNilA(t *testing.T) {
-	err := ProveAndVerifyW(nil, strings.Repeat("00", 48))
-	if err == nil {
-		t.Fatalf("expected error for nil a")
+func TestSetupWCircuitAndProveFromSetup_EndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping expensive setup+prove test in -short mode")
 	}
-}
 
-func TestProveAndVerifyW_RejectsShortWHex(t *testing.T) {
-	err := ProveAndVerifyW(big.NewInt(42), "aabb")
-	if err == nil {
-		t.Fatalf("expected error for short w hex")
-	}
-}
+	setupDir := filepath.Join(t.TempDir(), "setup-w")
 
-func TestExportProofBLS_RejectsNil(t *testing.T) {
-	_, err := exportProofBLS(nil)
-	if err == nil {
+	// 1) Run setup
+	if err := SetupWCircuit(setupDir); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if !SetupFilesExist(setupDir) {
+		t.Fatalf("setup files missing in %s", setupDir)
+	}
+
+	// 2) Prove twice from the same setup
+	for _, a := range []*big.Int{big.NewInt(1234567), big.NewInt(7654321)} {
+		wHex := computeWCompressedHexFromA(t, a)
+		proof, publicWitness, err := ProveWFromSetup(setupDir, a, wHex)
+		if err != nil {
+			t.Fatalf("prove from setup failed for a=%s: %v", a, err)
+		}
+
+		// 3) Export and verify the artifacts independently
+		outDir := filepath.Join(t.TempDir(), "out")
+		h, err := OpenSetup(setupDir)
+		if err != nil {
+			t.Fatalf("open setup: %v", err)
+		}
+		if err := SaveNativeFiles(h.VK, proof, publicWitness, outDir); err != nil {
+			t.Fatalf("save native files: %v", err)
+		}
+		if err := VerifyFromFiles(outDir); err != nil {
+			t.Fatalf("standalone verification: %v", err)
+		}
+	}
+
+	// 4) A W for a different a must be rejected
+	if _, _, err := ProveWFromSetup(setupDir, big.NewInt(1234567), computeWCompressedHexFromA(t, big.NewInt(42))); err == nil {
+		t.Fatalf("expected failure for W of a different a")
+	}
+}
+
+func TestProveWFromSetup_ValidatesBeforeLoad(t *testing.T) {
+	_, _, err := ProveWFromSetup("does-not-exist", big.NewInt(42), "aabb")
+	if err == nil || !strings.Contains(err.Error(), "invalid -w length") {
+		t.Fatalf("expected -w length error before load, got %v", err)
+	}
+
+	wHex := computeWCompressedHexFromA(t, big.NewInt(42))
+	_, _, err = ProveWFromSetup("does-not-exist", big.NewInt(42), wHex)
+	if err == nil || !strings.Contains(err.Error(), "load setup files") {
+		t.Fatalf("expected load error, got %v", err)
+	}
+}
+
+func TestProveAndVerifyW_RejectsNilA(t *testing.T) {
+	err := ProveAndVerifyW(nil, strings.Repeat("00", 48))
+	if err == nil {
+		t.Fatalf("expected error for nil a")
+	}
+}
+
+func TestProveAndVerifyW_RejectsShortWHex(t *testing.T) {
+	err := ProveAndVerifyW(big.NewInt(42), "aabb")
+	if err == nil {
+		t.Fatalf("expected error for short w hex")
+	}
+}
+
+func TestExportProofBLS_RejectsNil(t *testing.T) {
+	_, err := exportProofBLS(nil)
+	if err == nil {
 		t.Fatalf("expected error for nil proof")
 	}
 }
@@ -1265,6 +2020,9 @@ func TestExportProofBLS_HappyPath(t *testing.T) {
 	if len(pj.PiC) != 96 {
 		t.Fatalf("piC hex length: got %d want 96", len(pj.PiC))
 	}
+	if pj.Curve != CurveName {
+		t.Fatalf("curve: got %q want %q", pj.Curve, CurveName)
+	}
 }
 
 func TestExportVKBLS_HappyPath(t *testing.T) {
@@ -1293,6 +2051,12 @@ func TestExportVKBLS_HappyPath(t *testing.T) {
 	if vkj.NPublic != 1 {
 		t.Fatalf("nPublic: got %d want 1", vkj.NPublic)
 	}
+	if vkj.Curve != CurveName {
+		t.Fatalf("curve: got %q want %q", vkj.Curve, CurveName)
+	}
+	if want := ReadBuildInfo().GnarkCrypto; want == "" || vkj.GnarkCrypto != want {
+		t.Fatalf("gnarkCrypto: got %q want %q", vkj.GnarkCrypto, want)
+	}
 	if len(vkj.VkIC) != 2 {
 		t.Fatalf("IC length: got %d want 2", len(vkj.VkIC))
 	}
@@ -1468,3 +2232,4040 @@ func TestProveAndVerifyW_RejectsBadHex(t *testing.T) {
 		t.Fatalf("expected error for bad hex")
 	}
 }
+
+// ---------- repeated-prove benchmark ----------
+
+func TestSummarizeDurations_Stats(t *testing.T) {
+	samples := make([]time.Duration, 0, 20)
+	for i := 20; i >= 1; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+
+	s := summarizeDurations(samples)
+	if s.Count != 20 {
+		t.Fatalf("count: got %d want 20", s.Count)
+	}
+	if s.Min != time.Millisecond || s.Max != 20*time.Millisecond {
+		t.Fatalf("min/max: got %s/%s", s.Min, s.Max)
+	}
+	if s.Total != 210*time.Millisecond {
+		t.Fatalf("total: got %s want 210ms", s.Total)
+	}
+	if s.Mean != 10500*time.Microsecond {
+		t.Fatalf("mean: got %s want 10.5ms", s.Mean)
+	}
+	// nearest-rank p95 of 1..20 is the 19th value
+	if s.P95 != 19*time.Millisecond {
+		t.Fatalf("p95: got %s want 19ms", s.P95)
+	}
+}
+
+func TestSummarizeDurations_Empty(t *testing.T) {
+	if s := summarizeDurations(nil); s != (BenchStats{}) {
+		t.Fatalf("expected zero stats, got %+v", s)
+	}
+}
+
+func TestBenchWitness_SatisfiesRelation(t *testing.T) {
+	a := big.NewInt(1000)
+	r := big.NewInt(2000)
+	vHex, _, _ := computeVW0W1(t, a, r)
+
+	aI, rI, w0Hex, w1Hex, err := benchWitness(a, r, vHex, 3)
+	if err != nil {
+		t.Fatalf("benchWitness: %v", err)
+	}
+	if aI.Int64() != 1003 || rI.Int64() != 2003 {
+		t.Fatalf("unexpected iteration scalars a=%s r=%s", aI, rI)
+	}
+
+	_, wantW0, wantW1 := computeVW0W1(t, aI, rI)
+	if w0Hex != wantW0 || w1Hex != wantW1 {
+		t.Fatalf("benchWitness points do not match computeVW0W1")
+	}
+}
+
+// TestBenchWitness_RWrapsBelowQ checks that r_i stays in [1, q) when the run
+// starts next to q.
+func TestBenchWitness_RWrapsBelowQ(t *testing.T) {
+	a := big.NewInt(1000)
+	qMinus1 := new(big.Int).Sub(fr.Modulus(), big.NewInt(1))
+	vHex, _, _ := computeVW0W1(t, a, big.NewInt(2000))
+
+	for i, want := range []*big.Int{qMinus1, big.NewInt(1), big.NewInt(2)} {
+		_, rI, _, _, err := benchWitness(a, qMinus1, vHex, i)
+		if err != nil {
+			t.Fatalf("iteration %d: %v", i, err)
+		}
+		if rI.Cmp(want) != 0 {
+			t.Fatalf("iteration %d: r = %s, want %s", i, rI, want)
+		}
+		if err := validateR(rI); err != nil {
+			t.Fatalf("iteration %d: %v", i, err)
+		}
+	}
+}
+
+func TestRunProveBenchmark_RejectsBadArgs(t *testing.T) {
+	if _, err := RunProveBenchmark(nil, 1, big.NewInt(1), big.NewInt(0), "", false, nil); err == nil {
+		t.Fatalf("expected error for nil handle")
+	}
+	if _, err := RunProveBenchmark(&SetupHandle{}, 0, big.NewInt(1), big.NewInt(0), "", false, nil); err == nil {
+		t.Fatalf("expected error for count 0")
+	}
+	if _, err := RunProveBenchmark(&SetupHandle{}, 1, big.NewInt(0), big.NewInt(0), "", false, nil); err == nil {
+		t.Fatalf("expected error for zero a")
+	}
+}
+
+// ---------- pre-flight relation checks (fast, no proving) ----------
+
+func TestPreflightVW0W1_AcceptsConsistentPoints(t *testing.T) {
+	a := big.NewInt(4242)
+	r := big.NewInt(2424)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+	if err := PreflightVW0W1(a, r, vHex, w0Hex, w1Hex); err != nil {
+		t.Fatalf("expected consistent points to pass, got %v", err)
+	}
+}
+
+func TestPreflightVW0W1_WrongA(t *testing.T) {
+	r := big.NewInt(2424)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, big.NewInt(4242), r)
+	err := PreflightVW0W1(big.NewInt(4243), r, vHex, w0Hex, w1Hex)
+	if err == nil || !strings.Contains(err.Error(), "w0 mismatch") {
+		t.Fatalf("expected w0 mismatch, got %v", err)
+	}
+}
+
+func TestPreflightVW0W1_WrongR(t *testing.T) {
+	a := big.NewInt(4242)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, big.NewInt(2424))
+	err := PreflightVW0W1(a, big.NewInt(2425), vHex, w0Hex, w1Hex)
+	if err == nil || !strings.Contains(err.Error(), "w1 mismatch") {
+		t.Fatalf("expected w1 mismatch, got %v", err)
+	}
+}
+
+func TestProveVW0W1FromSetup_PreflightRunsBeforeLoad(t *testing.T) {
+	// The setup dir does not exist: a pre-flight failure must be reported
+	// instead of a load error, proving the check runs before any disk access.
+	a := big.NewInt(4242)
+	r := big.NewInt(2424)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+	err := ProveVW0W1FromSetup("does-not-exist", t.TempDir(), big.NewInt(7), r, vHex, w0Hex, w1Hex, false)
+	if err == nil || !strings.Contains(err.Error(), "w0 mismatch") {
+		t.Fatalf("expected w0 mismatch before load, got %v", err)
+	}
+
+	opts := ProveOptions{SkipPreflight: true}
+	err = ProveVW0W1FromSetupWithOptions("does-not-exist", t.TempDir(), big.NewInt(7), r, vHex, w0Hex, w1Hex, opts)
+	if err == nil || !strings.Contains(err.Error(), "load setup files") {
+		t.Fatalf("expected load error with pre-flight skipped, got %v", err)
+	}
+}
+
+func TestProveVW0W1FromSetup_RefusesToOverwrite(t *testing.T) {
+	a := big.NewInt(4242)
+	r := big.NewInt(2424)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+
+	outDir := t.TempDir()
+	for _, name := range []string{"proof.json", "vk.bin", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(outDir, name), []byte("old"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The overwrite check runs before the (missing) setup dir is touched.
+	err := ProveVW0W1FromSetupWithOptions("does-not-exist", outDir, a, r, vHex, w0Hex, w1Hex, ProveOptions{})
+	if err == nil || !strings.Contains(err.Error(), "proof.json, vk.bin") || !strings.Contains(err.Error(), "-force") {
+		t.Fatalf("expected overwrite refusal listing proof.json, vk.bin; got %v", err)
+	}
+	if strings.Contains(err.Error(), "notes.txt") {
+		t.Fatalf("unrelated file listed: %v", err)
+	}
+
+	err = ProveVW0W1FromSetupWithOptions("does-not-exist", outDir, a, r, vHex, w0Hex, w1Hex, ProveOptions{Force: true})
+	if err == nil || !strings.Contains(err.Error(), "load setup files") {
+		t.Fatalf("expected load error with -force, got %v", err)
+	}
+
+	err = ProveAndVerifyVW0W1WithOptions(a, r, vHex, w0Hex, w1Hex, outDir, ProveOptions{})
+	if err == nil || !strings.Contains(err.Error(), "proof.json") {
+		t.Fatalf("expected overwrite refusal without -setup, got %v", err)
+	}
+}
+
+func TestProveVW0W1FromSetup_NoExportSkipsOverwriteCheck(t *testing.T) {
+	a := big.NewInt(4242)
+	r := big.NewInt(2424)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+
+	outDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outDir, "proof.json"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing will be written, so existing artifacts are no reason to refuse.
+	err := ProveVW0W1FromSetupWithOptions("does-not-exist", outDir, a, r, vHex, w0Hex, w1Hex, ProveOptions{NoExport: true})
+	if err == nil || !strings.Contains(err.Error(), "load setup files") {
+		t.Fatalf("expected load error with NoExport, got %v", err)
+	}
+}
+
+func TestProveAndVerifyVW0W1_NoExportWritesNothing(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping expensive compile+setup+prove test in -short mode")
+	}
+
+	a := big.NewInt(4242)
+	r := big.NewInt(2424)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	if err := ProveAndVerifyVW0W1WithOptions(a, r, vHex, w0Hex, w1Hex, outDir, ProveOptions{NoExport: true}); err != nil {
+		t.Fatalf("prove+verify with NoExport: %v", err)
+	}
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Fatalf("expected %s not to be created, stat err = %v", outDir, err)
+	}
+}
+
+func TestCheckOverwrite_FollowsExportOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "vk.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkOverwrite(dir, ExportOptions{}.files()); err == nil || !strings.Contains(err.Error(), "vk.json") {
+		t.Fatalf("expected vk.json to be reported, got %v", err)
+	}
+	if err := checkOverwrite(dir, ExportOptions{BundleOnly: true}.files()); err != nil {
+		t.Fatalf("-bundle-only does not write vk.json, got %v", err)
+	}
+	if err := checkOverwrite(filepath.Join(dir, "missing"), ExportOptions{}.files()); err != nil {
+		t.Fatalf("missing dir should be fine, got %v", err)
+	}
+}
+
+func TestStartProfile_WritesCPUAndHeap(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "prof")
+	stop, err := startProfile(dir, "prove")
+	if err != nil {
+		t.Fatalf("startProfile: %v", err)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	for _, name := range []string{"prove.cpu.pprof", "prove.heap.pprof"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+		if fi.Size() == 0 {
+			t.Fatalf("%s is empty", name)
+		}
+	}
+}
+
+func TestStartProfile_EmptyDirIsNoop(t *testing.T) {
+	stop, err := startProfile("", "prove")
+	if err != nil {
+		t.Fatalf("startProfile: %v", err)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+}
+
+func TestMmapFile_MatchesFileContents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blob.bin")
+	want := []byte("not really a proving key")
+	if err := os.WriteFile(path, want, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	data, unmap, err := mmapFile(path)
+	if errors.Is(err, errMmapUnsupported) {
+		t.Skip("mmap not supported on this platform")
+	}
+	if err != nil {
+		t.Fatalf("mmapFile: %v", err)
+	}
+	defer unmap()
+	if !bytes.Equal(data, want) {
+		t.Fatalf("mapped contents differ: got %q want %q", data, want)
+	}
+}
+
+func TestLoadSetupFilesWithOptions_MmapCorruptPK(t *testing.T) {
+	tmp := t.TempDir()
+	for _, name := range []string{"ccs.bin", "pk.bin", "vk.bin"} {
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte("corrupt"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if _, err := loadProvingKey(filepath.Join(tmp, "pk.bin"), LoadOptions{MmapPK: true}); err == nil {
+		t.Fatalf("expected error for corrupt pk.bin via mmap")
+	}
+	if _, err := loadProvingKey(filepath.Join(tmp, "missing.bin"), LoadOptions{MmapPK: true}); err == nil {
+		t.Fatalf("expected error for missing pk.bin via mmap")
+	}
+}
+
+// squareCircuit is a tiny circuit (x*x == y) for exercising the setup file
+// round trip without paying for the vw0w1 setup.
+type squareCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *squareCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X), c.Y)
+	return nil
+}
+
+func TestSaveSetupFilesWithOptions_RawLoadsAndProves(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	var want bytes.Buffer
+	if _, err := pk.WriteRawTo(&want); err != nil {
+		t.Fatalf("serialize pk: %v", err)
+	}
+
+	assignment := &squareCircuit{X: 3, Y: 9}
+	for _, raw := range []bool{false, true} {
+		dir := t.TempDir()
+		if err := SaveSetupFilesWithOptions(ccs, pk, vk, dir, SaveOptions{Raw: raw}); err != nil {
+			t.Fatalf("raw=%v: save: %v", raw, err)
+		}
+
+		f, err := os.Open(filepath.Join(dir, "pk.bin"))
+		if err != nil {
+			t.Fatalf("raw=%v: open pk.bin: %v", raw, err)
+		}
+		gotRaw, err := provingKeyIsRaw(f)
+		f.Close()
+		if err != nil || gotRaw != raw {
+			t.Fatalf("raw=%v: provingKeyIsRaw = %v, %v", raw, gotRaw, err)
+		}
+
+		for _, opts := range []LoadOptions{{}, {MmapPK: true}, {UnsafeUncheckedPK: true}, {MmapPK: true, UnsafeUncheckedPK: true}} {
+			h, err := OpenSetupWithOptions(dir, opts)
+			if err != nil {
+				t.Fatalf("raw=%v %+v: load: %v", raw, opts, err)
+			}
+			var got bytes.Buffer
+			if _, err := h.PK.WriteRawTo(&got); err != nil {
+				t.Fatalf("raw=%v %+v: serialize pk: %v", raw, opts, err)
+			}
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Fatalf("raw=%v %+v: loaded pk differs from the original", raw, opts)
+			}
+			if _, _, err := h.proveAssignment(assignment, ProveOptions{}); err != nil {
+				t.Fatalf("raw=%v %+v: prove+verify: %v", raw, opts, err)
+			}
+		}
+	}
+}
+
+// TestLoadProvingKey_RawKeyCheckedUnlessOptedOut corrupts the first point of
+// a raw pk.bin: the default load must reject it, and only
+// UnsafeUncheckedPK may skip the check.
+func TestLoadProvingKey_RawKeyCheckedUnlessOptedOut(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	pk, _, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := pk.WriteRawTo(&buf); err != nil {
+		t.Fatalf("serialize pk: %v", err)
+	}
+	data := buf.Bytes()
+	// G1.Alpha follows the domain header as x || y; flipping the low bit of
+	// y moves it off the curve.
+	data[pkDomainSize+2*fp.Bytes-1] ^= 1
+	path := filepath.Join(t.TempDir(), "pk.bin")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, useMmap := range []bool{false, true} {
+		if _, err := loadProvingKey(path, LoadOptions{MmapPK: useMmap}); err == nil {
+			t.Fatalf("mmap=%v: corrupt raw pk.bin loaded without -unsafe-unchecked-pk", useMmap)
+		}
+		if _, err := loadProvingKey(path, LoadOptions{MmapPK: useMmap, UnsafeUncheckedPK: true}); err != nil {
+			t.Fatalf("mmap=%v: unchecked load: %v", useMmap, err)
+		}
+	}
+	if _, err := readProvingKeyStream(bytes.NewReader(data), false); err == nil {
+		t.Fatal("stream: corrupt raw pk loaded without the opt-in")
+	}
+	if _, err := readProvingKeyStream(bytes.NewReader(data), true); err != nil {
+		t.Fatalf("stream: unchecked load: %v", err)
+	}
+}
+
+func TestOpenSetupWithOptions_MmapPKMatchesCopiedLoad(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping expensive setup+prove test in -short mode")
+	}
+
+	setupDir := filepath.Join(t.TempDir(), "setup")
+	if err := SetupVW0W1Circuit(setupDir, false); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	copied, err := OpenSetup(setupDir)
+	if err != nil {
+		t.Fatalf("copied load: %v", err)
+	}
+	mapped, err := OpenSetupWithOptions(setupDir, LoadOptions{MmapPK: true})
+	if err != nil {
+		t.Fatalf("mmap load: %v", err)
+	}
+
+	var copiedBuf, mappedBuf bytes.Buffer
+	if _, err := copied.PK.WriteRawTo(&copiedBuf); err != nil {
+		t.Fatalf("serialize copied pk: %v", err)
+	}
+	if _, err := mapped.PK.WriteRawTo(&mappedBuf); err != nil {
+		t.Fatalf("serialize mapped pk: %v", err)
+	}
+	if !bytes.Equal(copiedBuf.Bytes(), mappedBuf.Bytes()) {
+		t.Fatalf("mmap-loaded pk differs from copied pk")
+	}
+
+	// Proofs are randomized, so "identical" means both verify against the same VK.
+	a := big.NewInt(31337)
+	r := big.NewInt(73313)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+	for name, h := range map[string]*SetupHandle{"copied": copied, "mapped": mapped} {
+		if _, _, err := h.ProveVW0W1(a, r, vHex, w0Hex, w1Hex, ProveOptions{}); err != nil {
+			t.Fatalf("%s prove+verify: %v", name, err)
+		}
+	}
+}
+
+func TestOpenSetupWithOptions_ParallelLoadProvesIdentically(t *testing.T) {
+	dir := saveTinySetup(t, &commitCircuit{})
+	sequential, err := OpenSetup(dir)
+	if err != nil {
+		t.Fatalf("sequential load: %v", err)
+	}
+	parallel, err := OpenSetupWithOptions(dir, LoadOptions{Parallel: true})
+	if err != nil {
+		t.Fatalf("parallel load: %v", err)
+	}
+
+	proofBytes := func(h *SetupHandle) []byte {
+		t.Helper()
+		var proof groth16.Proof
+		err := withDeterministicRand([]byte("parallel"), func() (err error) {
+			proof, _, err = h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+			return err
+		})
+		if err != nil {
+			t.Fatalf("prove: %v", err)
+		}
+		var buf bytes.Buffer
+		if _, err := proof.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	if !bytes.Equal(proofBytes(sequential), proofBytes(parallel)) {
+		t.Fatal("parallel-loaded setup proves differently from the sequential one")
+	}
+
+	// Both files are still opened; a ccs.bin failure is the one reported.
+	if err := os.Remove(filepath.Join(dir, "ccs.bin")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSetupWithOptions(dir, LoadOptions{Parallel: true}); err == nil || !strings.Contains(err.Error(), "ccs.bin") {
+		t.Fatalf("expected a ccs.bin error, got %v", err)
+	}
+}
+
+func TestExportAllWithOptions_BundleMatchesIndividualFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping gnark proof test in -short mode")
+	}
+
+	a := big.NewInt(12121)
+	r := big.NewInt(21212)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+	outDir := t.TempDir()
+
+	opts := ProveOptions{Export: ExportOptions{Bundle: true}}
+	if err := ProveAndVerifyVW0W1WithOptions(a, r, vHex, w0Hex, w1Hex, outDir, opts); err != nil {
+		t.Fatalf("prove: %v", err)
+	}
+
+	readJSON := func(name string, v interface{}) {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if err := json.Unmarshal(b, v); err != nil {
+			t.Fatalf("unmarshal %s: %v", name, err)
+		}
+	}
+
+	var bundle BundleJSON
+	var vkj VKJSON
+	var pj ProofJSON
+	var pubj PublicJSON
+	readJSON("all.json", &bundle)
+	readJSON("vk.json", &vkj)
+	readJSON("proof.json", &pj)
+	readJSON("public.json", &pubj)
+
+	if !reflect.DeepEqual(bundle.VK, vkj) {
+		t.Fatalf("all.json vk differs from vk.json")
+	}
+	if !reflect.DeepEqual(bundle.Proof, pj) {
+		t.Fatalf("all.json proof differs from proof.json")
+	}
+	if !reflect.DeepEqual(bundle.Public, pubj) {
+		t.Fatalf("all.json public differs from public.json")
+	}
+	if bundle.CommitmentWire == "" || bundle.CommitmentWire != pubj.CommitmentWire {
+		t.Fatalf("commitmentWire mismatch: bundle=%q public=%q", bundle.CommitmentWire, pubj.CommitmentWire)
+	}
+}
+
+func TestVerifyFromFiles_BundleOnly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping gnark proof test in -short mode")
+	}
+
+	a := big.NewInt(34343)
+	r := big.NewInt(43434)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+	outDir := t.TempDir()
+
+	opts := ProveOptions{Export: ExportOptions{BundleOnly: true}}
+	if err := ProveAndVerifyVW0W1WithOptions(a, r, vHex, w0Hex, w1Hex, outDir, opts); err != nil {
+		t.Fatalf("prove: %v", err)
+	}
+	for _, name := range []string{"vk.json", "proof.json", "public.json"} {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err == nil {
+			t.Fatalf("%s should not be written with BundleOnly", name)
+		}
+	}
+
+	if err := VerifyJSONFromDir(outDir); err != nil {
+		t.Fatalf("VerifyJSONFromDir(all.json): %v", err)
+	}
+
+	// Without vk.bin, VerifyFromFiles must fall back to all.json.
+	if err := os.Remove(filepath.Join(outDir, "vk.bin")); err != nil {
+		t.Fatalf("remove vk.bin: %v", err)
+	}
+	if err := VerifyFromFiles(outDir); err != nil {
+		t.Fatalf("VerifyFromFiles(all.json fallback): %v", err)
+	}
+
+	// A tampered public input must be rejected.
+	vkj, pj, pubj, err := LoadJSONArtifacts(outDir)
+	if err != nil {
+		t.Fatalf("LoadJSONArtifacts: %v", err)
+	}
+	pubj.Inputs[len(pubj.Inputs)-1] = "12345"
+	if err := VerifyJSON(vkj, pj, pubj); err == nil {
+		t.Fatalf("expected verification failure for tampered public input")
+	}
+}
+
+func TestLoadJSONArtifacts_MissingDir(t *testing.T) {
+	if _, _, _, err := LoadJSONArtifacts(filepath.Join(t.TempDir(), "noexist")); err == nil {
+		t.Fatalf("expected error for missing directory")
+	}
+}
+
+func TestVerifyJSON_RejectsMalformedVK(t *testing.T) {
+	err := VerifyJSON(VKJSON{VkAlpha: "zz"}, ProofJSON{}, PublicJSON{})
+	if err == nil || !strings.Contains(err.Error(), "vkAlpha") {
+		t.Fatalf("expected vkAlpha error, got %v", err)
+	}
+}
+
+func TestVerifyJSONWithOptions_ExpectWire(t *testing.T) {
+	// app/out holds the artifacts of the last Python-driven prove.
+	vkj, pj, pubj, err := LoadJSONArtifacts(filepath.Join("..", "out"))
+	if err != nil {
+		t.Skipf("no proof artifacts in ../out: %v", err)
+	}
+	if pubj.CommitmentWire == "" {
+		t.Skip("../out/public.json has no commitmentWire")
+	}
+
+	if err := VerifyJSONWithOptions(vkj, pj, pubj, VerifyOptions{ExpectWire: pubj.CommitmentWire}); err != nil {
+		t.Fatalf("expected matching wire, got %v", err)
+	}
+
+	err = VerifyJSONWithOptions(vkj, pj, pubj, VerifyOptions{ExpectWire: "12"})
+	if !errors.Is(err, ErrWireMismatch) {
+		t.Fatalf("expected ErrWireMismatch, got %v", err)
+	}
+	if !strings.Contains(err.Error(), pubj.CommitmentWire) {
+		t.Fatalf("mismatch should report the recomputed wire: %v", err)
+	}
+
+	// A broken proof is a verification failure, not a wire mismatch.
+	pj.PiA, pj.PiC = pj.PiC, pj.PiA
+	err = VerifyJSONWithOptions(vkj, pj, pubj, VerifyOptions{ExpectWire: "12"})
+	if err == nil || errors.Is(err, ErrWireMismatch) {
+		t.Fatalf("expected verification failure, got %v", err)
+	}
+}
+
+func TestParsePublicInputsFromHex(t *testing.T) {
+	var rMinus1 fr.Element
+	rMinus1.SetOne()
+	rMinus1.Neg(&rMinus1)
+	rMinus1Bytes := rMinus1.Bytes()
+
+	inputs := []string{
+		strings.Repeat("00", 31) + "01",
+		"0x" + strings.Repeat("00", 30) + "0102",
+		hex.EncodeToString(rMinus1Bytes[:]),
+	}
+	got, err := ParsePublicInputsFromHex(inputs)
+	if err != nil {
+		t.Fatalf("ParsePublicInputsFromHex: %v", err)
+	}
+	for i, want := range []uint64{1, 258} {
+		var w fr.Element
+		w.SetUint64(want)
+		if !got[i].Equal(&w) {
+			t.Fatalf("input %d: got %s want %d", i, got[i].String(), want)
+		}
+	}
+	if !got[2].Equal(&rMinus1) {
+		t.Fatalf("input 2: got %s want r-1", got[2].String())
+	}
+
+	modulus := hex.EncodeToString(fr.Modulus().Bytes())
+	for name, bad := range map[string]string{
+		"short":        strings.Repeat("00", 31),
+		"long":         strings.Repeat("00", 33),
+		"not hex":      strings.Repeat("zz", 32),
+		"out of field": modulus,
+	} {
+		if _, err := ParsePublicInputsFromHex([]string{inputs[0], bad}); err == nil || !strings.Contains(err.Error(), "public input 1") {
+			t.Fatalf("%s: expected error naming input 1, got %v", name, err)
+		}
+	}
+}
+
+func TestVerifyJSON_AcceptsInputsHex(t *testing.T) {
+	vkj, pj, pubj, err := LoadJSONArtifacts(filepath.Join("..", "out"))
+	if err != nil {
+		t.Skipf("no proof artifacts in ../out: %v", err)
+	}
+
+	hexPub := PublicJSON{CommitmentWire: pubj.CommitmentWire}
+	for i, s := range pubj.Inputs {
+		var e fr.Element
+		if _, err := e.SetString(s); err != nil {
+			t.Fatalf("input %d: %v", i, err)
+		}
+		b := e.Bytes()
+		hexPub.InputsHex = append(hexPub.InputsHex, hex.EncodeToString(b[:]))
+	}
+
+	if err := VerifyJSON(vkj, pj, pubj); err != nil {
+		t.Fatalf("decimal inputs: %v", err)
+	}
+	if err := VerifyJSON(vkj, pj, hexPub); err != nil {
+		t.Fatalf("hex inputs: %v", err)
+	}
+
+	both := hexPub
+	both.Inputs = pubj.Inputs
+	if err := VerifyJSON(vkj, pj, both); err == nil || !strings.Contains(err.Error(), "both inputs and inputsHex") {
+		t.Fatalf("expected rejection of both forms, got %v", err)
+	}
+
+	hexPub.InputsHex[len(hexPub.InputsHex)-1] = hex.EncodeToString(fr.Modulus().Bytes())
+	if err := VerifyJSON(vkj, pj, hexPub); err == nil || !strings.Contains(err.Error(), "public input") {
+		t.Fatalf("expected out-of-field rejection, got %v", err)
+	}
+}
+
+func TestVerifyJSON_RejectsOtherCurve(t *testing.T) {
+	// The curve check runs before any point is parsed.
+	err := VerifyJSON(VKJSON{Curve: "bn254", VkAlpha: "zz"}, ProofJSON{}, PublicJSON{})
+	if err == nil || !strings.Contains(err.Error(), `curve "bn254"`) {
+		t.Fatalf("expected curve error for vk, got %v", err)
+	}
+
+	if _, err := proofFromJSON(ProofJSON{Curve: "bn254", PiA: "zz"}); err == nil || !strings.Contains(err.Error(), `curve "bn254"`) {
+		t.Fatalf("expected curve error for proof, got %v", err)
+	}
+
+	// Artifacts without the field (exported before it existed) still load.
+	if err := checkCurve("vk", ""); err != nil {
+		t.Fatalf("empty curve should be accepted: %v", err)
+	}
+}
+
+func TestEntryDecryptInputs_Shapes(t *testing.T) {
+	r1 := g1Hex(mustG1Base(5))
+	g1b := g1Hex(mustG1Base(3))
+	g2b := g2Hex(mustG2Base(19))
+	r4 := g1Hex(mustG1Base(23))
+
+	cases := []struct {
+		name    string
+		datum   string
+		wantG2b string
+	}{
+		{
+			name:  "half level",
+			datum: `{"constructor":0,"fields":[{"bytes":"` + r1 + `"},{"bytes":"` + g1b + `"},{"bytes":"` + r4 + `"}]}`,
+		},
+		{
+			name: "full level",
+			datum: `{"constructor":0,"fields":[{"constructor":0,"fields":[` +
+				`{"bytes":"` + r1 + `"},{"bytes":"` + g1b + `"},{"bytes":"` + g2b + `"},{"bytes":"` + r4 + `"}]}]}`,
+			wantG2b: g2b,
+		},
+		{
+			name: "nested r2 without g2b",
+			datum: `{"constructor":0,"fields":[{"bytes":"` + r1 + `"},` +
+				`{"constructor":0,"fields":[{"bytes":"` + g1b + `"},{"constructor":1,"fields":[]}]}]}`,
+		},
+		{
+			name: "nested r2 with g2b",
+			datum: `{"constructor":0,"fields":[{"bytes":"` + r1 + `"},` +
+				`{"constructor":0,"fields":[{"bytes":"` + g1b + `"},{"constructor":0,"fields":[{"bytes":"` + g2b + `"}]}]}]}`,
+			wantG2b: g2b,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry, err := ParsePlutusDatum([]byte(tc.datum))
+			if err != nil {
+				t.Fatalf("ParsePlutusDatum: %v", err)
+			}
+			gotG1b, gotG2b, gotR1, err := EntryDecryptInputs(entry)
+			if err != nil {
+				t.Fatalf("EntryDecryptInputs: %v", err)
+			}
+			if gotG1b != g1b || gotG2b != tc.wantG2b || gotR1 != r1 {
+				t.Fatalf("got (g1b=%s, g2b=%s, r1=%s)", gotG1b, gotG2b, gotR1)
+			}
+		})
+	}
+}
+
+func TestEntryDecryptInputs_EmptyFullLevel(t *testing.T) {
+	entry, err := ParsePlutusDatum([]byte(`{"constructor":1,"fields":[]}`))
+	if err != nil {
+		t.Fatalf("ParsePlutusDatum: %v", err)
+	}
+	if _, _, _, err := EntryDecryptInputs(entry); err == nil || !strings.Contains(err.Error(), "empty full level") {
+		t.Fatalf("expected empty full level error, got %v", err)
+	}
+}
+
+func TestDecryptEntryToHash_CBORMatchesDirect(t *testing.T) {
+	r1 := mustG1Base(13)
+	g1b := mustG1Base(11)
+	g2b := mustG2Base(19)
+	r4 := mustG1Base(29)
+	shared := g2Hex(mustG2Base(17))
+
+	want, err := DecryptToHash(g1Hex(g1b), g2Hex(g2b), g1Hex(r1), shared)
+	if err != nil {
+		t.Fatalf("DecryptToHash: %v", err)
+	}
+
+	rb, gb, g2, r4b := r1.Bytes(), g1b.Bytes(), g2b.Bytes(), r4.Bytes()
+	inner := cbor.Tag{Number: 121, Content: []interface{}{rb[:], gb[:], g2[:], r4b[:]}}
+	raw, err := cbor.Marshal(cbor.Tag{Number: 121, Content: []interface{}{inner}})
+	if err != nil {
+		t.Fatalf("cbor.Marshal: %v", err)
+	}
+
+	for name, input := range map[string][]byte{
+		"binary": raw,
+		"hex":    []byte("0x" + hex.EncodeToString(raw) + "\n"),
+	} {
+		got, err := DecryptEntryToHash(input, shared)
+		if err != nil {
+			t.Fatalf("%s: DecryptEntryToHash: %v", name, err)
+		}
+		if got != want {
+			t.Fatalf("%s: got %s want %s", name, got, want)
+		}
+	}
+}
+
+func TestParsePlutusDatum_IntsAndLists(t *testing.T) {
+	big2to70 := new(big.Int).Lsh(big.NewInt(1), 70)
+	raw, err := cbor.Marshal(cbor.Tag{Number: 122, Content: []interface{}{
+		[]interface{}{[]byte{0xab}, uint64(7)},
+		int64(-3),
+		big2to70,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ParsePlutusDatum([]byte(hex.EncodeToString(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Constructor == nil || *d.Constructor != 1 || len(d.Fields) != 3 {
+		t.Fatalf("unexpected datum %+v", d)
+	}
+	list := d.Fields[0].List
+	if len(list) != 2 || list[0].Bytes == nil || *list[0].Bytes != "ab" || list[1].Int == nil || list[1].Int.Int64() != 7 {
+		t.Fatalf("unexpected list %+v", list)
+	}
+	if d.Fields[1].Int == nil || d.Fields[1].Int.Int64() != -3 {
+		t.Fatalf("unexpected int %+v", d.Fields[1])
+	}
+	if d.Fields[2].Int == nil || d.Fields[2].Int.Cmp(big2to70) != 0 {
+		t.Fatalf("unexpected bignum %+v", d.Fields[2])
+	}
+
+	if _, err := ParsePlutusDatum([]byte(`a1010203`)); err == nil {
+		t.Fatal("a CBOR map should be rejected")
+	}
+}
+
+// The encryption_datum_*.hex fixtures are app/data/encryption/encryption-datum.json
+// in the CBOR layout the chain serves: constructors and non-empty lists are
+// indefinite-length and byte strings longer than 64 bytes are chunked.
+// encryption_datum_full.hex is that datum as is (full_level Some, status
+// Open); encryption_datum_pending.hex has full_level None and a Pending
+// status holding the proof and public inputs from app/out.
+const (
+	chainDatumR1    = "a4917d0b621974b8443bebbbcee8257fde4973fc3f6f6b348caed81a7c635ad685f32aac8f3b18fe16f355cb44a84e9b"
+	chainDatumG1b   = "a5c5a380ac479fbaa7b7c71876970cf124e9e7d0df62d23021c0ef590f7990e533f7f195443f38f0d8e56bc79523c88e"
+	chainDatumFullR = "ad34aed9722216907e8bc6e8c6035a2e8fef3046a79081ef1b1da436c5b7f1419226a1cc174652f3f456a1e9df62cfda"
+	chainDatumFullG = "97c99558666e2efb68abb606a28f482221afe4176205f3087be4c50d8de9c472fa03d9c5c9f32ab5e9fd421c59c7756e"
+	chainDatumFullH = "8a48434b12b9797dbda7aab3243c6bed58f845abc6898c00a94df61120ba0c384f2460dc862788dcc8c80439eafe48c21556fda82eebfe9152901335c3eaa40be0b15b679859b4387c73f0f45905fc51aa56302eda84d4e97252ab31aefec19d"
+)
+
+func TestDecryptFromChain_Fixtures(t *testing.T) {
+	shared := g2Hex(mustG2Base(17))
+	halfKey, err := DecryptToHash(chainDatumG1b, "", chainDatumR1, shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullKey, err := DecryptToHash(chainDatumFullG, chainDatumFullH, chainDatumFullR, shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		fixture string
+		level   ChainLevel
+		want    FromChainResult
+		wantErr string
+	}{
+		{"encryption_datum_full.hex", ChainLevelAuto, FromChainResult{ChainLevelFull, chainDatumFullG, chainDatumFullH, chainDatumFullR, fullKey}, ""},
+		{"encryption_datum_full.hex", ChainLevelHalf, FromChainResult{ChainLevelHalf, chainDatumG1b, "", chainDatumR1, halfKey}, ""},
+		{"encryption_datum_pending.hex", ChainLevelAuto, FromChainResult{ChainLevelHalf, chainDatumG1b, "", chainDatumR1, halfKey}, ""},
+		{"encryption_datum_pending.hex", ChainLevelFull, FromChainResult{}, "has no full level"},
+	}
+	for _, tc := range cases {
+		raw := mustReadFile(t, filepath.Join("testdata", tc.fixture))
+		got, err := DecryptFromChain(raw, shared, FromChainOptions{Level: tc.level, ExpectKey: tc.want.Key})
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("%s %s: want error containing %q, got %v", tc.fixture, tc.level, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s %s: %v", tc.fixture, tc.level, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s %s: got %+v, want %+v", tc.fixture, tc.level, got, tc.want)
+		}
+	}
+
+	raw := mustReadFile(t, filepath.Join("testdata", "encryption_datum_full.hex"))
+	got, err := DecryptFromChain(raw, shared, FromChainOptions{ExpectKey: halfKey})
+	if !errors.Is(err, ErrKeyMismatch) || got.Key != fullKey {
+		t.Fatalf("want ErrKeyMismatch with the decrypted key, got %+v, %v", got, err)
+	}
+
+	// A bare level entry is not an encryption datum.
+	entry := `{"constructor":0,"fields":[{"bytes":"` + chainDatumR1 + `"},{"bytes":"` + chainDatumG1b + `"},{"bytes":"00"}]}`
+	if _, err := DecryptFromChain([]byte(entry), shared, FromChainOptions{}); err == nil || !strings.Contains(err.Error(), "not an encryption datum") {
+		t.Fatalf("want a not-an-encryption-datum error, got %v", err)
+	}
+}
+
+// chainTestEntries builds a half level followed by two full levels from small
+// base-point multiples, returning the parsed entries and their raw JSON.
+func chainTestEntries(t *testing.T) ([]PlutusData, string) {
+	t.Helper()
+	half := `{"constructor":0,"fields":[{"bytes":"` + g1Hex(mustG1Base(5)) + `"},{"bytes":"` + g1Hex(mustG1Base(3)) + `"},{"bytes":"` + g1Hex(mustG1Base(7)) + `"}]}`
+	full := func(r1, g1b, g2b, r4 int64) string {
+		return `{"constructor":0,"fields":[{"constructor":0,"fields":[` +
+			`{"bytes":"` + g1Hex(mustG1Base(r1)) + `"},{"bytes":"` + g1Hex(mustG1Base(g1b)) + `"},` +
+			`{"bytes":"` + g2Hex(mustG2Base(g2b)) + `"},{"bytes":"` + g1Hex(mustG1Base(r4)) + `"}]}]}`
+	}
+	raw := "[" + half + "," + full(11, 13, 17, 19) + "," + full(23, 29, 31, 37) + "]"
+
+	var entries []PlutusData
+	if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+		t.Fatalf("unmarshal entries: %v", err)
+	}
+	return entries, raw
+}
+
+func TestDecryptChain_ThreeLevelsMatchesManualHops(t *testing.T) {
+	entries, _ := chainTestEntries(t)
+	sharedInit := g2Hex(mustG2Base(41))
+
+	got, err := DecryptChain(sharedInit, entries)
+	if err != nil {
+		t.Fatalf("DecryptChain: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("want 3 hop keys, got %d", len(got))
+	}
+
+	// Manual walk: decrypt each hop, then shared = [int(key) mod r]G2.
+	shared := sharedInit
+	for i, entry := range entries {
+		g1b, g2b, r1, err := EntryDecryptInputs(entry)
+		if err != nil {
+			t.Fatalf("hop %d inputs: %v", i, err)
+		}
+		if (i == 0) != (g2b == "") {
+			t.Fatalf("hop %d: unexpected g2b presence %q", i, g2b)
+		}
+		want, err := DecryptToHash(g1b, g2b, r1, shared)
+		if err != nil {
+			t.Fatalf("hop %d DecryptToHash: %v", i, err)
+		}
+		if got[i] != want {
+			t.Fatalf("hop %d: chain key %s != manual key %s", i, got[i], want)
+		}
+
+		k, ok := new(big.Int).SetString(want, 16)
+		if !ok {
+			t.Fatalf("hop %d: key not hex", i)
+		}
+		k.Mod(k, fr.Modulus())
+		var next bls12381.G2Affine
+		next.ScalarMultiplicationBase(k)
+		shared = g2Hex(next)
+	}
+}
+
+func TestDecryptChain_Levels(t *testing.T) {
+	entries, _ := chainTestEntries(t)
+	sharedInit := g2Hex(mustG2Base(41))
+
+	// Half level then one full level, declared as two levels.
+	keys, err := DecryptChainWithOptions(sharedInit, entries[:2], ChainOptions{Levels: 2})
+	if err != nil {
+		t.Fatalf("two-level chain: %v", err)
+	}
+	all, err := DecryptChain(sharedInit, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != all[0] || keys[1] != all[1] {
+		t.Fatalf("two-level keys %v are not a prefix of %v", keys, all)
+	}
+
+	cases := []struct {
+		name    string
+		entries []PlutusData
+		levels  int
+		wantErr string
+	}{
+		{"full level first", entries[1:], 0, "hop 0 must be the half level"},
+		{"half level twice", []PlutusData{entries[0], entries[0]}, 0, "hop 1 must be a full level"},
+		{"half level last", []PlutusData{entries[0], entries[1], entries[0]}, 0, "hop 2 must be a full level"},
+		{"too deep", entries, 2, "chain has 3 levels, declared 2"},
+		{"too shallow", entries[:2], 3, "chain has 2 levels, declared 3"},
+	}
+	for _, tc := range cases {
+		_, err := DecryptChainWithOptions(sharedInit, tc.entries, ChainOptions{Levels: tc.levels})
+		if !errors.Is(err, ErrChainLevel) || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Fatalf("%s: want ErrChainLevel containing %q, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestDecryptChain_Empty(t *testing.T) {
+	if _, err := DecryptChain(g2Hex(mustG2Base(41)), nil); err == nil {
+		t.Fatalf("expected error for empty chain")
+	}
+}
+
+func TestPrepareVW0W1_RejectsZeroR(t *testing.T) {
+	a := big.NewInt(4242)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, big.NewInt(0))
+	_, err := prepareVW0W1(a, big.NewInt(0), vHex, w0Hex, w1Hex, ProveOptions{})
+	if err == nil || !strings.Contains(err.Error(), "no blinding") {
+		t.Fatalf("expected r = 0 rejection, got %v", err)
+	}
+	// nil r is treated as 0
+	if _, err := prepareVW0W1(a, nil, vHex, w0Hex, w1Hex, ProveOptions{}); err == nil {
+		t.Fatalf("expected nil r to be rejected as 0")
+	}
+}
+
+func TestPrepareVW0W1_RejectsRAtModulus(t *testing.T) {
+	a := big.NewInt(4242)
+	r := fr.Modulus()
+	// w1 computed from r mod q = 0, i.e. what silent reduction would have used.
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, big.NewInt(0))
+	_, err := prepareVW0W1(a, r, vHex, w0Hex, w1Hex, ProveOptions{SkipPreflight: true})
+	if err == nil || !strings.Contains(err.Error(), "modulus") {
+		t.Fatalf("expected modulus rejection, got %v", err)
+	}
+}
+
+func TestPrepareVW0W1_AcceptsRModulusMinusOne(t *testing.T) {
+	a := big.NewInt(4242)
+	r := new(big.Int).Sub(fr.Modulus(), big.NewInt(1))
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+	if _, err := prepareVW0W1(a, r, vHex, w0Hex, w1Hex, ProveOptions{}); err != nil {
+		t.Fatalf("r = modulus-1 should be accepted: %v", err)
+	}
+}
+
+func TestPrepareVW0W1_WritesWitnessOut(t *testing.T) {
+	a := big.NewInt(4242)
+	r := big.NewInt(7)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+	path := filepath.Join(t.TempDir(), "witness.json")
+
+	if _, err := prepareVW0W1(a, r, vHex, w0Hex, w1Hex, ProveOptions{WitnessOut: path}); err != nil {
+		t.Fatalf("prepareVW0W1: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read witness: %v", err)
+	}
+	var got VW0W1WitnessJSON
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("unmarshal witness: %v", err)
+	}
+
+	v, err := parseG1CompressedHex(vHex)
+	if err != nil {
+		t.Fatalf("parse v: %v", err)
+	}
+	w1, err := parseG1CompressedHex(w1Hex)
+	if err != nil {
+		t.Fatalf("parse w1: %v", err)
+	}
+	if got.A != "4242" || got.R != "7" {
+		t.Fatalf("secrets: got a=%s r=%s", got.A, got.R)
+	}
+	if got.VX != v.X.String() || got.VY != v.Y.String() || got.W1Y != w1.Y.String() {
+		t.Fatalf("coordinates do not match the parsed points: %+v", got)
+	}
+
+	// A failing pre-flight still leaves the dump behind for inspection.
+	badPath := filepath.Join(t.TempDir(), "bad.json")
+	if _, err := prepareVW0W1(big.NewInt(4243), r, vHex, w0Hex, w1Hex, ProveOptions{WitnessOut: badPath}); err == nil {
+		t.Fatalf("expected pre-flight failure for the wrong a")
+	}
+	if _, err := os.Stat(badPath); err != nil {
+		t.Fatalf("witness not written on pre-flight failure: %v", err)
+	}
+}
+
+func TestCheckSecretBits_Boundary(t *testing.T) {
+	pow := func(n uint) *big.Int { return new(big.Int).Lsh(big.NewInt(1), n) }
+	cases := []struct {
+		name    string
+		a       *big.Int
+		minBits int
+		weak    bool
+	}{
+		{"disabled", big.NewInt(1), 0, false},
+		{"exactly 64 bits", pow(63), 64, false},
+		{"one short of 64", new(big.Int).Sub(pow(63), big.NewInt(1)), 64, true},
+		{"r-1 meets the maximum", new(big.Int).Sub(fr.Modulus(), big.NewInt(1)), fr.Bits, false},
+		{"r+2 reduces to 2", new(big.Int).Add(fr.Modulus(), big.NewInt(2)), 3, true},
+	}
+	for _, tc := range cases {
+		err := checkSecretBits(tc.a, tc.minBits)
+		if tc.weak != errors.Is(err, ErrWeakSecret) {
+			t.Fatalf("%s: weak=%v, got %v", tc.name, tc.weak, err)
+		}
+	}
+	for _, n := range []int{-1, fr.Bits + 1} {
+		if err := validateMinEntropyBits(n); err == nil {
+			t.Fatalf("validateMinEntropyBits(%d) accepted", n)
+		}
+	}
+}
+
+func TestPrepareVW0W1_RejectsNegativeR(t *testing.T) {
+	a := big.NewInt(4242)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, big.NewInt(1))
+	_, err := prepareVW0W1(a, big.NewInt(-1), vHex, w0Hex, w1Hex, ProveOptions{SkipPreflight: true})
+	if err == nil || !strings.Contains(err.Error(), ">= 0") {
+		t.Fatalf("expected negative r rejection, got %v", err)
+	}
+}
+
+func TestCheckHexLen(t *testing.T) {
+	g1 := strings.Repeat("ab", 48)
+
+	for _, in := range []string{g1, "0x" + g1, " 0X" + strings.ToUpper(g1) + "\n"} {
+		if err := checkHexLen("publicV", in, 96); err != nil {
+			t.Fatalf("checkHexLen(%q): %v", in, err)
+		}
+	}
+
+	err := checkHexLen("publicV", g1+"ab", 96)
+	if err == nil || err.Error() != "publicV must be 96 hex chars (got 98)" {
+		t.Fatalf("unexpected error for long input: %v", err)
+	}
+
+	err = checkHexLen("publicV", "0x"+g1+"ab\n", 96)
+	if err == nil || !strings.Contains(err.Error(), "got 98 after trimming whitespace/0x; received 101 chars") {
+		t.Fatalf("unexpected error for prefixed long input: %v", err)
+	}
+
+	if err := checkHexArgs(hexArg{"a", g1, 96}, hexArg{"b", "00", 192}); err == nil || !strings.HasPrefix(err.Error(), "b ") {
+		t.Fatalf("expected error naming b, got %v", err)
+	}
+}
+
+func TestProtocolParams_MatchConstants(t *testing.T) {
+	p := ProtocolParams()
+	if p["domainTag"] != DomainTagHex || p["h0"] != H0Hex || p["curve"] != "bls12381" {
+		t.Fatalf("unexpected params: %v", p)
+	}
+	if _, err := parseG2CompressedHex(p["h0"].(string)); err != nil {
+		t.Fatalf("h0 is not a valid G2 point: %v", err)
+	}
+}
+
+func TestBLSWrappers_MatchGnarkCrypto(t *testing.T) {
+	a, r := big.NewInt(11), big.NewInt(13)
+	v := mustG1Base(42)
+
+	// [a]q + [r]v == [a + 42r]q
+	want := mustG1Base(11 + 42*13)
+
+	rv, err := ScalarMulG1(v, r)
+	if err != nil {
+		t.Fatalf("ScalarMulG1: %v", err)
+	}
+	got, err := AddG1(ScalarBaseMulG1(a), rv)
+	if err != nil {
+		t.Fatalf("AddG1: %v", err)
+	}
+	if !got.Equal(&want) {
+		t.Fatalf("[a]q + [r]v mismatch")
+	}
+
+	// Scalars are reduced mod r, so k and k + r give the same point.
+	kPlusR := new(big.Int).Add(a, fr.Modulus())
+	reduced := ScalarBaseMulG1(kPlusR)
+	qa := ScalarBaseMulG1(a)
+	if !reduced.Equal(&qa) {
+		t.Fatalf("scalar not reduced mod r")
+	}
+
+	qaHex, err := ScalarBaseMulG1Hex(a)
+	if err != nil {
+		t.Fatalf("ScalarBaseMulG1Hex: %v", err)
+	}
+	rvHex, err := ScalarMulG1Hex(g1Hex(v), r)
+	if err != nil {
+		t.Fatalf("ScalarMulG1Hex: %v", err)
+	}
+	sumHex, err := AddG1Hex(qaHex, rvHex)
+	if err != nil {
+		t.Fatalf("AddG1Hex: %v", err)
+	}
+	if sumHex != g1Hex(want) {
+		t.Fatalf("hex forms disagree: %s != %s", sumHex, g1Hex(want))
+	}
+}
+
+// g1OutsideSubgroup returns a curve point y^2 = x^3 + 4 that is not in the
+// prime-order subgroup.
+func g1OutsideSubgroup(t *testing.T) bls12381.G1Affine {
+	t.Helper()
+	var p bls12381.G1Affine
+	var four fp.Element
+	four.SetUint64(4)
+	for x := uint64(1); ; x++ {
+		var rhs fp.Element
+		p.X.SetUint64(x)
+		rhs.Square(&p.X).Mul(&rhs, &p.X).Add(&rhs, &four)
+		if p.Y.Sqrt(&rhs) != nil && !p.IsInSubGroup() {
+			break
+		}
+	}
+	if !p.IsOnCurve() {
+		t.Fatalf("test point is not on the curve")
+	}
+	return p
+}
+
+func TestBLSWrappers_RejectPointOutsideSubgroup(t *testing.T) {
+	p := g1OutsideSubgroup(t)
+
+	if _, err := ScalarMulG1(p, big.NewInt(3)); err == nil || !strings.Contains(err.Error(), "subgroup") {
+		t.Fatalf("expected subgroup error, got %v", err)
+	}
+	if _, err := AddG1(mustG1Base(1), p); err == nil || !strings.Contains(err.Error(), "q is not in the G1 subgroup") {
+		t.Fatalf("expected subgroup error for q, got %v", err)
+	}
+	if _, err := AddG1Hex("zz", g1Hex(mustG1Base(1))); err == nil || !strings.HasPrefix(err.Error(), "p:") {
+		t.Fatalf("expected hex error for p, got %v", err)
+	}
+}
+
+func TestWithDeterministicRand_ReproducibleFrSampling(t *testing.T) {
+	sample := func(seed string) fr.Element {
+		var e fr.Element
+		err := withDeterministicRand([]byte(seed), func() error {
+			_, err := e.SetRandom()
+			return err
+		})
+		if err != nil {
+			t.Fatalf("SetRandom: %v", err)
+		}
+		return e
+	}
+
+	a, b, c := sample("seed-1"), sample("seed-1"), sample("seed-2")
+	if !a.Equal(&b) {
+		t.Fatalf("same seed produced different samples")
+	}
+	if a.Equal(&c) {
+		t.Fatalf("different seeds produced the same sample")
+	}
+}
+
+func TestProveVW0W1_DeterministicSeedReproducesProofJSON(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping gnark proof test in -short mode")
+	}
+
+	ccs, err := CompileVW0W1Circuit()
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	h := &SetupHandle{CCS: ccs, PK: pk, VK: vk}
+
+	a := big.NewInt(56565)
+	r := big.NewInt(65656)
+	vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+
+	proveJSON := func(seed string) []byte {
+		t.Helper()
+		var proof groth16.Proof
+		var pub backend_witness.Witness
+		err := withDeterministicRand([]byte(seed), func() (err error) {
+			proof, pub, err = h.ProveVW0W1(a, r, vHex, w0Hex, w1Hex, ProveOptions{})
+			return err
+		})
+		if err != nil {
+			t.Fatalf("prove: %v", err)
+		}
+		dir := t.TempDir()
+		if err := ExportAll(vk, proof, pub, dir); err != nil {
+			t.Fatalf("export: %v", err)
+		}
+		b, err := os.ReadFile(filepath.Join(dir, "proof.json"))
+		if err != nil {
+			t.Fatalf("read proof.json: %v", err)
+		}
+		return b
+	}
+
+	first, second := proveJSON("golden"), proveJSON("golden")
+	if !bytes.Equal(first, second) {
+		t.Fatalf("same seed produced different proof.json:\n%s\n%s", first, second)
+	}
+	if bytes.Equal(first, proveJSON("other")) {
+		t.Fatalf("different seeds produced identical proof.json")
+	}
+}
+
+func TestSetup_DeterministicSeedReproducesVKJSON(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &commitCircuit{})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	vkJSON := func(seed string) []byte {
+		t.Helper()
+		var vk groth16.VerifyingKey
+		setup := func() (err error) {
+			_, vk, err = groth16.Setup(ccs)
+			return err
+		}
+		if seed != "" {
+			err = withDeterministicRand([]byte(seed), setup)
+		} else {
+			err = setup()
+		}
+		if err != nil {
+			t.Fatalf("setup: %v", err)
+		}
+		dir := t.TempDir()
+		if err := ExportVKOnly(vk, dir); err != nil {
+			t.Fatalf("export: %v", err)
+		}
+		b, err := os.ReadFile(filepath.Join(dir, "vk.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	first := vkJSON("golden")
+	if !bytes.Equal(first, vkJSON("golden")) {
+		t.Fatal("same seed produced different vk.json")
+	}
+	if bytes.Equal(first, vkJSON("other")) {
+		t.Fatal("different seeds produced identical vk.json")
+	}
+	if bytes.Equal(first, vkJSON("")) {
+		t.Fatal("unseeded setup reproduced the seeded vk.json")
+	}
+}
+
+func TestSetupVW0W1Circuit_DeterministicSeedReproducesVKJSON(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping two full vw0w1 setups in -short mode")
+	}
+	setup := func() []byte {
+		t.Helper()
+		dir := t.TempDir()
+		err := withDeterministicRand([]byte("golden"), func() error {
+			return SetupVW0W1CircuitWithOptions(dir, true, SetupOptions{})
+		})
+		if err != nil {
+			t.Fatalf("setup: %v", err)
+		}
+		b, err := os.ReadFile(filepath.Join(dir, "vk.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	if first, second := setup(), setup(); !bytes.Equal(first, second) {
+		t.Fatal("seeded setups produced different vk.json")
+	}
+}
+
+// ---------- batch proving ----------
+
+// batchStatements builds n valid statements with distinct (a, r).
+func batchStatements(t *testing.T, n int) []VW0W1Statement {
+	t.Helper()
+	stmts := make([]VW0W1Statement, n)
+	for i := range stmts {
+		a := big.NewInt(int64(1000 + i))
+		r := big.NewInt(int64(2000 + i))
+		vHex, w0Hex, w1Hex := computeVW0W1(t, a, r)
+		stmts[i] = VW0W1Statement{A: a, R: r, VHex: vHex, W0Hex: w0Hex, W1Hex: w1Hex}
+	}
+	return stmts
+}
+
+func TestParseStatementList(t *testing.T) {
+	stmts := batchStatements(t, 1)
+	raw := `[{"a": "1000", "r": "0x7d0", "v": "0x` + stmts[0].VHex + `", "w0": "` + stmts[0].W0Hex + `", "w1": "` + stmts[0].W1Hex + `"}]`
+	got, err := ParseStatementList([]byte(raw))
+	if err != nil {
+		t.Fatalf("ParseStatementList: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 statement, got %d", len(got))
+	}
+	if got[0].A.Cmp(stmts[0].A) != 0 || got[0].R.Cmp(stmts[0].R) != 0 {
+		t.Fatalf("scalars: got a=%s r=%s", got[0].A, got[0].R)
+	}
+	if got[0].VHex != stmts[0].VHex || got[0].W0Hex != stmts[0].W0Hex || got[0].W1Hex != stmts[0].W1Hex {
+		t.Fatalf("points not normalized: %+v", got[0])
+	}
+
+	if _, err := ParseStatementList([]byte(`[{"a": "zz", "r": "1"}]`)); err == nil || !strings.Contains(err.Error(), "statement 0") {
+		t.Fatalf("expected statement 0 parse error, got %v", err)
+	}
+	if _, err := ParseStatementList([]byte(`{"a": "1"}`)); err == nil {
+		t.Fatal("expected an error for a non-array file")
+	}
+}
+
+func TestPrepareVW0W1Batch_PublicInputsConcatenated(t *testing.T) {
+	stmts := batchStatements(t, 2)
+	batch, err := prepareVW0W1Batch(stmts, ProveOptions{})
+	if err != nil {
+		t.Fatalf("prepareVW0W1Batch: %v", err)
+	}
+	bw, err := frontend.NewWitness(batch, ecc.BLS12_381.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		t.Fatalf("batch witness: %v", err)
+	}
+	got := bw.Vector().(fr.Vector)
+
+	var want fr.Vector
+	for i, st := range stmts {
+		single, err := prepareVW0W1(st.A, st.R, st.VHex, st.W0Hex, st.W1Hex, ProveOptions{})
+		if err != nil {
+			t.Fatalf("statement %d: %v", i, err)
+		}
+		sw, err := frontend.NewWitness(single, ecc.BLS12_381.ScalarField(), frontend.PublicOnly())
+		if err != nil {
+			t.Fatalf("statement %d witness: %v", i, err)
+		}
+		want = append(want, sw.Vector().(fr.Vector)...)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("batch public inputs are not the per-statement inputs in order (got %d, want %d values)", len(got), len(want))
+	}
+}
+
+func TestProveBatchVW0W1_RejectsBadInput(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "out")
+	if _, err := ProveBatchVW0W1(nil, outDir, ProveOptions{}); err == nil || !strings.Contains(err.Error(), "no statements") {
+		t.Fatalf("expected empty batch error, got %v", err)
+	}
+
+	stmts := batchStatements(t, 2)
+	if _, err := ProveBatchVW0W1(stmts, outDir, ProveOptions{WitnessOut: filepath.Join(outDir, "w.json")}); err == nil || !strings.Contains(err.Error(), "WitnessOut") {
+		t.Fatalf("expected WitnessOut error, got %v", err)
+	}
+
+	stmts[1].W0Hex = stmts[0].W0Hex
+	_, err := ProveBatchVW0W1(stmts, outDir, ProveOptions{})
+	if err == nil || !strings.Contains(err.Error(), "statement 1") || !strings.Contains(err.Error(), "w0 mismatch") {
+		t.Fatalf("expected statement 1 w0 mismatch, got %v", err)
+	}
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written on failure, stat err = %v", err)
+	}
+}
+
+func TestCompileVW0W1BatchCircuit_RejectsEmpty(t *testing.T) {
+	if _, err := CompileVW0W1BatchCircuit(0); err == nil {
+		t.Fatal("expected an error for a batch of 0")
+	}
+}
+
+func TestProveBatchVW0W1(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping expensive batch compile+setup+prove test in -short mode")
+	}
+
+	for _, n := range []int{2, 4} {
+		n := n
+		t.Run(fmt.Sprintf("N=%d", n), func(t *testing.T) {
+			outDir := filepath.Join(t.TempDir(), "out")
+			report, err := ProveBatchVW0W1(batchStatements(t, n), outDir, ProveOptions{})
+			if err != nil {
+				t.Fatalf("ProveBatchVW0W1: %v", err)
+			}
+			if report.Statements != n || report.Constraints == 0 {
+				t.Fatalf("unexpected report %+v", report)
+			}
+			t.Logf("N=%d: %d constraints (%d per statement)", n, report.Constraints, report.PerStatement())
+
+			var pub PublicJSON
+			if err := json.Unmarshal(mustReadFile(t, filepath.Join(outDir, "public.json")), &pub); err != nil {
+				t.Fatalf("public.json: %v", err)
+			}
+			if want := 1 + 36*n; len(pub.Inputs) != want {
+				t.Fatalf("public.json has %d inputs, want %d", len(pub.Inputs), want)
+			}
+			if err := VerifyJSONFromDir(outDir); err != nil {
+				t.Fatalf("verify exported batch proof: %v", err)
+			}
+		})
+	}
+}
+
+// ---------- streamed setup ----------
+
+// mapFetcher serves setup files from memory. Bodies hide any Seek method so
+// the loader is exercised on plain streams.
+type mapFetcher map[string][]byte
+
+func (m mapFetcher) Fetch(url string) (io.ReadCloser, error) {
+	b, ok := m[url]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", url)
+	}
+	return io.NopCloser(struct{ io.Reader }{bytes.NewReader(b)}), nil
+}
+
+func TestOpenSetupFromURLs_StreamsBothEncodings(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	assignment := &squareCircuit{X: 3, Y: 9}
+	for _, raw := range []bool{false, true} {
+		dir := t.TempDir()
+		if err := SaveSetupFilesWithOptions(ccs, pk, vk, dir, SaveOptions{Raw: raw}); err != nil {
+			t.Fatalf("raw=%v: save: %v", raw, err)
+		}
+		files := mapFetcher{}
+		for _, name := range []string{"ccs.bin", "pk.bin", "vk.bin"} {
+			files["mem://"+name] = mustReadFile(t, filepath.Join(dir, name))
+		}
+
+		h, err := OpenSetupFromURLs(SetupURLs{CCS: "mem://ccs.bin", PK: "mem://pk.bin", VK: "mem://vk.bin"}, files)
+		if err != nil {
+			t.Fatalf("raw=%v: open from fetcher: %v", raw, err)
+		}
+		if _, _, err := h.proveAssignment(assignment, ProveOptions{}); err != nil {
+			t.Fatalf("raw=%v: prove+verify: %v", raw, err)
+		}
+
+		srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+		h, err = OpenSetupFromURLs(SetupURLs{CCS: srv.URL + "/ccs.bin", PK: srv.URL + "/pk.bin", VK: srv.URL + "/vk.bin"}, nil)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("raw=%v: open over HTTP: %v", raw, err)
+		}
+		if _, _, err := h.proveAssignment(assignment, ProveOptions{}); err != nil {
+			t.Fatalf("raw=%v: prove+verify over HTTP: %v", raw, err)
+		}
+	}
+}
+
+func TestOpenSetupFromURLs_Errors(t *testing.T) {
+	if _, err := OpenSetupFromURLs(SetupURLs{CCS: "x", PK: "y"}, mapFetcher{}); err == nil || !strings.Contains(err.Error(), "all required") {
+		t.Fatalf("expected missing URL error, got %v", err)
+	}
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	_, err := OpenSetupFromURLs(SetupURLs{CCS: srv.URL + "/ccs.bin", PK: srv.URL + "/pk.bin", VK: srv.URL + "/vk.bin"}, HTTPFetcher{Client: srv.Client()})
+	if err == nil || !strings.Contains(err.Error(), "fetch ccs.bin") || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected a 404 for ccs.bin, got %v", err)
+	}
+}
+
+// flakyServer serves dir, but answers the first fails requests for each path
+// with 503. It counts the requests per path.
+func flakyServer(t *testing.T, dir string, fails int) (*httptest.Server, map[string]int) {
+	t.Helper()
+	var mu sync.Mutex
+	hits := map[string]int{}
+	files := http.FileServer(http.Dir(dir))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		n := hits[r.URL.Path]
+		mu.Unlock()
+		if n <= fails {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, hits
+}
+
+func TestRetryFetcher_SucceedsOnThirdAttempt(t *testing.T) {
+	dir := saveTinySetup(t, &squareCircuit{})
+	srv, hits := flakyServer(t, dir, 2)
+	urls := SetupURLs{CCS: srv.URL + "/ccs.bin", PK: srv.URL + "/pk.bin", VK: srv.URL + "/vk.bin"}
+	noWait := func(int) time.Duration { return 0 }
+
+	h, err := OpenSetupFromURLs(urls, RetryFetcher{Fetcher: HTTPFetcher{Client: srv.Client()}, Retries: 2, Backoff: noWait})
+	if err != nil {
+		t.Fatalf("open through flaky server: %v", err)
+	}
+	if _, _, err := h.proveAssignment(&squareCircuit{X: 3, Y: 9}, ProveOptions{}); err != nil {
+		t.Fatalf("prove+verify: %v", err)
+	}
+	for _, name := range []string{"/ccs.bin", "/pk.bin", "/vk.bin"} {
+		if hits[name] != 3 {
+			t.Fatalf("%s fetched %d times, want 3", name, hits[name])
+		}
+	}
+
+	// One retry is not enough against two failures.
+	srv, _ = flakyServer(t, dir, 2)
+	f := RetryFetcher{Fetcher: HTTPFetcher{Client: srv.Client()}, Retries: 1, Backoff: noWait}
+	var se *HTTPStatusError
+	if _, err := f.Fetch(srv.URL + "/vk.bin"); !errors.As(err, &se) || se.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected a 503 after the last retry, got %v", err)
+	}
+}
+
+func TestRetryFetcher_StopsOnFinalErrors(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	f := RetryFetcher{Fetcher: HTTPFetcher{Client: srv.Client()}, Retries: 5, Backoff: func(int) time.Duration { return 0 }}
+	if _, err := f.Fetch(srv.URL + "/pk.bin"); err == nil || hits != 1 {
+		t.Fatalf("a 404 must not be retried: err=%v hits=%d", err, hits)
+	}
+
+	// A wait that would overrun the deadline is not started.
+	dir := t.TempDir()
+	flaky, flakyHits := flakyServer(t, dir, 100)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	f = RetryFetcher{Fetcher: HTTPFetcher{Client: flaky.Client(), Context: ctx}, Retries: 5, Backoff: func(int) time.Duration { return time.Hour }, Context: ctx}
+	start := time.Now()
+	if _, err := f.Fetch(flaky.URL + "/pk.bin"); err == nil || !strings.Contains(err.Error(), "deadline") {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second || flakyHits["/pk.bin"] != 1 {
+		t.Fatalf("retried past the deadline: %d requests in %v", flakyHits["/pk.bin"], time.Since(start))
+	}
+
+	if d := ExponentialBackoff(1); d != 500*time.Millisecond {
+		t.Fatalf("first backoff = %v", d)
+	}
+	if d := ExponentialBackoff(3); d != 2*time.Second {
+		t.Fatalf("third backoff = %v", d)
+	}
+	if d := ExponentialBackoff(50); d != 30*time.Second {
+		t.Fatalf("backoff is not capped: %v", d)
+	}
+}
+
+// ---------- commitment wire without a VK ----------
+
+func TestCommitmentWireFromJSON_MatchesVKComputation(t *testing.T) {
+	vkj, pj, pubj, err := LoadJSONArtifacts(filepath.Join("..", "out"))
+	if err != nil {
+		t.Skipf("no proof artifacts in ../out: %v", err)
+	}
+
+	vk, err := vkFromJSON(vkj)
+	if err != nil {
+		t.Fatalf("vk: %v", err)
+	}
+	proof, err := proofFromJSON(pj)
+	if err != nil {
+		t.Fatalf("proof: %v", err)
+	}
+	witness, err := publicInputs(pubj)
+	if err != nil {
+		t.Fatalf("public inputs: %v", err)
+	}
+	want, err := computeCommitmentWireFr(proof, vk, witness[1:])
+	if err != nil {
+		t.Fatalf("VK-based wire: %v", err)
+	}
+
+	got, err := CommitmentWireFromJSON(pj, pubj, CommitmentWireOptions{})
+	if err != nil {
+		t.Fatalf("CommitmentWireFromJSON: %v", err)
+	}
+	if got != want {
+		t.Fatalf("no-VK wire %s != VK-based wire %s", got, want)
+	}
+
+	// Explicit indices taken from the VK give the same wire.
+	got, err = CommitmentWireFromJSON(pj, pubj, CommitmentWireOptions{Committed: vk.PublicAndCommitmentCommitted[0]})
+	if err != nil || got != want {
+		t.Fatalf("explicit indices: got %s, %v; want %s", got, err, want)
+	}
+
+	// A bare public vector is accepted with NoOneWire, and rejected without it.
+	bare := PublicJSON{Inputs: pubj.Inputs[1:]}
+	if got, err = CommitmentWireFromJSON(pj, bare, CommitmentWireOptions{NoOneWire: true}); err != nil || got != want {
+		t.Fatalf("NoOneWire: got %s, %v; want %s", got, err, want)
+	}
+	if _, err := CommitmentWireFromJSON(pj, bare, CommitmentWireOptions{}); err == nil || !strings.Contains(err.Error(), "one-wire") {
+		t.Fatalf("expected a one-wire error, got %v", err)
+	}
+}
+
+func TestParseIndexList(t *testing.T) {
+	got, err := ParseIndexList("1,3-5, 9")
+	if err != nil {
+		t.Fatalf("ParseIndexList: %v", err)
+	}
+	if want := []int{1, 3, 4, 5, 9}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, _ := ParseIndexList("1-36"); len(got) != 36 || got[35] != 36 {
+		t.Fatalf("1-36: got %v", got)
+	}
+	for _, bad := range []string{"", "0", "a", "5-3", "1-", "1,,2"} {
+		if _, err := ParseIndexList(bad); err == nil {
+			t.Fatalf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestReduceScalar(t *testing.T) {
+	q := fr.Modulus()
+
+	small := ReduceScalar(big.NewInt(255))
+	if small.Decimal != "255" || small.Reduced {
+		t.Fatalf("255: %+v", small)
+	}
+	if want := strings.Repeat("00", 31) + "ff"; small.Hex != want {
+		t.Fatalf("255 hex: got %s want %s", small.Hex, want)
+	}
+
+	over := ReduceScalar(new(big.Int).Add(q, big.NewInt(7)))
+	if over.Decimal != "7" || !over.Reduced {
+		t.Fatalf("q+7: %+v", over)
+	}
+
+	zero := ReduceScalar(q)
+	if zero.Decimal != "0" || !zero.Reduced || zero.Hex != strings.Repeat("00", 32) {
+		t.Fatalf("q: %+v", zero)
+	}
+}
+
+func TestReduceFr_MatchesFrElement(t *testing.T) {
+	q := fr.Modulus()
+	for _, k := range []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(-1),
+		new(big.Int).Sub(q, big.NewInt(1)),
+		new(big.Int).Set(q),
+		new(big.Int).Add(q, big.NewInt(7)),
+		new(big.Int).Neg(new(big.Int).Add(q, big.NewInt(7))),
+		new(big.Int).Lsh(big.NewInt(1), 300),
+	} {
+		// The WASM prover's reduction, spelled out.
+		var e fr.Element
+		e.SetBigInt(k)
+		var want big.Int
+		e.BigInt(&want)
+
+		if got := reduceFr(k); got.Cmp(&want) != 0 {
+			t.Fatalf("reduceFr(%s) = %s, want %s", k, got, &want)
+		}
+		if got := ReduceScalar(k); got.Decimal != want.String() {
+			t.Fatalf("ReduceScalar(%s) = %s, want %s", k, got.Decimal, &want)
+		}
+		if m := new(big.Int).Mod(k, q); m.Cmp(&want) != 0 {
+			t.Fatalf("%s: fr.Element gives %s, k mod q gives %s", k, &want, m)
+		}
+		values := newVW0W1Values(k, k, bls12381.G1Affine{}, bls12381.G1Affine{}, bls12381.G1Affine{})
+		if values.A.Cmp(&want) != 0 || values.R.Cmp(&want) != 0 {
+			t.Fatalf("%s: witness values a=%s r=%s, want %s", k, &values.A, &values.R, &want)
+		}
+	}
+}
+
+func TestComputeVKX_MatchesNaiveAccumulation(t *testing.T) {
+	const n = 37
+	IC := make([]bls12381.G1Affine, n+1)
+	for i := range IC {
+		IC[i] = ScalarBaseMulG1(big.NewInt(int64(1000 + 17*i)))
+	}
+	inputs := make([]fr.Element, n)
+	inputs[0].SetOne()
+	for i := 1; i < n; i++ {
+		if _, err := inputs[i].SetRandom(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	naive := func(k int) bls12381.G1Affine {
+		acc := IC[0]
+		for i := 0; i < k; i++ {
+			var s big.Int
+			inputs[i].BigInt(&s)
+			var term bls12381.G1Affine
+			term.ScalarMultiplication(&IC[i+1], &s)
+			acc.Add(&acc, &term)
+		}
+		return acc
+	}
+
+	for _, k := range []int{0, 1, n - 1, n} {
+		got, err := computeVKX(IC, inputs[:k])
+		if err != nil {
+			t.Fatalf("k=%d: %v", k, err)
+		}
+		if want := naive(k); !got.Equal(&want) {
+			t.Fatalf("k=%d: MultiExp vk_x differs from the naive accumulation", k)
+		}
+	}
+
+	if _, err := computeVKX(IC[:n], inputs); err == nil {
+		t.Fatal("expected an error when IC is too short")
+	}
+}
+
+// cubeCircuit (x*x*x == y) differs from squareCircuit in constraints and wires.
+type cubeCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *cubeCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X, c.X), c.Y)
+	return nil
+}
+
+// sumCircuit (x*x == y+z) has squareCircuit's constraint count but two publics.
+type sumCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+	Z frontend.Variable `gnark:",public"`
+}
+
+func (c *sumCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X), api.Add(c.Y, c.Z))
+	return nil
+}
+
+// commitCircuit is squareCircuit with a BSB22 commitment, like vw0w1Circuit.
+type commitCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *commitCircuit) Define(api frontend.API) error {
+	cmt, err := api.(frontend.Committer).Commit(c.X, c.Y)
+	if err != nil {
+		return err
+	}
+	api.AssertIsDifferent(cmt, 0)
+	api.AssertIsEqual(api.Mul(c.X, c.X), c.Y)
+	return nil
+}
+
+// saveTinySetup compiles circuit, runs a setup and saves it to a fresh dir.
+func saveTinySetup(t *testing.T, circuit frontend.Circuit) string {
+	t.Helper()
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, circuit)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	dir := t.TempDir()
+	if err := SaveSetupFiles(ccs, pk, vk, dir); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	return dir
+}
+
+func TestOpenSetup_RejectsMixedCircuits(t *testing.T) {
+	square := saveTinySetup(t, &squareCircuit{})
+	cube := saveTinySetup(t, &cubeCircuit{})
+	sum := saveTinySetup(t, &sumCircuit{})
+
+	for _, dir := range []string{square, saveTinySetup(t, &commitCircuit{})} {
+		if _, err := OpenSetup(dir); err != nil {
+			t.Fatalf("matching setup should load: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name        string
+		ccs, pk, vk string
+		want        string
+	}{
+		{"ccs from another circuit", cube, square, square, "constraints"},
+		{"pk from another circuit", square, cube, square, "constraints"},
+		{"vk from another circuit", square, square, sum, "public inputs"},
+	}
+	for _, tc := range cases {
+		mixed := t.TempDir()
+		for name, src := range map[string]string{"ccs.bin": tc.ccs, "pk.bin": tc.pk, "vk.bin": tc.vk} {
+			if err := os.WriteFile(filepath.Join(mixed, name), mustReadFile(t, filepath.Join(src, name)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := OpenSetup(mixed)
+		if !errors.Is(err, ErrSetupMismatch) || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected ErrSetupMismatch mentioning %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+// mixKeyPair writes ccs.bin and pk.bin from pkDir and vk.bin from vkDir
+// into a new directory.
+func mixKeyPair(t *testing.T, pkDir, vkDir string) string {
+	t.Helper()
+	mixed := t.TempDir()
+	for name, src := range map[string]string{"ccs.bin": pkDir, "pk.bin": pkDir, "vk.bin": vkDir} {
+		if err := os.WriteFile(filepath.Join(mixed, name), mustReadFile(t, filepath.Join(src, name)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mixed
+}
+
+func TestCheckKeyPair_DetectsMixedSetups(t *testing.T) {
+	a := saveTinySetup(t, &commitCircuit{})
+	b := saveTinySetup(t, &commitCircuit{})
+
+	h, err := OpenSetup(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks, err := CheckKeyPair(h, &commitCircuit{X: 3, Y: 9})
+	if err != nil {
+		t.Fatalf("matching pair: %v", err)
+	}
+	if len(checks) != 3 {
+		t.Fatalf("expected 3 checks, got %+v", checks)
+	}
+
+	// Same circuit, so the sizes agree and the mixed directory loads.
+	h, err = OpenSetup(mixKeyPair(t, a, b))
+	if err != nil {
+		t.Fatalf("mixed setup of one circuit should load: %v", err)
+	}
+	checks, err = CheckKeyPair(h, &commitCircuit{X: 3, Y: 9})
+	if !errors.Is(err, ErrKeyPairMismatch) {
+		t.Fatalf("expected ErrKeyPairMismatch, got %v", err)
+	}
+	if last := checks[len(checks)-1]; last.Name != "shared elements" || last.Err == nil {
+		t.Fatalf("expected the shared elements check to fail, got %+v", checks)
+	}
+
+	// With the shared elements patched to agree, the test proof still fails.
+	h.PK.(*groth16bls.ProvingKey).G1.Alpha = h.VK.(*groth16bls.VerifyingKey).G1.Alpha
+	h.PK.(*groth16bls.ProvingKey).G1.Beta = h.VK.(*groth16bls.VerifyingKey).G1.Beta
+	h.PK.(*groth16bls.ProvingKey).G1.Delta = h.VK.(*groth16bls.VerifyingKey).G1.Delta
+	h.PK.(*groth16bls.ProvingKey).G2.Beta = h.VK.(*groth16bls.VerifyingKey).G2.Beta
+	h.PK.(*groth16bls.ProvingKey).G2.Delta = h.VK.(*groth16bls.VerifyingKey).G2.Delta
+	checks, err = CheckKeyPair(h, &commitCircuit{X: 3, Y: 9})
+	if !errors.Is(err, ErrKeyPairMismatch) {
+		t.Fatalf("expected ErrKeyPairMismatch from the test proof, got %v", err)
+	}
+	if last := checks[len(checks)-1]; last.Name != "test proof" || last.Err == nil {
+		t.Fatalf("expected the test proof check to fail, got %+v", checks)
+	}
+}
+
+func TestWriteArtifacts_OutputFormats(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &commitCircuit{})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	h := &SetupHandle{CCS: ccs, PK: pk, VK: vk}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatalf("prove: %v", err)
+	}
+
+	cases := []struct {
+		opts ProveOptions
+		want []string
+	}{
+		{ProveOptions{}, []string{"proof.bin", "proof.json", "public.json", "vk.bin", "vk.json", "witness.bin"}},
+		{ProveOptions{Format: FormatJSON}, []string{"proof.json", "public.json", "vk.json"}},
+		{ProveOptions{Format: FormatJSON, Export: ExportOptions{BundleOnly: true}}, []string{"all.json"}},
+		{ProveOptions{Format: FormatBin}, []string{"proof.bin", "vk.bin", "witness.bin"}},
+	}
+	for _, tc := range cases {
+		dir := filepath.Join(t.TempDir(), "out")
+		if err := checkArtifacts(dir, tc.opts); err != nil {
+			t.Fatalf("%+v: checkArtifacts: %v", tc.opts, err)
+		}
+		if err := writeArtifacts(&SetupHandle{CCS: ccs, VK: vk}, proof, publicWitness, dir, tc.opts); err != nil {
+			t.Fatalf("%+v: writeArtifacts: %v", tc.opts, err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Name())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%+v: wrote %v, want %v", tc.opts, got, tc.want)
+		}
+
+		// The overwrite check covers exactly the files of the chosen format.
+		if err := checkArtifacts(dir, tc.opts); err == nil {
+			t.Fatalf("%+v: expected an overwrite error on the second run", tc.opts)
+		}
+	}
+
+	// JSON-only output does not trip over binaries left by another run, and vice versa.
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := writeArtifacts(&SetupHandle{CCS: ccs, VK: vk}, proof, publicWitness, dir, ProveOptions{Format: FormatBin}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkArtifacts(dir, ProveOptions{Format: FormatJSON}); err != nil {
+		t.Fatalf("json after bin: %v", err)
+	}
+}
+
+func TestCheckArtifacts_RejectsBadFormats(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkArtifacts(dir, ProveOptions{Format: FormatBin, Export: ExportOptions{Bundle: true}}); err == nil {
+		t.Fatal("expected bin + Bundle to be rejected")
+	}
+	if err := checkArtifacts(dir, ProveOptions{Format: "xml"}); err == nil || !strings.Contains(err.Error(), "unknown output format") {
+		t.Fatalf("expected unknown format error, got %v", err)
+	}
+	for _, s := range []string{"", "both", "json", "bin"} {
+		if _, err := ParseOutputFormat(s); err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+	}
+}
+
+// ---------- proving server ----------
+
+// newTinyProveServer serves commitCircuit proofs: the request's a is x and
+// y = x*x, so the handler runs end to end without the vw0w1 setup.
+func newTinyProveServer(t *testing.T, maxConcurrent int) *ProveServer {
+	t.Helper()
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &commitCircuit{})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	srv := NewProveServer(&SetupHandle{CCS: ccs, PK: pk, VK: vk}, maxConcurrent, ProveOptions{})
+	srv.assign = func(req ProveRequest) (frontend.Circuit, error) {
+		x, ok := new(big.Int).SetString(req.A, 0)
+		if !ok {
+			return nil, fmt.Errorf("could not parse a")
+		}
+		return &commitCircuit{X: x, Y: new(big.Int).Mul(x, x)}, nil
+	}
+	return srv
+}
+
+func postProve(t *testing.T, client *http.Client, url, body string) (int, []byte) {
+	t.Helper()
+	resp, err := client.Post(url+"/prove", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /prove: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, b
+}
+
+func TestProveServer_ProveEndpoint(t *testing.T) {
+	ts := httptest.NewServer(newTinyProveServer(t, 2).Handler())
+	defer ts.Close()
+
+	code, body := postProve(t, ts.Client(), ts.URL, `{"a": "3", "r": "1", "v": "", "w0": "", "w1": ""}`)
+	if code != http.StatusOK {
+		t.Fatalf("want 200 got %d: %s", code, body)
+	}
+	var resp ProveResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.CommitmentWire == "" || resp.Public.CommitmentWire != resp.CommitmentWire {
+		t.Fatalf("missing commitment wire: %+v", resp)
+	}
+	if want := []string{"1", "9"}; !reflect.DeepEqual(resp.Public.Inputs, want) {
+		t.Fatalf("public inputs %v, want %v", resp.Public.Inputs, want)
+	}
+	wire, err := CommitmentWireFromJSON(resp.Proof, resp.Public, CommitmentWireOptions{})
+	if err != nil || wire != resp.CommitmentWire {
+		t.Fatalf("returned wire %s does not match the proof (%s, %v)", resp.CommitmentWire, wire, err)
+	}
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"a": "zz"}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+		{`{"a": "3", "extra": 1}`, http.StatusBadRequest},
+	} {
+		if code, body := postProve(t, ts.Client(), ts.URL, tc.body); code != tc.want {
+			t.Fatalf("%s: want %d got %d: %s", tc.body, tc.want, code, body)
+		}
+	}
+
+	resp2, err := ts.Client().Get(ts.URL + "/prove")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET /prove: want 405 got %d", resp2.StatusCode)
+	}
+}
+
+func TestProveServer_DefaultAssignRejectsBadInput(t *testing.T) {
+	srv := NewProveServer(&SetupHandle{}, 1, ProveOptions{})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	g := g1HexFromAffine(ScalarBaseMulG1(big.NewInt(2)))
+	for _, body := range []string{
+		`{"a": "0", "r": "1", "v": "` + g + `", "w0": "` + g + `", "w1": "` + g + `"}`,
+		`{"a": "3", "r": "x", "v": "` + g + `", "w0": "` + g + `", "w1": "` + g + `"}`,
+		`{"a": "3", "r": "5", "v": "` + g + `", "w0": "` + g + `", "w1": "` + g + `"}`, // fails the pre-flight
+	} {
+		code, resp := postProve(t, ts.Client(), ts.URL, body)
+		if code != http.StatusBadRequest || !strings.Contains(string(resp), `"error"`) {
+			t.Fatalf("%s: want 400 with an error, got %d: %s", body, code, resp)
+		}
+	}
+}
+
+func TestProveServer_WaitsForASlot(t *testing.T) {
+	srv := newTinyProveServer(t, 1)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	// Occupy the only slot: the request must wait, and give up with its client.
+	srv.sem <- struct{}{}
+	client := &http.Client{Timeout: 200 * time.Millisecond}
+	if _, err := client.Post(ts.URL+"/prove", "application/json", strings.NewReader(`{"a": "3"}`)); err == nil {
+		t.Fatal("expected the request to block while the slot is taken")
+	}
+	<-srv.sem
+
+	if code, body := postProve(t, ts.Client(), ts.URL, `{"a": "3"}`); code != http.StatusOK {
+		t.Fatalf("after the slot is freed: want 200 got %d: %s", code, body)
+	}
+}
+
+// scrapeMetric returns the value of name from GET /metrics.
+func scrapeMetric(t *testing.T, client *http.Client, url, name string) int64 {
+	t.Helper()
+	resp, err := client.Get(url + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if v, ok := strings.CutPrefix(line, name+" "); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				t.Fatalf("metric %s: %v", name, err)
+			}
+			return n
+		}
+	}
+	t.Fatalf("metric %s missing from:\n%s", name, b)
+	return 0
+}
+
+func TestProveServer_QueuesBeyondMaxConcurrent(t *testing.T) {
+	const n = 2
+	srv := newTinyProveServer(t, n)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	// Hold all n slots, as n running proofs would.
+	for i := 0; i < n; i++ {
+		srv.sem <- struct{}{}
+	}
+	done := make(chan int, 1)
+	go func() {
+		resp, err := ts.Client().Post(ts.URL+"/prove", "application/json", strings.NewReader(`{"a": "3"}`))
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+
+	// The (n+1)th request must queue rather than prove.
+	deadline := time.Now().Add(5 * time.Second)
+	for scrapeMetric(t, ts.Client(), ts.URL, "snark_prove_queued") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("request never showed up as queued")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case code := <-done:
+		t.Fatalf("request finished with %d while every slot was taken", code)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if got := scrapeMetric(t, ts.Client(), ts.URL, "snark_prove_in_flight"); got != 0 {
+		t.Fatalf("in flight: want 0 got %d", got)
+	}
+
+	<-srv.sem
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("after a slot is freed: want 200 got %d", code)
+	}
+	for i := 0; i < n-1; i++ {
+		<-srv.sem
+	}
+
+	if code, _ := postProve(t, ts.Client(), ts.URL, `not json`); code != http.StatusBadRequest {
+		t.Fatalf("bad body: want 400 got %d", code)
+	}
+	for name, want := range map[string]int64{
+		"snark_prove_queued":         0,
+		"snark_prove_in_flight":      0,
+		"snark_prove_requests_total": 2,
+		"snark_prove_failures_total": 1,
+		"snark_prove_max_concurrent": n,
+	} {
+		if got := scrapeMetric(t, ts.Client(), ts.URL, name); got != want {
+			t.Errorf("%s: want %d got %d", name, want, got)
+		}
+	}
+}
+
+func TestProveServer_CacheSkipsRepeatedProofs(t *testing.T) {
+	srv := newTinyProveServer(t, 1)
+	srv.cache = newProofCache(4)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	code, first := postProve(t, ts.Client(), ts.URL, `{"a": "3"}`)
+	if code != http.StatusOK {
+		t.Fatalf("first: want 200 got %d: %s", code, first)
+	}
+	code, second := postProve(t, ts.Client(), ts.URL, `{"a": "0x03"}`) // same witness, other spelling
+	if code != http.StatusOK {
+		t.Fatalf("second: want 200 got %d: %s", code, second)
+	}
+	if !bytes.Equal(first, second) {
+		t.Fatal("cached response differs from the original")
+	}
+	if got := scrapeMetric(t, ts.Client(), ts.URL, "snark_proofs_total"); got != 1 {
+		t.Fatalf("repeated statement was proved again: %d proofs", got)
+	}
+	if got := scrapeMetric(t, ts.Client(), ts.URL, "snark_prove_cache_hits_total"); got != 1 {
+		t.Fatalf("cache hits: want 1 got %d", got)
+	}
+
+	if code, body := postProve(t, ts.Client(), ts.URL, `{"a": "4"}`); code != http.StatusOK {
+		t.Fatalf("new statement: want 200 got %d: %s", code, body)
+	}
+	if got := scrapeMetric(t, ts.Client(), ts.URL, "snark_proofs_total"); got != 2 {
+		t.Fatalf("new statement: want 2 proofs got %d", got)
+	}
+}
+
+func TestProofCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newProofCache(2)
+	k := func(a string) [32]byte { return proofCacheKey(ProveRequest{A: a}) }
+	c.put(k("1"), ProveResponse{CommitmentWire: "1"})
+	c.put(k("2"), ProveResponse{CommitmentWire: "2"})
+	if _, ok := c.get(k("1")); !ok { // 1 is now the most recent
+		t.Fatal("1 missing")
+	}
+	c.put(k("3"), ProveResponse{CommitmentWire: "3"})
+	if _, ok := c.get(k("2")); ok {
+		t.Fatal("2 should have been evicted")
+	}
+	for _, a := range []string{"1", "3"} {
+		if resp, ok := c.get(k(a)); !ok || resp.CommitmentWire != a {
+			t.Fatalf("%s: got %+v, %v", a, resp, ok)
+		}
+	}
+
+	if newProofCache(0) != nil {
+		t.Fatal("size 0 should disable the cache")
+	}
+	var off *proofCache
+	off.put(k("1"), ProveResponse{})
+	if _, ok := off.get(k("1")); ok {
+		t.Fatal("disabled cache returned a hit")
+	}
+}
+
+func TestProveServer_ReadyzAfterLoad(t *testing.T) {
+	tiny := newTinyProveServer(t, 1)
+	srv := NewProveServer(nil, 1, ProveOptions{})
+	srv.assign = tiny.assign
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	get := func(path string) int {
+		t.Helper()
+		resp, err := ts.Client().Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Fatalf("healthz while loading: want 200 got %d", code)
+	}
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz while loading: want 503 got %d", code)
+	}
+	if code, body := postProve(t, ts.Client(), ts.URL, `{"a": "3"}`); code != http.StatusServiceUnavailable {
+		t.Fatalf("prove while loading: want 503 got %d: %s", code, body)
+	}
+
+	srv.SetHandle(tiny.handle.Load())
+	if code := get("/readyz"); code != http.StatusOK {
+		t.Fatalf("readyz after load: want 200 got %d", code)
+	}
+	if code, body := postProve(t, ts.Client(), ts.URL, `{"a": "3"}`); code != http.StatusOK {
+		t.Fatalf("prove after load: want 200 got %d: %s", code, body)
+	}
+}
+
+func TestServe_GracefulShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, ln, newTinyProveServer(t, 1).Handler(), time.Second) }()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/healthz")
+	if err != nil {
+		t.Fatalf("healthz: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("healthz: want 200 got %d", resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancel")
+	}
+}
+
+// ---------- canonical public-input order ----------
+
+func TestVW0W1PublicInputNames(t *testing.T) {
+	names, err := VW0W1PublicInputNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 36 || names[0] != "vx_Limbs_0" || names[6] != "vy_Limbs_0" || names[35] != "w1y_Limbs_5" {
+		t.Fatalf("unexpected public variable order: %v", names)
+	}
+}
+
+func TestCheckVW0W1PublicOrder_DetectsShuffle(t *testing.T) {
+	v := g1HexFromAffine(ScalarBaseMulG1(big.NewInt(2)))
+	w0 := g1HexFromAffine(ScalarBaseMulG1(big.NewInt(3)))
+	w1 := g1HexFromAffine(ScalarBaseMulG1(big.NewInt(5)))
+	inputs, err := CanonicalVW0W1PublicInputs(v, w0, w1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckVW0W1PublicOrder(inputs, v, w0, w1); err != nil {
+		t.Fatalf("canonical inputs rejected: %v", err)
+	}
+
+	// vx's low limb swapped with w0x's low limb.
+	shuffled := append(fr.Vector(nil), inputs...)
+	shuffled[0], shuffled[12] = shuffled[12], shuffled[0]
+	err = CheckVW0W1PublicOrder(shuffled, v, w0, w1)
+	if !errors.Is(err, ErrPublicInputOrder) || !strings.Contains(err.Error(), "w0x_Limbs_0") {
+		t.Fatalf("shuffle not detected: %v", err)
+	}
+
+	// Same values, other points.
+	if err := CheckVW0W1PublicOrder(inputs, w0, v, w1); !errors.Is(err, ErrPublicInputOrder) {
+		t.Fatalf("swapped points not detected: %v", err)
+	}
+	if err := CheckVW0W1PublicOrder(inputs[1:], v, w0, w1); !errors.Is(err, ErrPublicInputOrder) {
+		t.Fatalf("short vector not detected: %v", err)
+	}
+}
+
+// ---------- ccs-info ----------
+
+func TestSummarizeCCS_MatchesVKCommitted(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &commitCircuit{})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	_, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	s, err := SummarizeCCS(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.PublicVariables != 2 || s.SecretVariables != 1 || len(s.Commitments) != 1 {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+	want := vk.(*groth16bls.VerifyingKey).PublicAndCommitmentCommitted[0]
+	if !reflect.DeepEqual(s.Commitments[0].PublicCommitted, want) {
+		t.Fatalf("publicCommitted %v, vk has %v", s.Commitments[0].PublicCommitted, want)
+	}
+
+	plain, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx, err := committedPublicIndices(plain); err != nil || idx != nil {
+		t.Fatalf("circuit without commitments: %v, %v", idx, err)
+	}
+}
+
+// TestSummarizeVW0W1_CommitsEveryPublicInput pins the assumption behind
+// ComputeCommitmentWireNoVK's default. Compiling the circuit takes a while.
+func TestSummarizeVW0W1_CommitsEveryPublicInput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles the vw0w1 circuit")
+	}
+	s, err := SummarizeVW0W1("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.PublicVariables != 37 || len(s.Commitments) != 1 {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+	if got := s.Commitments[0].PublicCommitted; !reflect.DeepEqual(got, allCommittedIndices(36)) {
+		t.Fatalf("publicCommitted %v, want 1..36", got)
+	}
+}
+
+// wasmResultFixture proves commitCircuit with the setup in dir and writes the
+// result the way wasmProve shapes it: {proof, public} with the one-wire and
+// the commitment wire computed without a VK.
+func wasmResultFixture(t *testing.T, dir string) ExternalProof {
+	t.Helper()
+	h, err := OpenSetup(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pj, err := exportProofBLS(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubRaw, err := exportPublicInputs(publicWitness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubFr, err := witnessFrElements(publicWitness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err := ComputeCommitmentWireNoVK(proof.(*groth16bls.Proof), pubFr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ExternalProof{Proof: pj, Public: PublicJSON{Inputs: append([]string{"1"}, pubRaw...), CommitmentWire: wire}}
+}
+
+// TestVerifyExternalProof_OutFixture verifies the ../out proof as a browser
+// proof: vk.json stands in for the ceremony vk.bin.
+func TestVerifyExternalProof_OutFixture(t *testing.T) {
+	var vkj VKJSON
+	if err := readJSONFile(filepath.Join("..", "out", "vk.json"), &vkj); err != nil {
+		t.Skipf("no proof artifacts in ../out: %v", err)
+	}
+	ext, err := LoadExternalProof("", filepath.Join("..", "out", "proof.json"), filepath.Join("..", "out", "public.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vk, err := vkFromJSON(vkj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "vk.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vk.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if err := VerifyExternalProof(dir, ext, VerifyOptions{}); err != nil {
+		t.Fatalf("fixture proof rejected: %v", err)
+	}
+	ext.Public.Inputs = append([]string(nil), ext.Public.Inputs...)
+	ext.Public.Inputs[1], ext.Public.Inputs[2] = ext.Public.Inputs[2], ext.Public.Inputs[1]
+	if err := VerifyExternalProof(dir, ext, VerifyOptions{}); err == nil {
+		t.Fatal("tampered public inputs accepted")
+	}
+}
+
+// ---------- leading one-wire ----------
+
+func TestDropLeadingWire(t *testing.T) {
+	vec := func(xs ...uint64) fr.Vector {
+		v := make(fr.Vector, len(xs))
+		for i, x := range xs {
+			v[i].SetUint64(x)
+		}
+		return v
+	}
+	cases := []struct {
+		name    string
+		in      fr.Vector
+		mode    LeadingWire
+		want    fr.Vector
+		wantErr bool
+	}{
+		{"includes 1, declared", vec(1, 5, 6), LeadingWireIncluded, vec(5, 6), false},
+		{"includes 1, auto", vec(1, 5, 6), LeadingWireAuto, vec(5, 6), false},
+		{"includes 1, declared excluded", vec(1, 5, 6), LeadingWireExcluded, nil, true},
+		{"excludes it, declared", vec(5, 6), LeadingWireExcluded, vec(5, 6), false},
+		{"excludes it, auto", vec(5, 6), LeadingWireAuto, vec(5, 6), false},
+		{"excludes it, declared included", vec(5, 6), LeadingWireIncluded, nil, true},
+		{"first input is 1, declared excluded", vec(1, 6), LeadingWireExcluded, vec(1, 6), false},
+		{"declared included but starts with 0", vec(0, 5, 6), LeadingWireIncluded, nil, true},
+		{"too long for auto", vec(2, 5, 6), LeadingWireAuto, nil, true},
+	}
+	for _, tc := range cases {
+		got, err := dropLeadingWire(tc.in, 2, tc.mode)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+		if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: got %v want %v", tc.name, got, tc.want)
+		}
+	}
+
+	for s, want := range map[string]LeadingWire{"": LeadingWireAuto, "auto": LeadingWireAuto, "include": LeadingWireIncluded, "exclude": LeadingWireExcluded} {
+		if got, err := ParseLeadingWire(s); err != nil || got != want {
+			t.Fatalf("ParseLeadingWire(%q) = %q, %v", s, got, err)
+		}
+	}
+	if _, err := ParseLeadingWire("yes"); err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+}
+
+func TestVerifyExternalProof_LeadingWireModes(t *testing.T) {
+	dir := saveTinySetup(t, &commitCircuit{})
+	with := wasmResultFixture(t, dir)
+	without := with
+	without.Public.Inputs = with.Public.Inputs[1:]
+
+	for _, tc := range []struct {
+		name string
+		ext  ExternalProof
+		mode LeadingWire
+		ok   bool
+	}{
+		{"includes leading 1", with, LeadingWireIncluded, true},
+		{"includes leading 1, auto", with, LeadingWireAuto, true},
+		{"includes leading 1, declared excluded", with, LeadingWireExcluded, false},
+		{"excludes it", without, LeadingWireExcluded, true},
+		{"excludes it, auto", without, LeadingWireAuto, true},
+		{"excludes it, declared included", without, LeadingWireIncluded, false},
+	} {
+		err := VerifyExternalProof(dir, tc.ext, VerifyOptions{LeadingWire: tc.mode})
+		if (err == nil) != tc.ok {
+			t.Fatalf("%s: err = %v", tc.name, err)
+		}
+	}
+}
+
+// ---------- blake2b224 ----------
+
+// Expected digests are from hashlib.blake2b(data, digest_size=28), as in
+// src/hashing.py.
+func TestBlake2b224Hex_MatchesPython(t *testing.T) {
+	for _, tc := range []struct {
+		data    string
+		withTag bool
+		want    string
+	}{
+		{"", false, "836cc68931c2e4e3e838602eca1902591d216837bafddfe6f0c8cb07"},
+		{"deadbeef", false, "5f46d7914d0bb56a82c0e8627504499a5d9a2521338857fbe212b122"},
+		{"deadbeef", true, "8daf85a2ba90c670b991b93eea13afdb5823572f506d95b09529903b"},
+	} {
+		data, _ := hex.DecodeString(tc.data)
+		got, err := Blake2b224Hex(data, tc.withTag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Fatalf("%q tag=%v: got %s want %s", tc.data, tc.withTag, got, tc.want)
+		}
+	}
+}
+
+// Expected SHA-224 digests are from hashlib.sha224. Each must differ from the
+// blake2b-224 digest of the same input.
+func TestDigest224Hex_SHA224MatchesPython(t *testing.T) {
+	for _, tc := range []struct {
+		data    string
+		withTag bool
+		want    string
+	}{
+		{"", false, "d14a028c2a3a2bc9476102bb288234c415a2b01f828ea62ac5b3e42f"},
+		{"deadbeef", false, "55b9eee5f60cc362ddc07676f620372611e22272f60fdbec94f243f8"},
+		{"deadbeef", true, "305e0b34bf65f4df427bf90e9f72fc5e1162527f8cb63444e4c913d0"},
+	} {
+		data, _ := hex.DecodeString(tc.data)
+		got, err := Digest224Hex(DigestSHA224, data, tc.withTag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Fatalf("%q tag=%v: got %s want %s", tc.data, tc.withTag, got, tc.want)
+		}
+		b2, err := Digest224Hex(DigestBlake2b224, data, tc.withTag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b2 == got {
+			t.Fatalf("%q tag=%v: blake2b-224 and sha256-224 agree", tc.data, tc.withTag)
+		}
+	}
+	if _, err := ParseDigestAlgo("md5"); err == nil {
+		t.Fatal("unknown algorithm should be rejected")
+	}
+}
+
+func TestCheckProvingKeySize(t *testing.T) {
+	if err := checkProvingKeySize(100, 100); err != nil {
+		t.Fatalf("exact size: %v", err)
+	}
+	if err := checkProvingKeySize(100, 0); err != nil {
+		t.Fatalf("unknown size should be accepted: %v", err)
+	}
+	err := checkProvingKeySize(40, 100)
+	if err == nil || err.Error() != "PK truncated: got 40 bytes, expected 100" {
+		t.Fatalf("truncated: %v", err)
+	}
+	if err := checkProvingKeySize(120, 100); err == nil || !strings.Contains(err.Error(), "size mismatch") {
+		t.Fatalf("oversized: %v", err)
+	}
+}
+
+// ---------- setup manifest ----------
+
+func TestSetupManifest_WriteAndVerify(t *testing.T) {
+	dir := saveTinySetup(t, &commitCircuit{})
+	if _, err := VerifySetupManifest(dir); err == nil || errors.Is(err, ErrManifestMismatch) {
+		t.Fatalf("missing manifest: want a read error, got %v", err)
+	}
+	if err := WriteSetupManifest(dir); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadSetupManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ccs.bin", "pk.bin", "vk.bin"} {
+		e, ok := m.Entry(name)
+		if !ok {
+			t.Fatalf("%s missing from manifest", name)
+		}
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := fileHash(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Size != info.Size() || e.SHA256 != want {
+			t.Fatalf("%s: manifest %+v, file has %d bytes sha256=%s", name, e, info.Size(), want)
+		}
+	}
+	checks, err := VerifySetupManifest(dir)
+	if err != nil || len(checks) != 3 {
+		t.Fatalf("fresh setup: %v (%d checks)", err, len(checks))
+	}
+}
+
+func TestSetupManifest_DetectsTamperedFiles(t *testing.T) {
+	dir := saveTinySetup(t, &commitCircuit{})
+	if err := WriteSetupManifest(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip one byte of vk.bin (same size) and cut pk.bin short.
+	vkPath := filepath.Join(dir, "vk.bin")
+	b, err := os.ReadFile(vkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)-1] ^= 0xff
+	if err := os.WriteFile(vkPath, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(filepath.Join(dir, "pk.bin"), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	checks, err := VerifySetupManifest(dir)
+	if !errors.Is(err, ErrManifestMismatch) {
+		t.Fatalf("want ErrManifestMismatch, got %v", err)
+	}
+	got := map[string]string{}
+	for _, c := range checks {
+		if c.Err != nil {
+			got[c.Name] = c.Err.Error()
+		}
+	}
+	if len(got) != 2 || !strings.Contains(got["vk.bin"], "sha256") || !strings.Contains(got["pk.bin"], "size 10") {
+		t.Fatalf("unexpected failures: %v", got)
+	}
+}
+
+func TestCCSHash_StableAndCircuitSensitive(t *testing.T) {
+	hashOf := func(circuit frontend.Circuit) string {
+		t.Helper()
+		ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, circuit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := ccsHash(ccs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	square := hashOf(&squareCircuit{})
+	if again := hashOf(&squareCircuit{}); again != square {
+		t.Fatalf("hash changed between compilations: %s vs %s", square, again)
+	}
+	if cube := hashOf(&cubeCircuit{}); cube == square {
+		t.Fatal("different circuits share a hash")
+	}
+
+	// ccs.bin holds exactly the bytes ccsHash hashes.
+	got, err := setupCircuitHash(saveTinySetup(t, &squareCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != square {
+		t.Fatalf("setupCircuitHash = %s, ccsHash = %s", got, square)
+	}
+}
+
+// TestSetupHandle_CircuitHashComputedOnce checks that a handle hashes its
+// constraint system on first use and reuses the result for later proofs.
+func TestSetupHandle_CircuitHashComputedOnce(t *testing.T) {
+	compile := func(circuit frontend.Circuit) constraint.ConstraintSystem {
+		t.Helper()
+		ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, circuit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ccs
+	}
+	h := &SetupHandle{CCS: compile(&squareCircuit{})}
+	want, err := ccsHash(h.CCS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := h.circuitHash(); err != nil || got != want {
+		t.Fatalf("circuitHash = %q, %v; want %q", got, err, want)
+	}
+	// A second call must not serialize the constraint system again.
+	h.CCS = compile(&cubeCircuit{})
+	if got, err := h.circuitHash(); err != nil || got != want {
+		t.Fatalf("second circuitHash = %q, %v; want the memoized %q", got, err, want)
+	}
+}
+
+func TestCircuitHash_VW0W1(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles the vw0w1 circuit")
+	}
+	h1, err := CircuitHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ccs, err := CompileVW0W1Circuit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := ccsHash(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h2 || len(h1) != 64 {
+		t.Fatalf("CircuitHash %q, fresh compilation %q", h1, h2)
+	}
+}
+
+func TestSetupManifest_CircuitHash(t *testing.T) {
+	dir := saveTinySetup(t, &commitCircuit{})
+	h, err := OpenSetup(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ccsHash(h.CCS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exportVKOnly(h.VK, dir, want); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSetupManifest(dir); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadSetupManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CircuitHash != want {
+		t.Fatalf("manifest circuitHash %q, want %q", m.CircuitHash, want)
+	}
+	if _, err := VerifySetupManifest(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// A vk.json stamped for another circuit is caught.
+	vkj, err := LoadVK(filepath.Join(dir, "vk.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vkj.CircuitHash = strings.Repeat("00", 32)
+	b, err := json.Marshal(vkj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vk.json"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifySetupManifest(dir); !errors.Is(err, ErrCircuitHashMismatch) || !strings.Contains(err.Error(), "vk.json") {
+		t.Fatalf("want ErrCircuitHashMismatch for vk.json, got %v", err)
+	}
+}
+
+func TestG2ToXYDec_Orders(t *testing.T) {
+	// The G2 generator, from the BLS12-381 specification (refG2X0.. in decimal).
+	const (
+		x0 = "352701069587466618187139116011060144890029952792775240219908644239793785735715026873347600343865175952761926303160"
+		x1 = "3059144344244213709971259814753781636986470325476647558659373206291635324768958432433509563104347017837885763365758"
+		y0 = "1985150602287291935568054521177171638300868978215655730859378665066344726373823718423869104263333984641494340347905"
+		y1 = "927553665492332455747201965776037880757740193453592970025027978793976877002675564980949289727957565575433344219582"
+	)
+	_, _, g1, g2 := bls12381.Generators()
+	if got, want := g2ToXYDec(g2, Fp2OrderC0C1), [2][2]string{{x0, x1}, {y0, y1}}; got != want {
+		t.Fatalf("c0,c1 order: got %v, want %v", got, want)
+	}
+	if got, want := g2ToXYDec(g2, Fp2OrderC1C0), [2][2]string{{x1, x0}, {y1, y0}}; got != want {
+		t.Fatalf("c1,c0 order: got %v, want %v", got, want)
+	}
+	var zero Fp2Order
+	if zero != Fp2OrderC0C1 {
+		t.Fatal("the zero Fp2Order must be gnark's c0,c1 order")
+	}
+
+	gx, _ := new(big.Int).SetString(strings.TrimPrefix(refG1X, "0x"), 16)
+	if got := g1ToXYDec(g1); got[0] != gx.String() {
+		t.Fatalf("G1 x: got %s, want %s", got[0], gx)
+	}
+
+	// -1 mod p must print as p-1, not as gnark's short "-1".
+	var minusOne fp.Element
+	minusOne.SetOne()
+	minusOne.Neg(&minusOne)
+	want := new(big.Int).Sub(fp.Modulus(), big.NewInt(1)).String()
+	if got := fp2Dec(&minusOne, &minusOne, Fp2OrderC0C1); got[0] != want {
+		t.Fatalf("p-1 printed as %s", got[0])
+	}
+}
+
+// decimalVKOf writes vk in the snarkjs decimal-coordinate shape, with the
+// G2 halves in order.
+func decimalVKOf(vk *groth16bls.VerifyingKey, order Fp2Order) DecimalVK {
+	g1 := func(p bls12381.G1Affine) []string {
+		xy := g1ToXYDec(p)
+		return []string{xy[0], xy[1], "1"}
+	}
+	g2 := func(p bls12381.G2Affine) [][]string {
+		xy := g2ToXYDec(p, order)
+		return [][]string{xy[0][:], xy[1][:], {"1", "0"}}
+	}
+	d := DecimalVK{
+		Protocol: "groth16",
+		Curve:    CurveName,
+		NPublic:  len(vk.G1.K) - 1,
+		Alpha1:   g1(vk.G1.Alpha),
+		Beta2:    g2(vk.G2.Beta),
+		Gamma2:   g2(vk.G2.Gamma),
+		Delta2:   g2(vk.G2.Delta),
+	}
+	for _, k := range vk.G1.K {
+		d.IC = append(d.IC, g1(k))
+	}
+	return d
+}
+
+func TestAcceptUncompressedVK_VerifiesAgainstDecimalKey(t *testing.T) {
+	h, err := OpenSetup(saveTinySetup(t, &squareCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&squareCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := SaveNativeFiles(h.VK, proof, publicWitness, dir); err != nil {
+		t.Fatal(err)
+	}
+	vk := h.VK.(*groth16bls.VerifyingKey)
+
+	for _, order := range []Fp2Order{Fp2OrderC0C1, Fp2OrderC1C0} {
+		d := decimalVKOf(vk, order)
+		got, gotOrder, err := vkFromDecimal(d)
+		if err != nil {
+			t.Fatalf("order %d: import: %v", order, err)
+		}
+		if gotOrder != order {
+			t.Fatalf("detected order %d, want %d", gotOrder, order)
+		}
+		if !got.G2.Beta.Equal(&vk.G2.Beta) || !got.G1.Alpha.Equal(&vk.G1.Alpha) || len(got.G1.K) != len(vk.G1.K) {
+			t.Fatalf("order %d: imported key differs from the original", order)
+		}
+
+		vkPath := filepath.Join(t.TempDir(), "verification_key.json")
+		raw, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(vkPath, raw, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyFromFilesWithOptions(dir, VerifyOptions{VKPath: vkPath, AcceptUncompressedVK: true}); err != nil {
+			t.Fatalf("order %d: verify against decimal key: %v", order, err)
+		}
+		if err := VerifyFromFilesWithOptions(dir, VerifyOptions{VKPath: vkPath}); !errors.Is(err, ErrDecimalVK) {
+			t.Fatalf("without AcceptUncompressedVK: got %v, want ErrDecimalVK", err)
+		}
+	}
+
+	// Off-curve, non-canonical and projective points are refused.
+	for name, mutate := range map[string]func(*DecimalVK){
+		"off curve":     func(d *DecimalVK) { d.Alpha1[1] = "1" },
+		"not reduced":   func(d *DecimalVK) { d.IC[0][0] = fp.Modulus().String() },
+		"projective z":  func(d *DecimalVK) { d.IC[1][2] = "2" },
+		"nPublic":       func(d *DecimalVK) { d.NPublic++ },
+		"wrong curve":   func(d *DecimalVK) { d.Curve = "bn128" },
+		"off-curve G2":  func(d *DecimalVK) { d.Delta2[0][0] = "1" },
+		"G2 z not 1, 0": func(d *DecimalVK) { d.Gamma2[2] = []string{"1", "1"} },
+	} {
+		d := decimalVKOf(vk, Fp2OrderC0C1)
+		mutate(&d)
+		if _, _, err := vkFromDecimal(d); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCheckConstants_GeneratorCoordinates(t *testing.T) {
+	_, _, g1, g2 := bls12381.Generators()
+	for _, c := range []struct {
+		name string
+		got  *fp.Element
+		want string
+	}{
+		{"G1.x", &g1.X, refG1X},
+		{"G1.y", &g1.Y, refG1Y},
+		{"G2.x0", &g2.X.A0, refG2X0},
+		{"G2.x1", &g2.X.A1, refG2X1},
+		{"G2.y0", &g2.Y.A0, refG2Y0},
+		{"G2.y1", &g2.Y.A1, refG2Y1},
+	} {
+		var want fp.Element
+		if _, err := want.SetString(c.want); err != nil {
+			t.Fatal(err)
+		}
+		if !c.got.Equal(&want) {
+			t.Fatalf("%s = %s, want %s", c.name, c.got.String(), c.want)
+		}
+	}
+
+	checks, err := CheckConstants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 4 {
+		t.Fatalf("got %d checks", len(checks))
+	}
+	for _, c := range checks {
+		if c.Err != nil {
+			t.Fatalf("%s: %v", c.Name, c.Err)
+		}
+	}
+}
+
+func TestReencodePointHex_RoundTrips(t *testing.T) {
+	_, _, g1, g2 := bls12381.Generators()
+	var p1 bls12381.G1Affine
+	p1.ScalarMultiplication(&g1, big.NewInt(7))
+	var p2 bls12381.G2Affine
+	p2.ScalarMultiplication(&g2, big.NewInt(7))
+	c1, _ := g1CompressedHex(p1)
+	c2, _ := g2CompressedHex(p2)
+
+	for _, tc := range []struct {
+		group, compressed, uncompressed string
+	}{
+		{"g1", c1, g1UncompressedHex(p1)},
+		{"g2", c2, g2UncompressedHex(p2)},
+	} {
+		u, err := ReencodePointHex(tc.group, tc.compressed, encodingUncompressed)
+		if err != nil {
+			t.Fatalf("%s to uncompressed: %v", tc.group, err)
+		}
+		if u != tc.uncompressed {
+			t.Fatalf("%s to uncompressed = %s, want %s", tc.group, u, tc.uncompressed)
+		}
+		c, err := ReencodePointHex(tc.group, "0x"+strings.ToUpper(u), encodingCompressed)
+		if err != nil {
+			t.Fatalf("%s to compressed: %v", tc.group, err)
+		}
+		if c != tc.compressed {
+			t.Fatalf("%s round trip = %s, want %s", tc.group, c, tc.compressed)
+		}
+		if same, err := ReencodePointHex(tc.group, tc.compressed, encodingCompressed); err != nil || same != tc.compressed {
+			t.Fatalf("%s compressed to compressed = %s, %v", tc.group, same, err)
+		}
+	}
+
+	if _, err := ReencodePointHex("g3", c1, encodingCompressed); !errors.Is(err, ErrUnknownEncoding) {
+		t.Fatalf("bad -type: got %v", err)
+	}
+	if _, err := ReencodePointHex("g1", c1, "raw"); !errors.Is(err, ErrUnknownEncoding) {
+		t.Fatalf("bad -to: got %v", err)
+	}
+	if _, err := ReencodePointHex("g2", c1, encodingUncompressed); err == nil {
+		t.Fatal("G1 hex accepted as G2")
+	}
+	bad := []byte(g1UncompressedHex(p1))
+	bad[len(bad)-1] ^= 1
+	if _, err := ReencodePointHex("g1", string(bad), encodingCompressed); err == nil {
+		t.Fatal("off-curve uncompressed point accepted")
+	}
+}
+
+func TestWitnessHash_StableAndDistinct(t *testing.T) {
+	vHex, w0Hex, w1Hex := computeVW0W1(t, big.NewInt(5), big.NewInt(9))
+	h1, err := WitnessHash(vHex, w0Hex, w1Hex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := WitnessHash("0x"+strings.ToUpper(vHex), w0Hex, w1Hex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h2 || len(h1) != 64 {
+		t.Fatalf("identical witnesses hash differently: %s vs %s", h1, h2)
+	}
+
+	// The exported value is recovered from the public witness.
+	pub, err := CanonicalVW0W1PublicInputs(vHex, w0Hex, w1Hex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := witnessHashFromPublic(pub); got != h1 {
+		t.Fatalf("witnessHashFromPublic = %s, want %s", got, h1)
+	}
+	batch := append(append(fr.Vector{}, pub...), pub...)
+	if got := witnessHashFromPublic(batch); got == "" || got == h1 {
+		t.Fatalf("batch witnessHash = %q", got)
+	}
+	if got := witnessHashFromPublic(pub[:len(pub)-1]); got != "" {
+		t.Fatalf("non-vw0w1 vector hashed to %s", got)
+	}
+
+	otherV, otherW0, otherW1 := computeVW0W1(t, big.NewInt(6), big.NewInt(9))
+	h3, err := WitnessHash(otherV, otherW0, otherW1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h3 == h1 {
+		t.Fatal("different witnesses share a witnessHash")
+	}
+	if h4, _ := WitnessHash(vHex, w1Hex, w0Hex); h4 == h1 {
+		t.Fatal("swapping w0 and w1 kept the witnessHash")
+	}
+}
+
+func TestCheckCircuitG1Compression_RejectsWrongEncoding(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &g1CompressionCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, g, _ := bls12381.Generators()
+	var p bls12381.G1Affine
+	p.ScalarMultiplication(&g, big.NewInt(11))
+	want := p.Bytes()
+	if err := solveG1Compression(ccs, p, want); err != nil {
+		t.Fatal(err)
+	}
+	flipped := want
+	flipped[0] ^= 0x20 // sign bit
+	if err := solveG1Compression(ccs, p, flipped); err == nil {
+		t.Fatal("wrong sign bit accepted")
+	}
+	last := want
+	last[len(last)-1] ^= 1
+	if err := solveG1Compression(ccs, p, last); err == nil {
+		t.Fatal("wrong low byte accepted")
+	}
+}
+
+func TestSaveNativeFiles_WritesPublicWitnessOnly(t *testing.T) {
+	setupDir := saveTinySetup(t, &commitCircuit{})
+	h, err := OpenSetup(setupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignment := &commitCircuit{X: 3, Y: 9}
+	proof, _, err := h.proveAssignment(assignment, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := frontend.NewWitness(assignment, ecc.BLS12_381.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Handing over the full witness must still leave X out of witness.bin.
+	out := t.TempDir()
+	if err := SaveNativeFiles(h.VK, proof, full, out); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(filepath.Join(out, "witness.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Header: nbPublic, nbSecret (uint32 big-endian each), then the vector.
+	if nbPublic, nbSecret := binary.BigEndian.Uint32(raw[0:4]), binary.BigEndian.Uint32(raw[4:8]); nbPublic != 1 || nbSecret != 0 {
+		t.Fatalf("witness.bin header: nbPublic=%d nbSecret=%d, want 1 and 0", nbPublic, nbSecret)
+	}
+	if err := VerifyFromFiles(out); err != nil {
+		t.Fatalf("verify from the public-only witness: %v", err)
+	}
+}
+
+func TestCheckConformance_GoldenVectors(t *testing.T) {
+	checks, err := CheckConformance()
+	if err != nil {
+		for _, c := range checks {
+			t.Logf("%s: %v", c.Name, c.Err)
+		}
+		t.Fatal(err)
+	}
+	if len(checks) != len(gtToHashGolden) {
+		t.Fatalf("got %d checks for %d vectors", len(checks), len(gtToHashGolden))
+	}
+
+	// A moved value is reported against its vector.
+	bad := append([]gtToHashVector(nil), gtToHashGolden[:2]...)
+	bad[1].HK = gtToHashGolden[0].HK
+	checks, err = checkGTToHashVectors(bad, 2)
+	if !errors.Is(err, ErrConformanceMismatch) {
+		t.Fatalf("expected ErrConformanceMismatch, got %v", err)
+	}
+	if checks[0].Err != nil || checks[1].Err == nil || !strings.Contains(checks[1].Err.Error(), "hk is "+gtToHashGolden[1].HK) {
+		t.Fatalf("unexpected checks: %+v", checks)
+	}
+}
+
+func TestHashSecretsWithOptions_ParallelMatchesSequential(t *testing.T) {
+	secrets := []string{"1", "0x2", "12345", "not a number", "0", "0xff", "99999999999999999999", "7"}
+	seq, seqFailed := HashSecretsWithOptions(secrets, HashOptions{Jobs: 1})
+	for _, jobs := range []int{0, 3, 16} {
+		par, parFailed := HashSecretsWithOptions(secrets, HashOptions{Jobs: jobs})
+		if !reflect.DeepEqual(par, seq) || !reflect.DeepEqual(parFailed, seqFailed) {
+			t.Fatalf("jobs=%d: output differs from sequential\ngot  %+v %v\nwant %+v %v", jobs, par, parFailed, seq, seqFailed)
+		}
+	}
+	if !reflect.DeepEqual(seqFailed, []int{3, 4}) {
+		t.Fatalf("failed = %v, want [3 4]", seqFailed)
+	}
+	for i, r := range seq {
+		if r.A != secrets[i] {
+			t.Fatalf("result %d is for %q, want %q", i, r.A, secrets[i])
+		}
+	}
+}
+
+func TestHashSecretsWithOptions_DedupeMatchesPlain(t *testing.T) {
+	secrets := []string{"12345", "0x2", "12345", "nope", "0x3039", "2", "nope", "0"}
+	plain, plainFailed := HashSecretsWithOptions(secrets, HashOptions{Jobs: 1})
+	deduped, dedupedFailed := HashSecretsWithOptions(secrets, HashOptions{Jobs: 2, Dedupe: true})
+	if !reflect.DeepEqual(deduped, plain) || !reflect.DeepEqual(dedupedFailed, plainFailed) {
+		t.Fatalf("dedupe changed the output\ngot  %+v %v\nwant %+v %v", deduped, dedupedFailed, plain, plainFailed)
+	}
+
+	// 0x3039 is 12345 and 2 is 0x2, so only four pairings are needed.
+	unique, index := dedupeSecrets(secrets)
+	if !reflect.DeepEqual(unique, []string{"12345", "0x2", "nope", "0"}) || !reflect.DeepEqual(index, []int{0, 1, 0, 2, 0, 1, 2, 3}) {
+		t.Fatalf("dedupeSecrets = %v %v", unique, index)
+	}
+}
+
+func TestDecryptBatch_MatchesDecryptToHash(t *testing.T) {
+	var shared bls12381.G2Affine
+	shared.ScalarMultiplicationBase(big.NewInt(777))
+	sharedHex := g2HexFromAffine(shared)
+	g2b := func(k int64) string {
+		var p bls12381.G2Affine
+		p.ScalarMultiplicationBase(big.NewInt(k))
+		return g2HexFromAffine(p)
+	}
+	var inf bls12381.G1Affine
+
+	entries := []DecryptInputs{
+		{G1b: g1HexFromAffine(g1MulBase(big.NewInt(3))), R1: g1HexFromAffine(g1MulBase(big.NewInt(5)))},
+		{G1b: g1HexFromAffine(g1MulBase(big.NewInt(11))), G2b: g2b(13), R1: g1HexFromAffine(g1MulBase(big.NewInt(17)))},
+		{G1b: g1HexFromAffine(g1MulBase(big.NewInt(19))), R1: g1HexFromAffine(g1MulBase(big.NewInt(23)))},
+		{G1b: g1HexFromAffine(inf), G2b: g2b(29), R1: g1HexFromAffine(g1MulBase(big.NewInt(31)))},
+	}
+	for _, opts := range []DecryptOptions{{}, {DomainTagHex: "abcdef"}} {
+		got, err := DecryptBatchWithOptions(entries, sharedHex, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(entries) {
+			t.Fatalf("got %d keys for %d entries", len(got), len(entries))
+		}
+		for i, e := range entries {
+			want, err := DecryptToHashWithOptions(e.G1b, e.G2b, e.R1, sharedHex, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got[i] != want {
+				t.Fatalf("opts %+v entry %d: batch %s, single %s", opts, i, got[i], want)
+			}
+		}
+	}
+
+	bad := append([]DecryptInputs(nil), entries...)
+	bad[2].R1 = "zzzz"
+	if _, err := DecryptBatch(bad, sharedHex); err == nil || !strings.Contains(err.Error(), "entry 2: parse r1") {
+		t.Fatalf("expected entry 2 parse error, got %v", err)
+	}
+}
+
+func TestGTFromCanonicalBytes_Rejects(t *testing.T) {
+	if _, err := gtFromCanonicalBytes(make([]byte, 12*48-1)); err == nil {
+		t.Fatal("short encoding accepted")
+	}
+	raw := make([]byte, 12*48)
+	fp.Modulus().FillBytes(raw[5*48 : 6*48])
+	if _, err := gtFromCanonicalBytes(raw); err == nil || !strings.Contains(err.Error(), "coefficient 5") {
+		t.Fatalf("non-canonical coefficient accepted: %v", err)
+	}
+}
+
+func TestCheckNonZeroHk(t *testing.T) {
+	for _, hk := range []*big.Int{big.NewInt(0), fr.Modulus(), new(big.Int).Mul(fr.Modulus(), big.NewInt(3))} {
+		err := checkNonZeroHk(hk)
+		if !errors.Is(err, ErrZeroHk) || !strings.Contains(err.Error(), "point at infinity") {
+			t.Fatalf("hk=%s: expected ErrZeroHk, got %v", hk, err)
+		}
+	}
+	for _, a := range []int64{1, 2, 12345} {
+		hk, err := hkScalarFromA(big.NewInt(a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkNonZeroHk(hk); err != nil {
+			t.Fatalf("a=%d rejected: %v", a, err)
+		}
+	}
+}
+
+// goldenVKJSON is a small VK built from generator multiples, so the rendered
+// formats are stable across runs and can be pinned in testdata.
+func goldenVKJSON() VKJSON {
+	return VKJSON{
+		NPublic: 1,
+		VkAlpha: g1Hex(mustG1Base(1)),
+		VkBeta:  g2Hex(mustG2Base(1)),
+		VkGamma: g2Hex(mustG2Base(2)),
+		VkDelta: g2Hex(mustG2Base(3)),
+		VkIC:    []string{g1Hex(mustG1Base(2)), g1Hex(mustG1Base(3))},
+		CommitmentKeys: []CommitmentKeyJSON{
+			{G: g2Hex(mustG2Base(4)), GSigmaNeg: g2Hex(mustG2Base(5))},
+		},
+	}
+}
+
+func TestFormatVK_MatchesGoldenFixtures(t *testing.T) {
+	// vk_datum.golden is also what app/src/vk_convert.py writes for this VK.
+	for _, tc := range []struct {
+		format VKFormat
+		golden string
+	}{
+		{VKFormatAiken, "testdata/vk_aiken.golden"},
+		{VKFormatDatum, "testdata/vk_datum.golden"},
+	} {
+		want, err := os.ReadFile(tc.golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := FormatVK(goldenVKJSON(), tc.format)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s output differs from %s:\n%s", tc.format, tc.golden, got)
+		}
+	}
+}
+
+func TestFormatVK_AikenWithoutCommitmentKeys(t *testing.T) {
+	vkj := goldenVKJSON()
+	vkj.CommitmentKeys = nil
+	out, err := FormatVK(vkj, VKFormatAiken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "\n  commitmentKeys: [],\n}\n") {
+		t.Fatalf("expected an empty commitmentKeys list:\n%s", out)
+	}
+	if _, err := ParseVKFormat("cbor"); err == nil {
+		t.Fatal("unknown format accepted")
+	}
+}
+
+func TestSplitPKRanges(t *testing.T) {
+	shards, err := splitPKRanges(10, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PKShard{
+		{Name: "pk_0000.bin", Offset: 0, Size: 4},
+		{Name: "pk_0001.bin", Offset: 4, Size: 4},
+		{Name: "pk_0002.bin", Offset: 8, Size: 2},
+	}
+	if !reflect.DeepEqual(shards, want) {
+		t.Fatalf("shards = %+v, want %+v", shards, want)
+	}
+	if _, err := splitPKRanges(2, 3); err == nil {
+		t.Fatal("more shards than bytes should fail")
+	}
+	if _, err := splitPKRanges(10, 0); err == nil {
+		t.Fatal("zero shards should fail")
+	}
+}
+
+// TestSaveSetupFiles_PKSplitReassembles writes a tiny setup with a sharded
+// proving key and checks that the shards concatenate to the bytes pk.bin
+// would hold, that the setup loads and proves, and that the manifest covers
+// the shards.
+func TestSaveSetupFiles_PKSplitReassembles(t *testing.T) {
+	h, err := OpenSetup(saveTinySetup(t, &commitCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, raw := range []bool{false, true} {
+		whole := filepath.Join(t.TempDir(), "pk.bin")
+		if err := writeProvingKey(whole, h.PK, raw); err != nil {
+			t.Fatal(err)
+		}
+		want := mustReadFile(t, whole)
+
+		dir := t.TempDir()
+		if err := SaveSetupFilesWithOptions(h.CCS, h.PK, h.VK, dir, SaveOptions{Raw: raw, PKSplit: 3}); err != nil {
+			t.Fatalf("raw=%v: save: %v", raw, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "pk.bin")); !os.IsNotExist(err) {
+			t.Fatalf("raw=%v: pk.bin should not be written with PKSplit, stat err = %v", raw, err)
+		}
+		var got []byte
+		for i := 0; i < 3; i++ {
+			got = append(got, mustReadFile(t, filepath.Join(dir, pkShardName(i)))...)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("raw=%v: shards reassemble to %d bytes that differ from pk.bin (%d bytes)", raw, len(got), len(want))
+		}
+
+		if !SetupFilesExist(dir) {
+			t.Fatalf("raw=%v: SetupFilesExist = false for a sharded setup", raw)
+		}
+		split, err := OpenSetup(dir)
+		if err != nil {
+			t.Fatalf("raw=%v: open sharded setup: %v", raw, err)
+		}
+		if _, _, err := split.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{}); err != nil {
+			t.Fatalf("raw=%v: prove with sharded pk: %v", raw, err)
+		}
+
+		if err := WriteSetupManifest(dir); err != nil {
+			t.Fatal(err)
+		}
+		checks, err := VerifySetupManifest(dir)
+		if err != nil {
+			t.Fatalf("raw=%v: verify manifest: %v", raw, err)
+		}
+		if len(checks) != 6 {
+			t.Fatalf("raw=%v: manifest has %d entries, want ccs, pk_shards.json, 3 shards and vk", raw, len(checks))
+		}
+	}
+}
+
+func TestLoadSetupFiles_PKShardErrors(t *testing.T) {
+	h, err := OpenSetup(saveTinySetup(t, &squareCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := SaveSetupFilesWithOptions(h.CCS, h.PK, h.VK, dir, SaveOptions{PKSplit: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	last := filepath.Join(dir, pkShardName(1))
+	data := mustReadFile(t, last)
+	if err := os.WriteFile(last, data[:len(data)-1], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSetup(dir); err == nil || !strings.Contains(err.Error(), "pk_0001.bin is") {
+		t.Fatalf("truncated shard: err = %v", err)
+	}
+
+	if err := os.Remove(last); err != nil {
+		t.Fatal(err)
+	}
+	if SetupFilesExist(dir) {
+		t.Fatal("SetupFilesExist = true with a shard missing")
+	}
+
+	// Writing the unsplit layout clears the shards.
+	if err := SaveSetupFilesWithOptions(h.CCS, h.PK, h.VK, dir, SaveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if leftover, _ := filepath.Glob(filepath.Join(dir, "pk_*")); len(leftover) != 0 {
+		t.Fatalf("shard files left after an unsplit save: %v", leftover)
+	}
+	if _, err := OpenSetup(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReadBuildInfo checks that the embedded dependency versions are the ones
+// go.mod requires.
+func TestReadBuildInfo(t *testing.T) {
+	bi := ReadBuildInfo()
+	if bi.GoVersion != runtime.Version() {
+		t.Fatalf("go version = %q, want %q", bi.GoVersion, runtime.Version())
+	}
+	gomod := string(mustReadFile(t, "go.mod"))
+	for module, got := range map[string]string{gnarkModule: bi.Gnark, gnarkCryptoModule: bi.GnarkCrypto} {
+		if got == "" || !strings.Contains(gomod, module+" "+got+"\n") {
+			t.Fatalf("%s version = %q, not the one go.mod requires", module, got)
+		}
+	}
+}
+
+// TestVerifyFromFiles_ExternalVK verifies a proof whose directory holds a
+// stale VK from another setup: the colocated VK rejects it, the proving
+// setup's VK given as VKPath (vk.bin or vk.json) accepts it, and the stale VK
+// given as VKPath still rejects it. Both the binary and JSON layouts are
+// covered.
+func TestVerifyFromFiles_ExternalVK(t *testing.T) {
+	canonDir := saveTinySetup(t, &commitCircuit{})
+	staleDir := saveTinySetup(t, &commitCircuit{})
+	h, err := OpenSetup(canonDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := OpenSetup(staleDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportVKOnly(h.VK, canonDir); err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	if err := SaveNativeFiles(stale.VK, proof, publicWitness, out); err != nil {
+		t.Fatal(err)
+	}
+	jsonOut := t.TempDir()
+	if err := ExportAll(stale.VK, proof, publicWitness, jsonOut); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, verify := range map[string]func(string, VerifyOptions) error{
+		"bin":  VerifyFromFilesWithOptions,
+		"json": VerifyJSONFromDirWithOptions,
+	} {
+		dir := out
+		if name == "json" {
+			dir = jsonOut
+		}
+		if err := verify(dir, VerifyOptions{}); err == nil {
+			t.Fatalf("%s: proof verified against the stale colocated VK", name)
+		}
+		for _, vkPath := range []string{filepath.Join(canonDir, "vk.bin"), filepath.Join(canonDir, "vk.json")} {
+			if err := verify(dir, VerifyOptions{VKPath: vkPath}); err != nil {
+				t.Fatalf("%s: verify against %s: %v", name, filepath.Base(vkPath), err)
+			}
+		}
+		if err := verify(dir, VerifyOptions{VKPath: filepath.Join(staleDir, "vk.bin")}); err == nil {
+			t.Fatalf("%s: proof verified against the stale VK given as VKPath", name)
+		}
+	}
+
+	// The proof directory does not need a VK of its own.
+	if err := os.Remove(filepath.Join(out, "vk.bin")); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFromFilesWithOptions(out, VerifyOptions{VKPath: filepath.Join(canonDir, "vk.bin")}); err != nil {
+		t.Fatalf("verify without a colocated vk.bin: %v", err)
+	}
+}
+
+// TestVerifyJSONFromDir_StrictJSON adds a field to proof.json that ProofJSON
+// does not have. Lenient decoding ignores it and the proof verifies; strict
+// decoding rejects the file. A misspelled point name fails either way, but
+// only strict mode names the culprit.
+func TestVerifyJSONFromDir_StrictJSON(t *testing.T) {
+	h, err := OpenSetup(saveTinySetup(t, &commitCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ExportAllWithOptions(h.VK, proof, publicWitness, dir, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyJSONFromDirWithOptions(dir, VerifyOptions{StrictJSON: true}); err != nil {
+		t.Fatalf("strict verify of freshly exported artifacts: %v", err)
+	}
+
+	proofPath := filepath.Join(dir, "proof.json")
+	original := mustReadFile(t, proofPath)
+	rewrite := func(edit func(map[string]any)) {
+		t.Helper()
+		var m map[string]any
+		if err := json.Unmarshal(original, &m); err != nil {
+			t.Fatal(err)
+		}
+		edit(m)
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(proofPath, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rewrite(func(m map[string]any) { m["note"] = "extra" })
+	if err := VerifyJSONFromDirWithOptions(dir, VerifyOptions{}); err != nil {
+		t.Fatalf("lenient verify with an extra field: %v", err)
+	}
+	err = VerifyJSONFromDirWithOptions(dir, VerifyOptions{StrictJSON: true})
+	if err == nil || !strings.Contains(err.Error(), `unknown field "note"`) {
+		t.Fatalf("strict verify with an extra field: err = %v", err)
+	}
+
+	rewrite(func(m map[string]any) { m["piAA"] = m["piA"]; delete(m, "piA") })
+	if err := VerifyJSONFromDirWithOptions(dir, VerifyOptions{}); err == nil {
+		t.Fatal("lenient verify with piA misspelled should still fail")
+	}
+	err = VerifyJSONFromDirWithOptions(dir, VerifyOptions{StrictJSON: true})
+	if err == nil || !strings.Contains(err.Error(), `unknown field "piAA"`) {
+		t.Fatalf("strict verify with piA misspelled: err = %v", err)
+	}
+}
+
+// writeMixedProofDirs lays out a parent directory with two valid proofs
+// (a_ok binary, c_ok JSON), two invalid ones (b_bad: proof checked against a
+// VK from another setup, d_bad: unparsable proof.json), and a subdirectory
+// and a file that are not proofs.
+func writeMixedProofDirs(t *testing.T) string {
+	t.Helper()
+	h, err := OpenSetup(saveTinySetup(t, &commitCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := OpenSetup(saveTinySetup(t, &commitCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := t.TempDir()
+	sub := func(name string) string { return filepath.Join(parent, name) }
+	if err := SaveNativeFiles(h.VK, proof, publicWitness, sub("a_ok")); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveNativeFiles(other.VK, proof, publicWitness, sub("b_bad")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportAllWithOptions(h.VK, proof, publicWitness, sub("c_ok"), ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportAllWithOptions(h.VK, proof, publicWitness, sub("d_bad"), ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub("d_bad"), "proof.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(sub("notes"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sub("README"), []byte("not a proof"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return parent
+}
+
+func TestVerifyEach_ContinueOnError(t *testing.T) {
+	parent := writeMixedProofDirs(t)
+
+	results, err := VerifyEach(parent, VerifyEachOptions{ContinueOnError: true})
+	if !errors.Is(err, ErrProofsFailed) || !strings.Contains(err.Error(), "2 of 4") {
+		t.Fatalf("err = %v, want ErrProofsFailed with 2 of 4", err)
+	}
+	var names, failed []string
+	for _, r := range results {
+		names = append(names, r.Name)
+		if r.Err != nil {
+			failed = append(failed, r.Name)
+		}
+	}
+	if want := []string{"a_ok", "b_bad", "c_ok", "d_bad"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("verified %v, want %v", names, want)
+	}
+	if want := []string{"b_bad", "d_bad"}; !reflect.DeepEqual(failed, want) {
+		t.Fatalf("failed %v, want %v", failed, want)
+	}
+
+	// Without ContinueOnError the run stops at the first failure.
+	results, err = VerifyEach(parent, VerifyEachOptions{})
+	if err == nil || !strings.HasPrefix(err.Error(), "b_bad: ") {
+		t.Fatalf("err = %v, want the b_bad failure", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want to stop after b_bad", len(results))
+	}
+
+	if _, err := VerifyEach(t.TempDir(), VerifyEachOptions{}); err == nil {
+		t.Fatal("a directory without proofs should fail")
+	}
+}
+
+func TestExplainVerification(t *testing.T) {
+	h, err := OpenSetup(saveTinySetup(t, &commitCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	binDir, jsonDir := t.TempDir(), t.TempDir()
+	if err := SaveNativeFiles(h.VK, proof, publicWitness, binDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportAllWithOptions(h.VK, proof, publicWitness, jsonDir, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	bin, err := ExplainFromDir(binDir, false, VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := ExplainFromDir(jsonDir, true, VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, ex := range map[string]*VerifyExplanation{"bin": bin, "json": js} {
+		if !ex.Valid() {
+			t.Fatalf("%s: valid proof explained as invalid (holds=%v pok=%v)", name, ex.Holds, ex.PoKErr)
+		}
+		if len(ex.CommitmentWires) != 1 {
+			t.Fatalf("%s: want 1 commitment wire, got %d", name, len(ex.CommitmentWires))
+		}
+	}
+	if !bin.KSum.Equal(&js.KSum) || !bin.EAB.Equal(&js.EAB) {
+		t.Fatal("binary and JSON artifacts explain differently")
+	}
+	wire, err := computeCommitmentWireFr(proof.(*groth16bls.Proof), h.VK.(*groth16bls.VerifyingKey), bin.PublicInputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bin.CommitmentWires[0].String(); got != wire {
+		t.Fatalf("commitment wire %s, want %s", got, wire)
+	}
+	var text bytes.Buffer
+	bin.WriteText(&text)
+	for _, want := range []string{"vk_x = ", "e(kSum, γ)", "commitment proof of knowledge: ok", "equation holds"} {
+		if !strings.Contains(text.String(), want) {
+			t.Fatalf("explanation lacks %q:\n%s", want, text.String())
+		}
+	}
+
+	// A wrong public input moves vk_x, so the equation no longer holds.
+	wrong := append(fr.Vector{}, bin.PublicInputs...)
+	wrong[0].SetUint64(10)
+	ex, err := ExplainVerification(h.VK.(*groth16bls.VerifyingKey), proof.(*groth16bls.Proof), wrong)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Holds || ex.Valid() {
+		t.Fatal("tampered public input explained as valid")
+	}
+	text.Reset()
+	ex.WriteText(&text)
+	if !strings.Contains(text.String(), "equation does NOT hold") {
+		t.Fatalf("unexpected explanation:\n%s", text.String())
+	}
+	if _, err := ExplainVerification(h.VK.(*groth16bls.VerifyingKey), proof.(*groth16bls.Proof), wrong[:0]); err == nil {
+		t.Fatal("missing public inputs should be an error")
+	}
+}
+
+// writeLegacyArtifacts rewrites the fresh JSON export in dir the way older
+// exporters wrote it: no curve or gnarkCrypto, public inputs as inputsHex
+// without the one-wire, and no commitmentWire.
+func writeLegacyArtifacts(t *testing.T, dir string, pub []fr.Element) {
+	t.Helper()
+	for _, name := range []string{"vk.json", "proof.json"} {
+		var m map[string]any
+		if err := json.Unmarshal(mustReadFile(t, filepath.Join(dir, name)), &m); err != nil {
+			t.Fatal(err)
+		}
+		delete(m, "curve")
+		delete(m, "gnarkCrypto")
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacy := struct {
+		InputsHex []string `json:"inputsHex"`
+	}{}
+	for i := range pub {
+		b := pub[i].Bytes()
+		legacy.InputsHex = append(legacy.InputsHex, hex.EncodeToString(b[:]))
+	}
+	b, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "public.json"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNormalize_UpgradesLegacyArtifacts(t *testing.T) {
+	h, err := OpenSetup(saveTinySetup(t, &commitCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, legacy := t.TempDir(), t.TempDir()
+	for _, dir := range []string{fresh, legacy} {
+		if err := ExportAllWithOptions(h.VK, proof, publicWitness, dir, ExportOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pub, err := witnessFrElements(publicWitness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLegacyArtifacts(t, legacy, pub)
+
+	written, err := Normalize(legacy, NormalizeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"vk.json", "proof.json", "public.json"}; !reflect.DeepEqual(written, want) {
+		t.Fatalf("written = %v, want %v", written, want)
+	}
+	for _, name := range written {
+		if got, want := mustReadFile(t, filepath.Join(legacy, name)), mustReadFile(t, filepath.Join(fresh, name)); !bytes.Equal(got, want) {
+			t.Fatalf("%s after normalize:\n%s\nwant:\n%s", name, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(legacy, "all.json")); !os.IsNotExist(err) {
+		t.Fatalf("normalize added all.json to a separate-files layout: %v", err)
+	}
+
+	// A legacy set that does not verify is left alone.
+	writeLegacyArtifacts(t, legacy, append([]fr.Element{fr.NewElement(10)}, pub[1:]...))
+	before := mustReadFile(t, filepath.Join(legacy, "public.json"))
+	if _, err := Normalize(legacy, NormalizeOptions{}); err == nil || !strings.Contains(err.Error(), "do not verify") {
+		t.Fatalf("want a verification error, got %v", err)
+	}
+	if !bytes.Equal(mustReadFile(t, filepath.Join(legacy, "public.json")), before) {
+		t.Fatal("public.json was rewritten although the artifacts do not verify")
+	}
+}
+
+func TestVW0W1NbPublic(t *testing.T) {
+	names, err := VW0W1PublicInputNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != VW0W1NbPublic {
+		t.Fatalf("vw0w1 declares %d public inputs, VW0W1NbPublic is %d", len(names), VW0W1NbPublic)
+	}
+	if testing.Short() {
+		t.Skip("skipping vw0w1 compile in -short mode")
+	}
+	ccs, err := CompileVW0W1Circuit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := circuitNbPublic(ccs); got != VW0W1NbPublic {
+		t.Fatalf("compiled vw0w1 has %d public inputs, VW0W1NbPublic is %d", got, VW0W1NbPublic)
+	}
+}
+
+func TestExport_NbPublicFromCircuit(t *testing.T) {
+	for name, circuit := range map[string]frontend.Circuit{"commit": &commitCircuit{}, "square": &squareCircuit{}} {
+		h, err := OpenSetup(saveTinySetup(t, circuit))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assignment := map[string]frontend.Circuit{"commit": &commitCircuit{X: 3, Y: 9}, "square": &squareCircuit{X: 3, Y: 9}}[name]
+		proof, publicWitness, err := h.proveAssignment(assignment, ProveOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nb := circuitNbPublic(h.CCS)
+		if nb != 1 {
+			t.Fatalf("%s: circuitNbPublic = %d, want 1", name, nb)
+		}
+
+		// With the count known the layout matches the heuristic's for our circuits.
+		known, guessed := t.TempDir(), t.TempDir()
+		if err := ExportAllWithOptions(h.VK, proof, publicWitness, known, ExportOptions{NbPublic: nb}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := ExportAll(h.VK, proof, publicWitness, guessed); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(mustReadFile(t, filepath.Join(known, "public.json")), mustReadFile(t, filepath.Join(guessed, "public.json"))) {
+			t.Fatalf("%s: public.json differs between the known count and the heuristic", name)
+		}
+
+		// A public vector of the wrong length is rejected against the circuit's count.
+		pub, err := witnessFrElements(publicWitness)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrong, err := publicWitnessFromVector(append(pub, fr.NewElement(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ExportAllWithOptions(h.VK, proof, wrong, t.TempDir(), ExportOptions{NbPublic: nb}); !errors.Is(err, ErrPublicCount) {
+			t.Fatalf("%s: want ErrPublicCount, got %v", name, err)
+		}
+		if err := writeArtifacts(h, proof, wrong, t.TempDir(), ProveOptions{Format: FormatBin}); !errors.Is(err, ErrPublicCount) {
+			t.Fatalf("%s: bin output: want ErrPublicCount, got %v", name, err)
+		}
+
+		// The prove paths stamp vk.json with the circuit hash.
+		out := t.TempDir()
+		if err := writeArtifacts(h, proof, publicWitness, out, ProveOptions{Format: FormatJSON}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var vkj VKJSON
+		if err := readJSONFile(filepath.Join(out, "vk.json"), &vkj); err != nil {
+			t.Fatal(err)
+		}
+		if want, _ := ccsHash(h.CCS); vkj.CircuitHash != want {
+			t.Fatalf("%s: vk.json circuitHash %q, want %q", name, vkj.CircuitHash, want)
+		}
+	}
+}
+
+func TestVerifyProofInMemory_MatchesSequentialPairings(t *testing.T) {
+	h, err := OpenSetup(saveTinySetup(t, &commitCircuit{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, publicWitness, err := h.proveAssignment(&commitCircuit{X: 3, Y: 9}, ProveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vk, proof := h.VK.(*groth16bls.VerifyingKey), p.(*groth16bls.Proof)
+	pub, err := witnessFrElements(publicWitness)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	badC := *proof
+	gen := mustG1Base(1)
+	badC.Krs.Add(&badC.Krs, &gen)
+	wrongPub := append(fr.Vector{}, pub...)
+	wrongPub[0].SetUint64(10)
+
+	cases := []struct {
+		name  string
+		proof *groth16bls.Proof
+		pub   fr.Vector
+		valid bool
+	}{
+		{"valid", proof, pub, true},
+		{"C moved", &badC, pub, false},
+		{"wrong public input", proof, wrongPub, false},
+	}
+	for _, tc := range cases {
+		ex, err := ExplainVerification(vk, tc.proof, tc.pub)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		multi, err := pairingEquationHolds(vk, tc.proof, &ex.KSum)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if multi != ex.Holds || multi != tc.valid {
+			t.Fatalf("%s: multi-pairing %v, sequential %v, want %v", tc.name, multi, ex.Holds, tc.valid)
+		}
+		err = VerifyProofInMemory(vk, tc.proof, tc.pub)
+		if tc.valid && err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !tc.valid && !errors.Is(err, ErrPairingEquation) {
+			t.Fatalf("%s: want ErrPairingEquation, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestCanonicalizePublic_AllCombinations(t *testing.T) {
+	// Three public inputs; the first is 1, so only the length tells a
+	// vector without the one-wire from one with it.
+	dec := []string{"1", "12345", new(big.Int).Sub(fr.Modulus(), big.NewInt(1)).String()}
+	hexOf := func(vals []string) []string {
+		out := make([]string, len(vals))
+		for i, v := range vals {
+			n, _ := new(big.Int).SetString(v, 10)
+			out[i] = "0x" + hex.EncodeToString(n.FillBytes(make([]byte, 32)))
+		}
+		return out
+	}
+	withWire := append([]string{"1"}, dec...)
+	wire, hash := "777", "ab"
+	inputs := map[string]PublicJSON{
+		"decimal with wire":    {Inputs: withWire},
+		"decimal without wire": {Inputs: dec},
+		"hex with wire":        {InputsHex: hexOf(withWire)},
+		"hex without wire":     {InputsHex: hexOf(dec)},
+	}
+	for name, in := range inputs {
+		in.CommitmentWire, in.WitnessHash = wire, hash
+		for _, form := range []PublicForm{PublicFormDecimal, PublicFormHex} {
+			for _, lw := range []LeadingWire{LeadingWireIncluded, LeadingWireExcluded} {
+				out, err := CanonicalizePublic(in, CanonicalizeOptions{Form: form, LeadingWire: lw, NbPublic: 3})
+				if err != nil {
+					t.Fatalf("%s -> %s/%s: %v", name, form, lw, err)
+				}
+				want := dec
+				if lw == LeadingWireIncluded {
+					want = withWire
+				}
+				if form == PublicFormHex {
+					want = hexOf(want)
+					for i := range want {
+						want[i] = strings.TrimPrefix(want[i], "0x")
+					}
+					if out.Inputs != nil || strings.Join(out.InputsHex, ",") != strings.Join(want, ",") {
+						t.Fatalf("%s -> %s/%s: got %+v, want inputsHex %v", name, form, lw, out, want)
+					}
+				} else if out.InputsHex != nil || strings.Join(out.Inputs, ",") != strings.Join(want, ",") {
+					t.Fatalf("%s -> %s/%s: got %+v, want inputs %v", name, form, lw, out, want)
+				}
+				if out.CommitmentWire != wire || out.WitnessHash != hash {
+					t.Fatalf("%s -> %s/%s: commitment wire or witness hash not kept: %+v", name, form, lw, out)
+				}
+			}
+		}
+	}
+
+	bad := []struct {
+		name    string
+		in      PublicJSON
+		opts    CanonicalizeOptions
+		wantErr string
+	}{
+		{"auto output", PublicJSON{Inputs: dec}, CanonicalizeOptions{NbPublic: 3}, "must be include or exclude"},
+		{"both forms", PublicJSON{Inputs: dec, InputsHex: hexOf(dec)}, CanonicalizeOptions{LeadingWire: LeadingWireExcluded, NbPublic: 3}, "both inputs and inputsHex"},
+		{"above modulus", PublicJSON{Inputs: []string{"5", "6", fr.Modulus().String()}}, CanonicalizeOptions{LeadingWire: LeadingWireExcluded, NbPublic: 3}, "public input 2"},
+		{"not decimal", PublicJSON{Inputs: []string{"5", "0x6", "7"}}, CanonicalizeOptions{LeadingWire: LeadingWireExcluded, NbPublic: 3}, "not a decimal integer"},
+		{"bad wire", PublicJSON{Inputs: dec, CommitmentWire: "-1"}, CanonicalizeOptions{LeadingWire: LeadingWireExcluded, NbPublic: 3}, "commitment wire"},
+		{"wrong length", PublicJSON{Inputs: dec[:2]}, CanonicalizeOptions{LeadingWire: LeadingWireExcluded, NbPublic: 3}, "length mismatch"},
+		{"declared wire missing", PublicJSON{Inputs: []string{"5", "6", "7", "8"}}, CanonicalizeOptions{LeadingWire: LeadingWireExcluded, InputLeadingWire: LeadingWireIncluded, NbPublic: 3}, "first value is 5"},
+		{"unknown form", PublicJSON{Inputs: dec}, CanonicalizeOptions{Form: "octal", LeadingWire: LeadingWireExcluded, NbPublic: 3}, "unknown public input form"},
+	}
+	for _, tc := range bad {
+		if _, err := CanonicalizePublic(tc.in, tc.opts); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Fatalf("%s: want error containing %q, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
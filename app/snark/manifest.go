@@ -0,0 +1,173 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// manifest.go writes and checks manifest.json, the size and sha256 of each
+// setup artifact. Setups travel as large binaries over mirrors and browser
+// caches; the manifest lets a receiver confirm they arrived intact before
+// spending minutes deserializing them. The WASM loader can take its expected
+// pk.bin size from the same file.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// manifestFile is the name of the integrity manifest in a setup directory.
+const manifestFile = "manifest.json"
+
+// manifestArtifacts lists the files a manifest covers for dir, in order:
+// ccs.bin, the proving key (pk.bin, or pk_shards.json and its shards) and
+// vk.bin.
+func manifestArtifacts(dir string) ([]string, error) {
+	pkNames, err := provingKeyArtifacts(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := append([]string{"ccs.bin"}, pkNames...)
+	return append(names, "vk.bin"), nil
+}
+
+// ErrManifestMismatch is returned (wrapped) when a setup file does not match
+// its manifest entry.
+var ErrManifestMismatch = errors.New("setup does not match its manifest")
+
+// SetupManifest is the content of manifest.json.
+type SetupManifest struct {
+	// CircuitHash identifies the circuit of ccs.bin (see circuithash.go).
+	// Manifests written before it existed omit it.
+	CircuitHash string          `json:"circuitHash,omitempty"`
+	Files       []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry records one setup file.
+type ManifestEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ManifestCheck is the outcome of checking one manifest entry.
+type ManifestCheck struct {
+	ManifestEntry
+	Err error // nil when the file matches
+}
+
+// Entry returns the entry for name.
+func (m SetupManifest) Entry(name string) (ManifestEntry, bool) {
+	for _, e := range m.Files {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return ManifestEntry{}, false
+}
+
+// manifestEntryFor measures the file name in dir.
+func manifestEntryFor(dir, name string) (ManifestEntry, error) {
+	path := filepath.Join(dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	sum, err := fileHash(path)
+	if err != nil {
+		return ManifestEntry{}, fmt.Errorf("hash %s: %w", name, err)
+	}
+	return ManifestEntry{Name: name, Size: info.Size(), SHA256: sum}, nil
+}
+
+// WriteSetupManifest hashes ccs.bin, pk.bin (or its shards) and vk.bin in dir
+// and writes manifest.json next to them.
+func WriteSetupManifest(dir string) error {
+	names, err := manifestArtifacts(dir)
+	if err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	var m SetupManifest
+	for _, name := range names {
+		e, err := manifestEntryFor(dir, name)
+		if err != nil {
+			return fmt.Errorf("manifest: %w", err)
+		}
+		m.Files = append(m.Files, e)
+	}
+	if m.CircuitHash, err = setupCircuitHash(dir); err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeBytesAtomic(filepath.Join(dir, manifestFile), append(b, '\n'))
+}
+
+// ReadSetupManifest reads manifest.json from dir.
+func ReadSetupManifest(dir string) (SetupManifest, error) {
+	var m SetupManifest
+	if err := readJSONFile(filepath.Join(dir, manifestFile), &m); err != nil {
+		return SetupManifest{}, err
+	}
+	if len(m.Files) == 0 {
+		return SetupManifest{}, fmt.Errorf("%s lists no files", manifestFile)
+	}
+	return m, nil
+}
+
+// VerifySetupManifest recomputes the size and sha256 of every file listed in
+// dir's manifest. It checks them all and returns one ManifestCheck per entry;
+// the error wraps ErrManifestMismatch if any file is missing or differs. Once
+// the files match, the circuit hashes the manifest and vk.json record are
+// checked against ccs.bin (see checkSetupCircuitHash).
+func VerifySetupManifest(dir string) ([]ManifestCheck, error) {
+	m, err := ReadSetupManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	checks := make([]ManifestCheck, len(m.Files))
+	failed := 0
+	for i, want := range m.Files {
+		checks[i].ManifestEntry = want
+		got, err := manifestEntryFor(dir, want.Name)
+		switch {
+		case err != nil:
+			checks[i].Err = err
+		case got.Size != want.Size:
+			checks[i].Err = fmt.Errorf("size %d, manifest says %d", got.Size, want.Size)
+		case got.SHA256 != want.SHA256:
+			checks[i].Err = fmt.Errorf("sha256 %s, manifest says %s", got.SHA256, want.SHA256)
+		}
+		if checks[i].Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return checks, fmt.Errorf("%w: %d of %d files differ", ErrManifestMismatch, failed, len(checks))
+	}
+	return checks, checkSetupCircuitHash(dir, m)
+}
+
+// checkSetupCircuitHash checks that the circuit hash m records, and the one
+// in dir/vk.json if that file exists, are the circuit hash of dir/ccs.bin.
+// Either may be absent. The error wraps ErrCircuitHashMismatch.
+func checkSetupCircuitHash(dir string, m SetupManifest) error {
+	want, err := setupCircuitHash(dir)
+	if err != nil {
+		return err
+	}
+	if err := checkCircuitHash(manifestFile, m.CircuitHash, want); err != nil {
+		return err
+	}
+	var vkj VKJSON
+	err = readJSONFile(filepath.Join(dir, "vk.json"), &vkj)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return checkCircuitHash("vk.json", vkj.CircuitHash, want)
+}
@@ -0,0 +1,13 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+//go:build !unix
+
+// mmap_other.go is the fallback for platforms without mmap (Windows, js/wasm).
+// Callers detect errMmapUnsupported and use the regular read path instead.
+package main
+
+// mmapFile always reports errMmapUnsupported on this platform.
+func mmapFile(path string) (data []byte, unmap func() error, err error) {
+	return nil, nil, errMmapUnsupported
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// normalize.go migrates JSON artifacts written by older exporters to the
+// current schema. Older vk.json and proof.json lack the curve (and the
+// gnark-crypto version), older public.json may carry inputsHex instead of
+// decimal inputs, omit or disagree on the one-wire, and lack commitmentWire
+// and witnessHash. Rather than patch fields one by one, Normalize parses the
+// artifacts back into gnark objects, verifies them, and re-exports them
+// through the same code path `export` uses, so the result is exactly what a
+// fresh export of the same proof would write.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	groth16bls "github.com/consensys/gnark/backend/groth16/bls12-381"
+	backend_witness "github.com/consensys/gnark/backend/witness"
+)
+
+// NormalizeOptions tunes Normalize. The zero value infers the one-wire.
+type NormalizeOptions struct {
+	// LeadingWire declares whether the legacy public inputs start with the
+	// one-wire (see dropLeadingWire).
+	LeadingWire LeadingWire
+}
+
+// Normalize rewrites the JSON artifacts in dir in the current schema,
+// keeping their layout: vk.json/proof.json/public.json, all.json, or both.
+// Unknown fields in the old files are ignored. The artifacts must verify;
+// an invalid proof is reported and nothing is written. It returns the names
+// of the files rewritten.
+func Normalize(dir string, opts NormalizeOptions) ([]string, error) {
+	vkj, pj, pubj, err := loadJSONArtifacts(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	for artifact, curve := range map[string]string{"vk.json": vkj.Curve, "proof.json": pj.Curve} {
+		if err := checkCurve(artifact, curve); err != nil {
+			return nil, err
+		}
+	}
+	vk, err := vkFromJSON(vkj)
+	if err != nil {
+		return nil, fmt.Errorf("vk: %w", err)
+	}
+	proof, err := proofFromJSON(pj)
+	if err != nil {
+		return nil, fmt.Errorf("proof: %w", err)
+	}
+	pub, err := publicInputs(pubj)
+	if err != nil {
+		return nil, err
+	}
+	want := len(vk.G1.K) - len(vk.PublicAndCommitmentCommitted) - 1
+	if pub, err = dropLeadingWire(pub, want, opts.LeadingWire); err != nil {
+		return nil, err
+	}
+	if err := groth16bls.Verify(proof, vk, pub); err != nil {
+		return nil, fmt.Errorf("artifacts in %s do not verify, refusing to rewrite them: %w", dir, err)
+	}
+
+	publicWitness, err := publicWitnessFromVector(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "all.json"))
+	hasBundle := err == nil
+	_, err = os.Stat(filepath.Join(dir, "vk.json"))
+	hasSeparate := err == nil
+	exportOpts := ExportOptions{Bundle: hasBundle, BundleOnly: hasBundle && !hasSeparate, VerifyAfterExport: true, CircuitHash: vkj.CircuitHash}
+	if err := ExportAllWithOptions(vk, proof, publicWitness, dir, exportOpts); err != nil {
+		return nil, err
+	}
+
+	var written []string
+	if !exportOpts.BundleOnly {
+		written = append(written, "vk.json", "proof.json", "public.json")
+	}
+	if exportOpts.Bundle {
+		written = append(written, "all.json")
+	}
+	return written, nil
+}
+
+// publicWitnessFromVector wraps public inputs (without the one-wire) as a
+// gnark public witness, the form the exporters take.
+func publicWitnessFromVector(pub fr.Vector) (backend_witness.Witness, error) {
+	w, err := backend_witness.New(ecc.BLS12_381.ScalarField())
+	if err != nil {
+		return nil, fmt.Errorf("new witness: %w", err)
+	}
+	values := make(chan any, len(pub))
+	for i := range pub {
+		values <- pub[i]
+	}
+	close(values)
+	if err := w.Fill(len(pub), 0, values); err != nil {
+		return nil, fmt.Errorf("fill witness: %w", err)
+	}
+	return w, nil
+}
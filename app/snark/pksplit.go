@@ -0,0 +1,252 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// pksplit.go writes the proving key as numbered byte-range shards and
+// reassembles them on load. A single pk.bin of several hundred megabytes is
+// awkward for static hosts and CDNs with per-object size limits, and a failed
+// download has to start over. With -pk-split N the key is cut into
+// pk_0000.bin..pk_{N-1}.bin plus pk_shards.json, which records each shard's
+// offset and size. Concatenating the shards in order gives back the exact
+// bytes pk.bin would have held, so loaders stream them into the same
+// deserializer without ever materializing the whole file.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark/backend/groth16"
+)
+
+// pkShardManifestFile lists the proving key shards in a setup directory.
+const pkShardManifestFile = "pk_shards.json"
+
+// PKShardManifest is the content of pk_shards.json.
+type PKShardManifest struct {
+	Size   int64     `json:"size"` // total proving key size in bytes
+	Shards []PKShard `json:"shards"`
+}
+
+// PKShard is one byte range of the proving key.
+type PKShard struct {
+	Name   string `json:"name"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+// pkShardName is the file name of shard i.
+func pkShardName(i int) string {
+	return fmt.Sprintf("pk_%04d.bin", i)
+}
+
+// splitPKRanges cuts size bytes into n contiguous shards of ceil(size/n)
+// bytes, the last one taking the remainder.
+func splitPKRanges(size int64, n int) ([]PKShard, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("pk split must be at least 1, got %d", n)
+	}
+	if int64(n) > size {
+		return nil, fmt.Errorf("cannot split a %d-byte proving key into %d shards", size, n)
+	}
+	step := (size + int64(n) - 1) / int64(n)
+	shards := make([]PKShard, n)
+	for i := range shards {
+		off := int64(i) * step
+		shards[i] = PKShard{Name: pkShardName(i), Offset: off, Size: min(step, size-off)}
+	}
+	return shards, nil
+}
+
+// writeProvingKeyFiles writes pk into dir as pk.bin, or as opts.PKSplit shards
+// when that is positive. Proving key files of the other layout left by an
+// earlier run are removed first, so a directory never holds both.
+func writeProvingKeyFiles(dir string, pk groth16.ProvingKey, opts SaveOptions) error {
+	if err := removeProvingKeyFiles(dir); err != nil {
+		return err
+	}
+	if opts.PKSplit <= 0 {
+		return writeProvingKey(filepath.Join(dir, "pk.bin"), pk, opts.Raw)
+	}
+
+	// Serialize once to a scratch file so the shard boundaries are known
+	// before any shard is written. It is tracked like the atomic writes'
+	// temp files, so an interrupt removes it too.
+	tmp := filepath.Join(dir, "pk.bin.tmp")
+	if err := beginTempFile(tmp); err != nil {
+		return err
+	}
+	defer endTempFile(tmp)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create pk.bin.tmp: %w", err)
+	}
+	defer f.Close()
+	if err := writeProvingKeyTo(f, pk, opts.Raw); err != nil {
+		return fmt.Errorf("write pk.bin.tmp: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	shards, err := splitPKRanges(info.Size(), opts.PKSplit)
+	if err != nil {
+		return err
+	}
+	for _, s := range shards {
+		if err := writeShard(filepath.Join(dir, s.Name), f, s.Size); err != nil {
+			return err
+		}
+	}
+	b, err := json.MarshalIndent(PKShardManifest{Size: info.Size(), Shards: shards}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeBytesAtomic(filepath.Join(dir, pkShardManifestFile), append(b, '\n'))
+}
+
+// writeShard copies the next n bytes of r into a new file at path.
+func writeShard(path string, r io.Reader, n int64) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.CopyN(w, r, n)
+		return err
+	})
+}
+
+// removeProvingKeyFiles deletes pk.bin, pk_shards.json and any pk_NNNN.bin
+// shards from dir. Missing files are not an error.
+func removeProvingKeyFiles(dir string) error {
+	shards, err := filepath.Glob(filepath.Join(dir, "pk_[0-9][0-9][0-9][0-9].bin"))
+	if err != nil {
+		return err
+	}
+	paths := append([]string{filepath.Join(dir, "pk.bin"), filepath.Join(dir, pkShardManifestFile)}, shards...)
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// readPKShardManifest reads pk_shards.json from dir and checks that its
+// shards tile [0, Size) in order.
+func readPKShardManifest(dir string) (PKShardManifest, error) {
+	var m PKShardManifest
+	if err := readJSONFile(filepath.Join(dir, pkShardManifestFile), &m); err != nil {
+		return PKShardManifest{}, err
+	}
+	if len(m.Shards) == 0 {
+		return PKShardManifest{}, fmt.Errorf("%s lists no shards", pkShardManifestFile)
+	}
+	var next int64
+	for i, s := range m.Shards {
+		if s.Offset != next || s.Size <= 0 {
+			return PKShardManifest{}, fmt.Errorf("%s: shard %d (%s) covers [%d, %d), expected to start at %d",
+				pkShardManifestFile, i, s.Name, s.Offset, s.Offset+s.Size, next)
+		}
+		if filepath.Base(s.Name) != s.Name {
+			return PKShardManifest{}, fmt.Errorf("%s: shard %d has a path in its name: %q", pkShardManifestFile, i, s.Name)
+		}
+		next += s.Size
+	}
+	if next != m.Size {
+		return PKShardManifest{}, fmt.Errorf("%s: shards cover %d bytes, manifest says %d", pkShardManifestFile, next, m.Size)
+	}
+	return m, nil
+}
+
+// hasPKShards reports whether dir holds a sharded proving key instead of
+// pk.bin. pk.bin wins when both are present.
+func hasPKShards(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "pk.bin")); err == nil {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, pkShardManifestFile))
+	return err == nil
+}
+
+// provingKeyArtifacts names the proving key files in dir: pk.bin, or the
+// shard manifest followed by the shards it lists.
+func provingKeyArtifacts(dir string) ([]string, error) {
+	if !hasPKShards(dir) {
+		return []string{"pk.bin"}, nil
+	}
+	m, err := readPKShardManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{pkShardManifestFile}
+	for _, s := range m.Shards {
+		names = append(names, s.Name)
+	}
+	return names, nil
+}
+
+// openPKShards opens the shards listed in dir's pk_shards.json, checking
+// each file's size against the manifest, and returns them as one reader in
+// shard order. The caller must call closeAll.
+func openPKShards(dir string) (r io.Reader, closeAll func(), err error) {
+	m, err := readPKShardManifest(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := make([]*os.File, 0, len(m.Shards))
+	closeAll = func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	readers := make([]io.Reader, 0, len(m.Shards))
+	for _, s := range m.Shards {
+		f, err := os.Open(filepath.Join(dir, s.Name))
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("open %s: %w", s.Name, err)
+		}
+		files = append(files, f)
+		info, err := f.Stat()
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		if info.Size() != s.Size {
+			closeAll()
+			return nil, nil, fmt.Errorf("%s is %d bytes, %s says %d", s.Name, info.Size(), pkShardManifestFile, s.Size)
+		}
+		readers = append(readers, f)
+	}
+	return io.MultiReader(readers...), closeAll, nil
+}
+
+// joinPKParts reads in-memory proving key parts (shards in order, or a single
+// pk.bin) as one stream, without copying them into one buffer.
+func joinPKParts(parts [][]byte) io.Reader {
+	readers := make([]io.Reader, len(parts))
+	for i, p := range parts {
+		readers[i] = bytes.NewReader(p)
+	}
+	return io.MultiReader(readers...)
+}
+
+// loadProvingKeyShards deserializes the sharded proving key in dir, streaming
+// the shards through the decoder in order. unchecked is as for
+// readProvingKey.
+func loadProvingKeyShards(dir string, unchecked bool) (groth16.ProvingKey, error) {
+	r, closeShards, err := openPKShards(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer closeShards()
+	pk, err := readProvingKeyStream(r, unchecked)
+	if err != nil {
+		return nil, fmt.Errorf("read pk shards: %w", err)
+	}
+	return pk, nil
+}
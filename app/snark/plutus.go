@@ -0,0 +1,239 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// plutus.go decodes the subset of Plutus data needed to read encryption-level
+// entries and encryption UTxO datums (constructors, byte strings, integers and
+// lists) from either the detailed JSON schema used under app/data or raw CBOR,
+// and extracts the decrypt inputs from them.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// PlutusData is a node of a Plutus datum in the detailed JSON schema:
+// {"constructor": n, "fields": [...]}, {"bytes": "<hex>"}, {"int": n} or
+// {"list": [...]}. Level entries only use the first two; integers and lists
+// appear in the status of a pending encryption datum. Maps are not used and
+// are rejected.
+type PlutusData struct {
+	Constructor *int         `json:"constructor,omitempty"`
+	Fields      []PlutusData `json:"fields,omitempty"`
+	Bytes       *string      `json:"bytes,omitempty"`
+	Int         *big.Int     `json:"int,omitempty"`
+	List        []PlutusData `json:"list,omitempty"`
+}
+
+// ParsePlutusDatum decodes raw as a Plutus datum. JSON (detailed schema) is
+// detected by a leading '{'; otherwise raw is CBOR, given either as hex text
+// (as printed by cardano-cli / Koios) or as binary.
+func ParsePlutusDatum(raw []byte) (PlutusData, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return PlutusData{}, fmt.Errorf("empty datum")
+	}
+
+	if trimmed[0] == '{' {
+		var d PlutusData
+		if err := json.Unmarshal(trimmed, &d); err != nil {
+			return PlutusData{}, fmt.Errorf("unmarshal datum json: %w", err)
+		}
+		return d, nil
+	}
+
+	cborBytes := trimmed
+	hexText := strings.TrimPrefix(strings.TrimPrefix(string(trimmed), "0x"), "0X")
+	if decoded, err := hex.DecodeString(hexText); err == nil {
+		cborBytes = decoded
+	}
+	var v interface{}
+	if err := cbor.Unmarshal(cborBytes, &v); err != nil {
+		return PlutusData{}, fmt.Errorf("unmarshal datum cbor: %w", err)
+	}
+	return plutusFromCBOR(v)
+}
+
+// plutusFromCBOR converts a generic CBOR value into PlutusData. Constructors
+// use the standard Plutus tags: 121..127 for 0..6, 1280..1400 for 7..127 and
+// 102 for the general [index, fields] form.
+func plutusFromCBOR(v interface{}) (PlutusData, error) {
+	switch x := v.(type) {
+	case []byte:
+		h := hex.EncodeToString(x)
+		return PlutusData{Bytes: &h}, nil
+
+	case cbor.Tag:
+		var idx int
+		var content interface{} = x.Content
+		switch {
+		case x.Number >= 121 && x.Number <= 127:
+			idx = int(x.Number - 121)
+		case x.Number >= 1280 && x.Number <= 1400:
+			idx = int(x.Number-1280) + 7
+		case x.Number == 102:
+			pair, ok := x.Content.([]interface{})
+			if !ok || len(pair) != 2 {
+				return PlutusData{}, fmt.Errorf("cbor tag 102: expected [index, fields]")
+			}
+			n, ok := pair[0].(uint64)
+			if !ok {
+				return PlutusData{}, fmt.Errorf("cbor tag 102: constructor index is not an unsigned int")
+			}
+			idx = int(n)
+			content = pair[1]
+		default:
+			return PlutusData{}, fmt.Errorf("unsupported cbor tag %d", x.Number)
+		}
+
+		items, ok := content.([]interface{})
+		if !ok {
+			return PlutusData{}, fmt.Errorf("constructor %d: fields are not a list", idx)
+		}
+		fields := make([]PlutusData, len(items))
+		for i, item := range items {
+			f, err := plutusFromCBOR(item)
+			if err != nil {
+				return PlutusData{}, fmt.Errorf("constructor %d field %d: %w", idx, i, err)
+			}
+			fields[i] = f
+		}
+		return PlutusData{Constructor: &idx, Fields: fields}, nil
+
+	case uint64:
+		return PlutusData{Int: new(big.Int).SetUint64(x)}, nil
+	case int64:
+		return PlutusData{Int: big.NewInt(x)}, nil
+	case big.Int:
+		return PlutusData{Int: &x}, nil
+
+	case []interface{}:
+		list := make([]PlutusData, len(x))
+		for i, item := range x {
+			e, err := plutusFromCBOR(item)
+			if err != nil {
+				return PlutusData{}, fmt.Errorf("list item %d: %w", i, err)
+			}
+			list[i] = e
+		}
+		return PlutusData{List: list}, nil
+
+	default:
+		return PlutusData{}, fmt.Errorf("unsupported cbor value %T (only constructors, bytes, integers and lists are expected)", v)
+	}
+}
+
+// field returns d.Fields[i], or an error naming path if d is not a
+// constructor with at least i+1 fields.
+func (d PlutusData) field(path string, i int) (PlutusData, error) {
+	if d.Constructor == nil {
+		return PlutusData{}, fmt.Errorf("%s: not a constructor", path)
+	}
+	if i >= len(d.Fields) {
+		return PlutusData{}, fmt.Errorf("%s: missing fields[%d] (have %d)", path, i, len(d.Fields))
+	}
+	return d.Fields[i], nil
+}
+
+// bytesAt returns the hex bytes of d.Fields[i].
+func (d PlutusData) bytesAt(path string, i int) (string, error) {
+	f, err := d.field(path, i)
+	if err != nil {
+		return "", err
+	}
+	if f.Bytes == nil {
+		return "", fmt.Errorf("%s.fields[%d]: not a bytes node", path, i)
+	}
+	return *f.Bytes, nil
+}
+
+// EntryDecryptInputs extracts the DecryptToHash inputs from an encryption-level
+// entry. Three shapes are recognized by their constructor tags:
+//
+//	half level:  Constr 0 [r1, g1b, r4]                       -> g2b = ""
+//	full level:  Constr 0 [Constr 0 [r1, g1b, g2b, r4]]
+//	nested r2:   Constr _ [r1, Constr _ [g1b, opt], ...]      -> opt is Constr 0 [g2b] or Constr 1 [] (no g2b)
+//
+// The first two are what app/src/level.py writes; the third is the layout
+// documented on DecryptToHash. An empty full level (Constr 1 []) has nothing
+// to decrypt and is rejected.
+func EntryDecryptInputs(entry PlutusData) (g1b, g2b, r1 string, err error) {
+	if entry.Constructor == nil {
+		return "", "", "", fmt.Errorf("entry: not a constructor")
+	}
+	if *entry.Constructor == 1 && len(entry.Fields) == 0 {
+		return "", "", "", fmt.Errorf("entry: empty full level (constructor 1) has no decrypt inputs")
+	}
+
+	// full level: unwrap Constr 0 [Constr 0 [...]]
+	if len(entry.Fields) == 1 && entry.Fields[0].Constructor != nil {
+		inner := entry.Fields[0]
+		if r1, err = inner.bytesAt("entry.fields[0]", 0); err != nil {
+			return "", "", "", err
+		}
+		if g1b, err = inner.bytesAt("entry.fields[0]", 1); err != nil {
+			return "", "", "", err
+		}
+		if g2b, err = inner.bytesAt("entry.fields[0]", 2); err != nil {
+			return "", "", "", err
+		}
+		return g1b, g2b, r1, nil
+	}
+
+	if r1, err = entry.bytesAt("entry", 0); err != nil {
+		return "", "", "", err
+	}
+	r2, err := entry.field("entry", 1)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	// half level: r2 is just the G1 bytes
+	if r2.Bytes != nil {
+		return *r2.Bytes, "", r1, nil
+	}
+
+	// nested r2: Constr [g1b, opt]
+	if g1b, err = r2.bytesAt("entry.fields[1]", 0); err != nil {
+		return "", "", "", err
+	}
+	opt, err := r2.field("entry.fields[1]", 1)
+	if err != nil {
+		return "", "", "", err
+	}
+	if opt.Constructor == nil {
+		return "", "", "", fmt.Errorf("entry.fields[1].fields[1]: not a constructor")
+	}
+	if *opt.Constructor == 1 {
+		return g1b, "", r1, nil
+	}
+	if g2b, err = opt.bytesAt("entry.fields[1].fields[1]", 0); err != nil {
+		return "", "", "", err
+	}
+	return g1b, g2b, r1, nil
+}
+
+// DecryptEntryToHash parses a raw entry datum (JSON or CBOR, see
+// ParsePlutusDatum), extracts its inputs and runs DecryptToHash with sharedHex.
+func DecryptEntryToHash(raw []byte, sharedHex string) (string, error) {
+	return DecryptEntryToHashWithOptions(raw, sharedHex, DecryptOptions{})
+}
+
+// DecryptEntryToHashWithOptions is DecryptEntryToHash with explicit DecryptOptions.
+func DecryptEntryToHashWithOptions(raw []byte, sharedHex string, opts DecryptOptions) (string, error) {
+	entry, err := ParsePlutusDatum(raw)
+	if err != nil {
+		return "", err
+	}
+	g1b, g2b, r1, err := EntryDecryptInputs(entry)
+	if err != nil {
+		return "", err
+	}
+	return DecryptToHashWithOptions(g1b, g2b, r1, sharedHex, opts)
+}
@@ -0,0 +1,57 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// profile.go provides pprof wiring for the expensive gnark calls. A profile
+// section brackets exactly one groth16.Setup or groth16.Prove call: the CPU
+// profile covers the call and a heap profile is written right after it returns.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfile begins a CPU profile written to dir/<name>.cpu.pprof. The returned
+// stop function ends the CPU profile and writes dir/<name>.heap.pprof. An empty
+// dir disables profiling and returns a no-op stop function.
+func startProfile(dir, name string) (stop func() error, err error) {
+	if dir == "" {
+		return func() error { return nil }, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir profile dir: %w", err)
+	}
+
+	cpuPath := filepath.Join(dir, name+".cpu.pprof")
+	cpuFile, err := os.Create(cpuPath)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", cpuPath, err)
+	}
+	if err := pprof.StartCPUProfile(cpuFile); err != nil {
+		cpuFile.Close()
+		return nil, fmt.Errorf("start cpu profile: %w", err)
+	}
+
+	return func() error {
+		pprof.StopCPUProfile()
+		if err := cpuFile.Close(); err != nil {
+			return fmt.Errorf("close %s: %w", cpuPath, err)
+		}
+
+		heapPath := filepath.Join(dir, name+".heap.pprof")
+		heapFile, err := os.Create(heapPath)
+		if err != nil {
+			return fmt.Errorf("create %s: %w", heapPath, err)
+		}
+		defer heapFile.Close()
+
+		runtime.GC() // materialize up-to-date allocation statistics
+		if err := pprof.WriteHeapProfile(heapFile); err != nil {
+			return fmt.Errorf("write heap profile: %w", err)
+		}
+		return nil
+	}, nil
+}
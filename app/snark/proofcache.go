@@ -0,0 +1,89 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// proofcache.go holds the optional LRU of recent proofs behind `snark serve`.
+// Retries and duplicate submissions re-send the same (a, r, v, w0, w1); a
+// hit answers them without another multi-minute Prove.
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// proofCacheKey hashes the witness inputs of req. Scalars are compared by
+// value and points by their normalized hex, so "0x0a" and "10" share a key.
+func proofCacheKey(req ProveRequest) [sha256.Size]byte {
+	scalar := func(s string) string {
+		if k, ok := new(big.Int).SetString(strings.TrimSpace(s), 0); ok {
+			return k.String()
+		}
+		return strings.TrimSpace(s)
+	}
+	h := sha256.New()
+	for _, f := range []string{scalar(req.A), scalar(req.R), normalizeHex(req.V), normalizeHex(req.W0), normalizeHex(req.W1)} {
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+	}
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+// proofCache is a fixed-size LRU of proof responses, safe for concurrent use.
+type proofCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is most recently used
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+type proofCacheEntry struct {
+	key  [sha256.Size]byte
+	resp ProveResponse
+}
+
+// newProofCache returns a cache holding up to size responses, or nil when
+// size is not positive. A nil cache misses every lookup.
+func newProofCache(size int) *proofCache {
+	if size <= 0 {
+		return nil
+	}
+	return &proofCache{size: size, order: list.New(), entries: make(map[[sha256.Size]byte]*list.Element)}
+}
+
+func (c *proofCache) get(key [sha256.Size]byte) (ProveResponse, bool) {
+	if c == nil {
+		return ProveResponse{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return ProveResponse{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*proofCacheEntry).resp, true
+}
+
+func (c *proofCache) put(key [sha256.Size]byte, resp ProveResponse) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*proofCacheEntry).resp = resp
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&proofCacheEntry{key: key, resp: resp})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*proofCacheEntry).key)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// publicform.go converts a public.json between the shapes producers write.
+// The same public vector turns up as decimal strings (inputs, what `prove`
+// writes) or as 32-byte big-endian hex (inputsHex, what the on-chain tooling
+// prints), and with or without the constant one-wire "1" in front. The
+// commitment wire is never one of the inputs: gnark appends it to the vector
+// during verification, so public.json carries it separately in
+// commitmentWire, always in decimal. CanonicalizePublic reads any of these
+// shapes and writes exactly the one asked for; it is the explicit form of the
+// reconciliation the importers (publicInputs, dropLeadingWire) and the
+// exporter (choosePublicInputs) otherwise do implicitly.
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+)
+
+// PublicForm is the encoding of the public inputs in a public.json.
+type PublicForm string
+
+const (
+	PublicFormDecimal PublicForm = "decimal" // inputs: decimal strings
+	PublicFormHex     PublicForm = "hex"     // inputsHex: 32-byte big-endian hex
+)
+
+// ParsePublicForm parses the -form flag: decimal or hex.
+func ParsePublicForm(s string) (PublicForm, error) {
+	switch f := PublicForm(s); f {
+	case PublicFormDecimal, PublicFormHex:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown public input form %q (want decimal or hex)", s)
+}
+
+// CanonicalizeOptions selects the shape CanonicalizePublic writes and
+// declares the shape it reads.
+type CanonicalizeOptions struct {
+	// Form is the encoding to write; empty means PublicFormDecimal.
+	Form PublicForm
+
+	// LeadingWire is whether to write the one-wire: LeadingWireIncluded or
+	// LeadingWireExcluded. Auto is rejected, as the output must be definite.
+	LeadingWire LeadingWire
+
+	// InputLeadingWire declares whether the input starts with the one-wire;
+	// the default infers it from the length (see dropLeadingWire).
+	InputLeadingWire LeadingWire
+
+	// NbPublic is the number of public inputs without the one-wire; zero
+	// means VW0W1NbPublic.
+	NbPublic int
+}
+
+// CanonicalizePublic parses pub, in either encoding and with or without the
+// one-wire, and returns it in the form opts selects. Every value, the
+// commitment wire included, must be a canonical element of Fr: a decimal
+// value at or above the modulus is rejected rather than reduced. The
+// commitment wire and witnessHash are carried over unchanged apart from
+// that check.
+func CanonicalizePublic(pub PublicJSON, opts CanonicalizeOptions) (PublicJSON, error) {
+	form := opts.Form
+	if form == "" {
+		form = PublicFormDecimal
+	}
+	if _, err := ParsePublicForm(string(form)); err != nil {
+		return PublicJSON{}, err
+	}
+	if opts.LeadingWire != LeadingWireIncluded && opts.LeadingWire != LeadingWireExcluded {
+		return PublicJSON{}, fmt.Errorf("output leading wire must be include or exclude, got %q", opts.LeadingWire)
+	}
+	nbPublic := opts.NbPublic
+	if nbPublic == 0 {
+		nbPublic = VW0W1NbPublic
+	}
+
+	var in fr.Vector
+	var err error
+	switch {
+	case len(pub.Inputs) > 0 && len(pub.InputsHex) > 0:
+		return PublicJSON{}, fmt.Errorf("public.json has both inputs and inputsHex; keep only one")
+	case len(pub.InputsHex) > 0:
+		in, err = ParsePublicInputsFromHex(pub.InputsHex)
+	default:
+		in, err = parseDecimalFrs("public input", pub.Inputs)
+	}
+	if err != nil {
+		return PublicJSON{}, err
+	}
+	if in, err = dropLeadingWire(in, nbPublic, opts.InputLeadingWire); err != nil {
+		return PublicJSON{}, err
+	}
+
+	out := PublicJSON{WitnessHash: pub.WitnessHash}
+	if pub.CommitmentWire != "" {
+		w, err := parseDecimalFrs("commitment wire", []string{pub.CommitmentWire})
+		if err != nil {
+			return PublicJSON{}, err
+		}
+		out.CommitmentWire = frDecimal(&w[0])
+	}
+
+	vals := make([]string, 0, len(in)+1)
+	encode := func(e *fr.Element) {
+		if form == PublicFormHex {
+			b := e.Bytes()
+			vals = append(vals, hex.EncodeToString(b[:]))
+		} else {
+			vals = append(vals, frDecimal(e))
+		}
+	}
+	if opts.LeadingWire == LeadingWireIncluded {
+		one := fr.One()
+		encode(&one)
+	}
+	for i := range in {
+		encode(&in[i])
+	}
+	if form == PublicFormHex {
+		out.InputsHex = vals
+	} else {
+		out.Inputs = vals
+	}
+	return out, nil
+}
+
+// parseDecimalFrs parses decimal strings as canonical Fr elements, naming
+// the offending value (what, and its index when there are several).
+func parseDecimalFrs(what string, vals []string) (fr.Vector, error) {
+	out := make(fr.Vector, len(vals))
+	for i, s := range vals {
+		name := what
+		if len(vals) > 1 {
+			name = fmt.Sprintf("%s %d", what, i)
+		}
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("%s: %q is not a decimal integer", name, s)
+		}
+		if n.Sign() < 0 || n.Cmp(fr.Modulus()) >= 0 {
+			return nil, fmt.Errorf("%s: %s is not in [0, Fr modulus)", name, s)
+		}
+		out[i].SetBigInt(n)
+	}
+	return out, nil
+}
+
+// frDecimal returns e in canonical decimal. fr.Element.String does not: it
+// prints values just below the modulus as small negatives ("-1").
+func frDecimal(e *fr.Element) string {
+	return e.BigInt(new(big.Int)).String()
+}
@@ -0,0 +1,113 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// reencode.go converts BLS12-381 point hex between the compressed encoding
+// used on-chain and the uncompressed one (full x and y coordinates) some
+// tools want. Input in either encoding goes through the same subgroup-checked
+// decoding as every other point parser in the package.
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+)
+
+// ErrUnknownEncoding is returned (wrapped) for a -type or -to value that
+// reencode does not recognize.
+var ErrUnknownEncoding = errors.New("unknown point encoding")
+
+// Point encodings accepted by ReencodePointHex.
+const (
+	encodingCompressed   = "compressed"
+	encodingUncompressed = "uncompressed"
+)
+
+// g1UncompressedHex serializes p to its 96-byte uncompressed form (x || y
+// with the IETF flag bits) as a lowercase hex string (192 characters).
+func g1UncompressedHex(p bls12381.G1Affine) string {
+	b := p.RawBytes()
+	return hex.EncodeToString(b[:])
+}
+
+// g2UncompressedHex serializes p to its 192-byte uncompressed form as a
+// lowercase hex string (384 characters).
+func g2UncompressedHex(p bls12381.G2Affine) string {
+	b := p.RawBytes()
+	return hex.EncodeToString(b[:])
+}
+
+// parseG1AnyHex decodes a G1 point given in either encoding, chosen by
+// length. The point must be in the prime-order subgroup.
+func parseG1AnyHex(h string) (bls12381.G1Affine, error) {
+	switch len(h) {
+	case 2 * bls12381.SizeOfG1AffineCompressed:
+		return parseG1CompressedHex(h)
+	case 2 * bls12381.SizeOfG1AffineUncompressed:
+		raw, err := hex.DecodeString(h)
+		if err != nil {
+			return bls12381.G1Affine{}, withKind(ErrBadHex, fmt.Errorf("decode G1 hex: %w", err))
+		}
+		var p bls12381.G1Affine
+		if _, err := p.SetBytes(raw); err != nil {
+			return bls12381.G1Affine{}, withKind(ErrBadPoint, fmt.Errorf("G1.SetBytes: %w", err))
+		}
+		return p, nil
+	}
+	return bls12381.G1Affine{}, withKind(ErrBadHex, fmt.Errorf("G1 point must be %d (compressed) or %d (uncompressed) hex chars (got %d)",
+		2*bls12381.SizeOfG1AffineCompressed, 2*bls12381.SizeOfG1AffineUncompressed, len(h)))
+}
+
+// parseG2AnyHex is the G2 counterpart of parseG1AnyHex.
+func parseG2AnyHex(h string) (bls12381.G2Affine, error) {
+	switch len(h) {
+	case 2 * bls12381.SizeOfG2AffineCompressed:
+		return parseG2CompressedHex(h)
+	case 2 * bls12381.SizeOfG2AffineUncompressed:
+		raw, err := hex.DecodeString(h)
+		if err != nil {
+			return bls12381.G2Affine{}, withKind(ErrBadHex, fmt.Errorf("decode G2 hex: %w", err))
+		}
+		var p bls12381.G2Affine
+		if _, err := p.SetBytes(raw); err != nil {
+			return bls12381.G2Affine{}, withKind(ErrBadPoint, fmt.Errorf("G2.SetBytes: %w", err))
+		}
+		return p, nil
+	}
+	return bls12381.G2Affine{}, withKind(ErrBadHex, fmt.Errorf("G2 point must be %d (compressed) or %d (uncompressed) hex chars (got %d)",
+		2*bls12381.SizeOfG2AffineCompressed, 2*bls12381.SizeOfG2AffineUncompressed, len(h)))
+}
+
+// ReencodePointHex parses pointHex as a point of group ("g1" or "g2") in
+// either encoding and returns it in the encoding named by to ("compressed"
+// or "uncompressed"). pointHex is normalized first, so a 0x prefix and
+// upper-case digits are accepted.
+func ReencodePointHex(group, pointHex, to string) (string, error) {
+	if to != encodingCompressed && to != encodingUncompressed {
+		return "", fmt.Errorf("%w: -to %q (want %s or %s)", ErrUnknownEncoding, to, encodingCompressed, encodingUncompressed)
+	}
+	h := normalizeHex(pointHex)
+	switch group {
+	case "g1":
+		p, err := parseG1AnyHex(h)
+		if err != nil {
+			return "", err
+		}
+		if to == encodingUncompressed {
+			return g1UncompressedHex(p), nil
+		}
+		return g1CompressedHex(p)
+	case "g2":
+		p, err := parseG2AnyHex(h)
+		if err != nil {
+			return "", err
+		}
+		if to == encodingUncompressed {
+			return g2UncompressedHex(p), nil
+		}
+		return g2CompressedHex(p)
+	}
+	return "", fmt.Errorf("%w: -type %q (want g1 or g2)", ErrUnknownEncoding, group)
+}
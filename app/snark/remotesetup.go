@@ -0,0 +1,261 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// remotesetup.go loads ccs.bin, pk.bin and vk.bin straight from a stream,
+// typically an HTTP(S) response body, so ephemeral provers do not have to
+// stage the multi-gigabyte proving key on local disk first. It is the native
+// counterpart of the WASM byte-slice loader. RetryFetcher retries a fetch that
+// fails on a transient network error or server status, with exponential
+// backoff, so one flaky request does not abort a long workflow.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+)
+
+// SetupFetcher opens a setup file by URL. The loader reads the returned body
+// once, front to back, and closes it. Object stores with their own SDK can be
+// plugged in by implementing this interface.
+type SetupFetcher interface {
+	Fetch(url string) (io.ReadCloser, error)
+}
+
+// HTTPFetcher fetches setup files with plain GET requests. A nil Client uses
+// http.DefaultClient; a nil Context uses context.Background(). The context
+// bounds the whole request, including reading the body.
+type HTTPFetcher struct {
+	Client  *http.Client
+	Context context.Context
+}
+
+// HTTPStatusError is returned by HTTPFetcher for a response other than 200.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.URL, e.Status)
+}
+
+// Fetch implements SetupFetcher. Any status other than 200 is an
+// *HTTPStatusError.
+func (f HTTPFetcher) Fetch(url string) (io.ReadCloser, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx := f.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &HTTPStatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp.Body, nil
+}
+
+// RetryFetcher wraps a SetupFetcher and retries a failed Fetch up to Retries
+// more times. Only opening the response is retried: once a body is handed to
+// the loader, a failure while streaming it is returned as is, because the
+// loader has already consumed part of it.
+type RetryFetcher struct {
+	// Fetcher does the actual fetching. Nil uses HTTPFetcher{} with Context.
+	Fetcher SetupFetcher
+	// Retries is the number of attempts after the first one.
+	Retries int
+	// Backoff returns the wait before retry n (1-based). Nil uses
+	// ExponentialBackoff.
+	Backoff func(n int) time.Duration
+	// Context stops the retries, and the wait between them, once it is done.
+	// A retry that would start after its deadline is not attempted. Nil uses
+	// context.Background().
+	Context context.Context
+}
+
+// Fetch implements SetupFetcher.
+func (f RetryFetcher) Fetch(url string) (io.ReadCloser, error) {
+	ctx := f.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	fetcher := f.Fetcher
+	if fetcher == nil {
+		fetcher = HTTPFetcher{Context: ctx}
+	}
+	backoff := f.Backoff
+	if backoff == nil {
+		backoff = ExponentialBackoff
+	}
+
+	for n := 0; ; n++ {
+		body, err := fetcher.Fetch(url)
+		if err == nil {
+			return body, nil
+		}
+		if n >= f.Retries || !retryableFetchError(err) {
+			return nil, err
+		}
+		wait := backoff(n + 1)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, fmt.Errorf("%w (no time left for retry %d before the deadline)", err, n+1)
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, fmt.Errorf("%w (retry %d canceled: %v)", err, n+1, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// ExponentialBackoff waits 500ms before the first retry and doubles the wait
+// for each one after it, up to 30s.
+func ExponentialBackoff(n int) time.Duration {
+	const base, limit = 500 * time.Millisecond, 30 * time.Second
+	if n < 1 {
+		return 0
+	}
+	if n > 7 {
+		return limit
+	}
+	return min(base<<(n-1), limit)
+}
+
+// retryableFetchError reports whether a failed Fetch may succeed if tried
+// again: server errors, 408 and 429 responses, and transport errors. Other
+// statuses (a 404, a 403) and a done context are final.
+func retryableFetchError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var se *HTTPStatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500 || se.StatusCode == http.StatusRequestTimeout || se.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// SetupURLs locates the three setup files.
+type SetupURLs struct {
+	CCS, PK, VK string
+}
+
+// OpenSetupFromURLs streams the setup files named by urls through fetcher
+// into a SetupHandle. A nil fetcher uses HTTPFetcher{}.
+func OpenSetupFromURLs(urls SetupURLs, fetcher SetupFetcher) (*SetupHandle, error) {
+	if urls.CCS == "" || urls.PK == "" || urls.VK == "" {
+		return nil, fmt.Errorf("ccs, pk and vk URLs are all required")
+	}
+	if fetcher == nil {
+		fetcher = HTTPFetcher{}
+	}
+
+	h := &SetupHandle{}
+	if err := fetchInto(fetcher, urls.CCS, "ccs.bin", func(r io.Reader) (err error) {
+		h.CCS, err = readCCS(r)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if err := fetchInto(fetcher, urls.PK, "pk.bin", func(r io.Reader) (err error) {
+		h.PK, err = readProvingKeyStream(r, false)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if err := fetchInto(fetcher, urls.VK, "vk.bin", func(r io.Reader) (err error) {
+		h.VK, err = readVerifyingKey(r)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if err := checkSetupConsistency(h.CCS, h.PK, h.VK); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// LoadSetupFromReaders is LoadSetupFiles over streams instead of a directory.
+// The proving key may be in either encoding; none of the readers need to seek.
+func LoadSetupFromReaders(ccsR, pkR, vkR io.Reader) (constraint.ConstraintSystem, groth16.ProvingKey, groth16.VerifyingKey, error) {
+	ccs, err := readCCS(ccsR)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("read ccs.bin: %w", err)
+	}
+	pk, err := readProvingKeyStream(pkR, false)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("read pk.bin: %w", err)
+	}
+	vk, err := readVerifyingKey(vkR)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("read vk.bin: %w", err)
+	}
+	if err := checkSetupConsistency(ccs, pk, vk); err != nil {
+		return nil, nil, nil, err
+	}
+	return ccs, pk, vk, nil
+}
+
+// fetchInto opens url and hands its body to read, closing it afterwards.
+func fetchInto(fetcher SetupFetcher, url, name string, read func(io.Reader) error) error {
+	body, err := fetcher.Fetch(url)
+	if err != nil {
+		return fmt.Errorf("fetch %s: %w", name, err)
+	}
+	defer body.Close()
+	if err := read(body); err != nil {
+		return fmt.Errorf("read %s: %w", name, err)
+	}
+	return nil
+}
+
+// readProvingKeyStream is readProvingKey for readers that cannot seek: the
+// header used to detect the encoding is peeked rather than re-read.
+func readProvingKeyStream(r io.Reader, unchecked bool) (groth16.ProvingKey, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	raw := false
+	if unchecked {
+		head, err := br.Peek(pkDomainSize + 1)
+		if err != nil {
+			return nil, fmt.Errorf("read proving key header: %w", err)
+		}
+		if raw, err = provingKeyIsRaw(bytes.NewReader(head)); err != nil {
+			return nil, err
+		}
+	}
+
+	pk := groth16.NewProvingKey(ecc.BLS12_381)
+	var err error
+	if raw {
+		_, err = pk.UnsafeReadFrom(br)
+	} else {
+		_, err = pk.ReadFrom(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pk, nil
+}
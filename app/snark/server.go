@@ -0,0 +1,275 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// server.go implements `snark serve`: a small HTTP service that loads the
+// setup once into a SetupHandle and proves vw0w1 statements on request.
+// Every proof holds several GB while it runs, so concurrent proofs are
+// bounded by a semaphore and excess requests wait their turn. The server
+// listens while the setup is still loading; /healthz reports liveness and
+// /readyz readiness, like gnarkIsReady does for the WASM prover. /metrics
+// exposes the queue counters in the Prometheus text format. An optional LRU
+// (proofcache.go) answers repeated statements without proving again.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// maxProveRequestBytes bounds the POST /prove body; a request is five short
+// strings.
+const maxProveRequestBytes = 64 << 10
+
+// ProveRequest is the body of POST /prove. Scalars are decimal or 0x-hex
+// strings and points are compressed G1 hex, as for `prove`.
+type ProveRequest struct {
+	A  string `json:"a"`
+	R  string `json:"r"`
+	V  string `json:"v"`
+	W0 string `json:"w0"`
+	W1 string `json:"w1"`
+}
+
+// ProveResponse is the body of a successful POST /prove.
+type ProveResponse struct {
+	Proof          ProofJSON  `json:"proof"`
+	Public         PublicJSON `json:"public"`
+	CommitmentWire string     `json:"commitmentWire,omitempty"`
+	WitnessHash    string     `json:"witnessHash,omitempty"`
+}
+
+// ProveServer serves proofs from a loaded setup.
+type ProveServer struct {
+	handle atomic.Pointer[SetupHandle] // nil until the setup is loaded
+	opts   ProveOptions
+	sem    chan struct{}
+	cache  *proofCache // nil when caching is disabled
+
+	// Counters for /metrics.
+	inFlight atomic.Int64 // proofs running
+	queued   atomic.Int64 // requests waiting for a slot
+	total    atomic.Int64 // POST /prove requests received
+	failures atomic.Int64 // POST /prove requests not answered with 200
+	proofs   atomic.Int64 // proofs computed
+	hits     atomic.Int64 // requests answered from the cache
+
+	// assign builds the circuit assignment for a request. Errors are the
+	// caller's fault and answered with 400. Tests swap in a smaller circuit.
+	assign func(ProveRequest) (frontend.Circuit, error)
+}
+
+// ProveServerOptions configures NewProveServerWithOptions.
+type ProveServerOptions struct {
+	// MaxConcurrent caps the proofs running at once (at least one).
+	MaxConcurrent int
+
+	// CacheSize is how many recent proofs to keep, keyed by a hash of the
+	// request's witness inputs. Zero disables the cache.
+	CacheSize int
+
+	// Prove is passed to every proof. Proofs are verified before they are
+	// returned unless Prove.SkipVerify is set.
+	Prove ProveOptions
+}
+
+// NewProveServer returns a server proving with h, at most maxConcurrent
+// proofs at a time and without a proof cache.
+func NewProveServer(h *SetupHandle, maxConcurrent int, opts ProveOptions) *ProveServer {
+	return NewProveServerWithOptions(h, ProveServerOptions{MaxConcurrent: maxConcurrent, Prove: opts})
+}
+
+// NewProveServerWithOptions returns a server proving with h. h may be nil
+// while the setup is still loading; the server is not ready until SetHandle
+// is called.
+func NewProveServerWithOptions(h *SetupHandle, o ProveServerOptions) *ProveServer {
+	maxConcurrent := o.MaxConcurrent
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	s := &ProveServer{opts: o.Prove, sem: make(chan struct{}, maxConcurrent), cache: newProofCache(o.CacheSize)}
+	if h != nil {
+		s.SetHandle(h)
+	}
+	s.assign = func(req ProveRequest) (frontend.Circuit, error) {
+		a, ok := new(big.Int).SetString(req.A, 0)
+		if !ok || a.Sign() == 0 {
+			return nil, fmt.Errorf("could not parse a (must be a non-zero integer; decimal or 0x.. hex)")
+		}
+		r, ok := new(big.Int).SetString(req.R, 0)
+		if !ok {
+			return nil, fmt.Errorf("could not parse r (must be an integer; decimal or 0x.. hex)")
+		}
+		return prepareVW0W1(a, r, normalizeHex(req.V), normalizeHex(req.W0), normalizeHex(req.W1), s.opts)
+	}
+	return s
+}
+
+// SetHandle installs the loaded setup and marks the server ready.
+func (s *ProveServer) SetHandle(h *SetupHandle) {
+	s.handle.Store(h)
+}
+
+// Ready reports whether the setup has been loaded.
+func (s *ProveServer) Ready() bool {
+	return s.handle.Load() != nil
+}
+
+// Handler returns the server's routes: POST /prove, GET /healthz (liveness),
+// GET /readyz (200 once the setup is loaded, 503 before) and GET /metrics.
+func (s *ProveServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/prove", s.handleProve)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !s.Ready() {
+			writeJSONResponse(w, http.StatusServiceUnavailable, map[string]string{"status": "loading"})
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ready"})
+	})
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	return mux
+}
+
+// handleMetrics writes the proving counters in the Prometheus text format.
+func (s *ProveServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range []struct {
+		name, kind, help string
+		value            int64
+	}{
+		{"snark_prove_in_flight", "gauge", "Proofs currently running.", s.inFlight.Load()},
+		{"snark_prove_queued", "gauge", "Prove requests waiting for a proving slot.", s.queued.Load()},
+		{"snark_prove_requests_total", "counter", "Prove requests received.", s.total.Load()},
+		{"snark_prove_failures_total", "counter", "Prove requests that did not return a proof.", s.failures.Load()},
+		{"snark_prove_max_concurrent", "gauge", "Proving slots.", int64(cap(s.sem))},
+		{"snark_proofs_total", "counter", "Proofs computed.", s.proofs.Load()},
+		{"snark_prove_cache_hits_total", "counter", "Prove requests answered from the proof cache.", s.hits.Load()},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", m.name, m.help, m.name, m.kind, m.name, m.value)
+	}
+}
+
+func (s *ProveServer) handleProve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, errors.New("use POST"))
+		return
+	}
+
+	s.total.Add(1)
+	fail := func(status int, err error) {
+		s.failures.Add(1)
+		writeJSONError(w, status, err)
+	}
+
+	h := s.handle.Load()
+	if h == nil {
+		fail(http.StatusServiceUnavailable, errors.New("setup is still loading"))
+		return
+	}
+
+	var req ProveRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxProveRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		fail(http.StatusBadRequest, fmt.Errorf("decode request: %w", err))
+		return
+	}
+	assignment, err := s.assign(req)
+	if err != nil {
+		fail(http.StatusBadRequest, err)
+		return
+	}
+	defer wipeAssignment(assignment)
+	key := proofCacheKey(req)
+	if resp, ok := s.cache.get(key); ok {
+		s.hits.Add(1)
+		writeJSONResponse(w, http.StatusOK, resp)
+		return
+	}
+
+	// Wait for a proving slot, giving up if the client goes away. The slot
+	// count is the memory ceiling: every running proof holds the full witness
+	// and the MSM buffers.
+	s.queued.Add(1)
+	select {
+	case s.sem <- struct{}{}:
+		s.queued.Add(-1)
+		s.inFlight.Add(1)
+		defer func() {
+			s.inFlight.Add(-1)
+			<-s.sem
+		}()
+	case <-r.Context().Done():
+		s.queued.Add(-1)
+		fail(http.StatusServiceUnavailable, r.Context().Err())
+		return
+	}
+
+	s.proofs.Add(1)
+	proof, publicWitness, err := h.proveAssignment(assignment, s.opts)
+	if err != nil {
+		fail(http.StatusInternalServerError, err)
+		return
+	}
+	bundle, err := buildBundleJSON(h.VK, proof, publicWitness, circuitNbPublic(h.CCS))
+	if err != nil {
+		fail(http.StatusInternalServerError, fmt.Errorf("export: %w", err))
+		return
+	}
+	resp := ProveResponse{
+		Proof:          bundle.Proof,
+		Public:         bundle.Public,
+		CommitmentWire: bundle.CommitmentWire,
+		WitnessHash:    bundle.WitnessHash,
+	}
+	s.cache.put(key, resp)
+	writeJSONResponse(w, http.StatusOK, resp)
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSONResponse(w, status, map[string]string{"error": err.Error()})
+}
+
+// Serve runs handler on ln until ctx is cancelled, then stops accepting
+// connections and waits up to shutdownTimeout for in-flight proofs to finish.
+func Serve(ctx context.Context, ln net.Listener, handler http.Handler, shutdownTimeout time.Duration) error {
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -36,6 +36,10 @@ func testVerify() {
 		fmt.Fprintf(os.Stderr, "unmarshal vk.json: %v\n", err)
 		os.Exit(1)
 	}
+	if err := checkCurve("vk.json", vkJSON.Curve); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	// Load proof
 	proofData, err := os.ReadFile(filepath.Join(outDir, "proof.json"))
@@ -48,6 +52,10 @@ func testVerify() {
 		fmt.Fprintf(os.Stderr, "unmarshal proof.json: %v\n", err)
 		os.Exit(1)
 	}
+	if err := checkCurve("proof.json", proofJSON.Curve); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	// Load public inputs
 	publicData, err := os.ReadFile(filepath.Join(outDir, "public.json"))
@@ -0,0 +1,108 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// trace.go explains a vw0w1 witness the solver rejected. gnark reports an
+// unsatisfied constraint by index only, and the emulated field defers its
+// equality checks to one batched check at the end of the circuit, so the index
+// cannot be mapped back to a line of define. Instead each relation is compiled
+// on its own and the witness is solved against it with IsSolved; the ones
+// that fail are named.
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+)
+
+// vw0w1Relation names one of the equalities the vw0w1 circuit enforces.
+type vw0w1Relation int
+
+const (
+	relationW0 vw0w1Relation = iota + 1 // w0 == [hk]q
+	relationW1                          // w1 == [a]q + [r]v
+)
+
+// vw0w1Relations lists the relations in the order define adds them.
+var vw0w1Relations = []vw0w1Relation{relationW0, relationW1}
+
+func (rel vw0w1Relation) String() string {
+	switch rel {
+	case relationW0:
+		return "W0 = [hk]G"
+	case relationW1:
+		return "W1 = [a]G + [r]V"
+	}
+	return fmt.Sprintf("vw0w1Relation(%d)", int(rel))
+}
+
+// vw0w1RelationCircuit is vw0w1Circuit restricted to one relation.
+type vw0w1RelationCircuit struct {
+	Statement vw0w1Circuit
+	only      vw0w1Relation
+}
+
+// Define adds the constraints of c.only alone.
+func (c *vw0w1RelationCircuit) Define(api frontend.API) error {
+	return c.Statement.define(api, &vw0w1Gadgets{only: c.only})
+}
+
+// ErrRelationFailed is returned (wrapped) by traceVW0W1 for each relation the
+// assignment does not satisfy.
+var ErrRelationFailed = errors.New("check failed")
+
+// traceVW0W1 solves assignment against each vw0w1 relation separately and
+// returns an error naming every relation that does not hold, or nil if all
+// do. Each relation is compiled afresh, so this takes about as long as one
+// compilation of the full circuit; it is meant for failures only.
+func traceVW0W1(assignment *vw0w1Circuit) error {
+	var failed []error
+	for _, rel := range vw0w1Relations {
+		ccs, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &vw0w1RelationCircuit{only: rel})
+		if err != nil {
+			return fmt.Errorf("trace %s: compile: %w", rel, err)
+		}
+		w, err := frontend.NewWitness(&vw0w1RelationCircuit{Statement: *assignment, only: rel}, ecc.BLS12_381.ScalarField())
+		if err != nil {
+			return fmt.Errorf("trace %s: new witness: %w", rel, err)
+		}
+		if err := ccs.IsSolved(w); err != nil {
+			failed = append(failed, fmt.Errorf("%s %w", rel, ErrRelationFailed))
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// traceProveError annotates a failed Prove of assignment with the relations
+// it violates, when assignment is a vw0w1 statement or batch. Other circuits
+// and traces that find nothing leave err unchanged.
+func traceProveError(err error, assignment frontend.Circuit) error {
+	var statements []vw0w1Circuit
+	switch a := assignment.(type) {
+	case *vw0w1Circuit:
+		statements = []vw0w1Circuit{*a}
+	case *vw0w1BatchCircuit:
+		statements = a.Items
+	default:
+		return err
+	}
+
+	var found []error
+	for i := range statements {
+		traceErr := traceVW0W1(&statements[i])
+		if traceErr == nil {
+			continue
+		}
+		if len(statements) > 1 {
+			traceErr = fmt.Errorf("statement %d: %w", i, traceErr)
+		}
+		found = append(found, traceErr)
+	}
+	if len(found) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w\n%w", errors.Join(found...), err)
+}
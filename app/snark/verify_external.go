@@ -0,0 +1,72 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// verify_external.go checks proofs produced elsewhere, typically by the WASM
+// browser prover, against the authoritative vk.bin of a setup directory. The
+// browser does not ship a VK with its proof, so the JSON verifier alone would
+// have to trust one; here the key comes from the ceremony output, and the
+// commitment wire the browser computed is checked against the proof.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	groth16bls "github.com/consensys/gnark/backend/groth16/bls12-381"
+)
+
+// ExternalProof is the object the WASM prover returns: the proof.json and
+// public.json payloads side by side.
+type ExternalProof struct {
+	Proof  ProofJSON  `json:"proof"`
+	Public PublicJSON `json:"public"`
+}
+
+// LoadExternalProof reads a WASM prover result from resultPath or, when
+// resultPath is empty, from the separate proofPath and publicPath files.
+func LoadExternalProof(resultPath, proofPath, publicPath string) (ExternalProof, error) {
+	var ext ExternalProof
+	if resultPath != "" {
+		if err := readJSONFile(resultPath, &ext); err != nil {
+			return ExternalProof{}, err
+		}
+		return ext, nil
+	}
+	if err := readJSONFile(proofPath, &ext.Proof); err != nil {
+		return ExternalProof{}, err
+	}
+	if err := readJSONFile(publicPath, &ext.Public); err != nil {
+		return ExternalProof{}, err
+	}
+	return ext, nil
+}
+
+// VerifyExternalProof verifies ext against vk.bin in setupDir. A proof with
+// commitments must carry the commitment wire the on-chain verifier needs; it
+// is recomputed from the proof and compared, a difference yielding
+// ErrWireMismatch. opts.ExpectWire, if set, takes precedence over the wire
+// in ext.
+func VerifyExternalProof(setupDir string, ext ExternalProof, opts VerifyOptions) error {
+	f, err := os.Open(filepath.Join(setupDir, "vk.bin"))
+	if err != nil {
+		return fmt.Errorf("open vk.bin: %w", err)
+	}
+	defer f.Close()
+	vkAny, err := readVerifyingKey(f)
+	if err != nil {
+		return fmt.Errorf("read vk.bin: %w", err)
+	}
+	vk, ok := vkAny.(*groth16bls.VerifyingKey)
+	if !ok {
+		return fmt.Errorf("unexpected vk type: %T", vkAny)
+	}
+
+	if opts.ExpectWire == "" {
+		if len(ext.Proof.Commitments) > 0 && ext.Public.CommitmentWire == "" {
+			return fmt.Errorf("public has no commitmentWire; the proof has commitments and cannot be verified on-chain without it")
+		}
+		opts.ExpectWire = ext.Public.CommitmentWire
+	}
+	return verifyJSONWithVK(vk, ext.Proof, ext.Public, opts)
+}
@@ -0,0 +1,436 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// verify_json.go verifies a proof from its exported JSON artifacts rather than
+// the gnark-native binaries. It accepts either the three separate files
+// (vk.json, proof.json, public.json) or the combined all.json bundle, so the
+// verify side is symmetric with ExportAllWithOptions.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr/pedersen"
+	groth16bls "github.com/consensys/gnark/backend/groth16/bls12-381"
+)
+
+// LoadJSONArtifacts reads the exported JSON artifacts from dir. If all.json is
+// present it is used; otherwise vk.json, proof.json and public.json are read.
+func LoadJSONArtifacts(dir string) (VKJSON, ProofJSON, PublicJSON, error) {
+	return loadJSONArtifacts(dir, false)
+}
+
+// loadJSONArtifacts is LoadJSONArtifacts, rejecting unknown fields when
+// strict is set (see decodeJSONFile).
+func loadJSONArtifacts(dir string, strict bool) (VKJSON, ProofJSON, PublicJSON, error) {
+	if _, err := os.Stat(filepath.Join(dir, "all.json")); err == nil {
+		return loadBundleArtifacts(dir, strict)
+	}
+	return loadSeparateArtifacts(dir, strict)
+}
+
+// loadBundleArtifacts reads all.json from dir.
+func loadBundleArtifacts(dir string, strict bool) (VKJSON, ProofJSON, PublicJSON, error) {
+	var bundle BundleJSON
+	if err := decodeJSONFile(filepath.Join(dir, "all.json"), &bundle, strict); err != nil {
+		return VKJSON{}, ProofJSON{}, PublicJSON{}, err
+	}
+	if bundle.Public.CommitmentWire == "" {
+		bundle.Public.CommitmentWire = bundle.CommitmentWire
+	}
+	return bundle.VK, bundle.Proof, bundle.Public, nil
+}
+
+// loadSeparateArtifacts reads vk.json, proof.json and public.json from dir.
+func loadSeparateArtifacts(dir string, strict bool) (VKJSON, ProofJSON, PublicJSON, error) {
+	var vkj VKJSON
+	if err := decodeJSONFile(filepath.Join(dir, "vk.json"), &vkj, strict); err != nil {
+		return VKJSON{}, ProofJSON{}, PublicJSON{}, err
+	}
+	pj, pubj, err := loadProofAndPublic(dir, strict)
+	if err != nil {
+		return VKJSON{}, ProofJSON{}, PublicJSON{}, err
+	}
+	return vkj, pj, pubj, nil
+}
+
+// loadProofAndPublic reads proof.json and public.json from dir.
+func loadProofAndPublic(dir string, strict bool) (ProofJSON, PublicJSON, error) {
+	var pj ProofJSON
+	var pubj PublicJSON
+	if err := decodeJSONFile(filepath.Join(dir, "proof.json"), &pj, strict); err != nil {
+		return ProofJSON{}, PublicJSON{}, err
+	}
+	if err := decodeJSONFile(filepath.Join(dir, "public.json"), &pubj, strict); err != nil {
+		return ProofJSON{}, PublicJSON{}, err
+	}
+	return pj, pubj, nil
+}
+
+// verifyExported re-reads the artifacts ExportAllWithOptions just wrote to
+// dir for opts and verifies them with VerifyJSON: the three separate files
+// and all.json, whichever were written. It catches an exporter that
+// mis-serializes a point before the artifacts leave the machine. The files
+// are read strictly, so a field the importers would drop fails here too.
+func verifyExported(dir string, opts ExportOptions) error {
+	vopts := VerifyOptions{ExpectedICLen: opts.ExpectedICLen}
+	if !opts.BundleOnly {
+		vkj, pj, pubj, err := loadSeparateArtifacts(dir, true)
+		if err == nil {
+			err = VerifyJSONWithOptions(vkj, pj, pubj, vopts)
+		}
+		if err != nil {
+			return fmt.Errorf("vk.json/proof.json/public.json: %w", err)
+		}
+	}
+	if opts.Bundle || opts.BundleOnly {
+		vkj, pj, pubj, err := loadBundleArtifacts(dir, true)
+		if err == nil {
+			err = VerifyJSONWithOptions(vkj, pj, pubj, vopts)
+		}
+		if err != nil {
+			return fmt.Errorf("all.json: %w", err)
+		}
+	}
+	return nil
+}
+
+// VerifyJSONFromDir loads the JSON artifacts in dir (see LoadJSONArtifacts)
+// and verifies them with VerifyJSON.
+func VerifyJSONFromDir(dir string) error {
+	return VerifyJSONFromDirWithOptions(dir, VerifyOptions{})
+}
+
+// VerifyJSONFromDirWithOptions is VerifyJSONFromDir with explicit VerifyOptions.
+// With opts.VKPath the proof is checked against that key and any VK in dir
+// is ignored.
+func VerifyJSONFromDirWithOptions(dir string, opts VerifyOptions) error {
+	if opts.VKPath != "" {
+		vk, err := loadVerifyingKeyFile(opts.VKPath, opts.StrictJSON, opts.AcceptUncompressedVK)
+		if err != nil {
+			return err
+		}
+		pj, pubj, err := loadProofArtifacts(dir, opts.StrictJSON)
+		if err != nil {
+			return err
+		}
+		return verifyJSONWithVK(vk, pj, pubj, opts)
+	}
+	vkj, pj, pubj, err := loadJSONArtifacts(dir, opts.StrictJSON)
+	if err != nil {
+		return err
+	}
+	return VerifyJSONWithOptions(vkj, pj, pubj, opts)
+}
+
+// loadProofArtifacts reads only the proof and public inputs from dir: from
+// all.json if present, otherwise from proof.json and public.json.
+func loadProofArtifacts(dir string, strict bool) (ProofJSON, PublicJSON, error) {
+	if _, err := os.Stat(filepath.Join(dir, "all.json")); err == nil {
+		_, pj, pubj, err := loadBundleArtifacts(dir, strict)
+		return pj, pubj, err
+	}
+	return loadProofAndPublic(dir, strict)
+}
+
+// LoadVerifyingKeyFile reads a verifying key from path, either gnark's vk.bin
+// or a vk.json (detected by a leading '{').
+func LoadVerifyingKeyFile(path string) (*groth16bls.VerifyingKey, error) {
+	return loadVerifyingKeyFile(path, false, false)
+}
+
+// loadVerifyingKeyFile is LoadVerifyingKeyFile, rejecting unknown fields in
+// a vk.json when strict is set. With acceptDecimal it also reads a key in
+// decimal affine coordinates (see vkdecimal.go); without it such a key is
+// refused with ErrDecimalVK rather than misread as a vk.json missing its
+// points.
+func loadVerifyingKeyFile(path string, strict, acceptDecimal bool) (*groth16bls.VerifyingKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read vk: %w", err)
+	}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		if isDecimalVK(trimmed) {
+			if !acceptDecimal {
+				return nil, fmt.Errorf("vk %s: %w (snarkjs/Solidity form); pass -accept-uncompressed-vk to import it", path, ErrDecimalVK)
+			}
+			var d DecimalVK
+			if err := decodeJSON(trimmed, &d, strict); err != nil {
+				return nil, fmt.Errorf("unmarshal %s: %w", path, err)
+			}
+			vk, _, err := vkFromDecimal(d)
+			if err != nil {
+				return nil, fmt.Errorf("vk %s: %w", path, err)
+			}
+			return vk, nil
+		}
+		var vkj VKJSON
+		if err := decodeJSON(trimmed, &vkj, strict); err != nil {
+			return nil, fmt.Errorf("unmarshal %s: %w", path, err)
+		}
+		vk, err := vkFromJSON(vkj)
+		if err != nil {
+			return nil, fmt.Errorf("vk %s: %w", path, err)
+		}
+		return vk, nil
+	}
+	vk, err := readVerifyingKey(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	v, ok := vk.(*groth16bls.VerifyingKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: unexpected vk type %T", path, vk)
+	}
+	return v, nil
+}
+
+// VerifyJSON rebuilds the gnark verifying key, proof and public witness from
+// their JSON exports and runs the standard Groth16 (+ commitment extension)
+// verification. The exported public vector may carry the leading "1" added by
+// choosePublicInputs; it is dropped before handing the witness to gnark.
+func VerifyJSON(vkj VKJSON, pj ProofJSON, pubj PublicJSON) error {
+	return VerifyJSONWithOptions(vkj, pj, pubj, VerifyOptions{})
+}
+
+// VerifyJSONWithOptions is VerifyJSON with explicit VerifyOptions.
+func VerifyJSONWithOptions(vkj VKJSON, pj ProofJSON, pubj PublicJSON, opts VerifyOptions) error {
+	vk, err := vkFromJSON(vkj)
+	if err != nil {
+		return fmt.Errorf("vk: %w", err)
+	}
+	return verifyJSONWithVK(vk, pj, pubj, opts)
+}
+
+// verifyJSONWithVK verifies the JSON proof and public inputs against an
+// already decoded verifying key.
+func verifyJSONWithVK(vk *groth16bls.VerifyingKey, pj ProofJSON, pubj PublicJSON, opts VerifyOptions) error {
+	if err := checkICLen(vk, opts.ExpectedICLen); err != nil {
+		return err
+	}
+
+	proof, err := proofFromJSON(pj)
+	if err != nil {
+		return fmt.Errorf("proof: %w", err)
+	}
+	if err := checkCommitment(proof, opts.RequireCommitment); err != nil {
+		return err
+	}
+
+	witness, err := publicInputs(pubj)
+	if err != nil {
+		return err
+	}
+
+	// gnark expects len(K) - nCommitments - 1 publics (the one-wire is implicit).
+	want := len(vk.G1.K) - len(vk.PublicAndCommitmentCommitted) - 1
+	if witness, err = dropLeadingWire(witness, want, opts.LeadingWire); err != nil {
+		return err
+	}
+
+	if err := checkCanonical(witness, opts.Canonical); err != nil {
+		return err
+	}
+	if err := groth16bls.Verify(proof, vk, witness); err != nil {
+		return fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+	}
+	return checkExpectedWire(proof, vk, witness, opts.ExpectWire)
+}
+
+// LeadingWire declares whether a public vector starts with the constant
+// one-wire "1". The WASM prover and `prove` write it; other producers may
+// not.
+type LeadingWire string
+
+const (
+	LeadingWireAuto     LeadingWire = ""        // infer from the length and the first value (the default)
+	LeadingWireIncluded LeadingWire = "include" // the vector starts with the one-wire
+	LeadingWireExcluded LeadingWire = "exclude" // the vector holds the circuit's public inputs only
+)
+
+// ParseLeadingWire parses the -leading-wire flag: "auto", "include" or
+// "exclude".
+func ParseLeadingWire(s string) (LeadingWire, error) {
+	switch s {
+	case "", "auto":
+		return LeadingWireAuto, nil
+	case "include":
+		return LeadingWireIncluded, nil
+	case "exclude":
+		return LeadingWireExcluded, nil
+	}
+	return "", fmt.Errorf("unknown leading wire mode %q (want auto, include or exclude)", s)
+}
+
+// dropLeadingWire returns the want public inputs of witness, removing the
+// one-wire as mode declares. LeadingWireAuto accepts either form and strips
+// a leading 1 only when the vector is one entry too long.
+func dropLeadingWire(witness fr.Vector, want int, mode LeadingWire) (fr.Vector, error) {
+	switch mode {
+	case LeadingWireIncluded:
+		if len(witness) != want+1 {
+			return nil, fmt.Errorf("public inputs length mismatch: got %d, vk expects %d plus the one-wire", len(witness), want)
+		}
+		if !witness[0].IsOne() {
+			return nil, fmt.Errorf("public inputs were declared to start with the one-wire, but the first value is %s", witness[0].String())
+		}
+		return witness[1:], nil
+	case LeadingWireExcluded:
+		if len(witness) != want {
+			return nil, fmt.Errorf("public inputs length mismatch: got %d, vk expects %d (declared without the one-wire)", len(witness), want)
+		}
+		return witness, nil
+	case LeadingWireAuto:
+		switch {
+		case len(witness) == want:
+			return witness, nil
+		case len(witness) == want+1 && witness[0].IsOne():
+			return witness[1:], nil
+		}
+		return nil, fmt.Errorf("public inputs length mismatch: got %d, vk expects %d", len(witness), want)
+	}
+	return nil, fmt.Errorf("unknown leading wire mode %q", mode)
+}
+
+// publicInputs parses the public vector of pubj from whichever form it
+// carries: decimal Inputs or big-endian hex InputsHex.
+func publicInputs(pubj PublicJSON) (fr.Vector, error) {
+	switch {
+	case len(pubj.Inputs) > 0 && len(pubj.InputsHex) > 0:
+		return nil, fmt.Errorf("public.json has both inputs and inputsHex; keep only one")
+	case len(pubj.InputsHex) > 0:
+		return ParsePublicInputsFromHex(pubj.InputsHex)
+	}
+
+	witness := make(fr.Vector, len(pubj.Inputs))
+	for i, s := range pubj.Inputs {
+		if _, err := witness[i].SetString(s); err != nil {
+			return nil, fmt.Errorf("parse public input %d: %w", i, err)
+		}
+	}
+	return witness, nil
+}
+
+// ParsePublicInputsFromHex decodes public inputs given as 32-byte big-endian
+// hex (as the on-chain tooling prints them; 0x prefix optional). Each value
+// must be exactly 32 bytes and strictly less than the Fr modulus.
+func ParsePublicInputsFromHex(inputs []string) ([]fr.Element, error) {
+	out := make([]fr.Element, len(inputs))
+	for i, h := range inputs {
+		raw, err := hex.DecodeString(normalizeHex(h))
+		if err != nil {
+			return nil, fmt.Errorf("public input %d: decode hex: %w", i, err)
+		}
+		if len(raw) != fr.Bytes {
+			return nil, fmt.Errorf("public input %d: got %d bytes, want %d", i, len(raw), fr.Bytes)
+		}
+		if out[i], err = fr.BigEndian.Element((*[fr.Bytes]byte)(raw)); err != nil {
+			return nil, fmt.Errorf("public input %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// checkCurve rejects an artifact recorded for a curve other than CurveName.
+// An empty curve (artifacts from before the field existed) is accepted.
+func checkCurve(artifact, curve string) error {
+	if curve != "" && curve != CurveName {
+		return fmt.Errorf("%s was exported for curve %q, this build only supports %q", artifact, curve, CurveName)
+	}
+	return nil
+}
+
+// vkFromJSON is the inverse of exportVKBLS.
+func vkFromJSON(vkj VKJSON) (*groth16bls.VerifyingKey, error) {
+	if err := checkCurve("vk", vkj.Curve); err != nil {
+		return nil, err
+	}
+	vk := &groth16bls.VerifyingKey{}
+
+	var err error
+	if vk.G1.Alpha, err = parseG1CompressedHex(vkj.VkAlpha); err != nil {
+		return nil, fmt.Errorf("vkAlpha: %w", err)
+	}
+	if vk.G2.Beta, err = parseG2CompressedHex(vkj.VkBeta); err != nil {
+		return nil, fmt.Errorf("vkBeta: %w", err)
+	}
+	if vk.G2.Gamma, err = parseG2CompressedHex(vkj.VkGamma); err != nil {
+		return nil, fmt.Errorf("vkGamma: %w", err)
+	}
+	if vk.G2.Delta, err = parseG2CompressedHex(vkj.VkDelta); err != nil {
+		return nil, fmt.Errorf("vkDelta: %w", err)
+	}
+
+	if len(vkj.VkIC) < 1 {
+		return nil, fmt.Errorf("vkIC is empty")
+	}
+	vk.G1.K = make([]bls12381.G1Affine, len(vkj.VkIC))
+	for i, h := range vkj.VkIC {
+		if vk.G1.K[i], err = parseG1CompressedHex(h); err != nil {
+			return nil, fmt.Errorf("vkIC[%d]: %w", i, err)
+		}
+	}
+
+	vk.CommitmentKeys = make([]pedersen.VerifyingKey, len(vkj.CommitmentKeys))
+	for i, ck := range vkj.CommitmentKeys {
+		if vk.CommitmentKeys[i].G, err = parseG2CompressedHex(ck.G); err != nil {
+			return nil, fmt.Errorf("commitmentKeys[%d].g: %w", i, err)
+		}
+		if vk.CommitmentKeys[i].GSigmaNeg, err = parseG2CompressedHex(ck.GSigmaNeg); err != nil {
+			return nil, fmt.Errorf("commitmentKeys[%d].gSigmaNeg: %w", i, err)
+		}
+	}
+
+	// omitempty drops an all-empty index list; gnark still needs one entry per commitment.
+	vk.PublicAndCommitmentCommitted = make([][]int, len(vk.CommitmentKeys))
+	if len(vkj.PublicAndCommitmentCommitted) > len(vk.CommitmentKeys) {
+		return nil, fmt.Errorf("publicAndCommitmentCommitted has %d entries for %d commitment keys",
+			len(vkj.PublicAndCommitmentCommitted), len(vk.CommitmentKeys))
+	}
+	for i := range vkj.PublicAndCommitmentCommitted {
+		vk.PublicAndCommitmentCommitted[i] = append([]int{}, vkj.PublicAndCommitmentCommitted[i]...)
+	}
+
+	if err := vk.Precompute(); err != nil {
+		return nil, fmt.Errorf("precompute: %w", err)
+	}
+	return vk, nil
+}
+
+// proofFromJSON is the inverse of exportProofBLS.
+func proofFromJSON(pj ProofJSON) (*groth16bls.Proof, error) {
+	if err := checkCurve("proof", pj.Curve); err != nil {
+		return nil, err
+	}
+	proof := &groth16bls.Proof{}
+
+	var err error
+	if proof.Ar, err = parseG1CompressedHex(pj.PiA); err != nil {
+		return nil, fmt.Errorf("piA: %w", err)
+	}
+	if proof.Bs, err = parseG2CompressedHex(pj.PiB); err != nil {
+		return nil, fmt.Errorf("piB: %w", err)
+	}
+	if proof.Krs, err = parseG1CompressedHex(pj.PiC); err != nil {
+		return nil, fmt.Errorf("piC: %w", err)
+	}
+
+	if len(pj.Commitments) > 0 {
+		proof.Commitments = make([]bls12381.G1Affine, len(pj.Commitments))
+		for i, h := range pj.Commitments {
+			if proof.Commitments[i], err = parseG1CompressedHex(h); err != nil {
+				return nil, fmt.Errorf("commitments[%d]: %w", i, err)
+			}
+		}
+		if proof.CommitmentPok, err = parseG1CompressedHex(pj.CommitmentPok); err != nil {
+			return nil, fmt.Errorf("commitmentPok: %w", err)
+		}
+	}
+	return proof, nil
+}
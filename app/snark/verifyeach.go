@@ -0,0 +1,105 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// verifyeach.go verifies a directory of proof directories, one per listing,
+// the way CI checks a block's worth of proofs. By default it stops at the
+// first proof that fails, like a single verify would. With ContinueOnError
+// it verifies them all and reports every failure by directory name, so one
+// bad listing does not hide the next.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ErrProofsFailed is returned (wrapped) by VerifyEach with ContinueOnError
+// when at least one proof directory fails.
+var ErrProofsFailed = errors.New("proofs failed verification")
+
+// VerifyEachOptions tunes VerifyEach. The zero value verifies each directory
+// like VerifyFromFiles and stops at the first failure.
+type VerifyEachOptions struct {
+	// Verify is applied to every directory. VKPath is the usual choice for a
+	// batch: every proof is then checked against the same canonical VK.
+	Verify VerifyOptions
+
+	// JSON verifies the JSON artifacts (VerifyJSONFromDirWithOptions) instead
+	// of the binary ones.
+	JSON bool
+
+	// ContinueOnError verifies every directory even after one fails.
+	ContinueOnError bool
+}
+
+// ProofDirResult is the outcome of verifying one proof directory.
+type ProofDirResult struct {
+	Name string // directory name under the parent
+	Err  error  // nil when the proof verified
+}
+
+// proofDirMarkers are the files that make a subdirectory a proof directory.
+var proofDirMarkers = []string{"proof.bin", "proof.json", "all.json"}
+
+// proofDirs lists the subdirectories of parent holding proof artifacts, in
+// name order. Other entries are skipped.
+func proofDirs(parent string) ([]string, error) {
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		for _, marker := range proofDirMarkers {
+			if _, err := os.Stat(filepath.Join(parent, e.Name(), marker)); err == nil {
+				names = append(names, e.Name())
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// VerifyEach verifies every proof directory under parent (see proofDirs) and
+// returns one ProofDirResult per directory verified. Without
+// opts.ContinueOnError it stops at the first failure and returns it, prefixed
+// with the directory name. With it, every directory is verified and the error
+// wraps ErrProofsFailed with the failure count.
+func VerifyEach(parent string, opts VerifyEachOptions) ([]ProofDirResult, error) {
+	names, err := proofDirs(parent)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no proof directories in %s (looked for subdirectories with proof.bin, proof.json or all.json)", parent)
+	}
+
+	verify := VerifyFromFilesWithOptions
+	if opts.JSON {
+		verify = VerifyJSONFromDirWithOptions
+	}
+	results := make([]ProofDirResult, 0, len(names))
+	failed := 0
+	for _, name := range names {
+		err := verify(filepath.Join(parent, name), opts.Verify)
+		results = append(results, ProofDirResult{Name: name, Err: err})
+		if err == nil {
+			continue
+		}
+		if !opts.ContinueOnError {
+			return results, fmt.Errorf("%s: %w", name, err)
+		}
+		failed++
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%w: %d of %d", ErrProofsFailed, failed, len(results))
+	}
+	return results, nil
+}
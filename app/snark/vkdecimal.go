@@ -0,0 +1,192 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// vkdecimal.go imports verifying keys written with decimal affine
+// coordinates, the verification_key.json shape of snarkjs that Solidity
+// verifier generators read and write, instead of our compressed hex:
+//
+//	{"protocol": "groth16", "curve": "bls12381", "nPublic": n,
+//	 "vk_alpha_1": [x, y, "1"],
+//	 "vk_beta_2":  [[x.c0, x.c1], [y.c0, y.c1], ["1", "0"]], ...
+//	 "IC": [[x, y, "1"], ...]}
+//
+// The trailing projective z may be left out. It is the inverse of
+// g1ToXYDec/g2ToXYDec. Such keys carry no BSB22 commitment keys, so only
+// proofs without commitments verify against them. The order of the Fp2
+// halves is not recorded in the file (see coords.go); the importer tries
+// c0,c1 first and falls back to c1,c0, since with the wrong order a G2
+// point is off the curve.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fp"
+	groth16bls "github.com/consensys/gnark/backend/groth16/bls12-381"
+)
+
+// ErrDecimalVK is returned (wrapped) when a decimal-coordinate verifying key
+// is read without AcceptUncompressedVK.
+var ErrDecimalVK = errors.New("verifying key uses decimal coordinates")
+
+// DecimalVK is a verifying key in the snarkjs decimal-coordinate shape.
+// vk_alphabeta_12 is not read; it is e(α, β), which gnark recomputes.
+type DecimalVK struct {
+	Protocol string     `json:"protocol"`
+	Curve    string     `json:"curve"`
+	NPublic  int        `json:"nPublic"`
+	Alpha1   []string   `json:"vk_alpha_1"`
+	Beta2    [][]string `json:"vk_beta_2"`
+	Gamma2   [][]string `json:"vk_gamma_2"`
+	Delta2   [][]string `json:"vk_delta_2"`
+	IC       [][]string `json:"IC"`
+
+	AlphaBeta12 json.RawMessage `json:"vk_alphabeta_12,omitempty"`
+}
+
+// isDecimalVK reports whether the JSON object raw is a decimal-coordinate
+// verifying key rather than a vk.json.
+func isDecimalVK(raw []byte) bool {
+	var probe struct {
+		Alpha1 json.RawMessage `json:"vk_alpha_1"`
+	}
+	return json.Unmarshal(raw, &probe) == nil && probe.Alpha1 != nil
+}
+
+// vkFromDecimal converts a decimal-coordinate key into a gnark verifying
+// key, checking every point is on the curve and in the subgroup. It returns
+// the Fp2 order the G2 points were found in.
+func vkFromDecimal(d DecimalVK) (*groth16bls.VerifyingKey, Fp2Order, error) {
+	if d.Protocol != "" && d.Protocol != "groth16" {
+		return nil, 0, fmt.Errorf("protocol %q, expected groth16", d.Protocol)
+	}
+	if d.Curve != "" && d.Curve != CurveName {
+		return nil, 0, fmt.Errorf("key is for curve %q, this build only supports %q", d.Curve, CurveName)
+	}
+	if len(d.IC) < 1 {
+		return nil, 0, fmt.Errorf("IC is empty")
+	}
+	if d.NPublic != 0 && len(d.IC) != d.NPublic+1 {
+		return nil, 0, fmt.Errorf("IC has %d points, nPublic %d expects %d", len(d.IC), d.NPublic, d.NPublic+1)
+	}
+
+	vk := &groth16bls.VerifyingKey{}
+	var err error
+	if vk.G1.Alpha, err = g1FromDec(d.Alpha1); err != nil {
+		return nil, 0, fmt.Errorf("vk_alpha_1: %w", err)
+	}
+	vk.G1.K = make([]bls12381.G1Affine, len(d.IC))
+	for i, p := range d.IC {
+		if vk.G1.K[i], err = g1FromDec(p); err != nil {
+			return nil, 0, fmt.Errorf("IC[%d]: %w", i, err)
+		}
+	}
+
+	order := Fp2OrderC0C1
+	if err = decimalG2s(vk, d, order); err != nil {
+		order = Fp2OrderC1C0
+		if errSwapped := decimalG2s(vk, d, order); errSwapped != nil {
+			return nil, 0, err
+		}
+	}
+
+	// No commitments: gnark still expects the (empty) index lists.
+	vk.PublicAndCommitmentCommitted = [][]int{}
+	if err := vk.Precompute(); err != nil {
+		return nil, 0, fmt.Errorf("precompute: %w", err)
+	}
+	return vk, order, nil
+}
+
+// decimalG2s sets β, γ and δ of vk from d, reading the Fp2 halves in order.
+func decimalG2s(vk *groth16bls.VerifyingKey, d DecimalVK, order Fp2Order) error {
+	var err error
+	if vk.G2.Beta, err = g2FromDec(d.Beta2, order); err != nil {
+		return fmt.Errorf("vk_beta_2: %w", err)
+	}
+	if vk.G2.Gamma, err = g2FromDec(d.Gamma2, order); err != nil {
+		return fmt.Errorf("vk_gamma_2: %w", err)
+	}
+	if vk.G2.Delta, err = g2FromDec(d.Delta2, order); err != nil {
+		return fmt.Errorf("vk_delta_2: %w", err)
+	}
+	return nil
+}
+
+// fpFromDec parses a decimal coordinate, which must be below the modulus.
+func fpFromDec(s string) (fp.Element, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return fp.Element{}, fmt.Errorf("%q is not a decimal integer", s)
+	}
+	if n.Sign() < 0 || n.Cmp(fp.Modulus()) >= 0 {
+		return fp.Element{}, fmt.Errorf("%s is not in [0, Fp modulus)", s)
+	}
+	var e fp.Element
+	e.SetBigInt(n)
+	return e, nil
+}
+
+// g1FromDec parses [x, y] or [x, y, "1"].
+func g1FromDec(c []string) (bls12381.G1Affine, error) {
+	if len(c) == 3 && c[2] != "1" {
+		return bls12381.G1Affine{}, fmt.Errorf("projective z is %q; only affine points (z = 1) are accepted", c[2])
+	}
+	if len(c) != 2 && len(c) != 3 {
+		return bls12381.G1Affine{}, fmt.Errorf("got %d coordinates, want [x, y] or [x, y, 1]", len(c))
+	}
+	var p bls12381.G1Affine
+	var err error
+	if p.X, err = fpFromDec(c[0]); err != nil {
+		return bls12381.G1Affine{}, fmt.Errorf("x: %w", err)
+	}
+	if p.Y, err = fpFromDec(c[1]); err != nil {
+		return bls12381.G1Affine{}, fmt.Errorf("y: %w", err)
+	}
+	if !p.IsOnCurve() {
+		return bls12381.G1Affine{}, fmt.Errorf("point is not on the curve")
+	}
+	if !p.IsInSubGroup() {
+		return bls12381.G1Affine{}, fmt.Errorf("point is not in the prime-order subgroup")
+	}
+	return p, nil
+}
+
+// g2FromDec parses [[x0, x1], [y0, y1]] or that followed by ["1", "0"], with
+// the halves of each coordinate in order.
+func g2FromDec(c [][]string, order Fp2Order) (bls12381.G2Affine, error) {
+	if len(c) == 3 && (len(c[2]) != 2 || c[2][0] != "1" || c[2][1] != "0") {
+		return bls12381.G2Affine{}, fmt.Errorf("projective z is %q; only affine points (z = 1) are accepted", c[2])
+	}
+	if len(c) != 2 && len(c) != 3 {
+		return bls12381.G2Affine{}, fmt.Errorf("got %d coordinates, want [x, y] or [x, y, z]", len(c))
+	}
+	var p bls12381.G2Affine
+	for i, dst := range []*[2]*fp.Element{{&p.X.A0, &p.X.A1}, {&p.Y.A0, &p.Y.A1}} {
+		if len(c[i]) != 2 {
+			return bls12381.G2Affine{}, fmt.Errorf("coordinate %d has %d halves, want 2", i, len(c[i]))
+		}
+		lo, hi := c[i][0], c[i][1]
+		if order == Fp2OrderC1C0 {
+			lo, hi = hi, lo
+		}
+		var err error
+		if *dst[0], err = fpFromDec(lo); err != nil {
+			return bls12381.G2Affine{}, fmt.Errorf("coordinate %d: %w", i, err)
+		}
+		if *dst[1], err = fpFromDec(hi); err != nil {
+			return bls12381.G2Affine{}, fmt.Errorf("coordinate %d: %w", i, err)
+		}
+	}
+	if !p.IsOnCurve() {
+		return bls12381.G2Affine{}, fmt.Errorf("point is not on the curve")
+	}
+	if !p.IsInSubGroup() {
+		return bls12381.G2Affine{}, fmt.Errorf("point is not in the prime-order subgroup")
+	}
+	return p, nil
+}
@@ -20,18 +20,12 @@ import (
 	"runtime/debug"
 	"syscall/js"
 
-	"reflect"
-
 	"github.com/consensys/gnark-crypto/ecc"
-	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
-	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr/hash_to_field"
 	"github.com/consensys/gnark/backend/groth16"
 	groth16bls "github.com/consensys/gnark/backend/groth16/bls12-381"
 	backend_witness "github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/std/math/emulated"
-	"github.com/consensys/gnark/std/math/emulated/emparams"
 )
 
 func init() {
@@ -47,6 +41,7 @@ type ProofResultWASM struct {
 
 // ProofJSONWASM matches the expected format
 type ProofJSONWASM struct {
+	Curve         string   `json:"curve,omitempty"`
 	PiA           string   `json:"piA"`
 	PiB           string   `json:"piB"`
 	PiC           string   `json:"piC"`
@@ -71,9 +66,20 @@ var (
 // wasmLoadSetup deserializes the constraint system and proving key from raw byte slices
 // into the global wasmCCS and wasmPK variables. This is called once after the WASM module
 // loads, before any proofs can be generated. The VK is not loaded because verification
-// happens on-chain, not in the browser.
-func wasmLoadSetup(ccsBytes, pkBytes []byte) error {
-	fmt.Printf("[WASM] wasmLoadSetup called with CCS=%d bytes, PK=%d bytes\n", len(ccsBytes), len(pkBytes))
+// happens on-chain, not in the browser. The proving key arrives as one or more parts:
+// a whole pk.bin, or the pk_NNNN.bin shards of a split key in shard order, which are
+// streamed through the decoder back to back. If expectedPKSize is positive, parts
+// totalling any other length are rejected before the slow deserialization starts.
+func wasmLoadSetup(ccsBytes []byte, pkParts [][]byte, expectedPKSize int64) error {
+	var pkSize int64
+	for _, part := range pkParts {
+		pkSize += int64(len(part))
+	}
+	fmt.Printf("[WASM] wasmLoadSetup called with CCS=%d bytes, PK=%d bytes in %d part(s)\n", len(ccsBytes), pkSize, len(pkParts))
+
+	if err := checkProvingKeySize(pkSize, expectedPKSize); err != nil {
+		return err
+	}
 
 	// Load CCS
 	fmt.Println("[WASM] Step 1/4: Creating constraint system object...")
@@ -88,17 +94,29 @@ func wasmLoadSetup(ccsBytes, pkBytes []byte) error {
 	fmt.Println("[WASM] Step 2/4: Done. CCS deserialized successfully.")
 
 	// Load PK
-	fmt.Println("[WASM] Step 3/4: Creating proving key object...")
-	pk := groth16.NewProvingKey(ecc.BLS12_381)
-	fmt.Println("[WASM] Step 3/4: Done. Proving key object created.")
+	fmt.Println("[WASM] Step 3/4: Detecting proving key encoding...")
+	raw, err := provingKeyIsRaw(joinPKParts(pkParts))
+	if err != nil {
+		return fmt.Errorf("read pk: %w", err)
+	}
+	encoding := "compressed"
+	if raw {
+		encoding = "raw"
+	}
+	fmt.Printf("[WASM] Step 3/4: Done. PK is %s.\n", encoding)
 
-	fmt.Printf("[WASM] Step 4/4: Deserializing PK (%d bytes)... This is the longest step.\n", len(pkBytes))
+	fmt.Printf("[WASM] Step 4/4: Deserializing PK (%d bytes, %s)... This is the longest step.\n", pkSize, encoding)
 	fmt.Println("[WASM] (The proving key contains millions of elliptic curve points to deserialize)")
-	if _, err := pk.ReadFrom(bytes.NewReader(pkBytes)); err != nil {
+	pk, err := readProvingKeyStream(joinPKParts(pkParts), false)
+	if err != nil {
 		return fmt.Errorf("read pk: %w", err)
 	}
 	fmt.Println("[WASM] Step 4/4: Done. PK deserialized successfully.")
 
+	if err := checkSetupConsistency(ccs, pk, nil); err != nil {
+		return err
+	}
+
 	// We don't need VK for proving, but we'll keep it nil
 	// VK is only needed for verification which happens on-chain
 
@@ -122,19 +140,34 @@ func wasmProve(aStr, rStr, vHex, w0Hex, w1Hex string) (*ProofResultWASM, error)
 	}
 	fmt.Println("[WASM] wasmProve: setup is loaded, parsing secrets...")
 
-	// Parse secrets
+	// Parse secrets. They and everything derived from them are wiped on
+	// return; the witness holds its own copies, cleared after proving.
 	a := new(big.Int)
+	defer wipeBigInt(a)
 	if _, ok := a.SetString(aStr, 0); !ok || a.Sign() == 0 {
 		return nil, fmt.Errorf("could not parse a (must be non-zero integer)")
 	}
 	fmt.Printf("[WASM] wasmProve: parsed a = %s\n", a.String())
 
 	r := new(big.Int)
+	defer wipeBigInt(r)
 	if _, ok := r.SetString(rStr, 0); !ok {
 		return nil, fmt.Errorf("could not parse r")
 	}
+	if err := validateR(r); err != nil {
+		return nil, err
+	}
 	fmt.Printf("[WASM] wasmProve: parsed r = %s\n", r.String())
 
+	hk, err := hkScalarFromA(a)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBigInt(hk)
+	if err := checkNonZeroHk(hk); err != nil {
+		return nil, err
+	}
+
 	// Parse public G1 points
 	fmt.Println("[WASM] wasmProve: parsing G1 point v...")
 	vAff, err := parseG1CompressedHex(vHex)
@@ -153,47 +186,20 @@ func wasmProve(aStr, rStr, vHex, w0Hex, w1Hex string) (*ProofResultWASM, error)
 	}
 	fmt.Println("[WASM] wasmProve: all G1 points parsed successfully")
 
-	// Reduce secrets into Fr
-	fmt.Println("[WASM] wasmProve: reducing secrets into Fr...")
-	var aFr, rFr fr.Element
-	aFr.SetBigInt(a)
-	rFr.SetBigInt(r)
-
-	var aRed, rRed big.Int
-	aFr.BigInt(&aRed)
-	rFr.BigInt(&rRed)
-	fmt.Printf("[WASM] wasmProve: reduced a = %s, r = %s\n", aRed.String(), rRed.String())
-
-	// Extract affine coords to big.Int
-	fmt.Println("[WASM] wasmProve: extracting affine coordinates...")
-	var vx, vy, w0x, w0y, w1x, w1y big.Int
-	vAff.X.ToBigIntRegular(&vx)
-	vAff.Y.ToBigIntRegular(&vy)
-	w0Aff.X.ToBigIntRegular(&w0x)
-	w0Aff.Y.ToBigIntRegular(&w0y)
-	w1Aff.X.ToBigIntRegular(&w1x)
-	w1Aff.Y.ToBigIntRegular(&w1y)
-	fmt.Println("[WASM] wasmProve: affine coordinates extracted")
+	// Reduce secrets into Fr and extract affine coords
+	fmt.Println("[WASM] wasmProve: reducing secrets and extracting affine coordinates...")
+	values := newVW0W1Values(a, r, vAff, w0Aff, w1Aff)
+	defer values.wipeSecrets()
+	fmt.Println(wasmReducedLogLine(&values.A, &values.R))
 
 	// Create witness assignment using the circuit from kappa.go
 	fmt.Println("[WASM] wasmProve: creating witness assignment...")
-	assignment := vw0w1Circuit{
-		A: emulated.ValueOf[emparams.BLS12381Fr](&aRed),
-		R: emulated.ValueOf[emparams.BLS12381Fr](&rRed),
-
-		VX: emulated.ValueOf[emparams.BLS12381Fp](&vx),
-		VY: emulated.ValueOf[emparams.BLS12381Fp](&vy),
-
-		W0X: emulated.ValueOf[emparams.BLS12381Fp](&w0x),
-		W0Y: emulated.ValueOf[emparams.BLS12381Fp](&w0y),
-
-		W1X: emulated.ValueOf[emparams.BLS12381Fp](&w1x),
-		W1Y: emulated.ValueOf[emparams.BLS12381Fp](&w1y),
-	}
+	assignment := values.assignment()
+	defer assignment.wipeSecrets()
 	fmt.Println("[WASM] wasmProve: witness assignment created")
 
 	fmt.Println("[WASM] wasmProve: creating frontend witness...")
-	witness, err := frontend.NewWitness(&assignment, ecc.BLS12_381.ScalarField())
+	witness, err := frontend.NewWitness(assignment, ecc.BLS12_381.ScalarField())
 	if err != nil {
 		return nil, fmt.Errorf("new witness: %w", err)
 	}
@@ -204,6 +210,7 @@ func wasmProve(aStr, rStr, vHex, w0Hex, w1Hex string) (*ProofResultWASM, error)
 	if err != nil {
 		return nil, fmt.Errorf("public witness: %w", err)
 	}
+	defer wipeSecretWitness(witness, publicWitness)
 	fmt.Println("[WASM] wasmProve: public witness extracted")
 
 	// Generate proof - reclaim memory first to maximize headroom
@@ -248,6 +255,7 @@ func wasmProve(aStr, rStr, vHex, w0Hex, w1Hex string) (*ProofResultWASM, error)
 	fmt.Println("[WASM] wasmProve: creating result struct...")
 	result := &ProofResultWASM{
 		Proof: ProofJSONWASM{
+			Curve:         proofJSON.Curve,
 			PiA:           proofJSON.PiA,
 			PiB:           proofJSON.PiB,
 			PiC:           proofJSON.PiC,
@@ -263,86 +271,32 @@ func wasmProve(aStr, rStr, vHex, w0Hex, w1Hex string) (*ProofResultWASM, error)
 	return result, nil
 }
 
-// computeCommitmentWireNoVK computes the commitment wire without a VK.
-// It hardcodes the committed indices [1..36] which is a fixed property of the
-// vw0w1Circuit (all public inputs are committed). This avoids needing to load
-// the VK in the WASM, saving ~99 minutes of deserialization.
+// computeCommitmentWireNoVK computes the commitment wire without a VK. The
+// committed public indices are read from the loaded CCS (see SummarizeCCS),
+// which is where Setup takes them from. This avoids needing to load the VK
+// in the WASM, saving ~99 minutes of deserialization.
 func computeCommitmentWireNoVK(proof groth16.Proof, publicWitness backend_witness.Witness) (string, error) {
 	p, ok := proof.(*groth16bls.Proof)
 	if !ok {
 		return "", fmt.Errorf("unexpected proof type: %T", proof)
 	}
-	if len(p.Commitments) == 0 {
-		return "", nil // No commitment extension
-	}
-
-	// Get public witness as Fr elements
-	vecAny := publicWitness.Vector()
-	if vecAny == nil {
-		return "", fmt.Errorf("publicWitness.Vector() returned nil")
-	}
-
-	var pubFr []fr.Element
-	switch v := vecAny.(type) {
-	case []fr.Element:
-		pubFr = v
-	default:
-		rv := reflect.ValueOf(vecAny)
-		if rv.Kind() != reflect.Slice {
-			return "", fmt.Errorf("unexpected witness vector type: %T", vecAny)
-		}
-		pubFr = make([]fr.Element, rv.Len())
-		for i := 0; i < rv.Len(); i++ {
-			ev := rv.Index(i)
-			if ev.Kind() == reflect.Interface && !ev.IsNil() {
-				ev = ev.Elem()
-			}
-			if ev.Type() == reflect.TypeOf(fr.Element{}) {
-				pubFr[i] = ev.Interface().(fr.Element)
-			} else {
-				var bi big.Int
-				m := ev.Addr().MethodByName("BigInt")
-				if m.IsValid() {
-					m.Call([]reflect.Value{reflect.ValueOf(&bi)})
-					pubFr[i].SetBigInt(&bi)
-				} else {
-					return "", fmt.Errorf("cannot convert witness[%d] to Fr: type %T", i, ev.Interface())
-				}
-			}
-		}
-	}
-
-	// All 36 public inputs are committed (indices 1-36, 1-based).
-	// This is a fixed property of the vw0w1Circuit.
-	commitment := p.Commitments[0]
-	commitmentBytes := commitment.Marshal() // 96 bytes uncompressed G1
-
-	prehash := make([]byte, 0, len(commitmentBytes)+len(pubFr)*32)
-	prehash = append(prehash, commitmentBytes...)
-
-	for i := 0; i < len(pubFr); i++ {
-		frBytes := pubFr[i].Marshal() // 32 bytes big-endian Fr
-		prehash = append(prehash, frBytes...)
+	pubFr, err := witnessFrElements(publicWitness)
+	if err != nil {
+		return "", err
 	}
-
-	hFunc := hash_to_field.New([]byte(constraint.CommitmentDst))
-	hFunc.Write(prehash)
-	hashBytes := hFunc.Sum(nil)
-	if len(hashBytes) == 0 {
-		return "", fmt.Errorf("hash_to_field returned empty result")
+	committed, err := committedPublicIndices(wasmCCS)
+	if err != nil {
+		return "", err
 	}
-
-	var wire fr.Element
-	wire.SetBytes(hashBytes)
-	var wireBi big.Int
-	wire.BigInt(&wireBi)
-	return wireBi.String(), nil
+	return ComputeCommitmentWireNoVK(p, pubFr, committed)
 }
 
 // gnarkLoadSetupJS is the JavaScript-callable wrapper for wasmLoadSetup.
-// It expects two Uint8Array arguments (CCS bytes and PK bytes), copies them
-// into Go memory, and returns a JS object with either {"success": true} or
-// {"error": "..."}. After loading, it triggers GC to reclaim the input buffers.
+// It expects the CCS bytes as a Uint8Array, the PK as a Uint8Array or as an
+// array of Uint8Array shards in order (pk_0000.bin, pk_0001.bin, ...), and an
+// optional expected total PK size in bytes, copies them into Go memory, and returns
+// a JS object with either {"success": true} or {"error": "..."}. After
+// loading, it triggers GC to reclaim the input buffers.
 func gnarkLoadSetupJS(this js.Value, args []js.Value) interface{} {
 	if len(args) < 2 {
 		return js.ValueOf(map[string]interface{}{
@@ -356,16 +310,31 @@ func gnarkLoadSetupJS(this js.Value, args []js.Value) interface{} {
 	ccsBytes := make([]byte, ccsLen)
 	js.CopyBytesToGo(ccsBytes, ccsArray)
 
-	// Get PK bytes from Uint8Array
-	pkArray := args[1]
-	pkLen := pkArray.Get("length").Int()
-	pkBytes := make([]byte, pkLen)
-	js.CopyBytesToGo(pkBytes, pkArray)
+	// Get PK bytes from a Uint8Array, or one part per shard from an array of them
+	pkArrays := []js.Value{args[1]}
+	if js.Global().Get("Array").Call("isArray", args[1]).Bool() {
+		pkArrays = make([]js.Value, args[1].Length())
+		for i := range pkArrays {
+			pkArrays[i] = args[1].Index(i)
+		}
+	}
+	pkParts := make([][]byte, len(pkArrays))
+	pkLen := 0
+	for i, pkArray := range pkArrays {
+		pkParts[i] = make([]byte, pkArray.Get("length").Int())
+		js.CopyBytesToGo(pkParts[i], pkArray)
+		pkLen += len(pkParts[i])
+	}
 
-	fmt.Printf("Loading setup: CCS=%d bytes, PK=%d bytes\n", ccsLen, pkLen)
+	fmt.Printf("Loading setup: CCS=%d bytes, PK=%d bytes in %d part(s)\n", ccsLen, pkLen, len(pkParts))
+
+	var expectedPKSize int64
+	if len(args) > 2 && args[2].Type() == js.TypeNumber {
+		expectedPKSize = int64(args[2].Float())
+	}
 
 	// Load setup
-	if err := wasmLoadSetup(ccsBytes, pkBytes); err != nil {
+	if err := wasmLoadSetup(ccsBytes, pkParts, expectedPKSize); err != nil {
 		return js.ValueOf(map[string]interface{}{
 			"error": err.Error(),
 		})
@@ -373,7 +342,7 @@ func gnarkLoadSetupJS(this js.Value, args []js.Value) interface{} {
 
 	// Drop big buffers and reclaim memory before proving
 	ccsBytes = nil
-	pkBytes = nil
+	pkParts = nil
 	runtime.GC()
 	debug.FreeOSMemory()
 
@@ -434,24 +403,18 @@ func gnarkProveJSInner(args []js.Value) (result interface{}) {
 	fmt.Printf("[WASM]   publicW1 length: %d (expected 96)\n", len(publicW1))
 
 	// Validate G1 point lengths (should be 96 hex chars = 48 bytes compressed)
-	if len(publicV) != 96 {
-		fmt.Printf("[WASM] ERROR: publicV has wrong length\n")
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("publicV must be 96 hex chars (got %d)", len(publicV)),
-		})
-	}
-	if len(publicW0) != 96 {
-		fmt.Printf("[WASM] ERROR: publicW0 has wrong length\n")
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("publicW0 must be 96 hex chars (got %d)", len(publicW0)),
-		})
-	}
-	if len(publicW1) != 96 {
-		fmt.Printf("[WASM] ERROR: publicW1 has wrong length\n")
+	// after trimming whitespace and an optional 0x prefix.
+	if err := checkHexArgs(
+		hexArg{"publicV", publicV, 96},
+		hexArg{"publicW0", publicW0, 96},
+		hexArg{"publicW1", publicW1, 96},
+	); err != nil {
+		fmt.Printf("[WASM] ERROR: %v\n", err)
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("publicW1 must be 96 hex chars (got %d)", len(publicW1)),
+			"error": err.Error(),
 		})
 	}
+	publicV, publicW0, publicW1 = normalizeHex(publicV), normalizeHex(publicW0), normalizeHex(publicW1)
 
 	fmt.Println("[WASM] Input validation passed, calling wasmProve...")
 
@@ -496,6 +459,15 @@ func gnarkIsReadyJS(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(wasmLoaded)
 }
 
+// gnarkGetParams returns the protocol constants the prover uses, so browser
+// code reads them from Go instead of keeping copies that can drift.
+//
+// Returns:
+//   - JSON object with "domainTag" (hex), "h0" (G2 compressed hex) and "curve"
+func gnarkGetParamsJS(this js.Value, args []js.Value) interface{} {
+	return js.ValueOf(ProtocolParams())
+}
+
 // gnarkGtToHash computes the GT hash from scalar a.
 // This is a lightweight operation that doesn't require the proving key setup.
 // Used for creating encryption listings.
@@ -568,29 +540,23 @@ func gnarkDecryptToHashJS(this js.Value, args []js.Value) interface{} {
 	fmt.Printf("[WASM] gnarkDecryptToHash: g1b=%d chars, r1=%d chars, shared=%d chars, g2b=%d chars\n",
 		len(g1bHex), len(r1Hex), len(sharedHex), len(g2bHex))
 
-	// Validate G1 points (96 hex chars)
-	if len(g1bHex) != 96 {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("g1bHex must be 96 hex chars (got %d)", len(g1bHex)),
-		})
-	}
-	if len(r1Hex) != 96 {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("r1Hex must be 96 hex chars (got %d)", len(r1Hex)),
-		})
-	}
-	// Validate G2 point (192 hex chars)
-	if len(sharedHex) != 192 {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("sharedHex must be 192 hex chars (got %d)", len(sharedHex)),
-		})
+	// Validate lengths after trimming whitespace and an optional 0x prefix:
+	// G1 points are 96 hex chars, G2 points 192.
+	hexArgs := []hexArg{
+		{"g1bHex", g1bHex, 96},
+		{"r1Hex", r1Hex, 96},
+		{"sharedHex", sharedHex, 192},
 	}
 	// g2bHex can be empty (for half-level) or 192 chars (for full-level)
-	if g2bHex != "" && len(g2bHex) != 192 {
+	if normalizeHex(g2bHex) != "" {
+		hexArgs = append(hexArgs, hexArg{"g2bHex", g2bHex, 192})
+	}
+	if err := checkHexArgs(hexArgs...); err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("g2bHex must be empty or 192 hex chars (got %d)", len(g2bHex)),
+			"error": err.Error(),
 		})
 	}
+	g1bHex, r1Hex, sharedHex, g2bHex = normalizeHex(g1bHex), normalizeHex(r1Hex), normalizeHex(sharedHex), normalizeHex(g2bHex)
 
 	fmt.Println("[WASM] gnarkDecryptToHash: computing decryption hash...")
 	hashHex, err := DecryptToHash(g1bHex, g2bHex, r1Hex, sharedHex)
@@ -612,7 +578,7 @@ func gnarkDecryptToHashJS(this js.Value, args []js.Value) interface{} {
 // on the global JS object and blocks forever to keep the Go runtime alive.
 func main() {
 	fmt.Println("SNARK WASM prover loaded")
-	fmt.Println("Available functions: gnarkLoadSetup, gnarkProve, gnarkIsReady, gnarkGtToHash, gnarkDecryptToHash")
+	fmt.Println("Available functions: gnarkLoadSetup, gnarkProve, gnarkIsReady, gnarkGtToHash, gnarkDecryptToHash, gnarkGetParams")
 
 	// Register JavaScript functions
 	js.Global().Set("gnarkLoadSetup", js.FuncOf(gnarkLoadSetupJS))
@@ -620,6 +586,7 @@ func main() {
 	js.Global().Set("gnarkIsReady", js.FuncOf(gnarkIsReadyJS))
 	js.Global().Set("gnarkGtToHash", js.FuncOf(gnarkGtToHashJS))
 	js.Global().Set("gnarkDecryptToHash", js.FuncOf(gnarkDecryptToHashJS))
+	js.Global().Set("gnarkGetParams", js.FuncOf(gnarkGetParamsJS))
 
 	// Keep the Go runtime alive
 	<-make(chan struct{})
@@ -0,0 +1,51 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// witnessdump.go writes the vw0w1 assignment for `prove -witness-out`, so a
+// proof that fails in-circuit can be reproduced and compared field by field.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// VW0W1WitnessJSON is the vw0w1Circuit assignment with every emulated element
+// as a decimal string. Keys match the circuit's gnark tags.
+type VW0W1WitnessJSON struct {
+	A   string `json:"a"`
+	R   string `json:"r"`
+	VX  string `json:"vx"`
+	VY  string `json:"vy"`
+	W0X string `json:"w0x"`
+	W0Y string `json:"w0y"`
+	W1X string `json:"w1x"`
+	W1Y string `json:"w1y"`
+}
+
+// JSON returns x in its -witness-out form.
+func (x *vw0w1Values) JSON() VW0W1WitnessJSON {
+	return VW0W1WitnessJSON{
+		A:   x.A.String(),
+		R:   x.R.String(),
+		VX:  x.VX.String(),
+		VY:  x.VY.String(),
+		W0X: x.W0X.String(),
+		W0Y: x.W0Y.String(),
+		W1X: x.W1X.String(),
+		W1Y: x.W1Y.String(),
+	}
+}
+
+// writeJSON writes x to path as indented JSON.
+func (x *vw0w1Values) writeJSON(path string) error {
+	b, err := json.MarshalIndent(x.JSON(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal witness: %w", err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write witness: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2025 Logical Mechanism LLC
+// SPDX-License-Identifier: GPL-3.0-only
+
+// witnesshash.go derives witnessHash, a stable identifier for the statement a
+// vw0w1 proof is about: sha256 over the compressed bytes of v || w0 || w1.
+// Proof bytes are randomized on every run, so two proofs of the same statement
+// never compare equal; their witnessHash does. It keys audit logs and
+// deduplication. The points are recovered from the public witness (see
+// canonical.go for the limb layout), so every export path fills it in without
+// being handed the points.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fp"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	"github.com/consensys/gnark/std/math/emulated/emparams"
+)
+
+// vw0w1PublicInputsPerStatement is the number of public inputs one vw0w1
+// statement contributes: three points, two coordinates each, in emulated
+// limbs.
+var vw0w1PublicInputsPerStatement = 6 * int(emparams.BLS12381Fp{}.NbLimbs())
+
+// WitnessHash returns the witnessHash of the statement over the compressed
+// G1 points v, w0, w1 (0x prefix optional). The points must be in the
+// prime-order subgroup.
+func WitnessHash(vHex, w0Hex, w1Hex string) (string, error) {
+	vAff, w0Aff, w1Aff, err := parseVW0W1Points(normalizeHex(vHex), normalizeHex(w0Hex), normalizeHex(w1Hex), false)
+	if err != nil {
+		return "", err
+	}
+	return witnessHashOfPoints([]bls12381.G1Affine{vAff, w0Aff, w1Aff}), nil
+}
+
+// witnessHashOfPoints hashes the compressed bytes of points in order.
+func witnessHashOfPoints(points []bls12381.G1Affine) string {
+	h := sha256.New()
+	for i := range points {
+		b := points[i].Bytes()
+		h.Write(b[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// witnessHashFromPublic recovers the points of one or more vw0w1 statements
+// from a public witness vector (without the one-wire) and returns their
+// witnessHash. For a batch the points of every statement are hashed in order.
+// It returns "" when pub is not a vw0w1 public vector, e.g. for the test
+// circuits or the w-from-hk circuit.
+func witnessHashFromPublic(pub fr.Vector) string {
+	if len(pub) == 0 || len(pub)%vw0w1PublicInputsPerStatement != 0 {
+		return ""
+	}
+	nbLimbs := int(emparams.BLS12381Fp{}.NbLimbs())
+	bitsPerLimb := emparams.BLS12381Fp{}.BitsPerLimb()
+	limbBound := new(big.Int).Lsh(big.NewInt(1), bitsPerLimb)
+
+	coord := func(limbs fr.Vector) (fp.Element, bool) {
+		acc := new(big.Int)
+		for i := len(limbs) - 1; i >= 0; i-- {
+			var l big.Int
+			limbs[i].BigInt(&l)
+			if l.Cmp(limbBound) >= 0 {
+				return fp.Element{}, false
+			}
+			acc.Lsh(acc, bitsPerLimb).Add(acc, &l)
+		}
+		if acc.Cmp(fp.Modulus()) >= 0 {
+			return fp.Element{}, false
+		}
+		var e fp.Element
+		e.SetBigInt(acc)
+		return e, true
+	}
+
+	points := make([]bls12381.G1Affine, 0, len(pub)/(2*nbLimbs))
+	for off := 0; off < len(pub); off += 2 * nbLimbs {
+		x, okX := coord(pub[off : off+nbLimbs])
+		y, okY := coord(pub[off+nbLimbs : off+2*nbLimbs])
+		if !okX || !okY {
+			return ""
+		}
+		p := bls12381.G1Affine{X: x, Y: y}
+		if !p.IsOnCurve() || !p.IsInSubGroup() {
+			return ""
+		}
+		points = append(points, p)
+	}
+	return witnessHashOfPoints(points)
+}
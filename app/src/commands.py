@@ -28,7 +28,9 @@ from src.groth_convert import convert_all
 from pathlib import Path
 
 
-def create_snark_tx(bob_public_value: str) -> tuple[int, int, int]:
+def create_snark_tx(
+    bob_public_value: str, force: bool = False
+) -> tuple[int, int, int]:
     """
     Create the artifacts for a SNARK transaction (Groth16 proof generation).
 
@@ -52,6 +54,9 @@ def create_snark_tx(bob_public_value: str) -> tuple[int, int, int]:
     Args:
         bob_public_value: Serialized public value for Bob (a G1 element encoding
             as expected by `scale(...)`).
+        force: Overwrite proof artifacts already present in `out/`. Leave this
+            off unless the previous proof is known to be unused, since it may
+            already have been submitted on-chain.
 
     Returns:
         A 3-tuple `(a0, r0, hk)` where:
@@ -87,6 +92,7 @@ def create_snark_tx(bob_public_value: str) -> tuple[int, int, int]:
         snark_path=snark_path,
         out_dir=out_path,
         setup_dir=setup_path,
+        force=force,
     )
     convert_all(gnark_proof_path, gnark_public_path, datum_path)
     return a0, r0, hk
@@ -134,13 +134,14 @@ def generate_snark_proof(
     out_dir: str | Path = "out",
     setup_dir: str | Path | None = None,
     no_verify: bool = False,
+    force: bool = False,
 ) -> None:
     """
     Generate a Groth16 proof for the vw0w1 circuit.
 
     Args:
         a: Secret scalar a (must be > 0)
-        r: Secret scalar r (can be 0)
+        r: Secret scalar r (must be in [1, q), q the BLS12-381 scalar field order)
         v: Public G1 point V (compressed hex, 96 chars)
         w0: Public G1 point W0 (compressed hex, 96 chars)
         w1: Public G1 point W1 (compressed hex, 96 chars)
@@ -149,6 +150,7 @@ def generate_snark_proof(
         setup_dir: Directory containing setup files (ccs.bin, pk.bin, vk.bin).
                    If None, compiles the circuit fresh (slower).
         no_verify: Skip verification after proving (only valid with setup_dir)
+        force: Overwrite proof artifacts already present in out_dir
 
     Output files:
       - vk.json, proof.json, public.json (JSON for Aiken)
@@ -175,6 +177,8 @@ def generate_snark_proof(
         cmd.extend(["-setup", str(setup_dir)])
     if no_verify:
         cmd.append("-no-verify")
+    if force:
+        cmd.append("-force")
     out = subprocess.run(cmd, capture_output=True, text=True, check=True)
     print(out.stdout.strip())
 
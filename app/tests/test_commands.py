@@ -180,7 +180,7 @@ def test_create_snark_tx_happy_path(monkeypatch):
 
     # Mock generate_snark_proof since it's not in _setup_common_mocks
     def fake_generate_snark_proof(
-        a0, r0, bob_public_value, w0, w1, snark_path, out_dir, setup_dir
+        a0, r0, bob_public_value, w0, w1, snark_path, out_dir, setup_dir, force=False
     ):
         calls.append(
             (
@@ -193,6 +193,7 @@ def test_create_snark_tx_happy_path(monkeypatch):
                 str(snark_path),
                 str(out_dir),
                 str(setup_dir),
+                force,
             )
         )
 
@@ -220,7 +221,7 @@ def test_create_snark_tx_happy_path(monkeypatch):
     # Check generate_snark_proof was called correctly
     proof_calls = _calls_of(calls, "generate_snark_proof")
     assert len(proof_calls) == 1
-    _, a0, r0, bob_pub, w0, w1, snark_p, out_p, setup_p = proof_calls[0]
+    _, a0, r0, bob_pub, w0, w1, snark_p, out_p, setup_p, force = proof_calls[0]
     assert a0 == 11
     assert r0 == 22
     assert bob_pub == bob_u
@@ -229,6 +230,23 @@ def test_create_snark_tx_happy_path(monkeypatch):
     assert str(snark_p).endswith("/snark/snark")
     assert str(out_p).endswith("/out")
     assert str(setup_p).endswith("/circuit")
+    assert force is False
+
+
+def test_create_snark_tx_passes_force(monkeypatch):
+    calls, set_to_int = _setup_common_mocks(monkeypatch)
+
+    def fake_generate_snark_proof(
+        a0, r0, bob_public_value, w0, w1, snark_path, out_dir, setup_dir, force=False
+    ):
+        calls.append(("generate_snark_proof", force))
+
+    monkeypatch.setattr(commands_mod, "generate_snark_proof", fake_generate_snark_proof)
+    set_to_int("GT(11)", 9)
+
+    commands_mod.create_snark_tx("BOB_U", force=True)
+
+    assert _calls_of(calls, "generate_snark_proof") == [("generate_snark_proof", True)]
 
 
 def test_create_encryption_tx_happy_path(monkeypatch):
@@ -180,7 +180,7 @@ def test_snark_prove():
     print(f"w0={w0}")
     print(f"w1={w1}")
 
-    generate_snark_proof(a0, r0, v, w0, w1, snark_path)
+    generate_snark_proof(a0, r0, v, w0, w1, snark_path, force=True)
 
 
 @pytest.mark.skip(
@@ -201,7 +201,9 @@ def test_snark_prove_and_verify_from_global_circuit():
     print(f"w1={w1}")
 
     circuit_path = f"{os.getcwd()}/circuit/"
-    generate_snark_proof(a0, r0, v, w0, w1, snark_path, setup_dir=circuit_path)
+    generate_snark_proof(
+        a0, r0, v, w0, w1, snark_path, setup_dir=circuit_path, force=True
+    )
     result = verify_snark_proof_via_go(out_path)
     assert result, "Go Proof verification failed"
 
@@ -21,7 +21,11 @@ declare class Go {
 
 // Declare the global functions exposed by the WASM module
 // These are set by wasm_main.go via js.Global().Set(...)
-declare function gnarkLoadSetup(ccsBytes: Uint8Array, pkBytes: Uint8Array): { success?: boolean; error?: string }
+declare function gnarkLoadSetup(
+  ccsBytes: Uint8Array,
+  pkBytes: Uint8Array | Uint8Array[],
+  expectedPkSize?: number
+): { success?: boolean; error?: string }
 declare function gnarkProve(
   secretA: string,
   secretR: string,
@@ -38,6 +42,8 @@ export interface InitMessage {
   wasmUrl: string
   pkData: ArrayBuffer
   ccsData: ArrayBuffer
+  /** Announced size of pk.bin in bytes; a truncated pkData is rejected before deserializing */
+  pkExpectedSize?: number
   /** If true, skip loading proving keys (for stub mode - hash functions still work) */
   skipProvingKeySetup?: boolean
 }
@@ -235,7 +241,7 @@ async function initialize(msg: InitMessage) {
     // This is the long-running operation
     // In a Web Worker, it won't freeze the UI
     const loadStart = Date.now()
-    const loadResult = gnarkLoadSetup(ccsBytes, pkBytes)
+    const loadResult = gnarkLoadSetup(ccsBytes, pkBytes, msg.pkExpectedSize)
     const loadElapsed = ((Date.now() - loadStart) / 1000).toFixed(1)
 
     if (loadResult.error) {